@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/importer"
+	"github.com/api-moose/company-earnings/internal/models"
+)
+
+// runImport implements the import subcommand, which loads the SEC and Nasdaq
+// Trader listing files into the company collection, SEC companyfacts
+// documents into the statements and earnings collections, EPS estimate CSV
+// files into the earnings collection and dividend and split CSV files into
+// their collections:
+//
+//	app import -sec company_tickers_exchange.json -nasdaq nasdaqlisted.txt -other otherlisted.txt
+//	app import -facts companyfacts/
+//	app import -estimates estimates.csv
+//	app import -dividends dividends.csv -splits splits.csv
+func runImport(ctx context.Context, args []string, stdout io.Writer) error {
+	var files importer.Files
+	var factsPath, estimatesPath, dividendsPath, splitsPath string
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	fs.StringVar(&files.SECTickers, "sec", "", "path to the SEC company_tickers_exchange.json file")
+	fs.StringVar(&files.NasdaqListed, "nasdaq", "", "path to the Nasdaq Trader nasdaqlisted.txt file")
+	fs.StringVar(&files.OtherListed, "other", "", "path to the Nasdaq Trader otherlisted.txt file")
+	fs.StringVar(&factsPath, "facts", "", "path to an SEC companyfacts JSON file or a directory of them")
+	fs.StringVar(&estimatesPath, "estimates", "", "path to an EPS estimate CSV file")
+	fs.StringVar(&dividendsPath, "dividends", "", "path to a dividend CSV file")
+	fs.StringVar(&splitsPath, "splits", "", "path to a stock split CSV file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if files == (importer.Files{}) && factsPath == "" && estimatesPath == "" && dividendsPath == "" && splitsPath == "" {
+		fs.Usage()
+		return errors.New("at least one of -sec, -nasdaq, -other, -facts, -estimates, -dividends or -splits is required")
+	}
+
+	listings, err := importer.Load(files)
+	if err != nil {
+		return err
+	}
+	var financials []models.Financials
+	if factsPath != "" {
+		if financials, err = importer.LoadCompanyFacts(factsPath); err != nil {
+			return err
+		}
+	}
+	var estimates []models.Earnings
+	if estimatesPath != "" {
+		if estimates, err = importer.LoadEstimates(estimatesPath); err != nil {
+			return err
+		}
+	}
+	var dividends []models.Dividend
+	if dividendsPath != "" {
+		if dividends, err = importer.LoadDividends(dividendsPath); err != nil {
+			return err
+		}
+	}
+	var splits []models.Split
+	if splitsPath != "" {
+		if splits, err = importer.LoadSplits(splitsPath); err != nil {
+			return err
+		}
+	}
+
+	cfg, err := mongo.ConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	client, err := mongo.Connect(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(ctx)
+	if err := client.EnsureIndexes(ctx); err != nil {
+		return err
+	}
+
+	if len(listings) > 0 {
+		summary, err := importer.New(client.Companies()).Import(ctx, listings)
+		fmt.Fprintf(stdout, "Imported %d listings: %s\n", len(listings), summary)
+		if err != nil {
+			return err
+		}
+	}
+	if factsPath != "" {
+		summary, err := importer.NewStatementImporter(client.Statements()).Import(ctx, financials)
+		fmt.Fprintf(stdout, "Imported %d financial periods: %s\n", len(financials), summary)
+		if err != nil {
+			return err
+		}
+		earnings, err := importer.FactsEarnings(ctx, client.Companies(), financials)
+		if err != nil {
+			return err
+		}
+		summary, err = importer.NewEarningsImporter(client.Earnings(), importer.SourceCompanyFacts).Import(ctx, earnings)
+		fmt.Fprintf(stdout, "Imported %d earnings publications: %s\n", len(earnings), summary)
+		if err != nil {
+			return err
+		}
+	}
+	if estimatesPath != "" {
+		summary, err := importer.NewEarningsImporter(client.Earnings(), importer.SourceEstimates).Import(ctx, estimates)
+		fmt.Fprintf(stdout, "Imported %d estimates: %s\n", len(estimates), summary)
+		if err != nil {
+			return err
+		}
+	}
+	actions := importer.NewCorporateActionImporter(client.CorporateActions())
+	if dividendsPath != "" {
+		summary, err := actions.ImportDividends(ctx, dividends)
+		fmt.Fprintf(stdout, "Imported %d dividends: %s\n", len(dividends), summary)
+		if err != nil {
+			return err
+		}
+	}
+	if splitsPath != "" {
+		summary, err := actions.ImportSplits(ctx, splits)
+		fmt.Fprintf(stdout, "Imported %d splits: %s\n", len(splits), summary)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
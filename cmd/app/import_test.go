@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunImport(t *testing.T) {
+	t.Setenv("MONGODB_URI", "")
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"No files", nil, "at least one of -sec, -nasdaq, -other, -facts, -estimates, -dividends or -splits is required"},
+		{"Unknown flag", []string{"-tickers", "x.json"}, "flag provided but not defined: -tickers"},
+		{"Missing file", []string{"-sec", "internal/importer/testdata/missing.json"}, "open internal/importer/testdata/missing.json: no such file or directory"},
+		{"Requires MongoDB", []string{"-sec", "internal/importer/testdata/company_tickers_exchange.json"}, "mongodb uri is required"},
+		{"Invalid companyfacts", []string{"-facts", "internal/importer/testdata/company_tickers_exchange.json"}, "internal/importer/testdata/company_tickers_exchange.json: invalid companyfacts file: missing cik"},
+		{"Companyfacts require MongoDB", []string{"-facts", "internal/importer/testdata"}, "mongodb uri is required"},
+		{"Invalid estimates", []string{"-estimates", "internal/importer/testdata/splits.csv"}, `internal/importer/testdata/splits.csv: invalid csv file: missing column "fiscal_year"`},
+		{"Estimates require MongoDB", []string{"-estimates", "internal/importer/testdata/estimates.csv"}, "mongodb uri is required"},
+		{"Invalid dividends", []string{"-dividends", "internal/importer/testdata/splits.csv"}, `internal/importer/testdata/splits.csv: invalid csv file: missing column "ex_date"`},
+		{"Splits require MongoDB", []string{"-splits", "internal/importer/testdata/splits.csv"}, "mongodb uri is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := runImport(context.Background(), tt.args, &out)
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
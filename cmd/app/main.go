@@ -16,13 +16,19 @@ import (
 
 	firebase "firebase.google.com/go/v4"
 	firebaseAuth "firebase.google.com/go/v4/auth"
+	"github.com/api-moose/company-earnings/internal/api/v1/apikeys"
 	"github.com/api-moose/company-earnings/internal/api/v1/auth"
 	"github.com/api-moose/company-earnings/internal/api/v1/company"
+	"github.com/api-moose/company-earnings/internal/api/v1/corporateactions"
+	"github.com/api-moose/company-earnings/internal/api/v1/earnings"
+	"github.com/api-moose/company-earnings/internal/api/v1/financials"
+	"github.com/api-moose/company-earnings/internal/api/v1/screener"
 	"github.com/api-moose/company-earnings/internal/db/mongo"
 	"github.com/api-moose/company-earnings/internal/middleware/access_control"
 	authMiddleware "github.com/api-moose/company-earnings/internal/middleware/auth"
 	"github.com/api-moose/company-earnings/internal/middleware/tenancy"
 	"github.com/api-moose/company-earnings/internal/utils/logging"
+	"github.com/api-moose/company-earnings/internal/utils/pagination"
 	"google.golang.org/api/option"
 )
 
@@ -41,43 +47,120 @@ func (f *FirebaseAuthWrapper) GetUser(ctx context.Context, uid string) (*firebas
 	return f.client.GetUser(ctx, uid)
 }
 
-func main() {
-	// Set up logging
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
-	log.Println("Starting Financial Data Platform API")
-
+// newFirebaseAuthenticator returns the Firebase authenticator, or nil when
+// Firebase is not configured
+func newFirebaseAuthenticator(cache auth.CacheConfig) auth.Authenticator {
 	// Load Firebase credentials
 	credentialsJSON := os.Getenv("FIREBASE_CREDENTIALS_FILE")
 	if credentialsJSON == "" {
 		log.Println("Warning: FIREBASE_CREDENTIALS_FILE environment variable is not set")
+		return nil
 	}
 
 	// Initialize Firebase app
-	var app *firebase.App
-	var err error
-	if credentialsJSON != "" {
-		opt := option.WithCredentialsJSON([]byte(credentialsJSON))
-		app, err = firebase.NewApp(context.Background(), nil, opt)
+	opt := option.WithCredentialsJSON([]byte(credentialsJSON))
+	app, err := firebase.NewApp(context.Background(), nil, opt)
+	if err != nil {
+		log.Printf("Error initializing Firebase app: %v", err)
+		// Continue without Firebase for now
+		return nil
+	}
+
+	// Get Firebase Auth client
+	authClient, err := app.Auth(context.Background())
+	if err != nil {
+		log.Printf("Error getting Firebase Auth client: %v", err)
+		// Continue without Firebase Auth for now
+		return nil
+	}
+	return auth.NewFirebaseAuthenticator(&FirebaseAuthWrapper{client: authClient}, cache)
+}
+
+func main() {
+	// Set up logging
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := runImport(context.Background(), os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("Import failed: %v", err)
+		}
+		return
+	}
+
+	log.Println("Starting Financial Data Platform API")
+
+	// Set up the identity providers bearer tokens are verified with
+	authCfg, err := auth.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Error loading authentication configuration: %v", err)
+	}
+
+	var authenticators []auth.Authenticator
+	for _, provider := range authCfg.Providers {
+		switch provider {
+		case auth.ProviderFirebase:
+			if firebaseAuthenticator := newFirebaseAuthenticator(authCfg.Cache); firebaseAuthenticator != nil {
+				authenticators = append(authenticators, firebaseAuthenticator)
+			}
+		case auth.ProviderOIDC:
+			oidcAuthenticator, err := auth.NewOIDCAuthenticator(context.Background(), authCfg.OIDC, nil)
+			if err != nil {
+				log.Fatalf("Error initializing OIDC authentication: %v", err)
+			}
+			defer oidcAuthenticator.Close()
+			authenticators = append(authenticators, oidcAuthenticator)
+		case auth.ProviderJWT:
+			jwtAuthenticator, err := auth.NewJWTAuthenticator(authCfg.JWT)
+			if err != nil {
+				log.Fatalf("Error initializing JWT authentication: %v", err)
+			}
+			authenticators = append(authenticators, jwtAuthenticator)
+		}
+	}
+
+	var authenticator auth.Authenticator
+	if len(authenticators) > 0 {
+		authenticator = auth.NewCache(auth.Chain(authenticators...), authCfg.Cache)
+	}
+
+	// Connect to MongoDB
+	mongoCfg, err := mongo.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Error loading MongoDB configuration: %v", err)
+	}
+
+	var repos repositories
+	if mongoCfg.URI == "" {
+		log.Println("Warning: MONGODB_URI environment variable is not set")
+	} else {
+		mongoClient, err := mongo.Connect(context.Background(), mongoCfg)
 		if err != nil {
-			log.Printf("Error initializing Firebase app: %v", err)
-			// Continue without Firebase for now
+			log.Fatalf("Error connecting to MongoDB: %v", err)
 		}
+		defer mongoClient.Disconnect(context.Background())
+		if err := mongoClient.EnsureIndexes(context.Background()); err != nil {
+			log.Fatalf("Error creating MongoDB indexes: %v", err)
+		}
+		repos.companies = mongoClient.Companies()
+		repos.earnings = mongoClient.Earnings()
+		repos.statements = mongoClient.Statements()
+		repos.revisions = mongoClient.Revisions()
+		repos.actions = mongoClient.CorporateActions()
+		repos.apiKeys = mongoClient.APIKeys()
 	}
 
-	var wrappedAuthClient *FirebaseAuthWrapper
-	if app != nil {
-		// Get Firebase Auth client
-		authClient, err := app.Auth(context.Background())
+	// Set up pagination cursors
+	cursors := pagination.NewCursorCodec(os.Getenv("CURSOR_SECRET"))
+	if os.Getenv("CURSOR_SECRET") == "" {
+		log.Println("Warning: CURSOR_SECRET environment variable is not set, cursors will not survive restarts")
+		cursors, err = pagination.NewRandomCursorCodec()
 		if err != nil {
-			log.Printf("Error getting Firebase Auth client: %v", err)
-			// Continue without Firebase Auth for now
-		} else {
-			wrappedAuthClient = &FirebaseAuthWrapper{client: authClient}
+			log.Fatalf("Error creating cursor codec: %v", err)
 		}
 	}
 
 	// Set up router
-	r := setupRouter(wrappedAuthClient)
+	r := setupRouter(authenticator, repos, cursors)
 
 	// Get port from environment variable
 	port := os.Getenv("PORT")
@@ -116,7 +199,17 @@ func main() {
 	log.Println("Server exiting")
 }
 
-func setupRouter(authClient auth.FirebaseAuthClient) *chi.Mux {
+// repositories holds the data stores behind the API routes
+type repositories struct {
+	companies  mongo.Repository
+	earnings   mongo.EarningsRepository
+	statements mongo.StatementsRepository
+	revisions  mongo.RevisionRepository
+	actions    mongo.CorporateActionsRepository
+	apiKeys    mongo.APIKeyRepository
+}
+
+func setupRouter(authenticator auth.Authenticator, repos repositories, cursors *pagination.CursorCodec) *chi.Mux {
 	r := chi.NewRouter()
 
 	r.Use(middleware.Recoverer)
@@ -125,23 +218,93 @@ func setupRouter(authClient auth.FirebaseAuthClient) *chi.Mux {
 	r.Use(middleware.Logger)
 	r.Use(logging.LoggingMiddleware)
 
-	if authClient != nil {
-		authHandler := auth.NewHandler(authClient)
-		r.Use(tenancy.NewTenantMiddleware(authClient).Middleware)
-		r.Use(authMiddleware.NewAuthMiddleware(authClient, authHandler).Middleware)
-		r.Use(access_control.RBACMiddleware)
-	} else {
-		log.Println("Warning: Running without authentication middleware")
+	// API keys are checked first so server-to-server clients need no bearer
+	// token, and work whether or not an identity provider is configured
+	if repos.apiKeys != nil {
+		r.Use(authMiddleware.NewAPIKeyMiddleware(auth.NewAPIKeyAuthenticator(repos.apiKeys)).Middleware)
+	}
+	// Each bearer token is verified once, by AuthMiddleware; the tenant is
+	// checked against the principal it stores. Without an identity provider
+	// every bearer token is rejected, so requests are never let through
+	// unauthenticated.
+	if authenticator == nil {
+		log.Println("Warning: No identity provider is configured, only API keys are accepted")
+		if repos.apiKeys == nil {
+			log.Println("Warning: API keys are not available either, every request will be rejected")
+		}
 	}
+	r.Use(authMiddleware.NewAuthMiddleware(authenticator).Middleware)
+	r.Use(tenancy.NewTenantMiddleware().Middleware)
+	r.Use(access_control.RBACMiddleware)
 
-	r.Get("/", mainHandler)
-	r.Get("/health", healthCheckHandler)
-	r.Get("/version", versionHandler)
+	// API keys need the read scope for lookups, including those sent as
+	// POST, and the write scope for changes
+	read := access_control.RequireScope(mongo.APIKeyScopeRead)
+	write := access_control.RequireScope(mongo.APIKeyScopeWrite)
 
-	// Add company search route
-	companyRepo := mongo.NewRepository()
-	companyHandler := company.NewHandler(companyRepo)
-	r.Get("/api/v1/companies", companyHandler.SearchHandler)
+	r.With(read).Get("/", mainHandler)
+	r.With(read).Get("/health", healthCheckHandler)
+	r.With(read).Get("/version", versionHandler)
+
+	// Add company routes
+	if repos.companies != nil {
+		companyHandler := company.NewHandler(repos.companies, cursors)
+		earningsHandler := earnings.NewHandler(repos.companies, repos.earnings, repos.revisions, repos.actions)
+		actionsHandler := corporateactions.NewHandler(repos.companies, repos.actions)
+		financialsHandler := financials.NewHandler(repos.companies, repos.statements, repos.revisions, repos.actions)
+		screenerHandler := screener.NewHandler(repos.companies, repos.statements, repos.actions, cursors)
+
+		r.Group(func(r chi.Router) {
+			r.Use(read)
+			r.Get("/api/v1/companies", companyHandler.SearchHandler)
+			r.Post("/api/v1/companies/batch", companyHandler.BatchHandler)
+			r.Get("/api/v1/companies/cik/{cik}", companyHandler.GetByCIKHandler)
+			r.Get("/api/v1/companies/{symbol}", companyHandler.GetBySymbolHandler)
+
+			r.Get("/api/v1/companies/{symbol}/earnings", earningsHandler.HistoryHandler)
+			r.Get("/api/v1/companies/{symbol}/earnings/{period}/revisions", earningsHandler.RevisionsHandler)
+			r.Get("/api/v1/earnings/calendar", earningsHandler.CalendarHandler)
+
+			r.Get("/api/v1/companies/{symbol}/dividends", actionsHandler.DividendsHandler)
+			r.Get("/api/v1/companies/{symbol}/splits", actionsHandler.SplitsHandler)
+
+			r.Get("/api/v1/companies/{symbol}/financials/{statement}", financialsHandler.StatementHandler)
+			r.Get("/api/v1/companies/{symbol}/financials/{period}/revisions", financialsHandler.RevisionsHandler)
+			r.Get("/api/v1/companies/{symbol}/metrics", financialsHandler.MetricsHandler)
+			r.Get("/api/v1/companies/{symbol}/ratios", financialsHandler.RatiosHandler)
+
+			r.Post("/api/v1/screener", screenerHandler.ScreenHandler)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(access_control.RequireRole("admin"))
+			r.Use(write)
+			r.Post("/api/v1/companies", companyHandler.CreateHandler)
+			r.Patch("/api/v1/companies/{symbol}", companyHandler.UpdateHandler)
+			r.Delete("/api/v1/companies/{symbol}", companyHandler.DeleteHandler)
+		})
+	} else {
+		log.Println("Warning: Running without company routes")
+	}
+
+	// Add API key management routes
+	if repos.apiKeys != nil {
+		apiKeysHandler := apikeys.NewHandler(repos.apiKeys)
+		r.Group(func(r chi.Router) {
+			r.Use(access_control.RequireRole("user"))
+			r.With(read).Get("/api/v1/api-keys", apiKeysHandler.ListHandler)
+			r.With(write).Post("/api/v1/api-keys", apiKeysHandler.CreateHandler)
+			r.With(write).Post("/api/v1/api-keys/{id}/rotate", apiKeysHandler.RotateHandler)
+			r.With(write).Delete("/api/v1/api-keys/{id}", apiKeysHandler.RevokeHandler)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(access_control.RequireRole("admin"))
+			r.With(read).Get("/api/v1/admin/api-keys", apiKeysHandler.AdminListHandler)
+			r.With(write).Post("/api/v1/admin/api-keys", apiKeysHandler.AdminCreateHandler)
+			r.With(write).Post("/api/v1/admin/api-keys/{id}/rotate", apiKeysHandler.AdminRotateHandler)
+			r.With(write).Delete("/api/v1/admin/api-keys/{id}", apiKeysHandler.AdminRevokeHandler)
+		})
+	}
 
 	r.NotFound(notFoundHandler)
 
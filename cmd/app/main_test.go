@@ -19,10 +19,14 @@ import (
 
 	firebaseAuth "firebase.google.com/go/v4/auth"
 	authHandler "github.com/api-moose/company-earnings/internal/api/v1/auth"
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
 	"github.com/api-moose/company-earnings/internal/middleware/access_control"
 	auth "github.com/api-moose/company-earnings/internal/middleware/auth"
 	"github.com/api-moose/company-earnings/internal/middleware/tenancy"
+	"github.com/api-moose/company-earnings/internal/models"
 	"github.com/api-moose/company-earnings/internal/utils/logging"
+	"github.com/api-moose/company-earnings/internal/utils/pagination"
 )
 
 type MockFirebaseAuthClient struct {
@@ -84,12 +88,12 @@ func setupTestRouter() *chi.Mux {
 		},
 	}, nil)
 
-	authHandlerInstance := authHandler.NewHandler(mockAuth)
+	authenticator := authHandler.NewFirebaseAuthenticator(mockAuth, authHandler.CacheConfig{})
 
 	r := chi.NewRouter()
 	r.Use(logging.LoggingMiddleware)
-	r.Use(tenancy.NewTenantMiddleware(mockAuth).Middleware)
-	r.Use(auth.NewAuthMiddleware(mockAuth, authHandlerInstance).Middleware)
+	r.Use(auth.NewAuthMiddleware(authenticator).Middleware)
+	r.Use(tenancy.NewTenantMiddleware().Middleware)
 	r.Use(access_control.RBACMiddleware)
 
 	r.Get("/", mainHandler)
@@ -200,6 +204,115 @@ func TestTenancyMiddleware(t *testing.T) {
 	}
 }
 
+// MockAPIKeyRepository authenticates the keys it holds by their secret
+type MockAPIKeyRepository struct {
+	mongo.APIKeyRepository
+	keys map[string]*mongo.APIKey
+}
+
+func (m *MockAPIKeyRepository) GetByHash(ctx context.Context, hash string) (*mongo.APIKey, error) {
+	for secret, key := range m.keys {
+		if mongo.HashAPIKey(secret) == hash {
+			return key, nil
+		}
+	}
+	return nil, dberrors.ErrNotFound
+}
+
+func (m *MockAPIKeyRepository) TouchLastUsed(ctx context.Context, id string) error {
+	return nil
+}
+
+func TestSetupRouter_WithoutIdentityProvider(t *testing.T) {
+	cursors := pagination.NewCursorCodec("secret")
+	apiKeys := &MockAPIKeyRepository{keys: map[string]*mongo.APIKey{
+		"valid-key": {ID: "key1", UserID: "svc-importer", Role: "user", TenantID: "tenant1", Scopes: []string{mongo.APIKeyScopeRead}},
+	}}
+
+	testCases := []struct {
+		name           string
+		repos          repositories
+		header         string
+		value          string
+		expectedStatus int
+	}{
+		{"API key", repositories{apiKeys: apiKeys}, "X-API-Key", "valid-key", http.StatusOK},
+		{"Invalid API key", repositories{apiKeys: apiKeys}, "X-API-Key", "invalid-key", http.StatusUnauthorized},
+		{"Bearer token", repositories{apiKeys: apiKeys}, "Authorization", "Bearer valid-token", http.StatusUnauthorized},
+		{"No credentials", repositories{apiKeys: apiKeys}, "", "", http.StatusUnauthorized},
+		{"Without API keys", repositories{}, "Authorization", "Bearer valid-token", http.StatusUnauthorized},
+		{"Without API keys or credentials", repositories{}, "", "", http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := setupRouter(nil, tc.repos, cursors)
+			req := httptest.NewRequest("GET", "/health", nil)
+			if tc.header != "" {
+				req.Header.Set(tc.header, tc.value)
+			}
+			req.Header.Set("X-Tenant-ID", "tenant1")
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+		})
+	}
+}
+
+// MockCompanies serves the company lookups of a fixed company list
+type MockCompanies struct {
+	mongo.Repository
+	companies []models.Company
+}
+
+func (m *MockCompanies) Lookup(ctx context.Context, symbols, ciks []string) ([]models.Company, error) {
+	return m.companies, nil
+}
+
+func (m *MockCompanies) List(ctx context.Context, filter mongo.ListFilter) ([]models.Company, error) {
+	return m.companies, nil
+}
+
+func TestSetupRouter_APIKeyScopes(t *testing.T) {
+	apple := models.Company{Symbol: "AAPL", CIK: "0000320193", SecurityName: "Apple Inc.", Exchange: "NASDAQ", Active: true}
+	repos := repositories{
+		companies: &MockCompanies{companies: []models.Company{apple}},
+		apiKeys: &MockAPIKeyRepository{keys: map[string]*mongo.APIKey{
+			"read-key":  {ID: "key1", UserID: "svc-reporting", Role: "admin", TenantID: "tenant1", Scopes: []string{mongo.APIKeyScopeRead}},
+			"write-key": {ID: "key2", UserID: "svc-importer", Role: "admin", TenantID: "tenant1", Scopes: []string{mongo.APIKeyScopeWrite}},
+		}},
+	}
+	router := setupRouter(nil, repos, pagination.NewCursorCodec("secret"))
+
+	testCases := []struct {
+		name           string
+		key            string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{"Batch lookup", "read-key", "POST", "/api/v1/companies/batch", `{"symbols":["AAPL"]}`, http.StatusOK},
+		{"Screen", "read-key", "POST", "/api/v1/screener", `{"filter":"exchange = \"NASDAQ\""}`, http.StatusOK},
+		{"Change without the write scope", "read-key", "DELETE", "/api/v1/companies/AAPL", "", http.StatusForbidden},
+		{"Lookup without the read scope", "write-key", "POST", "/api/v1/companies/batch", `{"symbols":["AAPL"]}`, http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("X-API-Key", tc.key)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code, rr.Body.String())
+		})
+	}
+}
+
 func LoadEnv(filepath string) error {
 	file, err := os.Open(filepath)
 	if err != nil {
@@ -0,0 +1,350 @@
+package apikeys
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	authHandler "github.com/api-moose/company-earnings/internal/api/v1/auth"
+	"github.com/api-moose/company-earnings/internal/api/v1/params"
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/middleware/auth"
+	"github.com/api-moose/company-earnings/internal/utils/response"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	maxBodyBytes  = 4 << 10
+	maxNameLength = 100
+	// secretPrefix marks secrets as API keys of this service, so they are
+	// easy to recognise in logs and by secret scanners
+	secretPrefix = "cek_"
+	secretBytes  = 32
+	// shownPrefixLength is how much of the secret is kept in the clear to
+	// tell keys apart in listings
+	shownPrefixLength = 12
+)
+
+// roles are the roles a key can act with
+var roles = []string{"admin", "user"}
+
+// Handler serves the API key endpoints. Users manage their own keys under
+// /api-keys; admins manage every key of their tenant under /admin/api-keys.
+// Secrets are only returned when a key is created or rotated, which needs a
+// bearer token: an API key cannot create or rotate keys, so a leaked one
+// cannot outlive itself.
+type Handler struct {
+	keys mongo.APIKeyRepository
+	now  func() time.Time
+}
+
+func NewHandler(keys mongo.APIKeyRepository) *Handler {
+	return &Handler{keys: keys, now: time.Now}
+}
+
+type createRequest struct {
+	Name      string     `json:"name"`
+	UserID    string     `json:"userId"`
+	Role      string     `json:"role"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+type secretResponse struct {
+	Key mongo.APIKey `json:"key"`
+	// Secret is shown once; only its hash is stored
+	Secret string `json:"secret"`
+}
+
+type listResponse struct {
+	Count int            `json:"count"`
+	Keys  []mongo.APIKey `json:"keys"`
+}
+
+// ListHandler serves GET /api-keys, listing the caller's keys newest first
+func (h *Handler) ListHandler(w http.ResponseWriter, r *http.Request) {
+	caller, ok := callerFrom(w, r)
+	if !ok {
+		return
+	}
+	h.list(w, r, mongo.APIKeyOwner{TenantID: caller.TenantID, UserID: caller.ID})
+}
+
+// AdminListHandler serves GET /admin/api-keys, listing the keys of the
+// caller's tenant newest first, optionally only those of user_id
+func (h *Handler) AdminListHandler(w http.ResponseWriter, r *http.Request) {
+	caller, ok := callerFrom(w, r)
+	if !ok {
+		return
+	}
+	p := params.NewParser(r)
+	userID := p.String("user_id")
+	if err := p.Err(); err != nil {
+		response.ErrorResponse(w, err)
+		return
+	}
+	h.list(w, r, mongo.APIKeyOwner{TenantID: caller.TenantID, UserID: userID})
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request, owner mongo.APIKeyOwner) {
+	keys, err := h.keys.List(r.Context(), owner)
+	if err != nil {
+		response.ErrorResponse(w, err)
+		return
+	}
+	response.JSONResponse(w, http.StatusOK, listResponse{Count: len(keys), Keys: keys})
+}
+
+// CreateHandler serves POST /api-keys, issuing the caller a key. The key can
+// act with the caller's role or as a plain user, with at most the caller's
+// scopes. Callers authenticated by API key are refused.
+func (h *Handler) CreateHandler(w http.ResponseWriter, r *http.Request) {
+	if signedIn(w, r) {
+		h.create(w, r, false)
+	}
+}
+
+// AdminCreateHandler serves POST /admin/api-keys, issuing a key to any user
+// of the caller's tenant. Callers authenticated by API key are refused.
+func (h *Handler) AdminCreateHandler(w http.ResponseWriter, r *http.Request) {
+	if signedIn(w, r) {
+		h.create(w, r, true)
+	}
+}
+
+func (h *Handler) create(w http.ResponseWriter, r *http.Request, admin bool) {
+	caller, ok := callerFrom(w, r)
+	if !ok {
+		return
+	}
+
+	var req createRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		response.ErrorResponse(w, &response.ErrorMessage{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("invalid request body: %v", err),
+		})
+		return
+	}
+
+	key, err := h.newKey(caller, req, admin)
+	if err != nil {
+		response.ErrorResponse(w, err)
+		return
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		response.ErrorResponse(w, err)
+		return
+	}
+	key.Prefix, key.Hash = secret[:shownPrefixLength], mongo.HashAPIKey(secret)
+
+	if err := h.keys.Create(r.Context(), key); err != nil {
+		response.ErrorResponse(w, err)
+		return
+	}
+	response.JSONResponse(w, http.StatusCreated, secretResponse{Key: key, Secret: secret})
+}
+
+// newKey validates a create request from caller and returns the key it
+// describes, without its secret
+func (h *Handler) newKey(caller *mongo.User, req createRequest, admin bool) (mongo.APIKey, error) {
+	now := h.now().UTC()
+	key := mongo.APIKey{
+		ID:        primitive.NewObjectID().Hex(),
+		Name:      strings.TrimSpace(req.Name),
+		UserID:    strings.TrimSpace(req.UserID),
+		Role:      strings.ToLower(strings.TrimSpace(req.Role)),
+		TenantID:  caller.TenantID,
+		CreatedAt: now,
+		ExpiresAt: req.ExpiresAt,
+	}
+
+	if len(key.Name) > maxNameLength {
+		return key, badRequest("name", "name must be at most %d characters", maxNameLength)
+	}
+
+	switch {
+	case key.UserID == "" || key.UserID == caller.ID:
+		key.UserID, key.Username, key.Email = caller.ID, caller.Username, caller.Email
+	case !admin:
+		return key, badRequest("userId", "userId can only be set by admins")
+	}
+
+	if key.Role == "" {
+		key.Role = "user"
+		if key.UserID == caller.ID {
+			key.Role = caller.Role
+		}
+	}
+	if !contains(roles, key.Role) {
+		return key, badRequest("role", "role must be one of %s", strings.Join(roles, ", "))
+	}
+	if !admin && key.Role != caller.Role && key.Role != "user" {
+		return key, badRequest("role", "role cannot exceed your own")
+	}
+
+	if len(req.Scopes) == 0 {
+		req.Scopes = mongo.APIKeyScopes
+	}
+	for _, scope := range req.Scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !mongo.IsValidAPIKeyScope(scope) {
+			return key, badRequest("scopes", "scopes must be among %s", strings.Join(mongo.APIKeyScopes, ", "))
+		}
+		if !caller.HasScope(scope) {
+			return key, badRequest("scopes", "scope %s cannot exceed your own", scope)
+		}
+		if !contains(key.Scopes, scope) {
+			key.Scopes = append(key.Scopes, scope)
+		}
+	}
+
+	if key.ExpiresAt != nil {
+		if !key.ExpiresAt.After(now) {
+			return key, badRequest("expiresAt", "expiresAt must be in the future")
+		}
+		expiresAt := key.ExpiresAt.UTC()
+		key.ExpiresAt = &expiresAt
+	}
+	return key, nil
+}
+
+// RotateHandler serves POST /api-keys/{id}/rotate, replacing the secret of
+// one of the caller's keys. The old secret stops working immediately.
+// Callers authenticated by API key are refused.
+func (h *Handler) RotateHandler(w http.ResponseWriter, r *http.Request) {
+	if !signedIn(w, r) {
+		return
+	}
+	if caller, ok := callerFrom(w, r); ok {
+		h.rotate(w, r, mongo.APIKeyOwner{TenantID: caller.TenantID, UserID: caller.ID})
+	}
+}
+
+// AdminRotateHandler serves POST /admin/api-keys/{id}/rotate for any key of
+// the caller's tenant. Callers authenticated by API key are refused.
+func (h *Handler) AdminRotateHandler(w http.ResponseWriter, r *http.Request) {
+	if !signedIn(w, r) {
+		return
+	}
+	if caller, ok := callerFrom(w, r); ok {
+		h.rotate(w, r, mongo.APIKeyOwner{TenantID: caller.TenantID})
+	}
+}
+
+func (h *Handler) rotate(w http.ResponseWriter, r *http.Request, owner mongo.APIKeyOwner) {
+	secret, err := newSecret()
+	if err != nil {
+		response.ErrorResponse(w, err)
+		return
+	}
+
+	key, err := h.keys.Rotate(r.Context(), owner, chi.URLParam(r, "id"), secret[:shownPrefixLength], mongo.HashAPIKey(secret))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	response.JSONResponse(w, http.StatusOK, secretResponse{Key: *key, Secret: secret})
+}
+
+// RevokeHandler serves DELETE /api-keys/{id}, revoking one of the caller's
+// keys. Revoked keys stay listed for auditing.
+func (h *Handler) RevokeHandler(w http.ResponseWriter, r *http.Request) {
+	if caller, ok := callerFrom(w, r); ok {
+		h.revoke(w, r, mongo.APIKeyOwner{TenantID: caller.TenantID, UserID: caller.ID})
+	}
+}
+
+// AdminRevokeHandler serves DELETE /admin/api-keys/{id} for any key of the
+// caller's tenant
+func (h *Handler) AdminRevokeHandler(w http.ResponseWriter, r *http.Request) {
+	if caller, ok := callerFrom(w, r); ok {
+		h.revoke(w, r, mongo.APIKeyOwner{TenantID: caller.TenantID})
+	}
+}
+
+func (h *Handler) revoke(w http.ResponseWriter, r *http.Request, owner mongo.APIKeyOwner) {
+	if _, err := h.keys.Revoke(r.Context(), owner, chi.URLParam(r, "id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// callerFrom returns the authenticated user, writing the error response when
+// there is none
+func callerFrom(w http.ResponseWriter, r *http.Request) (*mongo.User, bool) {
+	user, ok := auth.GetUserFromContext(r)
+	if !ok || user.TenantID == "" {
+		response.ErrorResponse(w, &response.ErrorMessage{
+			Status:  http.StatusUnauthorized,
+			Message: "authentication required",
+		})
+		return nil, false
+	}
+	return user, true
+}
+
+// signedIn reports whether the caller did not authenticate with an API key,
+// writing the error response when it did. A leaked key must not be able to
+// issue keys that outlive it or lock their owners out by rotating them.
+func signedIn(w http.ResponseWriter, r *http.Request) bool {
+	if principal, ok := auth.GetPrincipalFromContext(r); ok && principal.Provider == authHandler.ProviderAPIKey {
+		response.ErrorResponse(w, &response.ErrorMessage{
+			Status:  http.StatusForbidden,
+			Message: "api keys cannot create or rotate keys, sign in instead",
+		})
+		return false
+	}
+	return true
+}
+
+// writeError writes err, reporting unknown and revoked keys alike as not
+// found so other tenants' key IDs cannot be probed
+func writeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, dberrors.ErrNotFound) {
+		response.ErrorResponse(w, &response.ErrorMessage{
+			Status:  http.StatusNotFound,
+			Message: "api key not found",
+		})
+		return
+	}
+	response.ErrorResponse(w, err)
+}
+
+// newSecret returns a random key secret
+func newSecret() (string, error) {
+	b := make([]byte, secretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating api key: %v", err)
+	}
+	return secretPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func badRequest(parameter, format string, args ...interface{}) error {
+	return &response.ErrorMessage{
+		Status:    http.StatusBadRequest,
+		Message:   fmt.Sprintf(format, args...),
+		Parameter: parameter,
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
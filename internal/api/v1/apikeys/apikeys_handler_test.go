@@ -0,0 +1,283 @@
+package apikeys
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	authHandler "github.com/api-moose/company-earnings/internal/api/v1/auth"
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/middleware/auth"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockAPIKeyRepository struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyRepository) GetByHash(ctx context.Context, hash string) (*mongo.APIKey, error) {
+	args := m.Called(ctx, hash)
+	if args.Get(0) != nil {
+		return args.Get(0).(*mongo.APIKey), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) List(ctx context.Context, owner mongo.APIKeyOwner) ([]mongo.APIKey, error) {
+	args := m.Called(ctx, owner)
+	if args.Get(0) != nil {
+		return args.Get(0).([]mongo.APIKey), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) Create(ctx context.Context, key mongo.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) Rotate(ctx context.Context, owner mongo.APIKeyOwner, id, prefix, hash string) (*mongo.APIKey, error) {
+	args := m.Called(ctx, owner, id, prefix, hash)
+	if args.Get(0) != nil {
+		return args.Get(0).(*mongo.APIKey), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) Revoke(ctx context.Context, owner mongo.APIKeyOwner, id string) (*mongo.APIKey, error) {
+	args := m.Called(ctx, owner, id)
+	if args.Get(0) != nil {
+		return args.Get(0).(*mongo.APIKey), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) TouchLastUsed(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+var (
+	now   = time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+	alice = &mongo.User{ID: "alice", Email: "alice@example.com", Role: "user", TenantID: "tenant1"}
+	admin = &mongo.User{ID: "root", Role: "admin", TenantID: "tenant1"}
+	// reporter is authenticated by a read-only key
+	reporter = &mongo.User{ID: "svc-reporting", Role: "admin", TenantID: "tenant1", Scopes: []string{mongo.APIKeyScopeRead}}
+)
+
+func newRouter(keys mongo.APIKeyRepository) *chi.Mux {
+	h := NewHandler(keys)
+	h.now = func() time.Time { return now }
+
+	router := chi.NewRouter()
+	router.Get("/api-keys", h.ListHandler)
+	router.Post("/api-keys", h.CreateHandler)
+	router.Post("/api-keys/{id}/rotate", h.RotateHandler)
+	router.Delete("/api-keys/{id}", h.RevokeHandler)
+	router.Get("/admin/api-keys", h.AdminListHandler)
+	router.Post("/admin/api-keys", h.AdminCreateHandler)
+	router.Post("/admin/api-keys/{id}/rotate", h.AdminRotateHandler)
+	router.Delete("/admin/api-keys/{id}", h.AdminRevokeHandler)
+	return router
+}
+
+func serve(router http.Handler, user *mongo.User, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if user != nil {
+		req = req.WithContext(context.WithValue(req.Context(), auth.UserContextKey, user))
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+// serveWithKey serves a request from user authenticated by an API key
+func serveWithKey(router http.Handler, user *mongo.User, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	ctx := context.WithValue(req.Context(), auth.UserContextKey, user)
+	principal := &authHandler.Principal{User: user, Provider: authHandler.ProviderAPIKey}
+	req = req.WithContext(context.WithValue(ctx, auth.PrincipalContextKey, principal))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestCreateHandler(t *testing.T) {
+	expiry := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	keys := new(MockAPIKeyRepository)
+	keys.On("Create", mock.Anything, mock.Anything).Return(nil)
+	router := newRouter(keys)
+
+	rr := serve(router, alice, "POST", "/api-keys", `{"name":"nightly import","scopes":["read"],"expiresAt":"2025-01-01T00:00:00Z"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	var created secretResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+	assert.True(t, strings.HasPrefix(created.Secret, "cek_"))
+	assert.Equal(t, created.Secret[:12], created.Key.Prefix)
+	assert.NotContains(t, rr.Body.String(), mongo.HashAPIKey(created.Secret))
+
+	stored := keys.Calls[0].Arguments.Get(1).(mongo.APIKey)
+	assert.Equal(t, mongo.HashAPIKey(created.Secret), stored.Hash)
+	assert.Equal(t, created.Key.ID, stored.ID)
+	assert.Equal(t, "nightly import", stored.Name)
+	assert.Equal(t, "alice", stored.UserID)
+	assert.Equal(t, "alice@example.com", stored.Email)
+	assert.Equal(t, "user", stored.Role)
+	assert.Equal(t, "tenant1", stored.TenantID)
+	assert.Equal(t, []string{"read"}, stored.Scopes)
+	assert.Equal(t, now, stored.CreatedAt)
+	assert.Equal(t, &expiry, stored.ExpiresAt)
+
+	rr = serve(router, admin, "POST", "/admin/api-keys", `{"userId":"svc-importer"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	stored = keys.Calls[1].Arguments.Get(1).(mongo.APIKey)
+	assert.Equal(t, "svc-importer", stored.UserID)
+	assert.Equal(t, "user", stored.Role)
+	assert.Equal(t, []string{"read", "write"}, stored.Scopes)
+	assert.Nil(t, stored.ExpiresAt)
+
+	rr = serve(router, admin, "POST", "/api-keys", `{}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	stored = keys.Calls[2].Arguments.Get(1).(mongo.APIKey)
+	assert.Equal(t, "admin", stored.Role)
+	keys.AssertNumberOfCalls(t, "Create", 3)
+}
+
+func TestCreateHandler_Invalid(t *testing.T) {
+	keys := new(MockAPIKeyRepository)
+	keys.On("Create", mock.Anything, mock.Anything).Return(dberrors.NewDBError("timeout"))
+	router := newRouter(keys)
+
+	tests := []struct {
+		name           string
+		user           *mongo.User
+		path           string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"Unauthenticated", nil, "/api-keys", `{}`, http.StatusUnauthorized, `{"status":401,"error":"authentication required"}`},
+		{"Unknown field", alice, "/api-keys", `{"secret":"mine"}`, http.StatusBadRequest, `{"status":400,"error":"invalid request body: json: unknown field \"secret\""}`},
+		{"Long name", alice, "/api-keys", `{"name":"` + strings.Repeat("x", 101) + `"}`, http.StatusBadRequest, `{"status":400,"error":"name must be at most 100 characters","parameter":"name"}`},
+		{"Key for someone else", alice, "/api-keys", `{"userId":"bob"}`, http.StatusBadRequest, `{"status":400,"error":"userId can only be set by admins","parameter":"userId"}`},
+		{"Escalated role", alice, "/api-keys", `{"role":"admin"}`, http.StatusBadRequest, `{"status":400,"error":"role cannot exceed your own","parameter":"role"}`},
+		{"Unknown role", admin, "/admin/api-keys", `{"role":"owner"}`, http.StatusBadRequest, `{"status":400,"error":"role must be one of admin, user","parameter":"role"}`},
+		{"Unknown scope", alice, "/api-keys", `{"scopes":["delete"]}`, http.StatusBadRequest, `{"status":400,"error":"scopes must be among read, write","parameter":"scopes"}`},
+		{"Escalated scope", reporter, "/api-keys", `{"scopes":["write"]}`, http.StatusBadRequest, `{"status":400,"error":"scope write cannot exceed your own","parameter":"scopes"}`},
+		{"Past expiry", alice, "/api-keys", `{"expiresAt":"2024-09-01T12:00:00Z"}`, http.StatusBadRequest, `{"status":400,"error":"expiresAt must be in the future","parameter":"expiresAt"}`},
+		{"Repository failure", alice, "/api-keys", `{}`, http.StatusInternalServerError, `{"status":500,"error":"DB Error: timeout"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serve(router, tt.user, "POST", tt.path, tt.body)
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+	keys.AssertNumberOfCalls(t, "Create", 1)
+}
+
+func TestListHandlers(t *testing.T) {
+	key := mongo.APIKey{ID: "key1", Prefix: "cek_abcdefgh", Hash: "hash", UserID: "alice", Role: "user", TenantID: "tenant1", Scopes: []string{"read"}, CreatedAt: now}
+	keys := new(MockAPIKeyRepository)
+	keys.On("List", mock.Anything, mongo.APIKeyOwner{TenantID: "tenant1", UserID: "alice"}).Return([]mongo.APIKey{key}, nil)
+	keys.On("List", mock.Anything, mongo.APIKeyOwner{TenantID: "tenant1"}).Return([]mongo.APIKey{}, nil)
+	router := newRouter(keys)
+
+	rr := serve(router, alice, "GET", "/api-keys", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"count":1,"keys":[{
+		"id":"key1","prefix":"cek_abcdefgh","userId":"alice","role":"user","tenantId":"tenant1","scopes":["read"],
+		"createdAt":"2024-09-01T12:00:00Z","expiresAt":null,"lastUsedAt":null,"rotatedAt":null,"revokedAt":null
+	}]}`, rr.Body.String())
+
+	rr = serve(router, admin, "GET", "/admin/api-keys", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"count":0,"keys":[]}`, rr.Body.String())
+
+	rr = serve(router, admin, "GET", "/admin/api-keys?user_id=alice", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	keys.AssertExpectations(t)
+}
+
+func TestRotateAndRevokeHandlers(t *testing.T) {
+	own := mongo.APIKeyOwner{TenantID: "tenant1", UserID: "alice"}
+	tenant := mongo.APIKeyOwner{TenantID: "tenant1"}
+	keys := new(MockAPIKeyRepository)
+	keys.On("Rotate", mock.Anything, own, "key1", mock.Anything, mock.Anything).Return(&mongo.APIKey{ID: "key1", UserID: "alice", TenantID: "tenant1"}, nil)
+	keys.On("Rotate", mock.Anything, own, "key2", mock.Anything, mock.Anything).Return(nil, dberrors.ErrNotFound)
+	keys.On("Revoke", mock.Anything, own, "key1").Return(&mongo.APIKey{ID: "key1", RevokedAt: &now}, nil)
+	keys.On("Revoke", mock.Anything, own, "key2").Return(nil, dberrors.ErrNotFound)
+	keys.On("Revoke", mock.Anything, tenant, "key2").Return(&mongo.APIKey{ID: "key2", RevokedAt: &now}, nil)
+	router := newRouter(keys)
+
+	rr := serve(router, alice, "POST", "/api-keys/key1/rotate", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var rotated secretResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &rotated))
+	prefix, hash := keys.Calls[0].Arguments.String(3), keys.Calls[0].Arguments.String(4)
+	assert.Equal(t, rotated.Secret[:12], prefix)
+	assert.Equal(t, mongo.HashAPIKey(rotated.Secret), hash)
+
+	rr = serve(router, alice, "POST", "/api-keys/key2/rotate", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.JSONEq(t, `{"status":404,"error":"api key not found"}`, rr.Body.String())
+
+	rr = serve(router, alice, "DELETE", "/api-keys/key1", "")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = serve(router, alice, "DELETE", "/api-keys/key2", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = serve(router, admin, "DELETE", "/admin/api-keys/key2", "")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	keys.AssertExpectations(t)
+}
+
+func TestHandlers_AuthenticatedByAPIKey(t *testing.T) {
+	keys := new(MockAPIKeyRepository)
+	router := newRouter(keys)
+
+	tests := []struct {
+		name string
+		user *mongo.User
+		path string
+		body string
+	}{
+		{"Create own key", alice, "/api-keys", `{"name":"spare"}`},
+		{"Rotate own key", alice, "/api-keys/key1/rotate", ""},
+		{"Admin create for self", admin, "/admin/api-keys", `{}`},
+		{"Admin create for self by userId", admin, "/admin/api-keys", `{"userId":"root","expiresAt":"2025-01-01T00:00:00Z"}`},
+		{"Admin create for another user", admin, "/admin/api-keys", `{"userId":"svc-importer"}`},
+		{"Admin rotate", admin, "/admin/api-keys/key1/rotate", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serveWithKey(router, tt.user, "POST", tt.path, tt.body)
+			assert.Equal(t, http.StatusForbidden, rr.Code)
+			assert.JSONEq(t, `{"status":403,"error":"api keys cannot create or rotate keys, sign in instead"}`, rr.Body.String())
+		})
+	}
+	keys.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	keys.AssertNotCalled(t, "Rotate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRevokeHandlers_AuthenticatedByAPIKey(t *testing.T) {
+	keys := new(MockAPIKeyRepository)
+	keys.On("Revoke", mock.Anything, mongo.APIKeyOwner{TenantID: "tenant1"}, "key2").Return(&mongo.APIKey{ID: "key2", RevokedAt: &now}, nil)
+	router := newRouter(keys)
+
+	rr := serveWithKey(router, admin, "DELETE", "/admin/api-keys/key2", "")
+	assert.Equal(t, http.StatusNoContent, rr.Code, "revoking cannot extend a key's reach")
+	keys.AssertExpectations(t)
+}
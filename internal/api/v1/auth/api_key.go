@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+)
+
+// ErrInvalidAPIKey is returned for keys that do not match a stored key, or
+// whose key has been revoked or has expired
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// lastUsedResolution is how stale a key's last-used time may get before a
+// request refreshes it, so busy keys do not write on every request
+const lastUsedResolution = time.Minute
+
+// APIKeyStore is the part of mongo.APIKeyRepository the authenticator uses
+type APIKeyStore interface {
+	GetByHash(ctx context.Context, hash string) (*mongo.APIKey, error)
+	TouchLastUsed(ctx context.Context, id string) error
+}
+
+// APIKeyAuthenticator authenticates API keys, resolving them to the user they
+// were issued to with the key's role and scopes
+type APIKeyAuthenticator struct {
+	keys APIKeyStore
+	now  func() time.Time
+}
+
+func NewAPIKeyAuthenticator(keys APIKeyStore) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{keys: keys, now: time.Now}
+}
+
+func (a *APIKeyAuthenticator) Authenticate(ctx context.Context, key string) (*Principal, error) {
+	if key == "" {
+		return nil, ErrInvalidAPIKey
+	}
+
+	apiKey, err := a.keys.GetByHash(ctx, mongo.HashAPIKey(key))
+	if errors.Is(err, dberrors.ErrNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := a.now()
+	if apiKey.Expired(now) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	// Failing to record the use must not lock the client out
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= lastUsedResolution {
+		if err := a.keys.TouchLastUsed(ctx, apiKey.ID); err != nil {
+			log.Printf("APIKeyAuthenticator: Error recording use of key %s: %v", apiKey.ID, err)
+		}
+	}
+
+	principal := &Principal{User: apiKey.User(), Provider: ProviderAPIKey}
+	if apiKey.ExpiresAt != nil {
+		principal.ExpiresAt = *apiKey.ExpiresAt
+	}
+	return principal, nil
+}
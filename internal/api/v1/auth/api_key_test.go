@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAPIKeyStore struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyStore) GetByHash(ctx context.Context, hash string) (*mongo.APIKey, error) {
+	args := m.Called(ctx, hash)
+	if args.Get(0) != nil {
+		return args.Get(0).(*mongo.APIKey), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAPIKeyStore) TouchLastUsed(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func TestAPIKeyAuthenticator_Authenticate(t *testing.T) {
+	now := time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+	justUsed := now.Add(-10 * time.Second)
+	expired := now.Add(-time.Hour)
+	expiry := now.Add(time.Hour)
+
+	store := new(MockAPIKeyStore)
+	store.On("GetByHash", mock.Anything, mongo.HashAPIKey("valid_key")).Return(&mongo.APIKey{
+		ID: "key1", UserID: "svc-importer", Email: "importer@example.com", Role: "admin", TenantID: "tenant1",
+		Scopes: []string{mongo.APIKeyScopeRead},
+	}, nil)
+	store.On("GetByHash", mock.Anything, mongo.HashAPIKey("recent_key")).Return(&mongo.APIKey{
+		ID: "key2", UserID: "svc-importer", Role: "user", TenantID: "tenant1", LastUsedAt: &justUsed,
+	}, nil)
+	store.On("GetByHash", mock.Anything, mongo.HashAPIKey("expired_key")).Return(&mongo.APIKey{
+		ID: "key3", UserID: "svc-importer", Role: "user", TenantID: "tenant1", ExpiresAt: &expired,
+	}, nil)
+	store.On("GetByHash", mock.Anything, mongo.HashAPIKey("expiring_key")).Return(&mongo.APIKey{
+		ID: "key4", UserID: "svc-importer", Role: "user", TenantID: "tenant1", ExpiresAt: &expiry, LastUsedAt: &justUsed,
+	}, nil)
+	store.On("GetByHash", mock.Anything, mongo.HashAPIKey("unknown_key")).Return(nil, dberrors.ErrNotFound)
+	store.On("GetByHash", mock.Anything, mongo.HashAPIKey("any_key")).Return(nil, dberrors.NewDBError("timeout"))
+	store.On("TouchLastUsed", mock.Anything, "key1").Return(dberrors.NewDBError("timeout"))
+	authenticator := NewAPIKeyAuthenticator(store)
+	authenticator.now = func() time.Time { return now }
+
+	principal, err := authenticator.Authenticate(context.Background(), "valid_key")
+	assert.NoError(t, err)
+	assert.Equal(t, &Principal{
+		User:     &mongo.User{ID: "svc-importer", Email: "importer@example.com", Role: "admin", TenantID: "tenant1", Scopes: []string{"read"}},
+		Provider: ProviderAPIKey,
+	}, principal)
+
+	principal, err = authenticator.Authenticate(context.Background(), "recent_key")
+	assert.NoError(t, err)
+	assert.Equal(t, "user", principal.User.Role)
+
+	principal, err = authenticator.Authenticate(context.Background(), "expiring_key")
+	assert.NoError(t, err)
+	assert.Equal(t, expiry, principal.ExpiresAt)
+
+	for _, key := range []string{"expired_key", "unknown_key", ""} {
+		_, err = authenticator.Authenticate(context.Background(), key)
+		assert.ErrorIs(t, err, ErrInvalidAPIKey, key)
+	}
+
+	_, err = authenticator.Authenticate(context.Background(), "any_key")
+	assert.True(t, dberrors.IsDBError(err))
+
+	store.AssertExpectations(t)
+	store.AssertNumberOfCalls(t, "TouchLastUsed", 1)
+}
@@ -2,48 +2,59 @@ package auth
 
 import (
 	"context"
+	"errors"
+	"time"
 
-	firebaseAuth "firebase.google.com/go/v4/auth"
 	"github.com/api-moose/company-earnings/internal/db/mongo"
 )
 
-type FirebaseAuthClient interface {
-	VerifyIDToken(ctx context.Context, idToken string) (*firebaseAuth.Token, error)
-	GetUser(ctx context.Context, uid string) (*firebaseAuth.UserRecord, error)
-}
+// Names of the identity providers an Authenticator can stand for
+const (
+	ProviderFirebase = "firebase"
+	ProviderOIDC     = "oidc"
+	ProviderJWT      = "jwt"
+	ProviderAPIKey   = "apikey"
+)
 
-type AuthenticatorHandler interface {
-	AuthenticateUser(ctx context.Context, token string) (*mongo.User, error)
+// Principal is a caller an Authenticator vouched for, independent of the
+// identity provider that issued its credential
+type Principal struct {
+	User *mongo.User
+	// Provider names the identity provider that verified the credential
+	Provider string
+	// ExpiresAt is when the credential stops being valid, zero if it never
+	// expires
+	ExpiresAt time.Time
 }
 
-type Handler struct {
-	client FirebaseAuthClient
+// Authenticator verifies a credential, such as a bearer token or an API key,
+// and returns the principal it belongs to
+type Authenticator interface {
+	Authenticate(ctx context.Context, credential string) (*Principal, error)
 }
 
-func NewHandler(client FirebaseAuthClient) *Handler {
-	return &Handler{client: client}
-}
+type chain []Authenticator
 
-func (h *Handler) AuthenticateUser(ctx context.Context, token string) (*mongo.User, error) {
-	decodedToken, err := h.client.VerifyIDToken(ctx, token)
-	if err != nil {
-		return nil, err
-	}
-
-	firebaseUser, err := h.client.GetUser(ctx, decodedToken.UID)
-	if err != nil {
-		return nil, err
+// Chain returns an Authenticator trying each of authenticators in turn and
+// returning the first principal one of them vouches for, so tenants can be
+// moved between identity providers one at a time
+func Chain(authenticators ...Authenticator) Authenticator {
+	if len(authenticators) == 1 {
+		return authenticators[0]
 	}
+	return chain(authenticators)
+}
 
-	user := &mongo.User{
-		ID:       firebaseUser.UID,
-		Username: firebaseUser.DisplayName,
-		Email:    firebaseUser.Email,
-		Role:     getRoleFromClaims(decodedToken.Claims),
-		TenantID: getTenantIDFromClaims(decodedToken.Claims),
+func (c chain) Authenticate(ctx context.Context, credential string) (*Principal, error) {
+	var errs []error
+	for _, a := range c {
+		principal, err := a.Authenticate(ctx, credential)
+		if err == nil {
+			return principal, nil
+		}
+		errs = append(errs, err)
 	}
-
-	return user, nil
+	return nil, errors.Join(errs...)
 }
 
 func getRoleFromClaims(claims map[string]interface{}) string {
@@ -5,88 +5,47 @@ import (
 	"errors"
 	"testing"
 
-	"firebase.google.com/go/v4/auth"
 	"github.com/api-moose/company-earnings/internal/db/mongo"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
-type MockFirebaseClient struct {
+type MockAuthenticator struct {
 	mock.Mock
 }
 
-func (m *MockFirebaseClient) VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error) {
-	args := m.Called(ctx, idToken)
+func (m *MockAuthenticator) Authenticate(ctx context.Context, credential string) (*Principal, error) {
+	args := m.Called(ctx, credential)
 	if args.Get(0) != nil {
-		return args.Get(0).(*auth.Token), args.Error(1)
+		return args.Get(0).(*Principal), args.Error(1)
 	}
 	return nil, args.Error(1)
 }
 
-func (m *MockFirebaseClient) GetUser(ctx context.Context, uid string) (*auth.UserRecord, error) {
-	args := m.Called(ctx, uid)
-	if args.Get(0) != nil {
-		return args.Get(0).(*auth.UserRecord), args.Error(1)
-	}
-	return nil, args.Error(1)
-}
+func TestChain(t *testing.T) {
+	firebaseUser := &Principal{User: &mongo.User{ID: "alice", TenantID: "tenant1"}, Provider: ProviderFirebase}
+	oidcUser := &Principal{User: &mongo.User{ID: "bob", TenantID: "tenant2"}, Provider: ProviderOIDC}
 
-func TestAuthHandler_AuthenticateUser(t *testing.T) {
-	mockClient := new(MockFirebaseClient)
-	handler := NewHandler(mockClient)
+	firebase := new(MockAuthenticator)
+	firebase.On("Authenticate", mock.Anything, "firebase_token").Return(firebaseUser, nil)
+	firebase.On("Authenticate", mock.Anything, mock.Anything).Return(nil, errors.New("firebase: invalid token"))
+	oidc := new(MockAuthenticator)
+	oidc.On("Authenticate", mock.Anything, "oidc_token").Return(oidcUser, nil)
+	oidc.On("Authenticate", mock.Anything, mock.Anything).Return(nil, errors.New("oidc: invalid token"))
 
-	validToken := &auth.Token{
-		UID: "valid_user",
-		Claims: map[string]interface{}{
-			"role":     "admin",
-			"tenantID": "tenant1",
-		},
-	}
-	userRecord := &auth.UserRecord{
-		UserInfo: &auth.UserInfo{
-			UID:         "valid_user",
-			Email:       "test@example.com",
-			DisplayName: "Test User",
-		},
-	}
+	authenticator := Chain(firebase, oidc)
 
-	mockClient.On("VerifyIDToken", mock.Anything, "valid_token").Return(validToken, nil)
-	mockClient.On("VerifyIDToken", mock.Anything, "invalid_token").Return(nil, errors.New("invalid token"))
-	mockClient.On("GetUser", mock.Anything, "valid_user").Return(userRecord, nil)
+	principal, err := authenticator.Authenticate(context.Background(), "firebase_token")
+	assert.NoError(t, err)
+	assert.Equal(t, firebaseUser, principal)
+	oidc.AssertNotCalled(t, "Authenticate", mock.Anything, "firebase_token")
 
-	tests := []struct {
-		name         string
-		token        string
-		expectedUser *mongo.User
-		expectedErr  error
-	}{
-		{
-			name:  "Valid token",
-			token: "valid_token",
-			expectedUser: &mongo.User{
-				ID:       "valid_user",
-				Username: "Test User",
-				Email:    "test@example.com",
-				Role:     "admin",
-				TenantID: "tenant1",
-			},
-			expectedErr: nil,
-		},
-		{
-			name:         "Invalid token",
-			token:        "invalid_token",
-			expectedUser: nil,
-			expectedErr:  errors.New("invalid token"),
-		},
-	}
+	principal, err = authenticator.Authenticate(context.Background(), "oidc_token")
+	assert.NoError(t, err)
+	assert.Equal(t, oidcUser, principal)
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			user, err := handler.AuthenticateUser(context.Background(), tt.token)
-			assert.Equal(t, tt.expectedErr, err)
-			assert.Equal(t, tt.expectedUser, user)
-		})
-	}
+	_, err = authenticator.Authenticate(context.Background(), "forged_token")
+	assert.EqualError(t, err, "firebase: invalid token\noidc: invalid token")
 
-	mockClient.AssertExpectations(t)
+	assert.Same(t, firebase, Chain(firebase))
 }
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"time"
+)
+
+const (
+	DefaultCacheSize = 10000
+	DefaultCacheTTL  = 5 * time.Minute
+)
+
+// CacheConfig bounds the caches of verified tokens and user records
+type CacheConfig struct {
+	// Size is the most entries a cache holds; zero disables caching
+	Size int
+	// TTL is the longest an entry is kept. Cached tokens are also dropped
+	// once they expire.
+	TTL time.Duration
+}
+
+func (c CacheConfig) enabled() bool {
+	return c.Size > 0 && c.TTL > 0
+}
+
+// cache is an Authenticator remembering the principals another Authenticator
+// vouched for, so a token is only verified again once its entry expires
+type cache struct {
+	authenticator Authenticator
+	ttl           time.Duration
+	entries       *lru
+	now           func() time.Time
+}
+
+// NewCache returns an Authenticator caching the principals authenticator
+// returns for up to cfg.TTL, and never past the credential's own expiry.
+// Failed authentications are not cached. authenticator is returned unchanged
+// when caching is disabled.
+func NewCache(authenticator Authenticator, cfg CacheConfig) Authenticator {
+	if !cfg.enabled() {
+		return authenticator
+	}
+	return &cache{authenticator: authenticator, ttl: cfg.TTL, entries: newLRU(cfg.Size), now: time.Now}
+}
+
+func (c *cache) Authenticate(ctx context.Context, credential string) (*Principal, error) {
+	// Credentials are keyed by their hash so the cache holds no usable tokens
+	sum := sha256.Sum256([]byte(credential))
+	key := string(sum[:])
+
+	now := c.now()
+	if cached, ok := c.entries.get(key, now); ok {
+		return copyPrincipal(cached.(*Principal)), nil
+	}
+
+	principal, err := c.authenticator.Authenticate(ctx, credential)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := now.Add(c.ttl)
+	if !principal.ExpiresAt.IsZero() && principal.ExpiresAt.Before(expiresAt) {
+		expiresAt = principal.ExpiresAt
+	}
+	if principal.User != nil && now.Before(expiresAt) {
+		c.entries.add(key, copyPrincipal(principal), expiresAt)
+	}
+	return principal, nil
+}
+
+// copyPrincipal copies principal and its user, so callers changing the user
+// of one request cannot affect the cached entry
+func copyPrincipal(principal *Principal) *Principal {
+	p := *principal
+	if p.User != nil {
+		user := *p.User
+		p.User = &user
+	}
+	return &p
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_Authenticate(t *testing.T) {
+	now := time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+	upstream := new(MockAuthenticator)
+	upstream.On("Authenticate", mock.Anything, "long_lived").Return(&Principal{
+		User: &mongo.User{ID: "alice", TenantID: "tenant1"}, Provider: ProviderOIDC, ExpiresAt: now.Add(time.Hour),
+	}, nil)
+	upstream.On("Authenticate", mock.Anything, "expiring").Return(&Principal{
+		User: &mongo.User{ID: "bob", TenantID: "tenant1"}, Provider: ProviderOIDC, ExpiresAt: now.Add(30 * time.Second),
+	}, nil)
+	upstream.On("Authenticate", mock.Anything, "invalid").Return(nil, errors.New("invalid token"))
+
+	c := NewCache(upstream, CacheConfig{Size: 10, TTL: time.Minute}).(*cache)
+	c.now = func() time.Time { return now }
+	authenticate := func(token string) *Principal {
+		principal, err := c.Authenticate(context.Background(), token)
+		require.NoError(t, err)
+		return principal
+	}
+
+	// Cached principals are copies the caller may change
+	first := authenticate("long_lived")
+	first.User.Role = "admin"
+	assert.Equal(t, "", authenticate("long_lived").User.Role)
+	upstream.AssertNumberOfCalls(t, "Authenticate", 1)
+
+	authenticate("expiring")
+	authenticate("expiring")
+	upstream.AssertNumberOfCalls(t, "Authenticate", 2)
+
+	// Failures are not cached
+	for i := 0; i < 2; i++ {
+		_, err := c.Authenticate(context.Background(), "invalid")
+		assert.EqualError(t, err, "invalid token")
+	}
+	upstream.AssertNumberOfCalls(t, "Authenticate", 4)
+
+	// Entries expire with the token, and at the latest after the TTL
+	now = now.Add(30 * time.Second)
+	authenticate("long_lived")
+	upstream.AssertNumberOfCalls(t, "Authenticate", 4)
+	authenticate("expiring")
+	upstream.AssertNumberOfCalls(t, "Authenticate", 5)
+
+	now = now.Add(30 * time.Second)
+	authenticate("long_lived")
+	upstream.AssertNumberOfCalls(t, "Authenticate", 6)
+}
+
+func TestCache_Bounded(t *testing.T) {
+	upstream := new(MockAuthenticator)
+	for _, token := range []string{"a", "b", "c"} {
+		upstream.On("Authenticate", mock.Anything, token).Return(&Principal{User: &mongo.User{ID: token}}, nil)
+	}
+
+	c := NewCache(upstream, CacheConfig{Size: 2, TTL: time.Minute}).(*cache)
+	for _, token := range []string{"a", "b", "a", "c", "a", "b"} {
+		_, err := c.Authenticate(context.Background(), token)
+		require.NoError(t, err)
+	}
+
+	// c evicted b, the least recently used, so only b was verified twice
+	assert.Equal(t, 2, c.entries.len())
+	upstream.AssertNumberOfCalls(t, "Authenticate", 4)
+}
+
+func TestNewCache_Disabled(t *testing.T) {
+	upstream := new(MockAuthenticator)
+	assert.Same(t, upstream, NewCache(upstream, CacheConfig{Size: 0, TTL: time.Minute}))
+	assert.Same(t, upstream, NewCache(upstream, CacheConfig{Size: 10}))
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultProviders are the identity providers used when AUTH_PROVIDERS is not
+// set
+var DefaultProviders = []string{ProviderFirebase}
+
+// Config selects the identity providers bearer tokens are verified with
+type Config struct {
+	// Providers are tried in order until one accepts the token
+	Providers []string
+	OIDC      OIDCConfig
+	JWT       JWTConfig
+	Cache     CacheConfig
+}
+
+// ConfigFromEnv reads the authentication settings from the environment.
+// AUTH_PROVIDERS is a comma separated list of firebase, oidc and jwt; the oidc
+// provider is configured by OIDC_ISSUER, OIDC_AUDIENCE and the optional
+// OIDC_JWKS_URL, and the jwt provider by JWT_ISSUER, JWT_AUDIENCE and
+// JWT_JWKS_PATH. AUTH_CACHE_SIZE and AUTH_CACHE_TTL bound the caches of
+// verified tokens and user records; a size of 0 disables them.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Providers: DefaultProviders,
+		OIDC: OIDCConfig{
+			Issuer:   os.Getenv("OIDC_ISSUER"),
+			Audience: os.Getenv("OIDC_AUDIENCE"),
+			JWKSURL:  os.Getenv("OIDC_JWKS_URL"),
+		},
+		JWT: JWTConfig{
+			Issuer:   os.Getenv("JWT_ISSUER"),
+			Audience: os.Getenv("JWT_AUDIENCE"),
+			JWKSPath: os.Getenv("JWT_JWKS_PATH"),
+		},
+		Cache: CacheConfig{Size: DefaultCacheSize, TTL: DefaultCacheTTL},
+	}
+
+	if providers := os.Getenv("AUTH_PROVIDERS"); providers != "" {
+		cfg.Providers = nil
+		for _, p := range strings.Split(providers, ",") {
+			p = strings.ToLower(strings.TrimSpace(p))
+			if p != ProviderFirebase && p != ProviderOIDC && p != ProviderJWT {
+				return Config{}, fmt.Errorf("invalid AUTH_PROVIDERS %q: unknown provider %q", providers, p)
+			}
+			cfg.Providers = append(cfg.Providers, p)
+		}
+	}
+
+	if size := os.Getenv("AUTH_CACHE_SIZE"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil || n < 0 {
+			return Config{}, fmt.Errorf("invalid AUTH_CACHE_SIZE %q: must be a non-negative integer", size)
+		}
+		cfg.Cache.Size = n
+	}
+	if ttl := os.Getenv("AUTH_CACHE_TTL"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid AUTH_CACHE_TTL %q: %v", ttl, err)
+		}
+		cfg.Cache.TTL = d
+	}
+
+	if cfg.Uses(ProviderOIDC) && (cfg.OIDC.Issuer == "" || cfg.OIDC.Audience == "") {
+		return Config{}, fmt.Errorf("the oidc provider requires OIDC_ISSUER and OIDC_AUDIENCE")
+	}
+	if cfg.Uses(ProviderJWT) && (cfg.JWT.Issuer == "" || cfg.JWT.Audience == "" || cfg.JWT.JWKSPath == "") {
+		return Config{}, fmt.Errorf("the jwt provider requires JWT_ISSUER, JWT_AUDIENCE and JWT_JWKS_PATH")
+	}
+	return cfg, nil
+}
+
+// Uses reports whether provider is one of the configured providers
+func (c Config) Uses(provider string) bool {
+	for _, p := range c.Providers {
+		if p == provider {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		expected    Config
+		expectedErr string
+	}{
+		{
+			name:     "Defaults to firebase",
+			env:      map[string]string{},
+			expected: Config{Providers: []string{ProviderFirebase}, Cache: CacheConfig{Size: DefaultCacheSize, TTL: DefaultCacheTTL}},
+		},
+		{
+			name: "Firebase and oidc",
+			env: map[string]string{
+				"AUTH_PROVIDERS": "Firebase, oidc",
+				"OIDC_ISSUER":    "https://login.example.com",
+				"OIDC_AUDIENCE":  "company-earnings",
+				"OIDC_JWKS_URL":  "https://login.example.com/keys",
+			},
+			expected: Config{
+				Providers: []string{ProviderFirebase, ProviderOIDC},
+				OIDC: OIDCConfig{
+					Issuer:   "https://login.example.com",
+					Audience: "company-earnings",
+					JWKSURL:  "https://login.example.com/keys",
+				},
+				Cache: CacheConfig{Size: DefaultCacheSize, TTL: DefaultCacheTTL},
+			},
+		},
+		{
+			name: "Jwt",
+			env: map[string]string{
+				"AUTH_PROVIDERS": "jwt",
+				"JWT_ISSUER":     "https://login.local",
+				"JWT_AUDIENCE":   "company-earnings",
+				"JWT_JWKS_PATH":  "/etc/company-earnings/jwks",
+			},
+			expected: Config{
+				Providers: []string{ProviderJWT},
+				JWT: JWTConfig{
+					Issuer:   "https://login.local",
+					Audience: "company-earnings",
+					JWKSPath: "/etc/company-earnings/jwks",
+				},
+				Cache: CacheConfig{Size: DefaultCacheSize, TTL: DefaultCacheTTL},
+			},
+		},
+		{
+			name:     "Cache settings",
+			env:      map[string]string{"AUTH_CACHE_SIZE": "0", "AUTH_CACHE_TTL": "30s"},
+			expected: Config{Providers: []string{ProviderFirebase}, Cache: CacheConfig{Size: 0, TTL: 30 * time.Second}},
+		},
+		{
+			name:        "Invalid cache size",
+			env:         map[string]string{"AUTH_CACHE_SIZE": "-1"},
+			expectedErr: `invalid AUTH_CACHE_SIZE "-1": must be a non-negative integer`,
+		},
+		{
+			name:        "Invalid cache TTL",
+			env:         map[string]string{"AUTH_CACHE_TTL": "5"},
+			expectedErr: `invalid AUTH_CACHE_TTL "5": time: missing unit in duration "5"`,
+		},
+		{
+			name:        "Unknown provider",
+			env:         map[string]string{"AUTH_PROVIDERS": "firebase,saml"},
+			expectedErr: `invalid AUTH_PROVIDERS "firebase,saml": unknown provider "saml"`,
+		},
+		{
+			name:        "Oidc without audience",
+			env:         map[string]string{"AUTH_PROVIDERS": "oidc", "OIDC_ISSUER": "https://login.example.com"},
+			expectedErr: "the oidc provider requires OIDC_ISSUER and OIDC_AUDIENCE",
+		},
+		{
+			name:        "Jwt without keys",
+			env:         map[string]string{"AUTH_PROVIDERS": "jwt", "JWT_ISSUER": "https://login.local", "JWT_AUDIENCE": "company-earnings"},
+			expectedErr: "the jwt provider requires JWT_ISSUER, JWT_AUDIENCE and JWT_JWKS_PATH",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"AUTH_PROVIDERS", "OIDC_ISSUER", "OIDC_AUDIENCE", "OIDC_JWKS_URL", "JWT_ISSUER", "JWT_AUDIENCE", "JWT_JWKS_PATH", "AUTH_CACHE_SIZE", "AUTH_CACHE_TTL"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			cfg, err := ConfigFromEnv()
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, cfg)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	firebaseAuth "firebase.google.com/go/v4/auth"
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+)
+
+// FirebaseAuthClient is the part of the Firebase Auth client the
+// FirebaseAuthenticator uses
+type FirebaseAuthClient interface {
+	VerifyIDToken(ctx context.Context, idToken string) (*firebaseAuth.Token, error)
+	GetUser(ctx context.Context, uid string) (*firebaseAuth.UserRecord, error)
+}
+
+// FirebaseAuthenticator authenticates Firebase ID tokens, taking the role and
+// tenant from the token's custom claims and the user details from Firebase
+type FirebaseAuthenticator struct {
+	client FirebaseAuthClient
+	// users caches user records by UID, nil when caching is disabled
+	users    *lru
+	usersTTL time.Duration
+	now      func() time.Time
+}
+
+// NewFirebaseAuthenticator returns a FirebaseAuthenticator caching the user
+// records it fetches as cfg allows, so users refreshing their ID tokens are
+// not looked up again
+func NewFirebaseAuthenticator(client FirebaseAuthClient, cfg CacheConfig) *FirebaseAuthenticator {
+	a := &FirebaseAuthenticator{client: client, now: time.Now}
+	if cfg.enabled() {
+		a.users = newLRU(cfg.Size)
+		a.usersTTL = cfg.TTL
+	}
+	return a
+}
+
+func (a *FirebaseAuthenticator) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	decodedToken, err := a.client.VerifyIDToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	firebaseUser, err := a.getUser(ctx, decodedToken.UID)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &mongo.User{
+		ID:       firebaseUser.UID,
+		Username: firebaseUser.DisplayName,
+		Email:    firebaseUser.Email,
+		Role:     getRoleFromClaims(decodedToken.Claims),
+		TenantID: getTenantIDFromClaims(decodedToken.Claims),
+	}
+
+	return &Principal{User: user, Provider: ProviderFirebase, ExpiresAt: time.Unix(decodedToken.Expires, 0)}, nil
+}
+
+func (a *FirebaseAuthenticator) getUser(ctx context.Context, uid string) (*firebaseAuth.UserRecord, error) {
+	if a.users == nil {
+		return a.client.GetUser(ctx, uid)
+	}
+
+	now := a.now()
+	if cached, ok := a.users.get(uid, now); ok {
+		return cached.(*firebaseAuth.UserRecord), nil
+	}
+	firebaseUser, err := a.client.GetUser(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	a.users.add(uid, firebaseUser, now.Add(a.usersTTL))
+	return firebaseUser, nil
+}
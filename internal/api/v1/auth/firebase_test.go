@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockFirebaseClient struct {
+	mock.Mock
+}
+
+func (m *MockFirebaseClient) VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error) {
+	args := m.Called(ctx, idToken)
+	if args.Get(0) != nil {
+		return args.Get(0).(*auth.Token), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockFirebaseClient) GetUser(ctx context.Context, uid string) (*auth.UserRecord, error) {
+	args := m.Called(ctx, uid)
+	if args.Get(0) != nil {
+		return args.Get(0).(*auth.UserRecord), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func TestFirebaseAuthenticator_Authenticate(t *testing.T) {
+	mockClient := new(MockFirebaseClient)
+	authenticator := NewFirebaseAuthenticator(mockClient, CacheConfig{})
+
+	validToken := &auth.Token{
+		UID:     "valid_user",
+		Expires: 1725192000,
+		Claims: map[string]interface{}{
+			"role":     "admin",
+			"tenantID": "tenant1",
+		},
+	}
+	userRecord := &auth.UserRecord{
+		UserInfo: &auth.UserInfo{
+			UID:         "valid_user",
+			Email:       "test@example.com",
+			DisplayName: "Test User",
+		},
+	}
+
+	mockClient.On("VerifyIDToken", mock.Anything, "valid_token").Return(validToken, nil)
+	mockClient.On("VerifyIDToken", mock.Anything, "invalid_token").Return(nil, errors.New("invalid token"))
+	mockClient.On("GetUser", mock.Anything, "valid_user").Return(userRecord, nil)
+
+	tests := []struct {
+		name        string
+		token       string
+		expected    *Principal
+		expectedErr error
+	}{
+		{
+			name:  "Valid token",
+			token: "valid_token",
+			expected: &Principal{
+				User: &mongo.User{
+					ID:       "valid_user",
+					Username: "Test User",
+					Email:    "test@example.com",
+					Role:     "admin",
+					TenantID: "tenant1",
+				},
+				Provider:  ProviderFirebase,
+				ExpiresAt: time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC),
+			},
+			expectedErr: nil,
+		},
+		{
+			name:        "Invalid token",
+			token:       "invalid_token",
+			expected:    nil,
+			expectedErr: errors.New("invalid token"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := authenticator.Authenticate(context.Background(), tt.token)
+			assert.Equal(t, tt.expectedErr, err)
+			if tt.expected != nil {
+				assert.Equal(t, tt.expected.User, principal.User)
+				assert.Equal(t, tt.expected.Provider, principal.Provider)
+				assert.True(t, tt.expected.ExpiresAt.Equal(principal.ExpiresAt))
+			} else {
+				assert.Nil(t, principal)
+			}
+		})
+	}
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestFirebaseAuthenticator_CachesUsers(t *testing.T) {
+	mockClient := new(MockFirebaseClient)
+	authenticator := NewFirebaseAuthenticator(mockClient, CacheConfig{Size: 10, TTL: time.Minute})
+	now := time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+	authenticator.now = func() time.Time { return now }
+
+	// A user refreshing their ID token is not looked up again
+	for _, token := range []string{"first_token", "refreshed_token"} {
+		mockClient.On("VerifyIDToken", mock.Anything, token).Return(&auth.Token{UID: "valid_user", Expires: now.Add(time.Hour).Unix()}, nil)
+	}
+	mockClient.On("GetUser", mock.Anything, "valid_user").Return(&auth.UserRecord{
+		UserInfo: &auth.UserInfo{UID: "valid_user", Email: "test@example.com"},
+	}, nil)
+
+	for _, token := range []string{"first_token", "refreshed_token"} {
+		principal, err := authenticator.Authenticate(context.Background(), token)
+		assert.NoError(t, err)
+		assert.Equal(t, "test@example.com", principal.User.Email)
+	}
+	mockClient.AssertNumberOfCalls(t, "GetUser", 1)
+
+	now = now.Add(time.Minute)
+	_, err := authenticator.Authenticate(context.Background(), "refreshed_token")
+	assert.NoError(t, err)
+	mockClient.AssertNumberOfCalls(t, "GetUser", 2)
+}
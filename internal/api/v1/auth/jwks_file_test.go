@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeJSON(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+func TestJWTAuthenticator_Authenticate(t *testing.T) {
+	idp := newTestIdP(t)
+	keys := idp.jwks["keys"].([]map[string]string)
+
+	// One key per file, alongside a file that is not a JWKS
+	dir := t.TempDir()
+	writeJSON(t, filepath.Join(dir, "rsa.json"), map[string]interface{}{"keys": keys[:1]})
+	writeJSON(t, filepath.Join(dir, "ec.json"), map[string]interface{}{"keys": keys[1:]})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("signing keys"), 0o600))
+
+	authenticator, err := NewJWTAuthenticator(JWTConfig{Issuer: "https://login.local", Audience: "company-earnings", JWKSPath: dir})
+	require.NoError(t, err)
+
+	now := time.Now()
+	claims := func(overrides jwt.MapClaims) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss":      "https://login.local",
+			"aud":      "company-earnings",
+			"sub":      "dev",
+			"email":    "dev@example.com",
+			"tenantID": "tenant1",
+			"nbf":      now.Add(-time.Minute).Unix(),
+			"exp":      now.Add(time.Hour).Unix(),
+		}
+		for k, v := range overrides {
+			c[k] = v
+		}
+		return c
+	}
+
+	tests := []struct {
+		name        string
+		token       string
+		expected    *mongo.User
+		expectedErr bool
+	}{
+		{
+			name:     "RS256",
+			token:    idp.sign(t, jwt.SigningMethodRS256, "rsa-1", claims(nil)),
+			expected: &mongo.User{ID: "dev", Email: "dev@example.com", Role: "user", TenantID: "tenant1"},
+		},
+		{
+			name:     "ES256 admin",
+			token:    idp.sign(t, jwt.SigningMethodES256, "ec-1", claims(jwt.MapClaims{"role": "admin"})),
+			expected: &mongo.User{ID: "dev", Email: "dev@example.com", Role: "admin", TenantID: "tenant1"},
+		},
+		{name: "Wrong issuer", token: idp.sign(t, jwt.SigningMethodRS256, "rsa-1", claims(jwt.MapClaims{"iss": idp.server.URL})), expectedErr: true},
+		{name: "Wrong audience", token: idp.sign(t, jwt.SigningMethodRS256, "rsa-1", claims(jwt.MapClaims{"aud": "other"})), expectedErr: true},
+		{name: "Expired", token: idp.sign(t, jwt.SigningMethodRS256, "rsa-1", claims(jwt.MapClaims{"exp": now.Add(-time.Minute).Unix()})), expectedErr: true},
+		{name: "Not yet valid", token: idp.sign(t, jwt.SigningMethodRS256, "rsa-1", claims(jwt.MapClaims{"nbf": now.Add(time.Hour).Unix()})), expectedErr: true},
+		{name: "Unknown key", token: idp.sign(t, jwt.SigningMethodRS256, "rsa-2", claims(nil)), expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := authenticator.Authenticate(context.Background(), tt.token)
+			if tt.expectedErr {
+				assert.Error(t, err)
+				assert.Nil(t, principal)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, principal.User)
+			assert.Equal(t, ProviderJWT, principal.Provider)
+		})
+	}
+}
+
+func TestNewJWTAuthenticator_LoadJWKS(t *testing.T) {
+	idp := newTestIdP(t)
+	keys := idp.jwks["keys"].([]map[string]string)
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "jwks.json")
+	writeJSON(t, file, idp.jwks)
+	_, err := NewJWTAuthenticator(JWTConfig{Issuer: "https://login.local", Audience: "company-earnings", JWKSPath: file})
+	assert.NoError(t, err)
+
+	duplicates := filepath.Join(dir, "duplicates")
+	require.NoError(t, os.Mkdir(duplicates, 0o700))
+	writeJSON(t, filepath.Join(duplicates, "a.json"), map[string]interface{}{"keys": keys[:1]})
+	writeJSON(t, filepath.Join(duplicates, "b.json"), map[string]interface{}{"keys": keys[:1]})
+	_, err = NewJWTAuthenticator(JWTConfig{JWKSPath: duplicates})
+	assert.EqualError(t, err, `error parsing jwks `+filepath.Join(duplicates, "b.json")+`: duplicate key ID "rsa-1"`)
+
+	empty := filepath.Join(dir, "empty")
+	require.NoError(t, os.Mkdir(empty, 0o700))
+	_, err = NewJWTAuthenticator(JWTConfig{JWKSPath: empty})
+	assert.EqualError(t, err, "no signing keys found in "+empty)
+
+	malformed := filepath.Join(dir, "malformed.json")
+	require.NoError(t, os.WriteFile(malformed, []byte("{"), 0o600))
+	_, err = NewJWTAuthenticator(JWTConfig{JWKSPath: malformed})
+	assert.Error(t, err)
+
+	_, err = NewJWTAuthenticator(JWTConfig{JWKSPath: filepath.Join(dir, "missing.json")})
+	assert.Error(t, err)
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// signingMethods are the asymmetric algorithms tokens may be signed with.
+// HMAC and "none" are never accepted, so a public key cannot be used as a
+// shared secret to forge tokens.
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// jwtVerifier verifies JWTs signed with the keys keyfunc returns, checking
+// the signature, expiry, not-before time, issuer and audience
+type jwtVerifier struct {
+	keyfunc  jwt.Keyfunc
+	issuer   string
+	audience string
+	provider string
+}
+
+func (v *jwtVerifier) verify(token string) (*Principal, error) {
+	claims := jwt.MapClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods(signingMethods))
+	if _, err := parser.ParseWithClaims(token, claims, v.keyfunc); err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+
+	// Valid only checks exp when present; tokens that never expire are
+	// refused outright
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errors.New("invalid token: missing exp claim")
+	}
+	if !claims.VerifyIssuer(v.issuer, true) {
+		return nil, errors.New("invalid token: unexpected issuer")
+	}
+	if !claims.VerifyAudience(v.audience, true) {
+		return nil, errors.New("invalid token: unexpected audience")
+	}
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, errors.New("invalid token: missing sub claim")
+	}
+
+	user := &mongo.User{
+		ID:       subject,
+		Username: stringClaim(claims, "name", "preferred_username"),
+		Email:    stringClaim(claims, "email"),
+		Role:     getRoleFromClaims(claims),
+		TenantID: getTenantIDFromClaims(claims),
+	}
+	return &Principal{User: user, Provider: v.provider, ExpiresAt: time.Unix(int64(exp), 0)}, nil
+}
+
+// stringClaim returns the first of the named claims holding a non-empty
+// string
+func stringClaim(claims jwt.MapClaims, names ...string) string {
+	for _, name := range names {
+		if s, ok := claims[name].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MicahParks/keyfunc"
+)
+
+const (
+	// jwksRefreshInterval is how often the signing keys are refetched so
+	// rotated keys are picked up
+	jwksRefreshInterval = time.Hour
+	// jwksRefreshRateLimit bounds the refetches tokens signed with unknown
+	// keys can trigger
+	jwksRefreshRateLimit = 5 * time.Minute
+	jwksRequestTimeout   = 10 * time.Second
+	maxDiscoveryBytes    = 1 << 20
+)
+
+// OIDCConfig configures an OpenID Connect identity provider
+type OIDCConfig struct {
+	// Issuer is the iss claim tokens must carry, and where the provider's
+	// configuration is discovered from
+	Issuer string
+	// Audience is the aud claim tokens must carry, usually the client ID the
+	// provider issued to this API
+	Audience string
+	// JWKSURL is where the signing keys are fetched from. When empty it is
+	// discovered from the issuer's openid-configuration document.
+	JWKSURL string
+}
+
+// OIDCAuthenticator authenticates JWTs issued by an OpenID Connect provider,
+// verifying them against the provider's published signing keys. The role and
+// tenant are taken from the role and tenantID claims like Firebase custom
+// claims.
+type OIDCAuthenticator struct {
+	jwks     *keyfunc.JWKS
+	verifier *jwtVerifier
+}
+
+// NewOIDCAuthenticator fetches the provider's signing keys and returns an
+// authenticator that keeps them refreshed in the background until Close
+func NewOIDCAuthenticator(ctx context.Context, cfg OIDCConfig, client *http.Client) (*OIDCAuthenticator, error) {
+	if client == nil {
+		client = &http.Client{Timeout: jwksRequestTimeout}
+	}
+
+	jwksURL := cfg.JWKSURL
+	if jwksURL == "" {
+		var err error
+		if jwksURL, err = discoverJWKSURL(ctx, client, cfg.Issuer); err != nil {
+			return nil, err
+		}
+	}
+
+	jwks, err := keyfunc.Get(jwksURL, keyfunc.Options{
+		Client:            client,
+		RefreshInterval:   jwksRefreshInterval,
+		RefreshRateLimit:  jwksRefreshRateLimit,
+		RefreshTimeout:    jwksRequestTimeout,
+		RefreshUnknownKID: true,
+		RefreshErrorHandler: func(err error) {
+			log.Printf("OIDCAuthenticator: Error refreshing signing keys from %s: %v", jwksURL, err)
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching oidc signing keys: %v", err)
+	}
+
+	return &OIDCAuthenticator{
+		jwks: jwks,
+		verifier: &jwtVerifier{
+			keyfunc:  jwks.Keyfunc,
+			issuer:   cfg.Issuer,
+			audience: cfg.Audience,
+			provider: ProviderOIDC,
+		},
+	}, nil
+}
+
+func (a *OIDCAuthenticator) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	return a.verifier.verify(token)
+}
+
+// Close stops refreshing the signing keys
+func (a *OIDCAuthenticator) Close() {
+	a.jwks.EndBackground()
+}
+
+// discoverJWKSURL reads the jwks_uri from the issuer's OpenID Connect
+// discovery document, checking the document is the issuer's own
+func discoverJWKSURL(ctx context.Context, client *http.Client, issuer string) (string, error) {
+	url := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("error discovering oidc configuration: %v", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error discovering oidc configuration: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error discovering oidc configuration: %s returned %s", url, resp.Status)
+	}
+
+	var doc struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDiscoveryBytes)).Decode(&doc); err != nil {
+		return "", fmt.Errorf("error decoding oidc configuration: %v", err)
+	}
+	if doc.Issuer != issuer {
+		return "", fmt.Errorf("oidc configuration is for issuer %q, not %q", doc.Issuer, issuer)
+	}
+	if doc.JWKSURI == "" {
+		return "", fmt.Errorf("oidc configuration of %q has no jwks_uri", issuer)
+	}
+	return doc.JWKSURI, nil
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testIdP is an OpenID Connect provider serving a discovery document and the
+// public halves of an RSA and an EC signing key
+type testIdP struct {
+	server    *httptest.Server
+	rsaKey    *rsa.PrivateKey
+	ecKey     *ecdsa.PrivateKey
+	jwks      map[string]interface{}
+	discovery map[string]string
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	idp := &testIdP{rsaKey: rsaKey, ecKey: ecKey}
+	b64 := base64.RawURLEncoding.EncodeToString
+	idp.jwks = map[string]interface{}{
+		"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa-1", "alg": "RS256", "use": "sig",
+				"n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec-1", "alg": "ES256", "use": "sig", "crv": "P-256",
+				"x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(idp.discovery)
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(idp.jwks)
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	idp.discovery = map[string]string{"issuer": idp.server.URL, "jwks_uri": idp.server.URL + "/keys"}
+	return idp
+}
+
+func (idp *testIdP) sign(t *testing.T, method jwt.SigningMethod, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	var key interface{} = idp.rsaKey
+	if method == jwt.SigningMethodES256 {
+		key = idp.ecKey
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestOIDCAuthenticator_Authenticate(t *testing.T) {
+	idp := newTestIdP(t)
+	authenticator, err := NewOIDCAuthenticator(context.Background(), OIDCConfig{
+		Issuer:   idp.server.URL,
+		Audience: "company-earnings",
+	}, nil)
+	require.NoError(t, err)
+	defer authenticator.Close()
+
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	claims := func(overrides jwt.MapClaims) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss":      idp.server.URL,
+			"aud":      "company-earnings",
+			"sub":      "alice",
+			"email":    "alice@example.com",
+			"name":     "Alice",
+			"role":     "admin",
+			"tenantID": "tenant1",
+			"exp":      expires.Unix(),
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+
+	expected := &Principal{
+		User:      &mongo.User{ID: "alice", Username: "Alice", Email: "alice@example.com", Role: "admin", TenantID: "tenant1"},
+		Provider:  ProviderOIDC,
+		ExpiresAt: expires,
+	}
+
+	t.Run("RS256", func(t *testing.T) {
+		principal, err := authenticator.Authenticate(context.Background(), idp.sign(t, jwt.SigningMethodRS256, "rsa-1", claims(nil)))
+		require.NoError(t, err)
+		assert.Equal(t, expected.User, principal.User)
+		assert.Equal(t, expected.Provider, principal.Provider)
+		assert.True(t, expected.ExpiresAt.Equal(principal.ExpiresAt))
+	})
+
+	t.Run("ES256", func(t *testing.T) {
+		principal, err := authenticator.Authenticate(context.Background(), idp.sign(t, jwt.SigningMethodES256, "ec-1", claims(nil)))
+		require.NoError(t, err)
+		assert.Equal(t, expected.User, principal.User)
+	})
+
+	t.Run("Audience list and default role", func(t *testing.T) {
+		token := idp.sign(t, jwt.SigningMethodRS256, "rsa-1", claims(jwt.MapClaims{"aud": []string{"other", "company-earnings"}, "role": nil}))
+		principal, err := authenticator.Authenticate(context.Background(), token)
+		require.NoError(t, err)
+		assert.Equal(t, "user", principal.User.Role)
+	})
+
+	rejected := []struct {
+		name  string
+		token string
+	}{
+		{"Wrong issuer", idp.sign(t, jwt.SigningMethodRS256, "rsa-1", claims(jwt.MapClaims{"iss": "https://evil.example.com"}))},
+		{"Wrong audience", idp.sign(t, jwt.SigningMethodRS256, "rsa-1", claims(jwt.MapClaims{"aud": "other"}))},
+		{"Expired", idp.sign(t, jwt.SigningMethodRS256, "rsa-1", claims(jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}))},
+		{"Not yet valid", idp.sign(t, jwt.SigningMethodRS256, "rsa-1", claims(jwt.MapClaims{"nbf": time.Now().Add(time.Hour).Unix()}))},
+		{"Missing exp", idp.sign(t, jwt.SigningMethodRS256, "rsa-1", claims(jwt.MapClaims{"exp": nil}))},
+		{"Missing sub", idp.sign(t, jwt.SigningMethodRS256, "rsa-1", claims(jwt.MapClaims{"sub": nil}))},
+		{"Signed with another key", func() string {
+			other, err := rsa.GenerateKey(rand.Reader, 2048)
+			require.NoError(t, err)
+			token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims(nil))
+			token.Header["kid"] = "rsa-1"
+			signed, err := token.SignedString(other)
+			require.NoError(t, err)
+			return signed
+		}()},
+		{"HMAC with the public key", func() string {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims(nil))
+			token.Header["kid"] = "rsa-1"
+			signed, err := token.SignedString(idp.rsaKey.N.Bytes())
+			require.NoError(t, err)
+			return signed
+		}()},
+		{"Malformed", "not-a-jwt"},
+	}
+
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := authenticator.Authenticate(context.Background(), tt.token)
+			assert.Error(t, err)
+			assert.Nil(t, principal)
+		})
+	}
+}
+
+func TestNewOIDCAuthenticator_Discovery(t *testing.T) {
+	idp := newTestIdP(t)
+
+	t.Run("Explicit JWKS URL", func(t *testing.T) {
+		idp.discovery = map[string]string{}
+		authenticator, err := NewOIDCAuthenticator(context.Background(), OIDCConfig{
+			Issuer:   "https://login.example.com",
+			Audience: "company-earnings",
+			JWKSURL:  idp.server.URL + "/keys",
+		}, nil)
+		require.NoError(t, err)
+		authenticator.Close()
+	})
+
+	t.Run("Issuer mismatch", func(t *testing.T) {
+		idp.discovery = map[string]string{"issuer": "https://evil.example.com", "jwks_uri": idp.server.URL + "/keys"}
+		_, err := NewOIDCAuthenticator(context.Background(), OIDCConfig{Issuer: idp.server.URL, Audience: "company-earnings"}, nil)
+		assert.EqualError(t, err, `oidc configuration is for issuer "https://evil.example.com", not "`+idp.server.URL+`"`)
+	})
+
+	t.Run("Missing jwks_uri", func(t *testing.T) {
+		idp.discovery = map[string]string{"issuer": idp.server.URL}
+		_, err := NewOIDCAuthenticator(context.Background(), OIDCConfig{Issuer: idp.server.URL, Audience: "company-earnings"}, nil)
+		assert.EqualError(t, err, `oidc configuration of "`+idp.server.URL+`" has no jwks_uri`)
+	})
+}
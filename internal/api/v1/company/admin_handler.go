@@ -0,0 +1,123 @@
+package company
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/api-moose/company-earnings/internal/utils/response"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxCompanyBodyBytes bounds the body of admin writes, which carry one company
+const maxCompanyBodyBytes = 8 << 10
+
+// CreateHandler serves POST /companies, adding a company to the reference
+// data. Symbols are unique among companies that have not been deleted.
+func (h *Handler) CreateHandler(w http.ResponseWriter, r *http.Request) {
+	var company models.Company
+	if !decodeBody(w, r, &company) {
+		return
+	}
+
+	company.Normalize()
+	if err := company.Validate(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	err := h.repo.Create(r.Context(), company)
+	if errors.Is(err, dberrors.ErrConflict) {
+		response.ErrorResponse(w, &response.ErrorMessage{
+			Status:    http.StatusConflict,
+			Message:   fmt.Sprintf("company %s already exists", company.Symbol),
+			Parameter: "symbol",
+		})
+		return
+	}
+	if err != nil {
+		response.ErrorResponse(w, err)
+		return
+	}
+	response.JSONResponse(w, http.StatusCreated, company)
+}
+
+// UpdateHandler serves PATCH /companies/{symbol}. Only the fields present in
+// the body are changed; the symbol itself is immutable. The patched company
+// must still be valid as a whole, e.g. its delisting date cannot fall before
+// the stored listing date.
+func (h *Handler) UpdateHandler(w http.ResponseWriter, r *http.Request) {
+	symbol := strings.TrimSpace(chi.URLParam(r, "symbol"))
+
+	var update models.CompanyUpdate
+	if !decodeBody(w, r, &update) {
+		return
+	}
+
+	update.Normalize()
+	if err := update.Validate(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	company, err := h.repo.Update(r.Context(), symbol, update)
+	var fieldErr *models.FieldError
+	if errors.As(err, &fieldErr) {
+		writeValidationError(w, err)
+		return
+	}
+	if errors.Is(err, dberrors.ErrWriteConflict) {
+		response.ErrorResponse(w, &response.ErrorMessage{
+			Status:  http.StatusConflict,
+			Message: fmt.Sprintf("company %s was changed concurrently, retry the update", strings.ToUpper(symbol)),
+		})
+		return
+	}
+	writeCompany(w, company, err)
+}
+
+// DeleteHandler serves DELETE /companies/{symbol}. Companies are soft-deleted
+// so they drop out of every read but can be recreated later.
+func (h *Handler) DeleteHandler(w http.ResponseWriter, r *http.Request) {
+	symbol := strings.TrimSpace(chi.URLParam(r, "symbol"))
+
+	err := h.repo.Delete(r.Context(), symbol)
+	if err != nil {
+		writeCompany(w, nil, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCompanyBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		response.ErrorResponse(w, &response.ErrorMessage{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("invalid request body: %v", err),
+		})
+		return false
+	}
+	return true
+}
+
+func writeValidationError(w http.ResponseWriter, err error) {
+	var fieldErr *models.FieldError
+	if errors.As(err, &fieldErr) {
+		response.ErrorResponse(w, &response.ErrorMessage{
+			Status:    http.StatusBadRequest,
+			Message:   fieldErr.Error(),
+			Parameter: fieldErr.Field,
+		})
+		return
+	}
+	response.ErrorResponse(w, &response.ErrorMessage{
+		Status:  http.StatusBadRequest,
+		Message: err.Error(),
+	})
+}
@@ -0,0 +1,167 @@
+package company
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAdminHandlers(t *testing.T) {
+	apple := models.Company{Symbol: "AAPL", CIK: "0000320193", SecurityName: "Apple Inc.", SecurityType: "Common Stock", Region: "US", Exchange: "NASDAQ", Sector: "Technology", Active: true}
+	appleJSON := `{"symbol":"AAPL","cik":"0000320193","securityName":"Apple Inc.","securityType":"Common Stock","region":"US","exchange":"NASDAQ","sector":"Technology","active":true}`
+	sector := "Consumer Electronics"
+	updated := apple
+	updated.Sector = sector
+	empty := ""
+	inactive := false
+
+	mockRepo := new(MockRepository)
+	mockRepo.On("Create", mock.Anything, apple).Return(nil).Once()
+	mockRepo.On("Create", mock.Anything, apple).Return(dberrors.ErrConflict).Once()
+	mockRepo.On("Update", mock.Anything, "aapl", models.CompanyUpdate{Sector: &sector}).Return(&updated, nil)
+	mockRepo.On("Update", mock.Anything, "ZZZZ", models.CompanyUpdate{Sector: &empty}).Return(nil, dberrors.ErrNotFound)
+	mockRepo.On("Update", mock.Anything, "aapl", models.CompanyUpdate{Active: &inactive}).Return(nil, &models.FieldError{Field: "delistingDate", Message: "cannot be before listingDate"})
+	mockRepo.On("Update", mock.Anything, "busy", models.CompanyUpdate{Sector: &sector}).Return(nil, dberrors.ErrWriteConflict)
+	mockRepo.On("Delete", mock.Anything, "AAPL").Return(nil)
+	mockRepo.On("Delete", mock.Anything, "ZZZZ").Return(dberrors.ErrNotFound)
+
+	handler := NewHandler(mockRepo, testCursors)
+	router := chi.NewRouter()
+	router.Post("/companies", handler.CreateHandler)
+	router.Patch("/companies/{symbol}", handler.UpdateHandler)
+	router.Delete("/companies/{symbol}", handler.DeleteHandler)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Create normalizes input",
+			method:         "POST",
+			path:           "/companies",
+			body:           `{"symbol":" aapl ","cik":"320193","securityName":"Apple Inc.","securityType":"Common Stock","region":"us","exchange":"nasdaq","sector":"Technology","active":true}`,
+			expectedStatus: http.StatusCreated,
+			expectedBody:   appleJSON,
+		},
+		{
+			name:           "Create duplicate",
+			method:         "POST",
+			path:           "/companies",
+			body:           appleJSON,
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"status":409,"error":"company AAPL already exists","parameter":"symbol"}`,
+		},
+		{
+			name:           "Create invalid exchange",
+			method:         "POST",
+			path:           "/companies",
+			body:           strings.Replace(appleJSON, "NASDAQ", "LSE", 1),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"error":"exchange must be one of ` + strings.Join(models.Exchanges, ", ") + `","parameter":"exchange"}`,
+		},
+		{
+			name:           "Create invalid region",
+			method:         "POST",
+			path:           "/companies",
+			body:           strings.Replace(appleJSON, `"US"`, `"USA"`, 1),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"error":"region must be an ISO 3166-1 alpha-2 country code","parameter":"region"}`,
+		},
+		{
+			name:           "Create cannot set deletedAt",
+			method:         "POST",
+			path:           "/companies",
+			body:           `{"symbol":"AAPL","deletedAt":"2024-01-01T00:00:00Z"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"error":"invalid request body: json: unknown field \"deletedAt\""}`,
+		},
+		{
+			name:           "Update",
+			method:         "PATCH",
+			path:           "/companies/aapl",
+			body:           `{"sector":"Consumer Electronics"}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   strings.Replace(appleJSON, "Technology", sector, 1),
+		},
+		{
+			name:           "Update cannot change symbol",
+			method:         "PATCH",
+			path:           "/companies/aapl",
+			body:           `{"symbol":"APPL"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"error":"invalid request body: json: unknown field \"symbol\""}`,
+		},
+		{
+			name:           "Update invalid security type",
+			method:         "PATCH",
+			path:           "/companies/aapl",
+			body:           `{"securityType":"Bond"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"error":"securityType must be one of ` + strings.Join(models.SecurityTypes, ", ") + `","parameter":"securityType"}`,
+		},
+		{
+			name:           "Update invalid against the stored company",
+			method:         "PATCH",
+			path:           "/companies/aapl",
+			body:           `{"active":false}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"error":"delistingDate cannot be before listingDate","parameter":"delistingDate"}`,
+		},
+		{
+			name:           "Update conflicting with concurrent writes",
+			method:         "PATCH",
+			path:           "/companies/busy",
+			body:           `{"sector":"Consumer Electronics"}`,
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"status":409,"error":"company BUSY was changed concurrently, retry the update"}`,
+		},
+		{
+			name:           "Update unknown company",
+			method:         "PATCH",
+			path:           "/companies/ZZZZ",
+			body:           `{"sector":""}`,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":404,"error":"company not found"}`,
+		},
+		{
+			name:           "Delete",
+			method:         "DELETE",
+			path:           "/companies/AAPL",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "Delete unknown company",
+			method:         "DELETE",
+			path:           "/companies/ZZZZ",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":404,"error":"company not found"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedBody == "" {
+				assert.Empty(t, rr.Body.String())
+				return
+			}
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+
+	mockRepo.AssertExpectations(t)
+}
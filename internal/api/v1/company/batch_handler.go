@@ -0,0 +1,114 @@
+package company
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/api-moose/company-earnings/internal/utils/response"
+)
+
+const (
+	// maxBatchSize caps the number of identifiers resolved by one request
+	maxBatchSize = 500
+	// maxBatchBodyBytes comfortably fits maxBatchSize identifiers
+	maxBatchBodyBytes = 64 << 10
+)
+
+type batchRequest struct {
+	Symbols []string `json:"symbols"`
+	CIKs    []string `json:"ciks"`
+}
+
+type batchResponse struct {
+	Count      int              `json:"count"`
+	Results    []models.Company `json:"results"`
+	Unresolved []string         `json:"unresolved"`
+}
+
+// BatchHandler serves POST /companies/batch, resolving many symbols and CIKs
+// in one round trip. Results follow the order of the request, symbols first,
+// and identifiers that match nothing are echoed back in unresolved.
+func (h *Handler) BatchHandler(w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		response.ErrorResponse(w, &response.ErrorMessage{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("invalid request body: %v", err),
+		})
+		return
+	}
+
+	total := len(req.Symbols) + len(req.CIKs)
+	if total == 0 || total > maxBatchSize {
+		response.ErrorResponse(w, &response.ErrorMessage{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("between 1 and %d symbols and ciks must be provided", maxBatchSize),
+		})
+		return
+	}
+
+	symbols := make([]string, len(req.Symbols))
+	for i, symbol := range req.Symbols {
+		symbols[i] = strings.ToUpper(strings.TrimSpace(symbol))
+	}
+
+	ciks := make([]string, len(req.CIKs))
+	for i, cik := range req.CIKs {
+		normalized, err := models.NormalizeCIK(cik)
+		if err != nil {
+			response.ErrorResponse(w, &response.ErrorMessage{
+				Status:    http.StatusBadRequest,
+				Message:   fmt.Sprintf("invalid cik %q: %v", cik, err),
+				Parameter: "ciks",
+			})
+			return
+		}
+		ciks[i] = normalized
+	}
+
+	companies, err := h.repo.Lookup(r.Context(), symbols, ciks)
+	if err != nil {
+		response.ErrorResponse(w, err)
+		return
+	}
+
+	// Lookup orders active listings first, so the first hit per CIK is the
+	// same company GET /companies/cik/{cik} would return
+	bySymbol := make(map[string]models.Company, len(companies))
+	byCIK := make(map[string]models.Company, len(companies))
+	for _, c := range companies {
+		bySymbol[c.Symbol] = c
+		if _, ok := byCIK[c.CIK]; !ok {
+			byCIK[c.CIK] = c
+		}
+	}
+
+	resp := batchResponse{Results: []models.Company{}, Unresolved: []string{}}
+	seen := make(map[string]bool, total)
+	resolve := func(found models.Company, ok bool, identifier string) {
+		if !ok {
+			resp.Unresolved = append(resp.Unresolved, identifier)
+			return
+		}
+		if !seen[found.Symbol] {
+			seen[found.Symbol] = true
+			resp.Results = append(resp.Results, found)
+		}
+	}
+	for i, symbol := range symbols {
+		found, ok := bySymbol[symbol]
+		resolve(found, ok, req.Symbols[i])
+	}
+	for i, cik := range ciks {
+		found, ok := byCIK[cik]
+		resolve(found, ok, req.CIKs[i])
+	}
+	resp.Count = len(resp.Results)
+
+	response.JSONResponse(w, http.StatusOK, resp)
+}
@@ -0,0 +1,102 @@
+package company
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestBatchHandler(t *testing.T) {
+	apple := models.Company{Symbol: "AAPL", CIK: "0000320193", Active: true}
+	googl := models.Company{Symbol: "GOOGL", CIK: "0001652044", Active: true}
+	goog := models.Company{Symbol: "GOOG", CIK: "0001652044", Active: true}
+
+	tests := []struct {
+		name           string
+		body           string
+		expectSymbols  []string
+		expectCIKs     []string
+		mockResult     []models.Company
+		mockError      error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Symbols and CIKs",
+			body:           `{"symbols":["aapl","ZZZZ"],"ciks":["1652044","CIK0000000001"]}`,
+			expectSymbols:  []string{"AAPL", "ZZZZ"},
+			expectCIKs:     []string{"0001652044", "0000000001"},
+			mockResult:     []models.Company{apple, goog, googl},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"count":2,"results":[
+				{"symbol":"AAPL","cik":"0000320193","securityName":"","securityType":"","region":"","exchange":"","sector":"","active":true},
+				{"symbol":"GOOG","cik":"0001652044","securityName":"","securityType":"","region":"","exchange":"","sector":"","active":true}
+			],"unresolved":["ZZZZ","CIK0000000001"]}`,
+		},
+		{
+			name:           "Duplicates are returned once",
+			body:           `{"symbols":["AAPL","aapl"],"ciks":["320193"]}`,
+			expectSymbols:  []string{"AAPL", "AAPL"},
+			expectCIKs:     []string{"0000320193"},
+			mockResult:     []models.Company{apple},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"count":1,"results":[{"symbol":"AAPL","cik":"0000320193","securityName":"","securityType":"","region":"","exchange":"","sector":"","active":true}],"unresolved":[]}`,
+		},
+		{
+			name:           "Empty",
+			body:           `{"symbols":[]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"error":"between 1 and 500 symbols and ciks must be provided"}`,
+		},
+		{
+			name:           "Too many",
+			body:           fmt.Sprintf(`{"symbols":[%s"A"]}`, strings.Repeat(`"A",`, maxBatchSize)),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"error":"between 1 and 500 symbols and ciks must be provided"}`,
+		},
+		{
+			name:           "Malformed CIK",
+			body:           `{"ciks":["apple"]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"error":"invalid cik \"apple\": cik must be a number of at most 10 digits","parameter":"ciks"}`,
+		},
+		{
+			name:           "Unknown field",
+			body:           `{"tickers":["AAPL"]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"error":"invalid request body: json: unknown field \"tickers\""}`,
+		},
+		{
+			name:           "Repository failure",
+			body:           `{"symbols":["AAPL"]}`,
+			expectSymbols:  []string{"AAPL"},
+			expectCIKs:     []string{},
+			mockError:      dberrors.NewDBError("timeout"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":500,"error":"DB Error: timeout"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			if tt.expectSymbols != nil || tt.expectCIKs != nil {
+				mockRepo.On("Lookup", mock.Anything, tt.expectSymbols, tt.expectCIKs).Return(tt.mockResult, tt.mockError)
+			}
+
+			rr := httptest.NewRecorder()
+			NewHandler(mockRepo, testCursors).BatchHandler(rr, httptest.NewRequest("POST", "/companies/batch", strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -1,52 +1,162 @@
 package company
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/api-moose/company-earnings/internal/api/v1/params"
 	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
 	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/api-moose/company-earnings/internal/utils/pagination"
 	"github.com/api-moose/company-earnings/internal/utils/response"
+	"github.com/go-chi/chi/v5"
 )
 
 type Handler struct {
-	repo mongo.Repository
+	repo    mongo.Repository
+	cursors *pagination.CursorCodec
 }
 
-func NewHandler(repo mongo.Repository) *Handler {
-	return &Handler{repo: repo}
+func NewHandler(repo mongo.Repository, cursors *pagination.CursorCodec) *Handler {
+	return &Handler{repo: repo, cursors: cursors}
 }
 
+// pageToken is the payload of the search cursor. Scope ties the cursor to the
+// search it was issued for so it cannot be replayed against another query.
+type pageToken struct {
+	Scope string             `json:"q"`
+	After mongo.SearchCursor `json:"a"`
+}
+
+// Bounds for the search parameters documented in docs/api/swagger.yaml
+const (
+	maxQueryLength = 100
+	defaultLimit   = 10
+	maxLimit       = 100
+)
+
 func (h *Handler) SearchHandler(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query().Get("query")
-	if query == "" {
-		response.ErrorResponse(w, &response.ErrorMessage{
-			Status:  http.StatusBadRequest,
-			Message: "query cannot be empty",
-		})
+	p := params.NewParser(r)
+	query := p.RequiredString("query", 1, maxQueryLength)
+	mode := mongo.SearchMode(p.Enum("mode", string(mongo.SearchModeContains), mongo.SearchModes...))
+	exchange := p.Enum("exchange", "", models.Exchanges...)
+	active := p.Bool("active")
+	limit := p.Int("limit", defaultLimit, 1, maxLimit)
+	if err := p.Err(); err != nil {
+		response.ErrorResponse(w, err)
 		return
 	}
 
-	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
-	if limit == 0 {
-		limit = 10 // Default limit
+	req := mongo.SearchRequest{
+		Query:    query,
+		Mode:     mode,
+		Exchange: exchange,
+		Active:   active,
+		Limit:    limit,
 	}
+	scope := searchScope(req)
 
-	companies, err := h.repo.Search(r.Context(), query, limit)
+	if token := p.String("cursor"); token != "" {
+		var page pageToken
+		if err := h.cursors.Decode(token, &page); err != nil || page.Scope != scope {
+			response.ErrorResponse(w, &response.ErrorMessage{
+				Status:    http.StatusBadRequest,
+				Message:   pagination.ErrInvalidCursor.Error(),
+				Parameter: "cursor",
+			})
+			return
+		}
+		req.After = &page.After
+	}
+
+	result, err := h.repo.Search(r.Context(), req)
 	if err != nil {
 		response.ErrorResponse(w, err)
 		return
 	}
 
+	var nextURL *string
+	if result.Next != nil {
+		token, err := h.cursors.Encode(pageToken{Scope: scope, After: *result.Next})
+		if err != nil {
+			response.ErrorResponse(w, err)
+			return
+		}
+		next := r.URL.Query()
+		next.Set("cursor", token)
+		u := r.URL.Path + "?" + next.Encode()
+		nextURL = &u
+	}
+
 	resp := struct {
-		Count   int              `json:"count"`
+		Count   int64            `json:"count"`
 		Results []models.Company `json:"results"`
 		NextURL *string          `json:"next_url"`
 	}{
-		Count:   len(companies),
-		Results: companies,
-		NextURL: nil, // Implement pagination logic if needed
+		Count:   result.Total,
+		Results: result.Companies,
+		NextURL: nextURL,
 	}
 
 	response.JSONResponse(w, http.StatusOK, resp)
 }
+
+// GetBySymbolHandler serves GET /companies/{symbol}
+func (h *Handler) GetBySymbolHandler(w http.ResponseWriter, r *http.Request) {
+	symbol := strings.TrimSpace(chi.URLParam(r, "symbol"))
+	if symbol == "" {
+		response.ErrorResponse(w, &response.ErrorMessage{
+			Status:    http.StatusBadRequest,
+			Message:   "symbol cannot be empty",
+			Parameter: "symbol",
+		})
+		return
+	}
+
+	company, err := h.repo.GetBySymbol(r.Context(), symbol)
+	writeCompany(w, company, err)
+}
+
+// GetByCIKHandler serves GET /companies/cik/{cik}
+func (h *Handler) GetByCIKHandler(w http.ResponseWriter, r *http.Request) {
+	cik, err := models.NormalizeCIK(chi.URLParam(r, "cik"))
+	if err != nil {
+		response.ErrorResponse(w, &response.ErrorMessage{
+			Status:    http.StatusBadRequest,
+			Message:   err.Error(),
+			Parameter: "cik",
+		})
+		return
+	}
+
+	company, err := h.repo.GetByCIK(r.Context(), cik)
+	writeCompany(w, company, err)
+}
+
+func writeCompany(w http.ResponseWriter, company *models.Company, err error) {
+	if errors.Is(err, dberrors.ErrNotFound) {
+		response.ErrorResponse(w, &response.ErrorMessage{
+			Status:  http.StatusNotFound,
+			Message: "company not found",
+		})
+		return
+	}
+	if err != nil {
+		response.ErrorResponse(w, err)
+		return
+	}
+	response.JSONResponse(w, http.StatusOK, company)
+}
+
+// searchScope identifies the set of matches a search pages through. The limit
+// is left out so clients can change page size between requests.
+func searchScope(req mongo.SearchRequest) string {
+	active := ""
+	if req.Active != nil {
+		active = strconv.FormatBool(*req.Active)
+	}
+	return strings.Join([]string{req.Query, string(req.Mode), req.Exchange, active}, "\x00")
+}
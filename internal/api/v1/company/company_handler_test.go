@@ -5,9 +5,15 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
 	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/api-moose/company-earnings/internal/utils/pagination"
+	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -16,33 +22,97 @@ type MockRepository struct {
 	mock.Mock
 }
 
-func (m *MockRepository) Search(ctx context.Context, query string, limit int) ([]models.Company, error) {
-	args := m.Called(ctx, query, limit)
-	return args.Get(0).([]models.Company), args.Error(1)
+func (m *MockRepository) Search(ctx context.Context, req mongo.SearchRequest) (*mongo.SearchResult, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) != nil {
+		return args.Get(0).(*mongo.SearchResult), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) GetBySymbol(ctx context.Context, symbol string) (*models.Company, error) {
+	args := m.Called(ctx, symbol)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.Company), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) GetByCIK(ctx context.Context, cik string) (*models.Company, error) {
+	args := m.Called(ctx, cik)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.Company), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) Lookup(ctx context.Context, symbols, ciks []string) ([]models.Company, error) {
+	args := m.Called(ctx, symbols, ciks)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.Company), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) List(ctx context.Context, filter mongo.ListFilter) ([]models.Company, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.Company), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) Create(ctx context.Context, company models.Company) error {
+	return m.Called(ctx, company).Error(0)
+}
+
+func (m *MockRepository) Update(ctx context.Context, symbol string, update models.CompanyUpdate) (*models.Company, error) {
+	args := m.Called(ctx, symbol, update)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.Company), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) Delete(ctx context.Context, symbol string) error {
+	return m.Called(ctx, symbol).Error(0)
+}
+
+func (m *MockRepository) Upsert(ctx context.Context, company models.Company) (mongo.UpsertResult, error) {
+	args := m.Called(ctx, company)
+	return args.Get(0).(mongo.UpsertResult), args.Error(1)
 }
 
+var testCursors = pagination.NewCursorCodec("test-secret")
+
 func TestSearchHandler(t *testing.T) {
 	tests := []struct {
 		name           string
-		query          string
-		mockResult     []models.Company
+		url            string
+		expectedReq    *mongo.SearchRequest
+		mockResult     *mongo.SearchResult
 		mockError      error
 		expectedStatus int
 		expectedBody   map[string]interface{}
 	}{
 		{
-			name:  "Valid search",
-			query: "Apple",
-			mockResult: []models.Company{
-				{
-					Symbol:       "AAPL",
-					CIK:          "0000320193",
-					SecurityName: "Apple Inc.",
-					SecurityType: "Common Stock",
-					Region:       "US",
-					Exchange:     "NASDAQ",
-					Sector:       "Technology",
+			name:        "Valid search",
+			url:         "/companies?query=Apple",
+			expectedReq: &mongo.SearchRequest{Query: "Apple", Mode: mongo.SearchModeContains, Limit: 10},
+			mockResult: &mongo.SearchResult{
+				Companies: []models.Company{
+					{
+						Symbol:       "AAPL",
+						CIK:          "0000320193",
+						SecurityName: "Apple Inc.",
+						SecurityType: "Common Stock",
+						Region:       "US",
+						Exchange:     "NASDAQ",
+						Sector:       "Technology",
+						Active:       true,
+					},
 				},
+				Total: 1,
 			},
 			mockError:      nil,
 			expectedStatus: http.StatusOK,
@@ -57,6 +127,7 @@ func TestSearchHandler(t *testing.T) {
 						"region":       "US",
 						"exchange":     "NASDAQ",
 						"sector":       "Technology",
+						"active":       true,
 					},
 				},
 				"next_url": nil,
@@ -64,28 +135,122 @@ func TestSearchHandler(t *testing.T) {
 		},
 		{
 			name:           "Empty query",
-			query:          "",
+			url:            "/companies?query=",
 			mockResult:     nil,
 			mockError:      nil,
 			expectedStatus: http.StatusBadRequest,
 			expectedBody: map[string]interface{}{
-				"status": float64(http.StatusBadRequest),
-				"error":  "query cannot be empty",
+				"status":    float64(http.StatusBadRequest),
+				"error":     "query cannot be empty",
+				"parameter": "query",
+			},
+		},
+		{
+			name:           "Starts with mode",
+			url:            "/companies?query=app&mode=starts_with&limit=5",
+			expectedReq:    &mongo.SearchRequest{Query: "app", Mode: mongo.SearchModeStartsWith, Limit: 5},
+			mockResult:     &mongo.SearchResult{Companies: []models.Company{}},
+			expectedStatus: http.StatusOK,
+			expectedBody: map[string]interface{}{
+				"count":    float64(0),
+				"results":  []interface{}{},
+				"next_url": nil,
+			},
+		},
+		{
+			name:           "Exchange and active filters",
+			url:            "/companies?query=app&exchange=nyse&active=false",
+			expectedReq:    &mongo.SearchRequest{Query: "app", Mode: mongo.SearchModeContains, Exchange: "NYSE", Active: boolPtr(false), Limit: 10},
+			mockResult:     &mongo.SearchResult{Companies: []models.Company{}},
+			expectedStatus: http.StatusOK,
+			expectedBody: map[string]interface{}{
+				"count":    float64(0),
+				"results":  []interface{}{},
+				"next_url": nil,
+			},
+		},
+		{
+			name:           "Unknown exchange",
+			url:            "/companies?query=app&exchange=LSE",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody: map[string]interface{}{
+				"status":    float64(http.StatusBadRequest),
+				"error":     "exchange must be one of NYSE, NASDAQ, AMEX",
+				"parameter": "exchange",
+			},
+		},
+		{
+			name:           "Invalid active",
+			url:            "/companies?query=app&active=maybe",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody: map[string]interface{}{
+				"status":    float64(http.StatusBadRequest),
+				"error":     "active must be true or false",
+				"parameter": "active",
+			},
+		},
+		{
+			name:           "Invalid mode",
+			url:            "/companies?query=app&mode=fuzzy",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody: map[string]interface{}{
+				"status":    float64(http.StatusBadRequest),
+				"error":     "mode must be one of exact, starts_with, contains",
+				"parameter": "mode",
+			},
+		},
+		{
+			name:           "Query too long",
+			url:            "/companies?query=" + strings.Repeat("a", 101),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody: map[string]interface{}{
+				"status":    float64(http.StatusBadRequest),
+				"error":     "query must be between 1 and 100 characters",
+				"parameter": "query",
+			},
+		},
+		{
+			name:           "Malformed limit",
+			url:            "/companies?query=app&limit=abc",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody: map[string]interface{}{
+				"status":    float64(http.StatusBadRequest),
+				"error":     "limit must be an integer between 1 and 100",
+				"parameter": "limit",
+			},
+		},
+		{
+			name:           "Limit too small",
+			url:            "/companies?query=app&limit=0",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody: map[string]interface{}{
+				"status":    float64(http.StatusBadRequest),
+				"error":     "limit must be an integer between 1 and 100",
+				"parameter": "limit",
+			},
+		},
+		{
+			name:           "Limit too large",
+			url:            "/companies?query=app&limit=101",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody: map[string]interface{}{
+				"status":    float64(http.StatusBadRequest),
+				"error":     "limit must be an integer between 1 and 100",
+				"parameter": "limit",
 			},
 		},
-		// Add more test cases as needed
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockRepo := new(MockRepository)
-			if tt.query != "" {
-				mockRepo.On("Search", mock.Anything, tt.query, 10).Return(tt.mockResult, tt.mockError)
+			if tt.expectedReq != nil {
+				mockRepo.On("Search", mock.Anything, *tt.expectedReq).Return(tt.mockResult, tt.mockError)
 			}
 
-			handler := NewHandler(mockRepo)
+			handler := NewHandler(mockRepo, testCursors)
 
-			req, err := http.NewRequest("GET", "/companies?query="+tt.query, nil)
+			req, err := http.NewRequest("GET", tt.url, nil)
 			assert.NoError(t, err)
 
 			rr := httptest.NewRecorder()
@@ -103,3 +268,126 @@ func TestSearchHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestSearchHandler_Pagination(t *testing.T) {
+	mockRepo := new(MockRepository)
+	handler := NewHandler(mockRepo, testCursors)
+
+	first := mongo.SearchRequest{Query: "apple", Mode: mongo.SearchModeContains, Limit: 1}
+	after := mongo.SearchCursor{Rank: 3, Symbol: "AAPL"}
+	second := first
+	second.After = &after
+
+	mockRepo.On("Search", mock.Anything, first).Return(&mongo.SearchResult{
+		Companies: []models.Company{{Symbol: "AAPL"}},
+		Total:     2,
+		Next:      &after,
+	}, nil)
+	mockRepo.On("Search", mock.Anything, second).Return(&mongo.SearchResult{
+		Companies: []models.Company{{Symbol: "APLE"}},
+		Total:     2,
+	}, nil)
+
+	rr := httptest.NewRecorder()
+	handler.SearchHandler(rr, httptest.NewRequest("GET", "/api/v1/companies?query=apple&limit=1", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var page struct {
+		Count   int              `json:"count"`
+		Results []models.Company `json:"results"`
+		NextURL *string          `json:"next_url"`
+	}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	assert.Equal(t, 2, page.Count)
+	assert.Len(t, page.Results, 1)
+	if !assert.NotNil(t, page.NextURL) {
+		return
+	}
+
+	next, err := url.Parse(*page.NextURL)
+	assert.NoError(t, err)
+	assert.Equal(t, "/api/v1/companies", next.Path)
+	assert.Equal(t, "apple", next.Query().Get("query"))
+	assert.Equal(t, "1", next.Query().Get("limit"))
+
+	rr = httptest.NewRecorder()
+	handler.SearchHandler(rr, httptest.NewRequest("GET", *page.NextURL, nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	page.NextURL = nil
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	assert.Equal(t, "APLE", page.Results[0].Symbol)
+	assert.Nil(t, page.NextURL)
+
+	// The cursor is bound to the search it was issued for
+	rr = httptest.NewRecorder()
+	handler.SearchHandler(rr, httptest.NewRequest("GET", "/api/v1/companies?query=micro&cursor="+next.Query().Get("cursor"), nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSearchHandler_InvalidCursor(t *testing.T) {
+	mockRepo := new(MockRepository)
+	handler := NewHandler(mockRepo, testCursors)
+
+	forged, err := pagination.NewCursorCodec("other-secret").Encode(pageToken{
+		Scope: searchScope(mongo.SearchRequest{Query: "apple", Mode: mongo.SearchModeContains}),
+		After: mongo.SearchCursor{Rank: 4, Symbol: "ZZZZ"},
+	})
+	assert.NoError(t, err)
+
+	for _, cursor := range []string{"garbage", forged} {
+		rr := httptest.NewRecorder()
+		handler.SearchHandler(rr, httptest.NewRequest("GET", "/api/v1/companies?query=apple&cursor="+url.QueryEscape(cursor), nil))
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.JSONEq(t, `{"status":400,"error":"invalid cursor","parameter":"cursor"}`, rr.Body.String())
+	}
+
+	mockRepo.AssertNotCalled(t, "Search", mock.Anything, mock.Anything)
+}
+
+func TestLookupHandlers(t *testing.T) {
+	apple := &models.Company{Symbol: "AAPL", CIK: "0000320193", SecurityName: "Apple Inc.", Active: true}
+
+	mockRepo := new(MockRepository)
+	mockRepo.On("GetBySymbol", mock.Anything, "AAPL").Return(apple, nil)
+	mockRepo.On("GetBySymbol", mock.Anything, "ZZZZ").Return(nil, dberrors.ErrNotFound)
+	mockRepo.On("GetByCIK", mock.Anything, "0000320193").Return(apple, nil)
+	mockRepo.On("GetByCIK", mock.Anything, "0000000001").Return(nil, dberrors.ErrNotFound)
+
+	handler := NewHandler(mockRepo, testCursors)
+	router := chi.NewRouter()
+	router.Get("/companies/cik/{cik}", handler.GetByCIKHandler)
+	router.Get("/companies/{symbol}", handler.GetBySymbolHandler)
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"By symbol", "/companies/AAPL", http.StatusOK, `{"symbol":"AAPL","cik":"0000320193","securityName":"Apple Inc.","securityType":"","region":"","exchange":"","sector":"","active":true}`},
+		{"Unknown symbol", "/companies/ZZZZ", http.StatusNotFound, `{"status":404,"error":"company not found"}`},
+		{"By padded CIK", "/companies/cik/0000320193", http.StatusOK, `{"symbol":"AAPL","cik":"0000320193","securityName":"Apple Inc.","securityType":"","region":"","exchange":"","sector":"","active":true}`},
+		{"By unpadded CIK", "/companies/cik/320193", http.StatusOK, `{"symbol":"AAPL","cik":"0000320193","securityName":"Apple Inc.","securityType":"","region":"","exchange":"","sector":"","active":true}`},
+		{"Unknown CIK", "/companies/cik/1", http.StatusNotFound, `{"status":404,"error":"company not found"}`},
+		{"Malformed CIK", "/companies/cik/apple", http.StatusBadRequest, `{"status":400,"error":"cik must be a number of at most 10 digits","parameter":"cik"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", tt.path, nil))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+
+	mockRepo.AssertExpectations(t)
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
@@ -0,0 +1,111 @@
+package corporateactions
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/api-moose/company-earnings/internal/utils/response"
+	"github.com/go-chi/chi/v5"
+)
+
+// Companies is the part of mongo.Repository the corporate action handlers use
+type Companies interface {
+	GetBySymbol(ctx context.Context, symbol string) (*models.Company, error)
+}
+
+type Handler struct {
+	companies Companies
+	actions   mongo.CorporateActionsRepository
+}
+
+func NewHandler(companies Companies, actions mongo.CorporateActionsRepository) *Handler {
+	return &Handler{companies: companies, actions: actions}
+}
+
+type dividendsResponse struct {
+	Symbol    string            `json:"symbol"`
+	Count     int               `json:"count"`
+	Dividends []models.Dividend `json:"dividends"`
+}
+
+type splitsResponse struct {
+	Symbol string         `json:"symbol"`
+	Count  int            `json:"count"`
+	Splits []models.Split `json:"splits"`
+}
+
+// DividendsHandler serves GET /companies/{symbol}/dividends, returning the
+// dividends of the company, latest ex-date first, with amounts as declared
+func (h *Handler) DividendsHandler(w http.ResponseWriter, r *http.Request) {
+	company, ok := h.company(w, r)
+	if !ok {
+		return
+	}
+
+	dividends, err := h.actions.ListDividends(r.Context(), company.Symbol)
+	if err != nil {
+		response.ErrorResponse(w, err)
+		return
+	}
+
+	response.JSONResponse(w, http.StatusOK, dividendsResponse{
+		Symbol:    company.Symbol,
+		Count:     len(dividends),
+		Dividends: dividends,
+	})
+}
+
+// SplitsHandler serves GET /companies/{symbol}/splits, returning the stock
+// splits of the company, latest first
+func (h *Handler) SplitsHandler(w http.ResponseWriter, r *http.Request) {
+	company, ok := h.company(w, r)
+	if !ok {
+		return
+	}
+
+	splits, err := h.actions.ListSplits(r.Context(), company.Symbol)
+	if err != nil {
+		response.ErrorResponse(w, err)
+		return
+	}
+
+	response.JSONResponse(w, http.StatusOK, splitsResponse{
+		Symbol: company.Symbol,
+		Count:  len(splits),
+		Splits: splits,
+	})
+}
+
+// company resolves the {symbol} path parameter, writing the error response
+// when the company does not exist so unknown symbols are told apart from
+// companies without corporate actions
+func (h *Handler) company(w http.ResponseWriter, r *http.Request) (*models.Company, bool) {
+	symbol := strings.TrimSpace(chi.URLParam(r, "symbol"))
+	if symbol == "" {
+		response.ErrorResponse(w, &response.ErrorMessage{
+			Status:    http.StatusBadRequest,
+			Message:   "symbol cannot be empty",
+			Parameter: "symbol",
+		})
+		return nil, false
+	}
+
+	company, err := h.companies.GetBySymbol(r.Context(), symbol)
+	if errors.Is(err, dberrors.ErrNotFound) {
+		response.ErrorResponse(w, &response.ErrorMessage{
+			Status:  http.StatusNotFound,
+			Message: "company not found",
+		})
+		return nil, false
+	}
+	if err != nil {
+		response.ErrorResponse(w, err)
+		return nil, false
+	}
+	return company, true
+}
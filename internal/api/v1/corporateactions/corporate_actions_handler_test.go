@@ -0,0 +1,131 @@
+package corporateactions
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockCompanies struct {
+	mock.Mock
+}
+
+func (m *MockCompanies) GetBySymbol(ctx context.Context, symbol string) (*models.Company, error) {
+	args := m.Called(ctx, symbol)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.Company), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+type MockCorporateActionsRepository struct {
+	mock.Mock
+}
+
+func (m *MockCorporateActionsRepository) ListDividends(ctx context.Context, symbol string) ([]models.Dividend, error) {
+	args := m.Called(ctx, symbol)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.Dividend), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCorporateActionsRepository) ListSplits(ctx context.Context, symbol string) ([]models.Split, error) {
+	args := m.Called(ctx, symbol)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.Split), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCorporateActionsRepository) ListSplitsBySymbols(ctx context.Context, symbols []string) (map[string][]models.Split, error) {
+	args := m.Called(ctx, symbols)
+	if args.Get(0) != nil {
+		return args.Get(0).(map[string][]models.Split), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCorporateActionsRepository) UpsertDividend(ctx context.Context, dividend models.Dividend) (mongo.UpsertResult, error) {
+	args := m.Called(ctx, dividend)
+	return args.Get(0).(mongo.UpsertResult), args.Error(1)
+}
+
+func (m *MockCorporateActionsRepository) UpsertSplit(ctx context.Context, split models.Split) (mongo.UpsertResult, error) {
+	args := m.Called(ctx, split)
+	return args.Get(0).(mongo.UpsertResult), args.Error(1)
+}
+
+func TestCorporateActionsHandlers(t *testing.T) {
+	apple := &models.Company{Symbol: "AAPL", CIK: "0000320193", Active: true}
+	msft := &models.Company{Symbol: "MSFT", CIK: "0000789019", Active: true}
+	payDate := time.Date(2024, 8, 15, 0, 0, 0, 0, time.UTC)
+	dividend := models.Dividend{
+		Symbol: "AAPL", ExDate: time.Date(2024, 8, 12, 0, 0, 0, 0, time.UTC), PayDate: &payDate,
+		Amount: 0.25, Currency: "USD", Type: models.DividendTypeRegular,
+	}
+	split := models.Split{Symbol: "AAPL", Date: time.Date(2020, 8, 31, 0, 0, 0, 0, time.UTC), Numerator: 4, Denominator: 1}
+
+	companies := new(MockCompanies)
+	companies.On("GetBySymbol", mock.Anything, "aapl").Return(apple, nil)
+	companies.On("GetBySymbol", mock.Anything, "MSFT").Return(msft, nil)
+	companies.On("GetBySymbol", mock.Anything, "ZZZZ").Return(nil, dberrors.ErrNotFound)
+
+	actions := new(MockCorporateActionsRepository)
+	actions.On("ListDividends", mock.Anything, "AAPL").Return([]models.Dividend{dividend}, nil)
+	actions.On("ListSplits", mock.Anything, "AAPL").Return([]models.Split{split}, nil)
+	actions.On("ListDividends", mock.Anything, "MSFT").Return(nil, dberrors.NewDBError("timeout"))
+	actions.On("ListSplits", mock.Anything, "MSFT").Return([]models.Split{}, nil)
+
+	h := NewHandler(companies, actions)
+	router := chi.NewRouter()
+	router.Get("/companies/{symbol}/dividends", h.DividendsHandler)
+	router.Get("/companies/{symbol}/splits", h.SplitsHandler)
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Dividends",
+			path:           "/companies/aapl/dividends",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"symbol":"AAPL","count":1,"dividends":[
+				{"symbol":"AAPL","exDate":"2024-08-12T00:00:00Z","recordDate":null,"payDate":"2024-08-15T00:00:00Z","amount":0.25,"currency":"USD","type":"regular"}
+			]}`,
+		},
+		{
+			name:           "Splits",
+			path:           "/companies/aapl/splits",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"symbol":"AAPL","count":1,"splits":[{"symbol":"AAPL","date":"2020-08-31T00:00:00Z","numerator":4,"denominator":1,"ratio":4}]}`,
+		},
+		{"No splits", "/companies/MSFT/splits", http.StatusOK, `{"symbol":"MSFT","count":0,"splits":[]}`},
+		{"Unknown company", "/companies/ZZZZ/dividends", http.StatusNotFound, `{"status":404,"error":"company not found"}`},
+		{"Repository failure", "/companies/MSFT/dividends", http.StatusInternalServerError, `{"status":500,"error":"DB Error: timeout"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", tt.path, nil))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+
+	companies.AssertExpectations(t)
+	actions.AssertExpectations(t)
+}
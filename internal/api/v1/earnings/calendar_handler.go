@@ -0,0 +1,141 @@
+package earnings
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/api/v1/params"
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/api-moose/company-earnings/internal/utils/response"
+)
+
+// Bounds for the calendar parameters documented in docs/api/swagger.yaml
+const (
+	defaultCalendarDays = 7
+	maxCalendarDays     = 31
+	maxSectorLength     = 100
+)
+
+// Calendar event statuses
+const (
+	statusScheduled = "scheduled"
+	statusReported  = "reported"
+)
+
+type calendarEvent struct {
+	Symbol         string              `json:"symbol"`
+	SecurityName   string              `json:"securityName"`
+	Exchange       string              `json:"exchange"`
+	Sector         string              `json:"sector"`
+	FiscalYear     int                 `json:"fiscalYear"`
+	FiscalQuarter  int                 `json:"fiscalQuarter"`
+	PeriodEnd      time.Time           `json:"periodEnd"`
+	ReportDate     *time.Time          `json:"reportDate"`
+	ReportTime     string              `json:"reportTime"`
+	Status         string              `json:"status"`
+	EPSDiluted     *float64            `json:"epsDiluted"`
+	EPSEstimate    *models.EPSEstimate `json:"epsEstimate"`
+	EPSSurprisePct *float64            `json:"epsSurprisePct"`
+	Revenue        *float64            `json:"revenue"`
+	Currency       string              `json:"currency"`
+}
+
+type calendarResponse struct {
+	From   string          `json:"from"`
+	To     string          `json:"to"`
+	Count  int             `json:"count"`
+	Events []calendarEvent `json:"events"`
+}
+
+// CalendarHandler serves GET /earnings/calendar, listing the earnings reported
+// or scheduled between from and to. Without a range it covers the coming
+// week. Events are ordered by report date and then by time of day, and can be
+// narrowed to beats or misses with the surprise_pct_gt and surprise_pct_lt
+// parameters. With as_of the figures are those published by that date, and
+// quarters reported later are scheduled. Per-share figures are in shares
+// after the splits effective by as_of, or today, unless split_adjusted is
+// false.
+func (h *Handler) CalendarHandler(w http.ResponseWriter, r *http.Request) {
+	today := h.now().UTC().Truncate(24 * time.Hour)
+
+	p := params.NewParser(r)
+	from := p.Date("from", today)
+	to := p.Date("to", from.AddDate(0, 0, defaultCalendarDays-1))
+	exchange := p.Enum("exchange", "", models.Exchanges...)
+	sector := p.String("sector")
+	surprise := surpriseFilter(p)
+	asOf := p.OptionalDate("as_of")
+	splitAdjusted := p.Bool("split_adjusted")
+	switch {
+	case to.Before(from):
+		p.Fail("to", "to cannot be before from")
+	case to.Sub(from) >= maxCalendarDays*24*time.Hour:
+		p.Fail("to", "from and to cannot span more than %d days", maxCalendarDays)
+	case len(sector) > maxSectorLength:
+		p.Fail("sector", "sector must be at most %d characters", maxSectorLength)
+	}
+	if err := p.Err(); err != nil {
+		response.ErrorResponse(w, err)
+		return
+	}
+
+	events, err := h.earnings.Calendar(r.Context(), mongo.CalendarRequest{
+		From:     from,
+		To:       to,
+		Exchange: exchange,
+		Sector:   sector,
+		Surprise: surprise,
+		AsOf:     asOf,
+	})
+	if err != nil {
+		response.ErrorResponse(w, err)
+		return
+	}
+	if len(events) > 0 && (splitAdjusted == nil || *splitAdjusted) {
+		symbols := make([]string, len(events))
+		for i := range events {
+			symbols[i] = events[i].Symbol
+		}
+		bySymbol, err := h.splits.ListSplitsBySymbols(r.Context(), symbols)
+		if err != nil {
+			response.ErrorResponse(w, err)
+			return
+		}
+		for i := range events {
+			events[i].AdjustForSplits(models.SplitsBy(bySymbol[events[i].Symbol], asOf))
+		}
+	}
+
+	resp := calendarResponse{
+		From:   from.Format(params.DateLayout),
+		To:     to.Format(params.DateLayout),
+		Count:  len(events),
+		Events: make([]calendarEvent, len(events)),
+	}
+	for i, e := range events {
+		status := statusScheduled
+		if e.Reported() {
+			status = statusReported
+		}
+		resp.Events[i] = calendarEvent{
+			Symbol:         e.Symbol,
+			SecurityName:   e.Company.SecurityName,
+			Exchange:       e.Company.Exchange,
+			Sector:         e.Company.Sector,
+			FiscalYear:     e.FiscalYear,
+			FiscalQuarter:  e.FiscalQuarter,
+			PeriodEnd:      e.PeriodEnd,
+			ReportDate:     e.ReportDate,
+			ReportTime:     e.ReportTime,
+			Status:         status,
+			EPSDiluted:     e.EPSDiluted,
+			EPSEstimate:    e.EPSEstimate,
+			EPSSurprisePct: e.EPSSurprisePct,
+			Revenue:        e.Revenue,
+			Currency:       e.Currency,
+		}
+	}
+
+	response.JSONResponse(w, http.StatusOK, resp)
+}
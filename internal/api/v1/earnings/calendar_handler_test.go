@@ -0,0 +1,171 @@
+package earnings
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func TestCalendarHandler(t *testing.T) {
+	apple := models.Company{Symbol: "AAPL", SecurityName: "Apple Inc.", Exchange: "NASDAQ", Sector: "Technology"}
+	aple := models.Company{Symbol: "APLE", SecurityName: "Apple Hospitality REIT, Inc.", Exchange: "NYSE", Sector: "Real Estate"}
+	reportDate := date(2024, 8, 1)
+	reported := mongo.CalendarEvent{
+		Earnings: models.Earnings{
+			Symbol: "APLE", FiscalYear: 2024, FiscalQuarter: 2, PeriodEnd: date(2024, 6, 30),
+			ReportDate: &reportDate, ReportTime: models.ReportTimeBeforeOpen,
+			EPSDiluted: float64Ptr(0.27), Revenue: float64Ptr(390100000), Currency: "USD",
+			EPSEstimate:    &models.EPSEstimate{Mean: 0.25, High: 0.27, Low: 0.24, NumAnalysts: 4},
+			EPSSurprise:    float64Ptr(0.02),
+			EPSSurprisePct: float64Ptr(8),
+		},
+		Company: aple,
+	}
+	scheduled := mongo.CalendarEvent{
+		Earnings: models.Earnings{
+			Symbol: "AAPL", FiscalYear: 2024, FiscalQuarter: 3, PeriodEnd: date(2024, 6, 29),
+			ReportDate: &reportDate, ReportTime: models.ReportTimeAfterClose, Currency: "USD",
+			EPSEstimate: &models.EPSEstimate{Mean: 1.35, High: 1.45, Low: 1.30, NumAnalysts: 30},
+		},
+		Company: apple,
+	}
+
+	// Apple Hospitality split 2-for-1 after reporting
+	splits := new(MockSplits)
+	splits.On("ListSplitsBySymbols", mock.Anything, []string{"APLE", "AAPL"}).Return(map[string][]models.Split{
+		"APLE": {{Symbol: "APLE", Date: date(2024, 9, 2), Numerator: 2, Denominator: 1}},
+	}, nil)
+	splits.On("ListSplitsBySymbols", mock.Anything, []string{"AAPL"}).Return(nil, dberrors.NewDBError("timeout"))
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedReq    *mongo.CalendarRequest
+		mockResult     []mongo.CalendarEvent
+		mockError      error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Defaults to the coming week",
+			url:            "/earnings/calendar",
+			expectedReq:    &mongo.CalendarRequest{From: date(2024, 7, 29), To: date(2024, 8, 4)},
+			mockResult:     []mongo.CalendarEvent{reported, scheduled},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"from":"2024-07-29","to":"2024-08-04","count":2,"events":[
+				{"symbol":"APLE","securityName":"Apple Hospitality REIT, Inc.","exchange":"NYSE","sector":"Real Estate","fiscalYear":2024,"fiscalQuarter":2,"periodEnd":"2024-06-30T00:00:00Z","reportDate":"2024-08-01T00:00:00Z","reportTime":"before-open","status":"reported","epsDiluted":0.135,"epsEstimate":{"mean":0.125,"high":0.135,"low":0.12,"numAnalysts":4},"epsSurprisePct":8,"revenue":390100000,"currency":"USD"},
+				{"symbol":"AAPL","securityName":"Apple Inc.","exchange":"NASDAQ","sector":"Technology","fiscalYear":2024,"fiscalQuarter":3,"periodEnd":"2024-06-29T00:00:00Z","reportDate":"2024-08-01T00:00:00Z","reportTime":"after-close","status":"scheduled","epsDiluted":null,"epsEstimate":{"mean":1.35,"high":1.45,"low":1.3,"numAnalysts":30},"epsSurprisePct":null,"revenue":null,"currency":"USD"}
+			]}`,
+		},
+		{
+			name:           "As filed",
+			url:            "/earnings/calendar?split_adjusted=false",
+			expectedReq:    &mongo.CalendarRequest{From: date(2024, 7, 29), To: date(2024, 8, 4)},
+			mockResult:     []mongo.CalendarEvent{reported, scheduled},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"from":"2024-07-29","to":"2024-08-04","count":2,"events":[
+				{"symbol":"APLE","securityName":"Apple Hospitality REIT, Inc.","exchange":"NYSE","sector":"Real Estate","fiscalYear":2024,"fiscalQuarter":2,"periodEnd":"2024-06-30T00:00:00Z","reportDate":"2024-08-01T00:00:00Z","reportTime":"before-open","status":"reported","epsDiluted":0.27,"epsEstimate":{"mean":0.25,"high":0.27,"low":0.24,"numAnalysts":4},"epsSurprisePct":8,"revenue":390100000,"currency":"USD"},
+				{"symbol":"AAPL","securityName":"Apple Inc.","exchange":"NASDAQ","sector":"Technology","fiscalYear":2024,"fiscalQuarter":3,"periodEnd":"2024-06-29T00:00:00Z","reportDate":"2024-08-01T00:00:00Z","reportTime":"after-close","status":"scheduled","epsDiluted":null,"epsEstimate":{"mean":1.35,"high":1.45,"low":1.3,"numAnalysts":30},"epsSurprisePct":null,"revenue":null,"currency":"USD"}
+			]}`,
+		},
+		{
+			name:           "Splits failure",
+			url:            "/earnings/calendar?from=2024-08-01&to=2024-08-01",
+			expectedReq:    &mongo.CalendarRequest{From: date(2024, 8, 1), To: date(2024, 8, 1)},
+			mockResult:     []mongo.CalendarEvent{scheduled},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":500,"error":"DB Error: timeout"}`,
+		},
+		{
+			name:           "Filters",
+			url:            "/earnings/calendar?from=2024-08-01&to=2024-08-01&exchange=nasdaq&sector=Technology",
+			expectedReq:    &mongo.CalendarRequest{From: date(2024, 8, 1), To: date(2024, 8, 1), Exchange: "NASDAQ", Sector: "Technology"},
+			mockResult:     []mongo.CalendarEvent{},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"from":"2024-08-01","to":"2024-08-01","count":0,"events":[]}`,
+		},
+		{
+			name:           "Beats",
+			url:            "/earnings/calendar?surprise_pct_gt=5",
+			expectedReq:    &mongo.CalendarRequest{From: date(2024, 7, 29), To: date(2024, 8, 4), Surprise: mongo.SurpriseFilter{PctGT: float64Ptr(5)}},
+			mockResult:     []mongo.CalendarEvent{},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"from":"2024-07-29","to":"2024-08-04","count":0,"events":[]}`,
+		},
+		{
+			name:           "As of",
+			url:            "/earnings/calendar?as_of=2024-07-31",
+			expectedReq:    &mongo.CalendarRequest{From: date(2024, 7, 29), To: date(2024, 8, 4), AsOf: timePtr(date(2024, 7, 31))},
+			mockResult:     []mongo.CalendarEvent{},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"from":"2024-07-29","to":"2024-08-04","count":0,"events":[]}`,
+		},
+		{
+			name:           "Range ends before it starts",
+			url:            "/earnings/calendar?from=2024-08-01&to=2024-07-31",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"error":"to cannot be before from","parameter":"to"}`,
+		},
+		{
+			name:           "Range too long",
+			url:            "/earnings/calendar?from=2024-07-01&to=2024-08-01",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"error":"from and to cannot span more than 31 days","parameter":"to"}`,
+		},
+		{
+			name:           "Malformed date",
+			url:            "/earnings/calendar?from=tomorrow",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"error":"from must be a date formatted as YYYY-MM-DD","parameter":"from"}`,
+		},
+		{
+			name:           "Unknown exchange",
+			url:            "/earnings/calendar?exchange=LSE",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"error":"exchange must be one of NYSE, NASDAQ, AMEX","parameter":"exchange"}`,
+		},
+		{
+			name:           "Repository failure",
+			url:            "/earnings/calendar",
+			expectedReq:    &mongo.CalendarRequest{From: date(2024, 7, 29), To: date(2024, 8, 4)},
+			mockError:      dberrors.NewDBError("timeout"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":500,"error":"DB Error: timeout"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			earnings := new(MockEarningsRepository)
+			if tt.expectedReq != nil {
+				earnings.On("Calendar", mock.Anything, *tt.expectedReq).Return(tt.mockResult, tt.mockError)
+			}
+
+			h := NewHandler(new(MockCompanies), earnings, nil, splits)
+			h.now = func() time.Time { return time.Date(2024, 7, 29, 15, 4, 5, 0, time.UTC) }
+
+			rr := httptest.NewRecorder()
+			h.CalendarHandler(rr, httptest.NewRequest("GET", tt.url, nil))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			earnings.AssertExpectations(t)
+		})
+	}
+	splits.AssertExpectations(t)
+}
@@ -0,0 +1,136 @@
+package earnings
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/api/v1/params"
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/api-moose/company-earnings/internal/utils/response"
+	"github.com/go-chi/chi/v5"
+)
+
+// Companies is the part of mongo.Repository the earnings handlers use
+type Companies interface {
+	GetBySymbol(ctx context.Context, symbol string) (*models.Company, error)
+}
+
+// Splits is the part of mongo.CorporateActionsRepository the earnings
+// handlers use
+type Splits interface {
+	ListSplits(ctx context.Context, symbol string) ([]models.Split, error)
+	ListSplitsBySymbols(ctx context.Context, symbols []string) (map[string][]models.Split, error)
+}
+
+type Handler struct {
+	companies Companies
+	earnings  mongo.EarningsRepository
+	revisions mongo.RevisionRepository
+	splits    Splits
+	// now is the clock the default calendar range starts from
+	now func() time.Time
+}
+
+func NewHandler(companies Companies, earnings mongo.EarningsRepository, revisions mongo.RevisionRepository, splits Splits) *Handler {
+	return &Handler{companies: companies, earnings: earnings, revisions: revisions, splits: splits, now: time.Now}
+}
+
+type historyResponse struct {
+	Symbol   string            `json:"symbol"`
+	Count    int               `json:"count"`
+	Earnings []models.Earnings `json:"earnings"`
+}
+
+// HistoryHandler serves GET /companies/{symbol}/earnings, returning every
+// reported quarter of the company, oldest period first. The surprise_pct_gt
+// and surprise_pct_lt parameters keep only beats or misses of that size, and
+// as_of returns the quarters and figures published by that date.
+// Per-share figures are in shares after the splits effective by as_of, or
+// today, so quarters either side of a split compare, unless split_adjusted is
+// false.
+func (h *Handler) HistoryHandler(w http.ResponseWriter, r *http.Request) {
+	p := params.NewParser(r)
+	filter := mongo.EarningsFilter{
+		Surprise: surpriseFilter(p),
+		AsOf:     p.OptionalDate("as_of"),
+	}
+	splitAdjusted := p.Bool("split_adjusted")
+	if err := p.Err(); err != nil {
+		response.ErrorResponse(w, err)
+		return
+	}
+
+	company, ok := h.company(w, r)
+	if !ok {
+		return
+	}
+
+	earnings, err := h.earnings.ListBySymbol(r.Context(), company.Symbol, filter)
+	if err != nil {
+		response.ErrorResponse(w, err)
+		return
+	}
+	if splitAdjusted == nil || *splitAdjusted {
+		splits, err := h.splits.ListSplits(r.Context(), company.Symbol)
+		if err != nil {
+			response.ErrorResponse(w, err)
+			return
+		}
+		splits = models.SplitsBy(splits, filter.AsOf)
+		for i := range earnings {
+			earnings[i].AdjustForSplits(splits)
+		}
+	}
+
+	response.JSONResponse(w, http.StatusOK, historyResponse{
+		Symbol:   company.Symbol,
+		Count:    len(earnings),
+		Earnings: earnings,
+	})
+}
+
+// surpriseFilter reads the surprise_pct_gt and surprise_pct_lt parameters
+func surpriseFilter(p *params.Parser) mongo.SurpriseFilter {
+	f := mongo.SurpriseFilter{
+		PctGT: p.Float("surprise_pct_gt"),
+		PctLT: p.Float("surprise_pct_lt"),
+	}
+	if f.PctGT != nil && f.PctLT != nil && *f.PctGT >= *f.PctLT {
+		p.Fail("surprise_pct_lt", "surprise_pct_lt must be greater than surprise_pct_gt")
+	}
+	return f
+}
+
+// company resolves the {symbol} path parameter, writing the error response
+// when the company does not exist so unknown symbols are told apart from
+// companies without earnings
+func (h *Handler) company(w http.ResponseWriter, r *http.Request) (*models.Company, bool) {
+	symbol := strings.TrimSpace(chi.URLParam(r, "symbol"))
+	if symbol == "" {
+		response.ErrorResponse(w, &response.ErrorMessage{
+			Status:    http.StatusBadRequest,
+			Message:   "symbol cannot be empty",
+			Parameter: "symbol",
+		})
+		return nil, false
+	}
+
+	company, err := h.companies.GetBySymbol(r.Context(), symbol)
+	if errors.Is(err, dberrors.ErrNotFound) {
+		response.ErrorResponse(w, &response.ErrorMessage{
+			Status:  http.StatusNotFound,
+			Message: "company not found",
+		})
+		return nil, false
+	}
+	if err != nil {
+		response.ErrorResponse(w, err)
+		return nil, false
+	}
+	return company, true
+}
@@ -0,0 +1,195 @@
+package earnings
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockCompanies struct {
+	mock.Mock
+}
+
+func (m *MockCompanies) GetBySymbol(ctx context.Context, symbol string) (*models.Company, error) {
+	args := m.Called(ctx, symbol)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.Company), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+type MockEarningsRepository struct {
+	mock.Mock
+}
+
+func (m *MockEarningsRepository) ListBySymbol(ctx context.Context, symbol string, filter mongo.EarningsFilter) ([]models.Earnings, error) {
+	args := m.Called(ctx, symbol, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.Earnings), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockEarningsRepository) Upsert(ctx context.Context, earnings models.Earnings, source string) (mongo.UpsertResult, error) {
+	args := m.Called(ctx, earnings, source)
+	return args.Get(0).(mongo.UpsertResult), args.Error(1)
+}
+
+func (m *MockEarningsRepository) Calendar(ctx context.Context, req mongo.CalendarRequest) ([]mongo.CalendarEvent, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) != nil {
+		return args.Get(0).([]mongo.CalendarEvent), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+type MockSplits struct {
+	mock.Mock
+}
+
+func (m *MockSplits) ListSplits(ctx context.Context, symbol string) ([]models.Split, error) {
+	args := m.Called(ctx, symbol)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.Split), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockSplits) ListSplitsBySymbols(ctx context.Context, symbols []string) (map[string][]models.Split, error) {
+	args := m.Called(ctx, symbols)
+	if args.Get(0) != nil {
+		return args.Get(0).(map[string][]models.Split), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func float64Ptr(f float64) *float64 {
+	return &f
+}
+
+func TestHistoryHandler(t *testing.T) {
+	apple := &models.Company{Symbol: "AAPL", CIK: "0000320193", SecurityName: "Apple Inc.", Active: true}
+	msft := &models.Company{Symbol: "MSFT", CIK: "0000789019", SecurityName: "Microsoft Corp", Active: true}
+	reported := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	q1 := models.Earnings{
+		Symbol: "AAPL", FiscalYear: 2024, FiscalQuarter: 1,
+		PeriodEnd: time.Date(2023, 12, 30, 0, 0, 0, 0, time.UTC), ReportDate: &reported, ReportTime: models.ReportTimeAfterClose,
+		EPSBasic: float64Ptr(2.19), EPSDiluted: float64Ptr(2.18), Revenue: float64Ptr(119575000000), NetIncome: float64Ptr(33916000000),
+		Currency:    "USD",
+		EPSEstimate: &models.EPSEstimate{Mean: 2.10, High: 2.22, Low: 1.95, NumAnalysts: 28},
+		EPSSurprise: float64Ptr(0.08), EPSSurprisePct: float64Ptr(3.81),
+		Version: 1, PublishedAt: &reported,
+	}
+	q2 := models.Earnings{
+		Symbol: "AAPL", FiscalYear: 2024, FiscalQuarter: 2,
+		PeriodEnd:  time.Date(2024, 3, 30, 0, 0, 0, 0, time.UTC),
+		ReportTime: models.ReportTimeNotAnnounced,
+		Currency:   "USD",
+	}
+
+	companies := new(MockCompanies)
+	companies.On("GetBySymbol", mock.Anything, "aapl").Return(apple, nil)
+	companies.On("GetBySymbol", mock.Anything, "MSFT").Return(msft, nil)
+	companies.On("GetBySymbol", mock.Anything, "ZZZZ").Return(nil, dberrors.ErrNotFound)
+	companies.On("GetBySymbol", mock.Anything, "GOOG").Return(nil, dberrors.NewDBError("timeout"))
+
+	earnings := new(MockEarningsRepository)
+	asOf := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	earnings.On("ListBySymbol", mock.Anything, "AAPL", mongo.EarningsFilter{}).Return([]models.Earnings{q1, q2}, nil)
+	earnings.On("ListBySymbol", mock.Anything, "AAPL", mongo.EarningsFilter{Surprise: mongo.SurpriseFilter{PctGT: float64Ptr(3), PctLT: float64Ptr(10)}}).Return([]models.Earnings{q1}, nil)
+	earnings.On("ListBySymbol", mock.Anything, "AAPL", mongo.EarningsFilter{AsOf: &asOf}).Return([]models.Earnings{q1}, nil)
+	earnings.On("ListBySymbol", mock.Anything, "MSFT", mongo.EarningsFilter{}).Return([]models.Earnings{}, nil)
+
+	// a 2-for-1 split after the first quarter was reported, and a 3-for-1
+	// split after the as_of date
+	splits := new(MockSplits)
+	splits.On("ListSplits", mock.Anything, "AAPL").Return([]models.Split{
+		{Symbol: "AAPL", Date: time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC), Numerator: 3, Denominator: 1},
+		{Symbol: "AAPL", Date: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), Numerator: 2, Denominator: 1},
+	}, nil)
+	splits.On("ListSplits", mock.Anything, "MSFT").Return(nil, dberrors.NewDBError("timeout"))
+
+	router := chi.NewRouter()
+	router.Get("/companies/{symbol}/earnings", NewHandler(companies, earnings, nil, splits).HistoryHandler)
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "As filed",
+			path:           "/companies/aapl/earnings?split_adjusted=false",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"symbol":"AAPL","count":2,"earnings":[
+				{"symbol":"AAPL","fiscalYear":2024,"fiscalQuarter":1,"periodEnd":"2023-12-30T00:00:00Z","reportDate":"2024-02-01T00:00:00Z","reportTime":"after-close","epsBasic":2.19,"epsDiluted":2.18,"revenue":119575000000,"netIncome":33916000000,"currency":"USD","epsEstimate":{"mean":2.1,"high":2.22,"low":1.95,"numAnalysts":28},"epsSurprise":0.08,"epsSurprisePct":3.81,"version":1,"publishedAt":"2024-02-01T00:00:00Z"},
+				{"symbol":"AAPL","fiscalYear":2024,"fiscalQuarter":2,"periodEnd":"2024-03-30T00:00:00Z","reportDate":null,"reportTime":"not-announced","epsBasic":null,"epsDiluted":null,"revenue":null,"netIncome":null,"currency":"USD","epsEstimate":null,"epsSurprise":null,"epsSurprisePct":null,"version":0,"publishedAt":null}
+			]}`,
+		},
+		{
+			name:           "Surprise range",
+			path:           "/companies/aapl/earnings?surprise_pct_gt=3&surprise_pct_lt=10&split_adjusted=false",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"symbol":"AAPL","count":1,"earnings":[
+				{"symbol":"AAPL","fiscalYear":2024,"fiscalQuarter":1,"periodEnd":"2023-12-30T00:00:00Z","reportDate":"2024-02-01T00:00:00Z","reportTime":"after-close","epsBasic":2.19,"epsDiluted":2.18,"revenue":119575000000,"netIncome":33916000000,"currency":"USD","epsEstimate":{"mean":2.1,"high":2.22,"low":1.95,"numAnalysts":28},"epsSurprise":0.08,"epsSurprisePct":3.81,"version":1,"publishedAt":"2024-02-01T00:00:00Z"}
+			]}`,
+		},
+		{
+			name:           "As of",
+			path:           "/companies/aapl/earnings?as_of=2024-03-31&split_adjusted=false",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"symbol":"AAPL","count":1,"earnings":[
+				{"symbol":"AAPL","fiscalYear":2024,"fiscalQuarter":1,"periodEnd":"2023-12-30T00:00:00Z","reportDate":"2024-02-01T00:00:00Z","reportTime":"after-close","epsBasic":2.19,"epsDiluted":2.18,"revenue":119575000000,"netIncome":33916000000,"currency":"USD","epsEstimate":{"mean":2.1,"high":2.22,"low":1.95,"numAnalysts":28},"epsSurprise":0.08,"epsSurprisePct":3.81,"version":1,"publishedAt":"2024-02-01T00:00:00Z"}
+			]}`,
+		},
+		{
+			name:           "Split adjusted by default",
+			path:           "/companies/aapl/earnings",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"symbol":"AAPL","count":2,"earnings":[
+				{"symbol":"AAPL","fiscalYear":2024,"fiscalQuarter":1,"periodEnd":"2023-12-30T00:00:00Z","reportDate":"2024-02-01T00:00:00Z","reportTime":"after-close","epsBasic":0.365,"epsDiluted":0.36333333333333334,"revenue":119575000000,"netIncome":33916000000,"currency":"USD","epsEstimate":{"mean":0.35000000000000003,"high":0.37000000000000005,"low":0.325,"numAnalysts":28},"epsSurprise":0.013333333333333334,"epsSurprisePct":3.81,"version":1,"publishedAt":"2024-02-01T00:00:00Z"},
+				{"symbol":"AAPL","fiscalYear":2024,"fiscalQuarter":2,"periodEnd":"2024-03-30T00:00:00Z","reportDate":null,"reportTime":"not-announced","epsBasic":null,"epsDiluted":null,"revenue":null,"netIncome":null,"currency":"USD","epsEstimate":null,"epsSurprise":null,"epsSurprisePct":null,"version":0,"publishedAt":null}
+			]}`,
+		},
+		{
+			name:           "Split adjusted as of",
+			path:           "/companies/aapl/earnings?as_of=2024-03-31",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"symbol":"AAPL","count":1,"earnings":[
+				{"symbol":"AAPL","fiscalYear":2024,"fiscalQuarter":1,"periodEnd":"2023-12-30T00:00:00Z","reportDate":"2024-02-01T00:00:00Z","reportTime":"after-close","epsBasic":1.095,"epsDiluted":1.09,"revenue":119575000000,"netIncome":33916000000,"currency":"USD","epsEstimate":{"mean":1.05,"high":1.11,"low":0.975,"numAnalysts":28},"epsSurprise":0.04,"epsSurprisePct":3.81,"version":1,"publishedAt":"2024-02-01T00:00:00Z"}
+			]}`,
+		},
+		{"Malformed split adjusted", "/companies/aapl/earnings?split_adjusted=yes", http.StatusBadRequest, `{"status":400,"error":"split_adjusted must be true or false","parameter":"split_adjusted"}`},
+		{"Split repository failure", "/companies/MSFT/earnings", http.StatusInternalServerError, `{"status":500,"error":"DB Error: timeout"}`},
+		{"Malformed as of", "/companies/aapl/earnings?as_of=yesterday", http.StatusBadRequest, `{"status":400,"error":"as_of must be a date formatted as YYYY-MM-DD","parameter":"as_of"}`},
+		{"Malformed surprise", "/companies/aapl/earnings?surprise_pct_gt=ten", http.StatusBadRequest, `{"status":400,"error":"surprise_pct_gt must be a number","parameter":"surprise_pct_gt"}`},
+		{"Empty surprise range", "/companies/aapl/earnings?surprise_pct_gt=10&surprise_pct_lt=-10", http.StatusBadRequest, `{"status":400,"error":"surprise_pct_lt must be greater than surprise_pct_gt","parameter":"surprise_pct_lt"}`},
+		{"No earnings", "/companies/MSFT/earnings?split_adjusted=false", http.StatusOK, `{"symbol":"MSFT","count":0,"earnings":[]}`},
+		{"Unknown company", "/companies/ZZZZ/earnings", http.StatusNotFound, `{"status":404,"error":"company not found"}`},
+		{"Repository failure", "/companies/GOOG/earnings", http.StatusInternalServerError, `{"status":500,"error":"DB Error: timeout"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", tt.path, nil))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+
+	companies.AssertExpectations(t)
+	earnings.AssertExpectations(t)
+	splits.AssertExpectations(t)
+}
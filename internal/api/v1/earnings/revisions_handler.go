@@ -0,0 +1,55 @@
+package earnings
+
+import (
+	"net/http"
+
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/api-moose/company-earnings/internal/utils/response"
+	"github.com/go-chi/chi/v5"
+)
+
+type revisionsResponse struct {
+	Symbol        string            `json:"symbol"`
+	FiscalYear    int               `json:"fiscalYear"`
+	FiscalQuarter int               `json:"fiscalQuarter"`
+	Count         int               `json:"count"`
+	Revisions     []models.Revision `json:"revisions"`
+}
+
+// RevisionsHandler serves GET /companies/{symbol}/earnings/{period}/revisions,
+// returning every recorded change to the figures and estimates of a fiscal
+// quarter, such as 2024Q1, oldest first
+func (h *Handler) RevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	fiscalYear, fiscalPeriod, err := models.ParsePeriod(chi.URLParam(r, "period"))
+	if err == nil && fiscalPeriod == models.FiscalPeriodFY {
+		err = models.ErrInvalidPeriod
+	}
+	if err != nil {
+		response.ErrorResponse(w, &response.ErrorMessage{
+			Status:    http.StatusBadRequest,
+			Message:   "period must be a fiscal year and quarter such as 2024Q1",
+			Parameter: "period",
+		})
+		return
+	}
+	fiscalQuarter := int(fiscalPeriod[1] - '0')
+
+	company, ok := h.company(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := h.revisions.ListEarnings(r.Context(), company.Symbol, fiscalYear, fiscalQuarter)
+	if err != nil {
+		response.ErrorResponse(w, err)
+		return
+	}
+
+	response.JSONResponse(w, http.StatusOK, revisionsResponse{
+		Symbol:        company.Symbol,
+		FiscalYear:    fiscalYear,
+		FiscalQuarter: fiscalQuarter,
+		Count:         len(revisions),
+		Revisions:     revisions,
+	})
+}
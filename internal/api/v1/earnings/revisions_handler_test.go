@@ -0,0 +1,92 @@
+package earnings
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRevisionRepository struct {
+	mock.Mock
+}
+
+func (m *MockRevisionRepository) ListEarnings(ctx context.Context, symbol string, fiscalYear, fiscalQuarter int) ([]models.Revision, error) {
+	args := m.Called(ctx, symbol, fiscalYear, fiscalQuarter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.Revision), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRevisionRepository) ListFinancials(ctx context.Context, cik string, fiscalYear int, fiscalPeriod string) ([]models.Revision, error) {
+	args := m.Called(ctx, cik, fiscalYear, fiscalPeriod)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.Revision), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func TestRevisionsHandler(t *testing.T) {
+	apple := &models.Company{Symbol: "AAPL", CIK: "0000320193", SecurityName: "Apple Inc.", Active: true}
+	restatement := models.Revision{
+		Record: models.RecordEarnings, Symbol: "AAPL", FiscalYear: 2024, FiscalPeriod: "Q1",
+		Source: "restatement-feed", RecordedAt: time.Date(2024, 3, 15, 9, 30, 0, 0, time.UTC),
+		Changes: []models.FieldChange{{Field: "netIncome", Old: float64Ptr(33916000000), New: float64Ptr(33900000000)}},
+	}
+
+	companies := new(MockCompanies)
+	companies.On("GetBySymbol", mock.Anything, "aapl").Return(apple, nil)
+	companies.On("GetBySymbol", mock.Anything, "ZZZZ").Return(nil, dberrors.ErrNotFound)
+
+	revisions := new(MockRevisionRepository)
+	revisions.On("ListEarnings", mock.Anything, "AAPL", 2024, 1).Return([]models.Revision{restatement}, nil)
+	revisions.On("ListEarnings", mock.Anything, "AAPL", 2019, 4).Return([]models.Revision{}, nil)
+	revisions.On("ListEarnings", mock.Anything, "AAPL", 2020, 1).Return(nil, dberrors.NewDBError("timeout"))
+
+	router := chi.NewRouter()
+	router.Get("/companies/{symbol}/earnings/{period}/revisions", NewHandler(companies, new(MockEarningsRepository), revisions, nil).RevisionsHandler)
+
+	invalidPeriod := `{"status":400,"error":"period must be a fiscal year and quarter such as 2024Q1","parameter":"period"}`
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Restated quarter",
+			path:           "/companies/aapl/earnings/2024q1/revisions",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"symbol":"AAPL","fiscalYear":2024,"fiscalQuarter":1,"count":1,"revisions":[{
+				"record":"earnings","symbol":"AAPL","fiscalYear":2024,"fiscalPeriod":"Q1",
+				"source":"restatement-feed","recordedAt":"2024-03-15T09:30:00Z",
+				"changes":[{"field":"netIncome","old":33916000000,"new":33900000000}]}]}`,
+		},
+		{"No revisions", "/companies/aapl/earnings/2019Q4/revisions", http.StatusOK, `{"symbol":"AAPL","fiscalYear":2019,"fiscalQuarter":4,"count":0,"revisions":[]}`},
+		{"Fiscal year", "/companies/aapl/earnings/2023FY/revisions", http.StatusBadRequest, invalidPeriod},
+		{"Malformed period", "/companies/aapl/earnings/Q1-2024/revisions", http.StatusBadRequest, invalidPeriod},
+		{"Unknown company", "/companies/ZZZZ/earnings/2024Q1/revisions", http.StatusNotFound, `{"status":404,"error":"company not found"}`},
+		{"Repository failure", "/companies/aapl/earnings/2020Q1/revisions", http.StatusInternalServerError, `{"status":500,"error":"DB Error: timeout"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", tt.path, nil))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+
+	companies.AssertExpectations(t)
+	revisions.AssertExpectations(t)
+}
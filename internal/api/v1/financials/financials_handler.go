@@ -0,0 +1,199 @@
+package financials
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/api/v1/params"
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/api-moose/company-earnings/internal/utils/response"
+	"github.com/go-chi/chi/v5"
+)
+
+// Bounds for the statement parameters documented in docs/api/swagger.yaml
+const (
+	defaultLimit = 8
+	maxLimit     = 40
+)
+
+// Reporting periods accepted by the period parameter
+const (
+	periodQuarterly = "quarterly"
+	periodAnnual    = "annual"
+)
+
+// quarters are the fiscal periods of quarterly statements
+var quarters = []string{models.FiscalPeriodQ1, models.FiscalPeriodQ2, models.FiscalPeriodQ3, models.FiscalPeriodQ4}
+
+// Companies is the part of mongo.Repository the financials handlers use
+type Companies interface {
+	GetBySymbol(ctx context.Context, symbol string) (*models.Company, error)
+}
+
+// Splits is the part of mongo.CorporateActionsRepository the financials
+// handlers use
+type Splits interface {
+	ListSplits(ctx context.Context, symbol string) ([]models.Split, error)
+}
+
+type Handler struct {
+	companies  Companies
+	statements mongo.StatementsRepository
+	revisions  mongo.RevisionRepository
+	splits     Splits
+}
+
+func NewHandler(companies Companies, statements mongo.StatementsRepository, revisions mongo.RevisionRepository, splits Splits) *Handler {
+	return &Handler{companies: companies, statements: statements, revisions: revisions, splits: splits}
+}
+
+// statementPeriod is one fiscal period of a statement. LineItems holds every
+// line item of the statement, null when it was not reported, so the shape of
+// a statement does not depend on what a company happens to file.
+type statementPeriod struct {
+	FiscalYear   int                 `json:"fiscalYear"`
+	FiscalPeriod string              `json:"fiscalPeriod"`
+	PeriodStart  *time.Time          `json:"periodStart"`
+	PeriodEnd    time.Time           `json:"periodEnd"`
+	Currency     string              `json:"currency"`
+	LineItems    map[string]*float64 `json:"lineItems"`
+}
+
+type statementResponse struct {
+	Symbol    string            `json:"symbol"`
+	CIK       string            `json:"cik"`
+	Statement string            `json:"statement"`
+	Period    string            `json:"period"`
+	Count     int               `json:"count"`
+	Periods   []statementPeriod `json:"periods"`
+}
+
+// StatementHandler serves GET /companies/{symbol}/financials/{statement},
+// returning the most recent quarterly or annual periods of the income
+// statement, balance sheet or cash flow statement of a company. Statements
+// are joined to the company through its CIK. With as_of only the values filed
+// by that date are returned. Per-share values and share counts are in shares
+// after the splits effective by as_of, or today, unless split_adjusted is
+// false.
+func (h *Handler) StatementHandler(w http.ResponseWriter, r *http.Request) {
+	statement := strings.ToLower(chi.URLParam(r, "statement"))
+	lineItems := models.StatementLineItems(statement)
+
+	p := params.NewParser(r)
+	if len(lineItems) == 0 {
+		p.Fail("statement", "statement must be one of %s", strings.Join(models.Statements, ", "))
+	}
+	period := p.Enum("period", periodQuarterly, periodQuarterly, periodAnnual)
+	limit := p.Int("limit", defaultLimit, 1, maxLimit)
+	asOf := p.OptionalDate("as_of")
+	splitAdjusted := splitAdjusted(p)
+	if err := p.Err(); err != nil {
+		response.ErrorResponse(w, err)
+		return
+	}
+
+	company, ok := h.company(w, r)
+	if !ok {
+		return
+	}
+
+	filter := mongo.FinancialsFilter{FiscalPeriods: quarters, Limit: limit, AsOf: asOf}
+	if period == periodAnnual {
+		filter.FiscalPeriods = []string{models.FiscalPeriodFY}
+	}
+	financials, err := h.statements.ListByCIK(r.Context(), company.CIK, filter)
+	if err != nil {
+		response.ErrorResponse(w, err)
+		return
+	}
+	if splitAdjusted {
+		if err := h.adjustForSplits(r.Context(), company, financials, asOf); err != nil {
+			response.ErrorResponse(w, err)
+			return
+		}
+	}
+
+	resp := statementResponse{
+		Symbol:    company.Symbol,
+		CIK:       company.CIK,
+		Statement: statement,
+		Period:    period,
+		Count:     len(financials),
+		Periods:   make([]statementPeriod, len(financials)),
+	}
+	for i, f := range financials {
+		values := make(map[string]*float64, len(lineItems))
+		for _, def := range lineItems {
+			values[def.Name] = f.Value(def.Name)
+		}
+		resp.Periods[i] = statementPeriod{
+			FiscalYear:   f.FiscalYear,
+			FiscalPeriod: f.FiscalPeriod,
+			PeriodStart:  f.PeriodStart,
+			PeriodEnd:    f.PeriodEnd,
+			Currency:     f.Currency,
+			LineItems:    values,
+		}
+		if statement == models.StatementBalance {
+			// balance sheet items are values at the period end
+			resp.Periods[i].PeriodStart = nil
+		}
+	}
+
+	response.JSONResponse(w, http.StatusOK, resp)
+}
+
+// company resolves the {symbol} path parameter, writing the error response
+// when the company does not exist
+func (h *Handler) company(w http.ResponseWriter, r *http.Request) (*models.Company, bool) {
+	symbol := strings.TrimSpace(chi.URLParam(r, "symbol"))
+	if symbol == "" {
+		response.ErrorResponse(w, &response.ErrorMessage{
+			Status:    http.StatusBadRequest,
+			Message:   "symbol cannot be empty",
+			Parameter: "symbol",
+		})
+		return nil, false
+	}
+
+	company, err := h.companies.GetBySymbol(r.Context(), symbol)
+	if errors.Is(err, dberrors.ErrNotFound) {
+		response.ErrorResponse(w, &response.ErrorMessage{
+			Status:  http.StatusNotFound,
+			Message: "company not found",
+		})
+		return nil, false
+	}
+	if err != nil {
+		response.ErrorResponse(w, err)
+		return nil, false
+	}
+	return company, true
+}
+
+// splitAdjusted reads the split_adjusted parameter, true when absent
+func splitAdjusted(p *params.Parser) bool {
+	adjusted := p.Bool("split_adjusted")
+	return adjusted == nil || *adjusted
+}
+
+// adjustForSplits restates the per-share values and share counts of
+// financials in shares after the splits of the company effective by asOf, or
+// today, so that per-share figures either side of a split compare and match
+// the price they are valued at
+func (h *Handler) adjustForSplits(ctx context.Context, company *models.Company, financials []models.Financials, asOf *time.Time) error {
+	splits, err := h.splits.ListSplits(ctx, company.Symbol)
+	if err != nil {
+		return err
+	}
+	splits = models.SplitsBy(splits, asOf)
+	for i := range financials {
+		financials[i].AdjustForSplits(splits)
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package financials
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockCompanies struct {
+	mock.Mock
+}
+
+func (m *MockCompanies) GetBySymbol(ctx context.Context, symbol string) (*models.Company, error) {
+	args := m.Called(ctx, symbol)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.Company), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+type MockStatementsRepository struct {
+	mock.Mock
+}
+
+func (m *MockStatementsRepository) ListByCIK(ctx context.Context, cik string, filter mongo.FinancialsFilter) ([]models.Financials, error) {
+	args := m.Called(ctx, cik, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.Financials), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockStatementsRepository) ListByCIKs(ctx context.Context, ciks []string, filter mongo.FinancialsFilter) (map[string][]models.Financials, error) {
+	args := m.Called(ctx, ciks, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).(map[string][]models.Financials), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockStatementsRepository) Upsert(ctx context.Context, financials models.Financials, source string) (mongo.UpsertResult, error) {
+	args := m.Called(ctx, financials, source)
+	return args.Get(0).(mongo.UpsertResult), args.Error(1)
+}
+
+type MockSplits struct {
+	mock.Mock
+}
+
+func (m *MockSplits) ListSplits(ctx context.Context, symbol string) ([]models.Split, error) {
+	args := m.Called(ctx, symbol)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.Split), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestStatementHandler(t *testing.T) {
+	apple := &models.Company{Symbol: "AAPL", CIK: "0000320193", SecurityName: "Apple Inc.", Active: true}
+	start := date(2023, 10, 1)
+	q1 := models.Financials{
+		CIK: "0000320193", FiscalYear: 2024, FiscalPeriod: models.FiscalPeriodQ1,
+		PeriodStart: &start, PeriodEnd: date(2023, 12, 30), Currency: "USD",
+		Items: map[string]models.LineItem{
+			models.LineItemRevenue:    {Value: 119575000000, Concept: "Revenues"},
+			models.LineItemNetIncome:  {Value: 33916000000, Concept: "NetIncomeLoss"},
+			models.LineItemAssets:     {Value: 353514000000, Concept: "Assets"},
+			models.LineItemEPSDiluted: {Value: 2.18, Concept: "EarningsPerShareDiluted", Filed: date(2024, 2, 2)},
+		},
+	}
+	fyStart := date(2022, 9, 25)
+	fy := models.Financials{
+		CIK: "0000320193", FiscalYear: 2023, FiscalPeriod: models.FiscalPeriodFY,
+		PeriodStart: &fyStart, PeriodEnd: date(2023, 9, 30), Currency: "USD",
+		Items: map[string]models.LineItem{
+			models.LineItemOperatingCashFlow: {Value: 110543000000, Concept: "NetCashProvidedByUsedInOperatingActivities"},
+		},
+	}
+	quarterly := mongo.FinancialsFilter{FiscalPeriods: []string{"Q1", "Q2", "Q3", "Q4"}, Limit: 8}
+
+	companies := new(MockCompanies)
+	companies.On("GetBySymbol", mock.Anything, "aapl").Return(apple, nil)
+	companies.On("GetBySymbol", mock.Anything, "ZZZZ").Return(nil, dberrors.ErrNotFound)
+
+	statements := new(MockStatementsRepository)
+	for i := 0; i < 3; i++ {
+		// adjusting for splits replaces the items of the periods returned
+		statements.On("ListByCIK", mock.Anything, "0000320193", quarterly).Return([]models.Financials{q1}, nil).Once()
+	}
+	statements.On("ListByCIK", mock.Anything, "0000320193", mongo.FinancialsFilter{FiscalPeriods: []string{"FY"}, Limit: 1}).Return([]models.Financials{fy}, nil)
+	asOf := date(2023, 12, 31)
+	statements.On("ListByCIK", mock.Anything, "0000320193", mongo.FinancialsFilter{FiscalPeriods: []string{"FY"}, Limit: 1, AsOf: &asOf}).Return([]models.Financials{}, nil)
+	statements.On("ListByCIK", mock.Anything, "0000320193", mongo.FinancialsFilter{FiscalPeriods: []string{"Q1", "Q2", "Q3", "Q4"}, Limit: 40}).Return(nil, dberrors.NewDBError("timeout"))
+
+	// a 2-for-1 split after the first quarter was filed
+	splits := new(MockSplits)
+	splits.On("ListSplits", mock.Anything, "AAPL").Return([]models.Split{
+		{Symbol: "AAPL", Date: date(2024, 3, 4), Numerator: 2, Denominator: 1},
+	}, nil)
+
+	router := chi.NewRouter()
+	router.Get("/companies/{symbol}/financials/{statement}", NewHandler(companies, statements, nil, splits).StatementHandler)
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Income statement",
+			path:           "/companies/aapl/financials/income",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"symbol":"AAPL","cik":"0000320193","statement":"income","period":"quarterly","count":1,"periods":[
+				{"fiscalYear":2024,"fiscalPeriod":"Q1","periodStart":"2023-10-01T00:00:00Z","periodEnd":"2023-12-30T00:00:00Z","currency":"USD","lineItems":{
+					"revenue":119575000000,"costOfRevenue":null,"grossProfit":null,"operatingExpenses":null,"operatingIncome":null,
+					"incomeTaxExpense":null,"netIncome":33916000000,"epsBasic":null,"epsDiluted":1.09,"sharesDiluted":null
+				}}
+			]}`,
+		},
+		{
+			name:           "Income statement as filed",
+			path:           "/companies/aapl/financials/income?split_adjusted=false",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"symbol":"AAPL","cik":"0000320193","statement":"income","period":"quarterly","count":1,"periods":[
+				{"fiscalYear":2024,"fiscalPeriod":"Q1","periodStart":"2023-10-01T00:00:00Z","periodEnd":"2023-12-30T00:00:00Z","currency":"USD","lineItems":{
+					"revenue":119575000000,"costOfRevenue":null,"grossProfit":null,"operatingExpenses":null,"operatingIncome":null,
+					"incomeTaxExpense":null,"netIncome":33916000000,"epsBasic":null,"epsDiluted":2.18,"sharesDiluted":null
+				}}
+			]}`,
+		},
+		{
+			name:           "Malformed split adjusted",
+			path:           "/companies/aapl/financials/income?split_adjusted=no",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"error":"split_adjusted must be true or false","parameter":"split_adjusted"}`,
+		},
+		{
+			name:           "Balance sheet",
+			path:           "/companies/aapl/financials/Balance",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"symbol":"AAPL","cik":"0000320193","statement":"balance","period":"quarterly","count":1,"periods":[
+				{"fiscalYear":2024,"fiscalPeriod":"Q1","periodStart":null,"periodEnd":"2023-12-30T00:00:00Z","currency":"USD","lineItems":{
+					"cash":null,"currentAssets":null,"assets":353514000000,"currentLiabilities":null,"liabilities":null,
+					"longTermDebt":null,"stockholdersEquity":null
+				}}
+			]}`,
+		},
+		{
+			name:           "Annual cash flow",
+			path:           "/companies/aapl/financials/cashflow?period=annual&limit=1",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"symbol":"AAPL","cik":"0000320193","statement":"cashflow","period":"annual","count":1,"periods":[
+				{"fiscalYear":2023,"fiscalPeriod":"FY","periodStart":"2022-09-25T00:00:00Z","periodEnd":"2023-09-30T00:00:00Z","currency":"USD","lineItems":{
+					"operatingCashFlow":110543000000,"investingCashFlow":null,"financingCashFlow":null,"capitalExpenditure":null,
+					"depreciationAmortization":null,"dividendsPaid":null
+				}}
+			]}`,
+		},
+		{
+			name:           "As of",
+			path:           "/companies/aapl/financials/cashflow?period=annual&limit=1&as_of=2023-12-31",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"symbol":"AAPL","cik":"0000320193","statement":"cashflow","period":"annual","count":0,"periods":[]}`,
+		},
+		{
+			name:           "Malformed as of",
+			path:           "/companies/aapl/financials/income?as_of=2023-13-01",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"error":"as_of must be a date formatted as YYYY-MM-DD","parameter":"as_of"}`,
+		},
+		{
+			name:           "Unknown statement",
+			path:           "/companies/aapl/financials/equity",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"error":"statement must be one of income, balance, cashflow","parameter":"statement"}`,
+		},
+		{
+			name:           "Unknown period",
+			path:           "/companies/aapl/financials/income?period=monthly",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"error":"period must be one of quarterly, annual","parameter":"period"}`,
+		},
+		{
+			name:           "Limit out of range",
+			path:           "/companies/aapl/financials/income?limit=41",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"error":"limit must be an integer between 1 and 40","parameter":"limit"}`,
+		},
+		{
+			name:           "Unknown company",
+			path:           "/companies/ZZZZ/financials/income",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":404,"error":"company not found"}`,
+		},
+		{
+			name:           "Repository failure",
+			path:           "/companies/aapl/financials/income?limit=40",
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":500,"error":"DB Error: timeout"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", tt.path, nil))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+
+	companies.AssertExpectations(t)
+	statements.AssertExpectations(t)
+	splits.AssertExpectations(t)
+}
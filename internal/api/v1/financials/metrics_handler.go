@@ -0,0 +1,72 @@
+package financials
+
+import (
+	"net/http"
+
+	"github.com/api-moose/company-earnings/internal/api/v1/params"
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/metrics"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/api-moose/company-earnings/internal/utils/response"
+)
+
+// metricsLookbackYears is how many fiscal years are loaded beyond those the
+// returned quarters fill: one for a partly reported current year and two for
+// the trailing twelve months a year before the oldest quarter
+const metricsLookbackYears = 3
+
+type metricsResponse struct {
+	Symbol   string            `json:"symbol"`
+	CIK      string            `json:"cik"`
+	Count    int               `json:"count"`
+	Quarters []metrics.Quarter `json:"quarters"`
+}
+
+// MetricsHandler serves GET /companies/{symbol}/metrics, returning the
+// trailing-twelve-month figures and growth rates of the most recent fiscal
+// quarters of a company, computed from the values filed by as_of when set.
+// Per-share values and share counts are in shares after the splits effective
+// by as_of, or today, unless split_adjusted is false.
+func (h *Handler) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	p := params.NewParser(r)
+	limit := p.Int("limit", defaultLimit, 1, maxLimit)
+	asOf := p.OptionalDate("as_of")
+	splitAdjusted := splitAdjusted(p)
+	if err := p.Err(); err != nil {
+		response.ErrorResponse(w, err)
+		return
+	}
+
+	company, ok := h.company(w, r)
+	if !ok {
+		return
+	}
+
+	years := (limit+3)/4 + metricsLookbackYears
+	financials, err := h.statements.ListByCIK(r.Context(), company.CIK, mongo.FinancialsFilter{
+		Limit: years * len(models.FiscalPeriods),
+		AsOf:  asOf,
+	})
+	if err != nil {
+		response.ErrorResponse(w, err)
+		return
+	}
+
+	if splitAdjusted {
+		if err := h.adjustForSplits(r.Context(), company, financials, asOf); err != nil {
+			response.ErrorResponse(w, err)
+			return
+		}
+	}
+
+	quarters := metrics.Compute(financials)
+	if len(quarters) > limit {
+		quarters = quarters[:limit]
+	}
+	response.JSONResponse(w, http.StatusOK, metricsResponse{
+		Symbol:   company.Symbol,
+		CIK:      company.CIK,
+		Count:    len(quarters),
+		Quarters: quarters,
+	})
+}
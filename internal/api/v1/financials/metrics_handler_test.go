@@ -0,0 +1,161 @@
+package financials
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	apple := &models.Company{Symbol: "AAPL", CIK: "0000320193", SecurityName: "Apple Inc.", Active: true}
+	quarter := func(fiscalYear int, fiscalPeriod string, start, end time.Time, revenue float64) models.Financials {
+		return models.Financials{
+			CIK: "0000320193", FiscalYear: fiscalYear, FiscalPeriod: fiscalPeriod, PeriodStart: &start, PeriodEnd: end, Currency: "USD",
+			Items: map[string]models.LineItem{models.LineItemRevenue: {Value: revenue}},
+		}
+	}
+	financials := []models.Financials{
+		quarter(2024, "Q1", date(2023, 10, 1), date(2023, 12, 30), 119575000000),
+		quarter(2023, "FY", date(2022, 9, 25), date(2023, 9, 30), 383285000000),
+		quarter(2023, "Q3", date(2023, 4, 2), date(2023, 7, 1), 81797000000),
+		quarter(2023, "Q2", date(2023, 1, 1), date(2023, 4, 1), 94836000000),
+		quarter(2023, "Q1", date(2022, 9, 25), date(2022, 12, 31), 117154000000),
+	}
+
+	// Microsoft's first quarter was filed before a split, in half as many
+	// shares as the second
+	msft := &models.Company{Symbol: "MSFT", CIK: "0000789019", SecurityName: "Microsoft Corporation", Active: true}
+	eps := func(fiscalPeriod string, start, end, filed time.Time, eps float64) models.Financials {
+		return models.Financials{
+			CIK: "0000789019", FiscalYear: 2024, FiscalPeriod: fiscalPeriod, PeriodStart: &start, PeriodEnd: end, Currency: "USD", Filed: filed,
+			Items: map[string]models.LineItem{models.LineItemEPSDiluted: {Value: eps, Filed: filed}},
+		}
+	}
+	split := func() []models.Financials {
+		return []models.Financials{
+			eps("Q2", date(2023, 10, 1), date(2023, 12, 31), date(2024, 1, 30), 2.5),
+			eps("Q1", date(2023, 7, 1), date(2023, 9, 30), date(2023, 10, 24), 4),
+		}
+	}
+	ibm := &models.Company{Symbol: "IBM", CIK: "0000051143", SecurityName: "International Business Machines", Active: true}
+
+	companies := new(MockCompanies)
+	companies.On("GetBySymbol", mock.Anything, "aapl").Return(apple, nil)
+	companies.On("GetBySymbol", mock.Anything, "msft").Return(msft, nil)
+	companies.On("GetBySymbol", mock.Anything, "ibm").Return(ibm, nil)
+	companies.On("GetBySymbol", mock.Anything, "ZZZZ").Return(nil, dberrors.ErrNotFound)
+
+	statements := new(MockStatementsRepository)
+	statements.On("ListByCIK", mock.Anything, "0000320193", mongo.FinancialsFilter{Limit: 20}).Return(financials, nil)
+	statements.On("ListByCIK", mock.Anything, "0000320193", mongo.FinancialsFilter{Limit: 25}).Return(nil, dberrors.NewDBError("timeout"))
+	asOf := date(2023, 1, 31)
+	statements.On("ListByCIK", mock.Anything, "0000320193", mongo.FinancialsFilter{Limit: 20, AsOf: &asOf}).Return(financials[4:], nil)
+	statements.On("ListByCIK", mock.Anything, "0000789019", mongo.FinancialsFilter{Limit: 20}).Return(split(), nil).Once()
+	statements.On("ListByCIK", mock.Anything, "0000789019", mongo.FinancialsFilter{Limit: 20}).Return(split(), nil).Once()
+	statements.On("ListByCIK", mock.Anything, "0000051143", mongo.FinancialsFilter{Limit: 20}).Return([]models.Financials{}, nil)
+
+	splits := new(MockSplits)
+	splits.On("ListSplits", mock.Anything, "AAPL").Return([]models.Split{}, nil)
+	splits.On("ListSplits", mock.Anything, "MSFT").Return([]models.Split{{Symbol: "MSFT", Date: date(2023, 12, 1), Numerator: 2, Denominator: 1}}, nil).Once()
+	splits.On("ListSplits", mock.Anything, "IBM").Return(nil, dberrors.NewDBError("timeout"))
+
+	router := chi.NewRouter()
+	router.Get("/companies/{symbol}/metrics", NewHandler(companies, statements, nil, splits).MetricsHandler)
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Latest quarters",
+			path:           "/companies/aapl/metrics?limit=2",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"symbol":"AAPL","cik":"0000320193","count":2,"quarters":[
+				{"fiscalYear":2024,"fiscalQuarter":1,"periodStart":"2023-10-01T00:00:00Z","periodEnd":"2023-12-30T00:00:00Z","days":91,"derived":false,"metrics":{
+					"revenue":{"value":119575000000,"ttm":385706000000,"qoq":0.33606337571789313,"yoy":0.02066510746538744,"ttmYoY":null},
+					"netIncome":{"value":null,"ttm":null,"qoq":null,"yoy":null,"ttmYoY":null},
+					"epsDiluted":{"value":null,"ttm":null,"qoq":null,"yoy":null,"ttmYoY":null}
+				}},
+				{"fiscalYear":2023,"fiscalQuarter":4,"periodStart":"2023-07-02T00:00:00Z","periodEnd":"2023-09-30T00:00:00Z","days":91,"derived":true,"metrics":{
+					"revenue":{"value":89498000000,"ttm":383285000000,"qoq":0.0941477071286233,"yoy":null,"ttmYoY":null},
+					"netIncome":{"value":null,"ttm":null,"qoq":null,"yoy":null,"ttmYoY":null},
+					"epsDiluted":{"value":null,"ttm":null,"qoq":null,"yoy":null,"ttmYoY":null}
+				}}
+			]}`,
+		},
+		{
+			name:           "As of",
+			path:           "/companies/aapl/metrics?limit=1&as_of=2023-01-31",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"symbol":"AAPL","cik":"0000320193","count":1,"quarters":[
+				{"fiscalYear":2023,"fiscalQuarter":1,"periodStart":"2022-09-25T00:00:00Z","periodEnd":"2022-12-31T00:00:00Z","days":98,"derived":false,"metrics":{
+					"revenue":{"value":117154000000,"ttm":null,"qoq":null,"yoy":null,"ttmYoY":null},
+					"netIncome":{"value":null,"ttm":null,"qoq":null,"yoy":null,"ttmYoY":null},
+					"epsDiluted":{"value":null,"ttm":null,"qoq":null,"yoy":null,"ttmYoY":null}
+				}}
+			]}`,
+		},
+		{
+			name:           "Split adjusted",
+			path:           "/companies/msft/metrics?limit=2",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"symbol":"MSFT","cik":"0000789019","count":2,"quarters":[
+				{"fiscalYear":2024,"fiscalQuarter":2,"periodStart":"2023-10-01T00:00:00Z","periodEnd":"2023-12-31T00:00:00Z","days":92,"derived":false,"metrics":{
+					"revenue":{"value":null,"ttm":null,"qoq":null,"yoy":null,"ttmYoY":null},
+					"netIncome":{"value":null,"ttm":null,"qoq":null,"yoy":null,"ttmYoY":null},
+					"epsDiluted":{"value":2.5,"ttm":null,"qoq":0.25,"yoy":null,"ttmYoY":null}
+				}},
+				{"fiscalYear":2024,"fiscalQuarter":1,"periodStart":"2023-07-01T00:00:00Z","periodEnd":"2023-09-30T00:00:00Z","days":92,"derived":false,"metrics":{
+					"revenue":{"value":null,"ttm":null,"qoq":null,"yoy":null,"ttmYoY":null},
+					"netIncome":{"value":null,"ttm":null,"qoq":null,"yoy":null,"ttmYoY":null},
+					"epsDiluted":{"value":2,"ttm":null,"qoq":null,"yoy":null,"ttmYoY":null}
+				}}
+			]}`,
+		},
+		{
+			name:           "As filed",
+			path:           "/companies/msft/metrics?limit=2&split_adjusted=false",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"symbol":"MSFT","cik":"0000789019","count":2,"quarters":[
+				{"fiscalYear":2024,"fiscalQuarter":2,"periodStart":"2023-10-01T00:00:00Z","periodEnd":"2023-12-31T00:00:00Z","days":92,"derived":false,"metrics":{
+					"revenue":{"value":null,"ttm":null,"qoq":null,"yoy":null,"ttmYoY":null},
+					"netIncome":{"value":null,"ttm":null,"qoq":null,"yoy":null,"ttmYoY":null},
+					"epsDiluted":{"value":2.5,"ttm":null,"qoq":-0.375,"yoy":null,"ttmYoY":null}
+				}},
+				{"fiscalYear":2024,"fiscalQuarter":1,"periodStart":"2023-07-01T00:00:00Z","periodEnd":"2023-09-30T00:00:00Z","days":92,"derived":false,"metrics":{
+					"revenue":{"value":null,"ttm":null,"qoq":null,"yoy":null,"ttmYoY":null},
+					"netIncome":{"value":null,"ttm":null,"qoq":null,"yoy":null,"ttmYoY":null},
+					"epsDiluted":{"value":4,"ttm":null,"qoq":null,"yoy":null,"ttmYoY":null}
+				}}
+			]}`,
+		},
+		{"Splits failure", "/companies/ibm/metrics?limit=2", http.StatusInternalServerError, `{"status":500,"error":"DB Error: timeout"}`},
+		{"Limit out of range", "/companies/aapl/metrics?limit=0", http.StatusBadRequest, `{"status":400,"error":"limit must be an integer between 1 and 40","parameter":"limit"}`},
+		{"Unknown company", "/companies/ZZZZ/metrics", http.StatusNotFound, `{"status":404,"error":"company not found"}`},
+		{"Repository failure", "/companies/aapl/metrics?limit=8", http.StatusInternalServerError, `{"status":500,"error":"DB Error: timeout"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", tt.path, nil))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+
+	companies.AssertExpectations(t)
+	statements.AssertExpectations(t)
+	splits.AssertExpectations(t)
+}
@@ -0,0 +1,87 @@
+package financials
+
+import (
+	"net/http"
+
+	"github.com/api-moose/company-earnings/internal/api/v1/params"
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/metrics"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/api-moose/company-earnings/internal/utils/response"
+)
+
+type ratiosResponse struct {
+	Symbol string `json:"symbol"`
+	CIK    string `json:"cik"`
+	Period string `json:"period"`
+	// Price is the share price the latest period is valued at, null when
+	// neither the request nor the company has one
+	Price   *float64               `json:"price"`
+	Count   int                    `json:"count"`
+	Periods []metrics.PeriodRatios `json:"periods"`
+}
+
+// RatiosHandler serves GET /companies/{symbol}/ratios, returning the margins,
+// returns, liquidity and leverage ratios of the most recent quarterly or
+// annual periods of a company. The valuation ratios of the latest period use
+// the price parameter, or the stored price of the company when it is absent.
+// With as_of the ratios use the values filed by that date, and the stored
+// price, which is current, is not used. Earnings per share are in shares
+// after the splits effective by as_of, or today, like the price, unless
+// split_adjusted is false.
+func (h *Handler) RatiosHandler(w http.ResponseWriter, r *http.Request) {
+	p := params.NewParser(r)
+	period := p.Enum("period", periodQuarterly, periodQuarterly, periodAnnual)
+	limit := p.Int("limit", defaultLimit, 1, maxLimit)
+	asOf := p.OptionalDate("as_of")
+	splitAdjusted := splitAdjusted(p)
+	price := p.Float("price")
+	if price != nil && *price <= 0 {
+		p.Fail("price", "price must be greater than 0")
+	}
+	if err := p.Err(); err != nil {
+		response.ErrorResponse(w, err)
+		return
+	}
+
+	company, ok := h.company(w, r)
+	if !ok {
+		return
+	}
+	if price == nil && asOf == nil {
+		price = company.Price
+	}
+
+	filter := mongo.FinancialsFilter{FiscalPeriods: []string{models.FiscalPeriodFY}, Limit: limit, AsOf: asOf}
+	if period == periodQuarterly {
+		// quarterly returns need the trailing twelve months and fourth
+		// quarters derived from the fiscal years
+		years := (limit+3)/4 + metricsLookbackYears
+		filter = mongo.FinancialsFilter{Limit: years * len(models.FiscalPeriods), AsOf: asOf}
+	}
+	financials, err := h.statements.ListByCIK(r.Context(), company.CIK, filter)
+	if err != nil {
+		response.ErrorResponse(w, err)
+		return
+	}
+
+	if splitAdjusted {
+		if err := h.adjustForSplits(r.Context(), company, financials, asOf); err != nil {
+			response.ErrorResponse(w, err)
+			return
+		}
+	}
+
+	periods := metrics.ComputeRatios(financials, period == periodAnnual, price)
+	if len(periods) > limit {
+		periods = periods[:limit]
+	}
+	response.JSONResponse(w, http.StatusOK, ratiosResponse{
+		Symbol:  company.Symbol,
+		CIK:     company.CIK,
+		Period:  period,
+		Price:   price,
+		Count:   len(periods),
+		Periods: periods,
+	})
+}
@@ -0,0 +1,137 @@
+package financials
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRatiosHandler(t *testing.T) {
+	price := 50.0
+	apple := &models.Company{Symbol: "AAPL", CIK: "0000320193", SecurityName: "Apple Inc.", Active: true, Price: &price}
+	msft := &models.Company{Symbol: "MSFT", CIK: "0000789019", SecurityName: "Microsoft Corporation", Active: true}
+	fiscalYear := func(year int, revenue, grossProfit, netIncome, eps, equity float64) models.Financials {
+		filed := date(year, 11, 3)
+		return models.Financials{
+			CIK: "0000320193", FiscalYear: year, FiscalPeriod: "FY", PeriodEnd: date(year, 9, 30), Currency: "USD", Filed: filed,
+			Items: map[string]models.LineItem{
+				models.LineItemRevenue:            {Value: revenue, Filed: filed},
+				models.LineItemGrossProfit:        {Value: grossProfit, Filed: filed},
+				models.LineItemNetIncome:          {Value: netIncome, Filed: filed},
+				models.LineItemEPSDiluted:         {Value: eps, Filed: filed},
+				models.LineItemStockholdersEquity: {Value: equity, Filed: filed},
+			},
+		}
+	}
+	// each request gets its own financials, as the handler adjusts them for
+	// splits in place
+	annual := func() []models.Financials {
+		return []models.Financials{
+			fiscalYear(2023, 400, 170, 80, 4, 400),
+			fiscalYear(2022, 300, 120, 60, 3, 0),
+		}
+	}
+
+	companies := new(MockCompanies)
+	companies.On("GetBySymbol", mock.Anything, "aapl").Return(apple, nil)
+	companies.On("GetBySymbol", mock.Anything, "msft").Return(msft, nil)
+	companies.On("GetBySymbol", mock.Anything, "ZZZZ").Return(nil, dberrors.ErrNotFound)
+
+	statements := new(MockStatementsRepository)
+	statements.On("ListByCIK", mock.Anything, "0000320193", mongo.FinancialsFilter{FiscalPeriods: []string{"FY"}, Limit: 2}).Return(annual(), nil).Once()
+	statements.On("ListByCIK", mock.Anything, "0000320193", mongo.FinancialsFilter{FiscalPeriods: []string{"FY"}, Limit: 2}).Return(annual(), nil).Once()
+	statements.On("ListByCIK", mock.Anything, "0000789019", mongo.FinancialsFilter{FiscalPeriods: []string{"FY"}, Limit: 1}).Return(annual()[1:], nil)
+	statements.On("ListByCIK", mock.Anything, "0000320193", mongo.FinancialsFilter{Limit: 25}).Return(nil, dberrors.NewDBError("timeout"))
+	asOf := date(2023, 12, 31)
+	statements.On("ListByCIK", mock.Anything, "0000320193", mongo.FinancialsFilter{FiscalPeriods: []string{"FY"}, Limit: 1, AsOf: &asOf}).Return(annual()[:1], nil)
+	statements.On("ListByCIK", mock.Anything, "0000320193", mongo.FinancialsFilter{FiscalPeriods: []string{"FY"}, Limit: 1}).Return(annual()[:1], nil)
+	beforeSplit := date(2023, 11, 10)
+	statements.On("ListByCIK", mock.Anything, "0000320193", mongo.FinancialsFilter{FiscalPeriods: []string{"FY"}, Limit: 1, AsOf: &beforeSplit}).Return(annual()[:1], nil)
+
+	// the 2023 fiscal year was filed before the split, in half as many shares
+	splits := new(MockSplits)
+	splits.On("ListSplits", mock.Anything, "AAPL").Return([]models.Split{{Symbol: "AAPL", Date: date(2023, 11, 15), Numerator: 2, Denominator: 1}}, nil)
+	splits.On("ListSplits", mock.Anything, "MSFT").Return([]models.Split{}, nil)
+
+	router := chi.NewRouter()
+	router.Get("/companies/{symbol}/ratios", NewHandler(companies, statements, nil, splits).RatiosHandler)
+
+	fy2023 := func(pe string) string {
+		return `{"fiscalYear":2023,"fiscalPeriod":"FY","periodEnd":"2023-09-30T00:00:00Z",
+			"grossMargin":0.425,"operatingMargin":null,"netMargin":0.2,"roe":0.2,"roa":null,
+			"currentRatio":null,"debtToEquity":null,"pe":` + pe + `,"evToEbitda":null}`
+	}
+	fy2022 := `{"fiscalYear":2022,"fiscalPeriod":"FY","periodEnd":"2022-09-30T00:00:00Z",
+		"grossMargin":0.4,"operatingMargin":null,"netMargin":0.2,"roe":null,"roa":null,
+		"currentRatio":null,"debtToEquity":null,"pe":null,"evToEbitda":null}`
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Stored price",
+			path:           "/companies/aapl/ratios?period=annual&limit=2",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"symbol":"AAPL","cik":"0000320193","period":"annual","price":50,"count":2,"periods":[` + fy2023("25") + `,` + fy2022 + `]}`,
+		},
+		{
+			name:           "Price parameter",
+			path:           "/companies/aapl/ratios?period=annual&limit=2&price=40",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"symbol":"AAPL","cik":"0000320193","period":"annual","price":40,"count":2,"periods":[` + fy2023("20") + `,` + fy2022 + `]}`,
+		},
+		{
+			name:           "No price",
+			path:           "/companies/msft/ratios?period=annual&limit=1",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"symbol":"MSFT","cik":"0000789019","period":"annual","price":null,"count":1,"periods":[` + fy2022 + `]}`,
+		},
+		{
+			name:           "As of without the current price",
+			path:           "/companies/aapl/ratios?period=annual&limit=1&as_of=2023-12-31",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"symbol":"AAPL","cik":"0000320193","period":"annual","price":null,"count":1,"periods":[` + fy2023("null") + `]}`,
+		},
+		{
+			name:           "As of before a split",
+			path:           "/companies/aapl/ratios?period=annual&limit=1&as_of=2023-11-10&price=40",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"symbol":"AAPL","cik":"0000320193","period":"annual","price":40,"count":1,"periods":[` + fy2023("10") + `]}`,
+		},
+		{
+			name:           "As filed",
+			path:           "/companies/aapl/ratios?period=annual&limit=1&price=40&split_adjusted=false",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"symbol":"AAPL","cik":"0000320193","period":"annual","price":40,"count":1,"periods":[` + fy2023("10") + `]}`,
+		},
+		{"Invalid price", "/companies/aapl/ratios?price=abc", http.StatusBadRequest, `{"status":400,"error":"price must be a number","parameter":"price"}`},
+		{"Negative price", "/companies/aapl/ratios?price=-1", http.StatusBadRequest, `{"status":400,"error":"price must be greater than 0","parameter":"price"}`},
+		{"Invalid period", "/companies/aapl/ratios?period=monthly", http.StatusBadRequest, `{"status":400,"error":"period must be one of quarterly, annual","parameter":"period"}`},
+		{"Unknown company", "/companies/ZZZZ/ratios", http.StatusNotFound, `{"status":404,"error":"company not found"}`},
+		{"Repository failure", "/companies/aapl/ratios?limit=8", http.StatusInternalServerError, `{"status":500,"error":"DB Error: timeout"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", tt.path, nil))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+
+	companies.AssertExpectations(t)
+	statements.AssertExpectations(t)
+	splits.AssertExpectations(t)
+}
@@ -0,0 +1,53 @@
+package financials
+
+import (
+	"net/http"
+
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/api-moose/company-earnings/internal/utils/response"
+	"github.com/go-chi/chi/v5"
+)
+
+type revisionsResponse struct {
+	Symbol       string            `json:"symbol"`
+	CIK          string            `json:"cik"`
+	FiscalYear   int               `json:"fiscalYear"`
+	FiscalPeriod string            `json:"fiscalPeriod"`
+	Count        int               `json:"count"`
+	Revisions    []models.Revision `json:"revisions"`
+}
+
+// RevisionsHandler serves GET /companies/{symbol}/financials/{period}/revisions,
+// returning every recorded change to the line items of a fiscal quarter or
+// year, such as 2024Q1 or 2023FY, oldest first
+func (h *Handler) RevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	fiscalYear, fiscalPeriod, err := models.ParsePeriod(chi.URLParam(r, "period"))
+	if err != nil {
+		response.ErrorResponse(w, &response.ErrorMessage{
+			Status:    http.StatusBadRequest,
+			Message:   err.Error(),
+			Parameter: "period",
+		})
+		return
+	}
+
+	company, ok := h.company(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := h.revisions.ListFinancials(r.Context(), company.CIK, fiscalYear, fiscalPeriod)
+	if err != nil {
+		response.ErrorResponse(w, err)
+		return
+	}
+
+	response.JSONResponse(w, http.StatusOK, revisionsResponse{
+		Symbol:       company.Symbol,
+		CIK:          company.CIK,
+		FiscalYear:   fiscalYear,
+		FiscalPeriod: fiscalPeriod,
+		Count:        len(revisions),
+		Revisions:    revisions,
+	})
+}
@@ -0,0 +1,89 @@
+package financials
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRevisionRepository struct {
+	mock.Mock
+}
+
+func (m *MockRevisionRepository) ListEarnings(ctx context.Context, symbol string, fiscalYear, fiscalQuarter int) ([]models.Revision, error) {
+	args := m.Called(ctx, symbol, fiscalYear, fiscalQuarter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.Revision), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRevisionRepository) ListFinancials(ctx context.Context, cik string, fiscalYear int, fiscalPeriod string) ([]models.Revision, error) {
+	args := m.Called(ctx, cik, fiscalYear, fiscalPeriod)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.Revision), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func TestRevisionsHandler(t *testing.T) {
+	apple := &models.Company{Symbol: "AAPL", CIK: "0000320193", SecurityName: "Apple Inc.", Active: true}
+	old, restated := 352755000000.0, 352583000000.0
+	restatement := models.Revision{
+		Record: models.RecordFinancials, CIK: "0000320193", FiscalYear: 2023, FiscalPeriod: "FY",
+		Source: "sec-companyfacts", RecordedAt: time.Date(2024, 2, 3, 6, 0, 0, 0, time.UTC),
+		Changes: []models.FieldChange{{Field: models.LineItemAssets, Old: &old, New: &restated, Accession: "0000320193-24-000006"}},
+	}
+
+	companies := new(MockCompanies)
+	companies.On("GetBySymbol", mock.Anything, "aapl").Return(apple, nil)
+	companies.On("GetBySymbol", mock.Anything, "ZZZZ").Return(nil, dberrors.ErrNotFound)
+
+	revisions := new(MockRevisionRepository)
+	revisions.On("ListFinancials", mock.Anything, "0000320193", 2023, "FY").Return([]models.Revision{restatement}, nil)
+	revisions.On("ListFinancials", mock.Anything, "0000320193", 2024, "Q1").Return(nil, dberrors.NewDBError("timeout"))
+
+	router := chi.NewRouter()
+	router.Get("/companies/{symbol}/financials/{period}/revisions", NewHandler(companies, new(MockStatementsRepository), revisions, nil).RevisionsHandler)
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Restated fiscal year",
+			path:           "/companies/aapl/financials/2023fy/revisions",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"symbol":"AAPL","cik":"0000320193","fiscalYear":2023,"fiscalPeriod":"FY","count":1,"revisions":[{
+				"record":"financials","cik":"0000320193","fiscalYear":2023,"fiscalPeriod":"FY",
+				"source":"sec-companyfacts","recordedAt":"2024-02-03T06:00:00Z",
+				"changes":[{"field":"assets","old":352755000000,"new":352583000000,"accession":"0000320193-24-000006"}]}]}`,
+		},
+		{"Malformed period", "/companies/aapl/financials/2023H1/revisions", http.StatusBadRequest, `{"status":400,"error":"period must be a fiscal year and period such as 2024Q1 or 2023FY","parameter":"period"}`},
+		{"Unknown company", "/companies/ZZZZ/financials/2023FY/revisions", http.StatusNotFound, `{"status":404,"error":"company not found"}`},
+		{"Repository failure", "/companies/aapl/financials/2024Q1/revisions", http.StatusInternalServerError, `{"status":500,"error":"DB Error: timeout"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", tt.path, nil))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+
+	companies.AssertExpectations(t)
+	revisions.AssertExpectations(t)
+}
@@ -0,0 +1,153 @@
+package params
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/api-moose/company-earnings/internal/utils/response"
+)
+
+// Parser reads and validates query parameters for the v1 handlers. It keeps
+// the first validation failure so handlers can read every parameter in a row
+// and check Err once.
+type Parser struct {
+	values url.Values
+	err    *response.ErrorMessage
+}
+
+func NewParser(r *http.Request) *Parser {
+	return &Parser{values: r.URL.Query()}
+}
+
+// Err returns a 400 ErrorMessage naming the first invalid parameter, or nil
+func (p *Parser) Err() error {
+	if p.err == nil {
+		return nil
+	}
+	return p.err
+}
+
+// Fail records a validation failure for a parameter the caller checked itself
+func (p *Parser) Fail(name, format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	p.err = &response.ErrorMessage{
+		Status:    http.StatusBadRequest,
+		Message:   fmt.Sprintf(format, args...),
+		Parameter: name,
+	}
+}
+
+// RequiredString returns a parameter that must be present with a length
+// between minLen and maxLen characters
+func (p *Parser) RequiredString(name string, minLen, maxLen int) string {
+	v := p.values.Get(name)
+	if v == "" {
+		p.Fail(name, "%s cannot be empty", name)
+		return ""
+	}
+	if n := utf8.RuneCountInString(v); n < minLen || n > maxLen {
+		p.Fail(name, "%s must be between %d and %d characters", name, minLen, maxLen)
+		return ""
+	}
+	return v
+}
+
+// String returns an optional parameter, or an empty string when absent
+func (p *Parser) String(name string) string {
+	return p.values.Get(name)
+}
+
+// Int returns an optional integer parameter within [min, max], or def when
+// the parameter is absent
+func (p *Parser) Int(name string, def, min, max int) int {
+	v := p.values.Get(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min || n > max {
+		p.Fail(name, "%s must be an integer between %d and %d", name, min, max)
+		return def
+	}
+	return n
+}
+
+// Bool returns an optional boolean parameter, or nil when absent
+func (p *Parser) Bool(name string) *bool {
+	v := p.values.Get(name)
+	if v == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		p.Fail(name, "%s must be true or false", name)
+		return nil
+	}
+	return &b
+}
+
+// Float returns an optional finite number parameter, or nil when absent
+func (p *Parser) Float(name string) *float64 {
+	v := p.values.Get(name)
+	if v == "" {
+		return nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		p.Fail(name, "%s must be a number", name)
+		return nil
+	}
+	return &f
+}
+
+// Enum returns an optional parameter that must match one of allowed, ignoring
+// case. The canonical spelling from allowed is returned, or def when absent.
+func (p *Parser) Enum(name, def string, allowed ...string) string {
+	v := p.values.Get(name)
+	if v == "" {
+		return def
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(v, a) {
+			return a
+		}
+	}
+	p.Fail(name, "%s must be one of %s", name, strings.Join(allowed, ", "))
+	return def
+}
+
+// DateLayout is the YYYY-MM-DD format of date parameters
+const DateLayout = "2006-01-02"
+
+// Date returns an optional YYYY-MM-DD parameter as midnight UTC, or def when
+// the parameter is absent
+func (p *Parser) Date(name string, def time.Time) time.Time {
+	v := p.values.Get(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.Parse(DateLayout, v)
+	if err != nil {
+		p.Fail(name, "%s must be a date formatted as YYYY-MM-DD", name)
+		return def
+	}
+	return d
+}
+
+// OptionalDate returns an optional YYYY-MM-DD parameter as midnight UTC, or
+// nil when the parameter is absent or invalid
+func (p *Parser) OptionalDate(name string) *time.Time {
+	d := p.Date(name, time.Time{})
+	if d.IsZero() {
+		return nil
+	}
+	return &d
+}
@@ -0,0 +1,135 @@
+package params
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/utils/response"
+	"github.com/stretchr/testify/assert"
+)
+
+func newParser(rawQuery string) *Parser {
+	return NewParser(httptest.NewRequest("GET", "/?"+rawQuery, nil))
+}
+
+func TestParser_Valid(t *testing.T) {
+	p := newParser("query=apple&limit=25&active=false&exchange=nyse&from=2024-07-29&surprise_pct_gt=-2.5&as_of=2023-12-31")
+
+	assert.Equal(t, "apple", p.RequiredString("query", 1, 100))
+	assert.Equal(t, 25, p.Int("limit", 10, 1, 100))
+	assert.Equal(t, false, *p.Bool("active"))
+	assert.Equal(t, "NYSE", p.Enum("exchange", "", "NYSE", "NASDAQ", "AMEX"))
+	assert.Equal(t, "contains", p.Enum("mode", "contains", "exact", "starts_with", "contains"))
+	assert.Equal(t, "", p.String("cursor"))
+	assert.Equal(t, time.Date(2024, 7, 29, 0, 0, 0, 0, time.UTC), p.Date("from", time.Time{}))
+	assert.Equal(t, -2.5, *p.Float("surprise_pct_gt"))
+	assert.Equal(t, time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC), *p.OptionalDate("as_of"))
+	assert.NoError(t, p.Err())
+}
+
+func TestParser_Defaults(t *testing.T) {
+	p := newParser("")
+
+	assert.Equal(t, 10, p.Int("limit", 10, 1, 100))
+	assert.Nil(t, p.Bool("active"))
+	assert.Nil(t, p.Float("surprise_pct_gt"))
+	assert.Equal(t, "", p.Enum("exchange", "", "NYSE"))
+	today := time.Date(2024, 7, 29, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, today, p.Date("from", today))
+	assert.Nil(t, p.OptionalDate("as_of"))
+	assert.NoError(t, p.Err())
+}
+
+func TestParser_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		rawQuery  string
+		read      func(p *Parser)
+		parameter string
+		message   string
+	}{
+		{
+			name:      "Missing required",
+			rawQuery:  "",
+			read:      func(p *Parser) { p.RequiredString("query", 1, 100) },
+			parameter: "query",
+			message:   "query cannot be empty",
+		},
+		{
+			name:      "Too long",
+			rawQuery:  "query=" + strings.Repeat("a", 101),
+			read:      func(p *Parser) { p.RequiredString("query", 1, 100) },
+			parameter: "query",
+			message:   "query must be between 1 and 100 characters",
+		},
+		{
+			name:      "Not an integer",
+			rawQuery:  "limit=abc",
+			read:      func(p *Parser) { p.Int("limit", 10, 1, 100) },
+			parameter: "limit",
+			message:   "limit must be an integer between 1 and 100",
+		},
+		{
+			name:      "Out of range",
+			rawQuery:  "limit=0",
+			read:      func(p *Parser) { p.Int("limit", 10, 1, 100) },
+			parameter: "limit",
+			message:   "limit must be an integer between 1 and 100",
+		},
+		{
+			name:      "Not a bool",
+			rawQuery:  "active=maybe",
+			read:      func(p *Parser) { p.Bool("active") },
+			parameter: "active",
+			message:   "active must be true or false",
+		},
+		{
+			name:      "Not in enum",
+			rawQuery:  "exchange=LSE",
+			read:      func(p *Parser) { p.Enum("exchange", "", "NYSE", "NASDAQ") },
+			parameter: "exchange",
+			message:   "exchange must be one of NYSE, NASDAQ",
+		},
+		{
+			name:      "Not a date",
+			rawQuery:  "from=07/29/2024",
+			read:      func(p *Parser) { p.Date("from", time.Time{}) },
+			parameter: "from",
+			message:   "from must be a date formatted as YYYY-MM-DD",
+		},
+		{
+			name:      "Not a number",
+			rawQuery:  "surprise_pct_gt=NaN",
+			read:      func(p *Parser) { p.Float("surprise_pct_gt") },
+			parameter: "surprise_pct_gt",
+			message:   "surprise_pct_gt must be a number",
+		},
+		{
+			name:     "First failure wins",
+			rawQuery: "limit=abc&active=maybe",
+			read: func(p *Parser) {
+				p.Int("limit", 10, 1, 100)
+				p.Bool("active")
+			},
+			parameter: "limit",
+			message:   "limit must be an integer between 1 and 100",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newParser(tt.rawQuery)
+			tt.read(p)
+
+			err := p.Err()
+			assert.Equal(t, &response.ErrorMessage{
+				Status:    http.StatusBadRequest,
+				Message:   tt.message,
+				Parameter: tt.parameter,
+			}, err)
+		})
+	}
+}
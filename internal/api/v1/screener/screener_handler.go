@@ -0,0 +1,213 @@
+package screener
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/api-moose/company-earnings/internal/screener"
+	"github.com/api-moose/company-earnings/internal/utils/pagination"
+	"github.com/api-moose/company-earnings/internal/utils/response"
+)
+
+// Bounds for the screen request documented in docs/api/swagger.yaml
+const (
+	maxFilterLength = 1000
+	maxFields       = 30
+	defaultLimit    = 50
+	maxLimit        = 500
+	maxBodyBytes    = 16 << 10
+)
+
+type Handler struct {
+	screener *screener.Screener
+	cursors  *pagination.CursorCodec
+}
+
+func NewHandler(companies screener.Companies, statements screener.Statements, splits screener.Splits, cursors *pagination.CursorCodec) *Handler {
+	return &Handler{screener: screener.New(companies, statements, splits), cursors: cursors}
+}
+
+type screenRequest struct {
+	Filter string   `json:"filter"`
+	Sort   string   `json:"sort"`
+	Order  string   `json:"order"`
+	Fields []string `json:"fields"`
+	Limit  *int     `json:"limit"`
+	Cursor string   `json:"cursor"`
+	// SplitAdjusted is true when absent
+	SplitAdjusted *bool `json:"split_adjusted"`
+}
+
+// pageToken is the payload of the screen cursor. Scope ties the cursor to the
+// screen it was issued for so it cannot be replayed against another one.
+type pageToken struct {
+	Scope  string `json:"q"`
+	Offset int    `json:"o"`
+}
+
+type result struct {
+	Company models.Company         `json:"company"`
+	Values  map[string]interface{} `json:"values"`
+}
+
+type screenResponse struct {
+	Count      int      `json:"count"`
+	Results    []result `json:"results"`
+	NextCursor *string  `json:"next_cursor"`
+}
+
+// ScreenHandler serves POST /screener, returning the active companies
+// matching a filter expression such as
+//
+//	sector = "Technology" AND pe < 20 AND revenue_growth_yoy > 0.1
+//
+// ordered by sort, symbol by default. Each result carries the values of the
+// requested fields, or of the fields the filter and sort use when none are
+// requested. Count is the number of matching companies across all pages.
+func (h *Handler) ScreenHandler(w http.ResponseWriter, r *http.Request) {
+	var req screenRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		response.ErrorResponse(w, &response.ErrorMessage{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("invalid request body: %v", err),
+		})
+		return
+	}
+
+	query, offset, limit, err := h.parse(req)
+	if err != nil {
+		response.ErrorResponse(w, err)
+		return
+	}
+
+	rows, err := h.screener.Run(r.Context(), query)
+	if err != nil {
+		response.ErrorResponse(w, err)
+		return
+	}
+
+	resp := screenResponse{Count: len(rows), Results: []result{}}
+	for _, row := range page(rows, offset, limit) {
+		values := make(map[string]interface{}, len(query.Fields))
+		for _, f := range query.Fields {
+			values[f.Name] = row.Value(f)
+		}
+		resp.Results = append(resp.Results, result{Company: row.Company, Values: values})
+	}
+	if offset+limit < len(rows) {
+		token, err := h.cursors.Encode(pageToken{Scope: scope(query), Offset: offset + limit})
+		if err != nil {
+			response.ErrorResponse(w, err)
+			return
+		}
+		resp.NextCursor = &token
+	}
+
+	response.JSONResponse(w, http.StatusOK, resp)
+}
+
+// parse validates the request, returning the screen and the page of it to
+// return
+func (h *Handler) parse(req screenRequest) (query screener.Query, offset, limit int, err error) {
+	filter := strings.TrimSpace(req.Filter)
+	if filter == "" || len(filter) > maxFilterLength {
+		return query, 0, 0, invalid("filter", fmt.Sprintf("filter must be between 1 and %d characters", maxFilterLength))
+	}
+	query.Filter, err = screener.Parse(filter)
+	var parseErr *screener.Error
+	if errors.As(err, &parseErr) {
+		return query, 0, 0, invalid("filter", fmt.Sprintf("invalid filter: %v", parseErr))
+	}
+	if err != nil {
+		return query, 0, 0, err
+	}
+
+	sort := strings.ToLower(strings.TrimSpace(req.Sort))
+	if sort == "" {
+		sort = "symbol"
+	}
+	var ok bool
+	if query.Sort, ok = screener.LookupField(sort); !ok {
+		return query, 0, 0, invalid("sort", fmt.Sprintf("unknown field %q", req.Sort))
+	}
+	switch strings.ToLower(req.Order) {
+	case "", "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		return query, 0, 0, invalid("order", "order must be one of: asc, desc")
+	}
+
+	if len(req.Fields) > maxFields {
+		return query, 0, 0, invalid("fields", fmt.Sprintf("at most %d fields can be requested", maxFields))
+	}
+	for _, name := range req.Fields {
+		f, ok := screener.LookupField(strings.ToLower(strings.TrimSpace(name)))
+		if !ok {
+			return query, 0, 0, invalid("fields", fmt.Sprintf("unknown field %q", name))
+		}
+		query.Fields = append(query.Fields, f)
+	}
+	if len(query.Fields) == 0 {
+		// the symbol every result has is only listed when filtered on
+		query.Fields = screener.Fields(query.Filter)
+		if sort != "symbol" {
+			query.Fields = screener.Fields(query.Filter, query.Sort)
+		}
+	}
+
+	query.AsFiled = req.SplitAdjusted != nil && !*req.SplitAdjusted
+
+	limit = defaultLimit
+	if req.Limit != nil {
+		if *req.Limit < 1 || *req.Limit > maxLimit {
+			return query, 0, 0, invalid("limit", fmt.Sprintf("limit must be between 1 and %d", maxLimit))
+		}
+		limit = *req.Limit
+	}
+
+	if req.Cursor != "" {
+		var token pageToken
+		if err := h.cursors.Decode(req.Cursor, &token); err != nil || token.Scope != scope(query) || token.Offset < 0 {
+			return query, 0, 0, invalid("cursor", pagination.ErrInvalidCursor.Error())
+		}
+		offset = token.Offset
+	}
+	return query, offset, limit, nil
+}
+
+func invalid(parameter, message string) error {
+	return &response.ErrorMessage{
+		Status:    http.StatusBadRequest,
+		Message:   message,
+		Parameter: parameter,
+	}
+}
+
+// scope identifies the order of a screen, which the filter, sort and split
+// adjustment decide
+func scope(q screener.Query) string {
+	order := "asc"
+	if q.Descending {
+		order = "desc"
+	}
+	parts := []string{q.Filter.String(), q.Sort.Name, order}
+	if q.AsFiled {
+		parts = append(parts, "as-filed")
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// page returns rows[offset:offset+limit], clamped to rows
+func page(rows []screener.Row, offset, limit int) []screener.Row {
+	if offset >= len(rows) {
+		return nil
+	}
+	return rows[offset:min(offset+limit, len(rows))]
+}
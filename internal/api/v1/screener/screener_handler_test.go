@@ -0,0 +1,160 @@
+package screener
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/api-moose/company-earnings/internal/utils/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockCompanies struct {
+	mock.Mock
+}
+
+func (m *MockCompanies) List(ctx context.Context, filter mongo.ListFilter) ([]models.Company, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.Company), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+type MockStatements struct {
+	mock.Mock
+}
+
+func (m *MockStatements) ListByCIKs(ctx context.Context, ciks []string, filter mongo.FinancialsFilter) (map[string][]models.Financials, error) {
+	args := m.Called(ctx, ciks, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).(map[string][]models.Financials), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+var (
+	apple = models.Company{Symbol: "AAPL", CIK: "0000320193", Exchange: "NASDAQ", Sector: "Technology", Active: true}
+	msft  = models.Company{Symbol: "MSFT", CIK: "0000789019", Exchange: "NASDAQ", Sector: "Technology", Active: true}
+	ibm   = models.Company{Symbol: "IBM", CIK: "0000051143", Exchange: "NYSE", Sector: "Technology", Active: true}
+)
+
+// quarters returns first quarters of two years with the given revenue
+func quarters(before, after float64) []models.Financials {
+	q := func(year int, revenue float64) models.Financials {
+		start := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+		return models.Financials{
+			FiscalYear: year, FiscalPeriod: "Q1",
+			PeriodStart: &start, PeriodEnd: start.AddDate(0, 3, -1), Currency: "USD",
+			Items: map[string]models.LineItem{models.LineItemRevenue: {Value: revenue}},
+		}
+	}
+	return []models.Financials{q(2024, after), q(2023, before)}
+}
+
+type MockSplits struct {
+	mock.Mock
+}
+
+func (m *MockSplits) ListSplitsBySymbols(ctx context.Context, symbols []string) (map[string][]models.Split, error) {
+	args := m.Called(ctx, symbols)
+	if args.Get(0) != nil {
+		return args.Get(0).(map[string][]models.Split), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func screen(h *Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/screener", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	h.ScreenHandler(rr, req)
+	return rr
+}
+
+func TestScreenHandler(t *testing.T) {
+	companies := new(MockCompanies)
+	companies.On("List", mock.Anything, mongo.ListFilter{Sectors: []string{"Technology"}}).Return([]models.Company{apple, ibm, msft}, nil)
+	statements := new(MockStatements)
+	statements.On("ListByCIKs", mock.Anything, mock.Anything, mock.Anything).Return(map[string][]models.Financials{
+		apple.CIK: quarters(100, 105),
+		ibm.CIK:   quarters(100, 90),
+		msft.CIK:  quarters(100, 120),
+	}, nil)
+	splits := new(MockSplits)
+	splits.On("ListSplitsBySymbols", mock.Anything, mock.Anything).Return(map[string][]models.Split{}, nil)
+	h := NewHandler(companies, statements, splits, pagination.NewCursorCodec("secret"))
+
+	rr := screen(h, `{"filter":"sector = \"Technology\" AND revenue_growth_yoy > 0","sort":"revenue_growth_yoy","order":"desc","limit":1}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var first struct {
+		Count   int `json:"count"`
+		Results []struct {
+			Company models.Company         `json:"company"`
+			Values  map[string]interface{} `json:"values"`
+		} `json:"results"`
+		NextCursor *string `json:"next_cursor"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &first))
+	assert.Equal(t, 2, first.Count)
+	require.Len(t, first.Results, 1)
+	assert.Equal(t, "MSFT", first.Results[0].Company.Symbol)
+	assert.Equal(t, "Technology", first.Results[0].Values["sector"])
+	assert.InDelta(t, 0.2, first.Results[0].Values["revenue_growth_yoy"], 1e-9)
+	require.NotNil(t, first.NextCursor)
+
+	rr = screen(h, `{"filter":"sector = \"Technology\" AND revenue_growth_yoy > 0","sort":"revenue_growth_yoy","order":"desc","limit":1,"fields":["pe"],"cursor":"`+*first.NextCursor+`"}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.JSONEq(t, `{"count":2,"results":[{
+		"company":{"symbol":"AAPL","cik":"0000320193","securityName":"","securityType":"","region":"","exchange":"NASDAQ","sector":"Technology","active":true},
+		"values":{"pe":null}
+	}],"next_cursor":null}`, rr.Body.String())
+
+	rr = screen(h, `{"filter":"sector = \"Technology\" AND revenue_growth_yoy > 0","cursor":"`+*first.NextCursor+`"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"status":400,"error":"invalid cursor","parameter":"cursor"}`, rr.Body.String(), "the cursor belongs to another order")
+
+	rr = screen(h, `{"filter":"sector = \"Technology\" AND revenue_growth_yoy > 0","sort":"revenue_growth_yoy","order":"desc","split_adjusted":false,"cursor":"`+*first.NextCursor+`"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "the cursor belongs to the split-adjusted screen")
+}
+
+func TestScreenHandler_Errors(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{"Unknown body field", `{"query":"pe < 20"}`, http.StatusBadRequest, `{"status":400,"error":"invalid request body: json: unknown field \"query\""}`},
+		{"Missing filter", `{}`, http.StatusBadRequest, `{"status":400,"error":"filter must be between 1 and 1000 characters","parameter":"filter"}`},
+		{"Invalid filter", `{"filter":"pe < 20 AND moat > 1"}`, http.StatusBadRequest, `{"status":400,"error":"invalid filter: unknown field \"moat\" at position 13","parameter":"filter"}`},
+		{"Unknown sort", `{"filter":"pe < 20","sort":"moat"}`, http.StatusBadRequest, `{"status":400,"error":"unknown field \"moat\"","parameter":"sort"}`},
+		{"Invalid order", `{"filter":"pe < 20","order":"up"}`, http.StatusBadRequest, `{"status":400,"error":"order must be one of: asc, desc","parameter":"order"}`},
+		{"Unknown field", `{"filter":"pe < 20","fields":["moat"]}`, http.StatusBadRequest, `{"status":400,"error":"unknown field \"moat\"","parameter":"fields"}`},
+		{"Limit", `{"filter":"pe < 20","limit":501}`, http.StatusBadRequest, `{"status":400,"error":"limit must be between 1 and 500","parameter":"limit"}`},
+		{"Forged cursor", `{"filter":"pe < 20","cursor":"abc.def"}`, http.StatusBadRequest, `{"status":400,"error":"invalid cursor","parameter":"cursor"}`},
+		{"Repository failure", `{"filter":"exchange = \"NYSE\""}`, http.StatusInternalServerError, ""},
+	}
+
+	companies := new(MockCompanies)
+	companies.On("List", mock.Anything, mongo.ListFilter{Exchanges: []string{"NYSE"}}).Return(nil, dberrors.NewDBError("connection lost"))
+	h := NewHandler(companies, new(MockStatements), new(MockSplits), pagination.NewCursorCodec("secret"))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := screen(h, tt.body)
+			assert.Equal(t, tt.expectedCode, rr.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
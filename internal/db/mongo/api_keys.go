@@ -0,0 +1,223 @@
+package mongo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"go.mongodb.org/mongo-driver/bson"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// API key scopes. Read allows safe requests such as GET, write everything
+// else.
+const (
+	APIKeyScopeRead  = "read"
+	APIKeyScopeWrite = "write"
+)
+
+// APIKeyScopes lists the scopes a key can be granted
+var APIKeyScopes = []string{APIKeyScopeRead, APIKeyScopeWrite}
+
+// IsValidAPIKeyScope reports whether scope is one of APIKeyScopes
+func IsValidAPIKeyScope(scope string) bool {
+	for _, s := range APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey is a credential for server-to-server clients. Only the SHA-256 hash
+// of the secret is stored; the key acts as the user it was issued to, with
+// its own role and scopes.
+type APIKey struct {
+	ID         string     `bson:"_id" json:"id"`
+	Name       string     `bson:"name,omitempty" json:"name,omitempty"`
+	Prefix     string     `bson:"prefix" json:"prefix"`
+	Hash       string     `bson:"hash" json:"-"`
+	UserID     string     `bson:"userId" json:"userId"`
+	Username   string     `bson:"username,omitempty" json:"username,omitempty"`
+	Email      string     `bson:"email,omitempty" json:"email,omitempty"`
+	Role       string     `bson:"role" json:"role"`
+	TenantID   string     `bson:"tenantId" json:"tenantId"`
+	Scopes     []string   `bson:"scopes" json:"scopes"`
+	CreatedAt  time.Time  `bson:"createdAt" json:"createdAt"`
+	ExpiresAt  *time.Time `bson:"expiresAt" json:"expiresAt"`
+	LastUsedAt *time.Time `bson:"lastUsedAt" json:"lastUsedAt"`
+	RotatedAt  *time.Time `bson:"rotatedAt" json:"rotatedAt"`
+	RevokedAt  *time.Time `bson:"revokedAt" json:"revokedAt"`
+}
+
+// User returns the user the key authenticates as
+func (k *APIKey) User() *User {
+	user := NewUser(k.UserID, k.Username, k.Email, k.Role, k.TenantID)
+	user.Scopes = k.Scopes
+	return user
+}
+
+// Expired reports whether the key's expiry has passed at now
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// HashAPIKey returns the hex encoded SHA-256 hash keys are stored and looked
+// up by. Keys are random, so an unsalted hash is enough to keep a leaked
+// collection from being usable.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyOwner restricts key operations to the keys of one tenant and, when
+// UserID is set, of one user in it
+type APIKeyOwner struct {
+	TenantID string
+	UserID   string
+}
+
+// APIKeyRepository stores API keys by the hash of their secret. Revoked keys
+// are kept for auditing but can no longer authenticate, be rotated or be
+// revoked again.
+type APIKeyRepository interface {
+	// GetByHash returns the unrevoked key with the given hash, or
+	// dberrors.ErrNotFound
+	GetByHash(ctx context.Context, hash string) (*APIKey, error)
+	// List returns the keys of owner, revoked ones included, newest first
+	List(ctx context.Context, owner APIKeyOwner) ([]APIKey, error)
+	// Create stores a new key
+	Create(ctx context.Context, key APIKey) error
+	// Rotate replaces the secret of an unrevoked key of owner, keeping its
+	// role, scopes and expiry, and returns the updated key
+	Rotate(ctx context.Context, owner APIKeyOwner, id, prefix, hash string) (*APIKey, error)
+	// Revoke revokes an unrevoked key of owner and returns the revoked key
+	Revoke(ctx context.Context, owner APIKeyOwner, id string) (*APIKey, error)
+	// TouchLastUsed records that a key has just been used
+	TouchLastUsed(ctx context.Context, id string) error
+}
+
+type apiKeyRepository struct {
+	coll    collection
+	timeout time.Duration
+	now     func() time.Time
+}
+
+// NewAPIKeyRepository returns an APIKeyRepository over coll
+func NewAPIKeyRepository(coll *driver.Collection, timeout time.Duration) APIKeyRepository {
+	return newAPIKeyRepository(coll, timeout)
+}
+
+func newAPIKeyRepository(coll collection, timeout time.Duration) *apiKeyRepository {
+	return &apiKeyRepository{coll: coll, timeout: timeout, now: time.Now}
+}
+
+// notRevoked restricts a filter to keys that have not been revoked
+var notRevoked = bson.E{Key: "revokedAt", Value: nil}
+
+func (r *apiKeyRepository) GetByHash(ctx context.Context, hash string) (*APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var key APIKey
+	if err := r.coll.FindOne(ctx, bson.D{{Key: "hash", Value: hash}, notRevoked}).Decode(&key); err != nil {
+		if errors.Is(err, driver.ErrNoDocuments) {
+			return nil, dberrors.ErrNotFound
+		}
+		return nil, dberrors.NewDBError(fmt.Sprintf("error finding api key: %v", err))
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) List(ctx context.Context, owner APIKeyOwner) ([]APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: 1}})
+	cursor, err := r.coll.Find(ctx, ownerFilter(owner), opts)
+	if err != nil {
+		return nil, dberrors.NewDBError(fmt.Sprintf("error listing api keys: %v", err))
+	}
+	defer cursor.Close(ctx)
+
+	keys := []APIKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, dberrors.NewDBError(fmt.Sprintf("error decoding api keys: %v", err))
+	}
+	return keys, nil
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, key APIKey) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if _, err := r.coll.InsertOne(ctx, key); err != nil {
+		if driver.IsDuplicateKeyError(err) {
+			return dberrors.ErrConflict
+		}
+		return dberrors.NewDBError(fmt.Sprintf("error creating api key: %v", err))
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) Rotate(ctx context.Context, owner APIKeyOwner, id, prefix, hash string) (*APIKey, error) {
+	return r.update(ctx, owner, id, bson.D{
+		{Key: "prefix", Value: prefix},
+		{Key: "hash", Value: hash},
+		{Key: "rotatedAt", Value: r.now().UTC()},
+	}, "rotating")
+}
+
+func (r *apiKeyRepository) Revoke(ctx context.Context, owner APIKeyOwner, id string) (*APIKey, error) {
+	return r.update(ctx, owner, id, bson.D{{Key: "revokedAt", Value: r.now().UTC()}}, "revoking")
+}
+
+// update sets fields on an unrevoked key of owner, naming the operation verb
+// in errors
+func (r *apiKeyRepository) update(ctx context.Context, owner APIKeyOwner, id string, set bson.D, verb string) (*APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	filter := append(bson.D{{Key: "_id", Value: id}}, ownerFilter(owner)...)
+	filter = append(filter, notRevoked)
+
+	var key APIKey
+	err := r.coll.FindOneAndUpdate(ctx, filter,
+		bson.D{{Key: "$set", Value: set}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&key)
+	if err != nil {
+		if errors.Is(err, driver.ErrNoDocuments) {
+			return nil, dberrors.ErrNotFound
+		}
+		return nil, dberrors.NewDBError(fmt.Sprintf("error %s api key: %v", verb, err))
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) TouchLastUsed(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.coll.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "lastUsedAt", Value: r.now().UTC()}}}},
+	)
+	if err != nil {
+		return dberrors.NewDBError(fmt.Sprintf("error recording api key use: %v", err))
+	}
+	return nil
+}
+
+func ownerFilter(owner APIKeyOwner) bson.D {
+	filter := bson.D{{Key: "tenantId", Value: owner.TenantID}}
+	if owner.UserID != "" {
+		filter = append(filter, bson.E{Key: "userId", Value: owner.UserID})
+	}
+	return filter
+}
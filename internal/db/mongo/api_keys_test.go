@@ -0,0 +1,144 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var serviceKey = APIKey{
+	ID: "key1", Name: "importer", Prefix: "cek_abcdefgh", Hash: HashAPIKey("ce_secret"),
+	UserID: "svc-importer", Role: "admin", TenantID: "tenant1", Scopes: []string{APIKeyScopeRead},
+	CreatedAt: time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC),
+}
+
+func TestHashAPIKey(t *testing.T) {
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", HashAPIKey(""))
+	assert.Len(t, HashAPIKey("ce_secret"), 64)
+	assert.NotEqual(t, HashAPIKey("ce_secret"), HashAPIKey("ce_secreT"))
+}
+
+func TestAPIKey(t *testing.T) {
+	expiry := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	key := serviceKey
+	assert.False(t, key.Expired(expiry))
+	key.ExpiresAt = &expiry
+	assert.False(t, key.Expired(expiry.Add(-time.Second)))
+	assert.True(t, key.Expired(expiry))
+
+	user := key.User()
+	assert.Equal(t, &User{ID: "svc-importer", Role: "admin", TenantID: "tenant1", Scopes: []string{"read"}}, user)
+	assert.True(t, user.HasScope(APIKeyScopeRead))
+	assert.False(t, user.HasScope(APIKeyScopeWrite))
+	assert.True(t, NewUser("1", "", "", "user", "tenant1").HasScope(APIKeyScopeWrite))
+
+	assert.True(t, IsValidAPIKeyScope("write"))
+	assert.False(t, IsValidAPIKeyScope("admin"))
+}
+
+func TestAPIKeyRepository_GetByHash(t *testing.T) {
+	coll := new(MockCollection)
+	coll.On("FindOne", mock.Anything, bson.D{{Key: "hash", Value: serviceKey.Hash}, notRevoked}, mock.Anything).Return(serviceKey, nil)
+	coll.On("FindOne", mock.Anything, bson.D{{Key: "hash", Value: "unknown"}, notRevoked}, mock.Anything).Return(nil, driver.ErrNoDocuments)
+	coll.On("FindOne", mock.Anything, bson.D{{Key: "hash", Value: "broken"}, notRevoked}, mock.Anything).Return(nil, errors.New("socket closed"))
+	r := newAPIKeyRepository(coll, time.Second)
+
+	got, err := r.GetByHash(context.Background(), serviceKey.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, &serviceKey, got)
+
+	_, err = r.GetByHash(context.Background(), "unknown")
+	assert.ErrorIs(t, err, dberrors.ErrNotFound)
+
+	_, err = r.GetByHash(context.Background(), "broken")
+	assert.EqualError(t, err, "DB Error: error finding api key: socket closed")
+	coll.AssertExpectations(t)
+}
+
+func TestAPIKeyRepository_List(t *testing.T) {
+	newestFirst := func(opts []*options.FindOptions) bool {
+		return len(opts) == 1 && assert.ObjectsAreEqual(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: 1}}, opts[0].Sort)
+	}
+	coll := new(MockCollection)
+	coll.On("Find", mock.Anything, bson.D{{Key: "tenantId", Value: "tenant1"}}, mock.MatchedBy(newestFirst)).Return([]interface{}{serviceKey}, nil)
+	coll.On("Find", mock.Anything, bson.D{{Key: "tenantId", Value: "tenant1"}, {Key: "userId", Value: "alice"}}, mock.Anything).Return([]interface{}{}, nil)
+	coll.On("Find", mock.Anything, bson.D{{Key: "tenantId", Value: "tenant2"}}, mock.Anything).Return(nil, errors.New("socket closed"))
+	r := newAPIKeyRepository(coll, time.Second)
+
+	got, err := r.List(context.Background(), APIKeyOwner{TenantID: "tenant1"})
+	require.NoError(t, err)
+	assert.Equal(t, []APIKey{serviceKey}, got)
+
+	got, err = r.List(context.Background(), APIKeyOwner{TenantID: "tenant1", UserID: "alice"})
+	require.NoError(t, err)
+	assert.Equal(t, []APIKey{}, got)
+
+	_, err = r.List(context.Background(), APIKeyOwner{TenantID: "tenant2"})
+	assert.True(t, dberrors.IsDBError(err))
+	coll.AssertExpectations(t)
+}
+
+func TestAPIKeyRepository_Create(t *testing.T) {
+	coll := new(MockCollection)
+	coll.On("InsertOne", mock.Anything, serviceKey, mock.Anything).Return(nil).Once()
+	coll.On("InsertOne", mock.Anything, serviceKey, mock.Anything).Return(driver.WriteException{WriteErrors: driver.WriteErrors{{Code: 11000}}}).Once()
+	r := newAPIKeyRepository(coll, time.Second)
+
+	assert.NoError(t, r.Create(context.Background(), serviceKey))
+	assert.ErrorIs(t, r.Create(context.Background(), serviceKey), dberrors.ErrConflict)
+	coll.AssertExpectations(t)
+}
+
+func TestAPIKeyRepository_RotateAndRevoke(t *testing.T) {
+	now := time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+	owned := bson.D{{Key: "_id", Value: "key1"}, {Key: "tenantId", Value: "tenant1"}, {Key: "userId", Value: "svc-importer"}, notRevoked}
+	rotated := serviceKey
+	rotated.Prefix, rotated.Hash, rotated.RotatedAt = "cek_newnewne", HashAPIKey("ce_new"), &now
+	revoked := serviceKey
+	revoked.RevokedAt = &now
+
+	coll := new(MockCollection)
+	coll.On("FindOneAndUpdate", mock.Anything, owned, bson.D{{Key: "$set", Value: bson.D{
+		{Key: "prefix", Value: rotated.Prefix}, {Key: "hash", Value: rotated.Hash}, {Key: "rotatedAt", Value: now},
+	}}}, mock.Anything).Return(rotated, nil)
+	coll.On("FindOneAndUpdate", mock.Anything, owned, bson.D{{Key: "$set", Value: bson.D{{Key: "revokedAt", Value: now}}}}, mock.Anything).Return(revoked, nil)
+	coll.On("FindOneAndUpdate", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, driver.ErrNoDocuments)
+	r := newAPIKeyRepository(coll, time.Second)
+	r.now = func() time.Time { return now }
+	owner := APIKeyOwner{TenantID: "tenant1", UserID: "svc-importer"}
+
+	got, err := r.Rotate(context.Background(), owner, "key1", rotated.Prefix, rotated.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, &rotated, got)
+
+	got, err = r.Revoke(context.Background(), owner, "key1")
+	require.NoError(t, err)
+	assert.Equal(t, &revoked, got)
+
+	_, err = r.Revoke(context.Background(), APIKeyOwner{TenantID: "tenant2"}, "key1")
+	assert.ErrorIs(t, err, dberrors.ErrNotFound)
+	coll.AssertExpectations(t)
+}
+
+func TestAPIKeyRepository_TouchLastUsed(t *testing.T) {
+	now := time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+	coll := new(MockCollection)
+	coll.On("UpdateOne", mock.Anything, bson.D{{Key: "_id", Value: "key1"}}, bson.D{{Key: "$set", Value: bson.D{{Key: "lastUsedAt", Value: now}}}}, mock.Anything).
+		Return(&driver.UpdateResult{MatchedCount: 1}, nil).Once()
+	coll.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("socket closed"))
+	r := newAPIKeyRepository(coll, time.Second)
+	r.now = func() time.Time { return now }
+
+	assert.NoError(t, r.TouchLastUsed(context.Background(), "key1"))
+	assert.EqualError(t, r.TouchLastUsed(context.Background(), "key1"), "DB Error: error recording api key use: socket closed")
+	coll.AssertExpectations(t)
+}
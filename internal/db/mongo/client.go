@@ -0,0 +1,263 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+const (
+	DefaultDatabase             = "company_earnings"
+	DefaultCompanyCollection    = "companies"
+	DefaultEarningsCollection   = "earnings"
+	DefaultStatementsCollection = "statements"
+	DefaultRevisionsCollection  = "revisions"
+	DefaultDividendsCollection  = "dividends"
+	DefaultSplitsCollection     = "splits"
+	DefaultAPIKeysCollection    = "api_keys"
+	DefaultTimeout              = 5 * time.Second
+)
+
+// Config holds the settings needed to reach the MongoDB deployment
+type Config struct {
+	URI                  string
+	Database             string
+	CompanyCollection    string
+	EarningsCollection   string
+	StatementsCollection string
+	RevisionsCollection  string
+	DividendsCollection  string
+	SplitsCollection     string
+	APIKeysCollection    string
+	Timeout              time.Duration
+}
+
+// ConfigFromEnv reads the MongoDB settings from the environment, falling back
+// to the defaults for anything that is not set
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		URI:                  os.Getenv("MONGODB_URI"),
+		Database:             os.Getenv("MONGODB_DATABASE"),
+		CompanyCollection:    os.Getenv("MONGODB_COMPANY_COLLECTION"),
+		EarningsCollection:   os.Getenv("MONGODB_EARNINGS_COLLECTION"),
+		StatementsCollection: os.Getenv("MONGODB_STATEMENTS_COLLECTION"),
+		RevisionsCollection:  os.Getenv("MONGODB_REVISIONS_COLLECTION"),
+		DividendsCollection:  os.Getenv("MONGODB_DIVIDENDS_COLLECTION"),
+		SplitsCollection:     os.Getenv("MONGODB_SPLITS_COLLECTION"),
+		APIKeysCollection:    os.Getenv("MONGODB_API_KEYS_COLLECTION"),
+	}
+
+	if timeout := os.Getenv("MONGODB_TIMEOUT"); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid MONGODB_TIMEOUT %q: %v", timeout, err)
+		}
+		cfg.Timeout = d
+	}
+
+	return cfg.withDefaults(), nil
+}
+
+func (c Config) withDefaults() Config {
+	if c.Database == "" {
+		c.Database = DefaultDatabase
+	}
+	if c.CompanyCollection == "" {
+		c.CompanyCollection = DefaultCompanyCollection
+	}
+	if c.EarningsCollection == "" {
+		c.EarningsCollection = DefaultEarningsCollection
+	}
+	if c.StatementsCollection == "" {
+		c.StatementsCollection = DefaultStatementsCollection
+	}
+	if c.RevisionsCollection == "" {
+		c.RevisionsCollection = DefaultRevisionsCollection
+	}
+	if c.DividendsCollection == "" {
+		c.DividendsCollection = DefaultDividendsCollection
+	}
+	if c.SplitsCollection == "" {
+		c.SplitsCollection = DefaultSplitsCollection
+	}
+	if c.APIKeysCollection == "" {
+		c.APIKeysCollection = DefaultAPIKeysCollection
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultTimeout
+	}
+	return c
+}
+
+// Client wraps a connected MongoDB client and hands out repositories bound
+// to the configured database
+type Client struct {
+	client *driver.Client
+	db     *driver.Database
+	cfg    Config
+}
+
+// Connect opens a connection to MongoDB and verifies it with a ping
+func Connect(ctx context.Context, cfg Config) (*Client, error) {
+	cfg = cfg.withDefaults()
+	if cfg.URI == "" {
+		return nil, fmt.Errorf("mongodb uri is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	client, err := driver.Connect(ctx, options.Client().ApplyURI(cfg.URI))
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to mongodb: %v", err)
+	}
+
+	if err := client.Ping(ctx, readpref.Primary()); err != nil {
+		_ = client.Disconnect(context.Background())
+		return nil, fmt.Errorf("error pinging mongodb: %v", err)
+	}
+
+	return &Client{
+		client: client,
+		db:     client.Database(cfg.Database),
+		cfg:    cfg,
+	}, nil
+}
+
+// EnsureIndexes creates the indexes the repositories rely on. The unique
+// index on symbol and deletion date keeps one live company per symbol, which
+// keeps the company search order total, next to any soft-deleted ones.
+// Earnings are unique per symbol and fiscal period, the calendar scans
+// earnings by report date, financials are unique per CIK and fiscal period
+// and listed per CIK from the latest period, revisions are listed per record
+// in the order they were recorded, dividends are unique per symbol, ex-date
+// and type, splits per symbol and date, and API keys are looked up by the
+// unique hash of their secret and listed per tenant and user.
+func (c *Client) EnsureIndexes(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	companies := c.db.Collection(c.cfg.CompanyCollection)
+	// the unique symbol index of earlier versions kept a single record per
+	// symbol, so recreating a deleted company overwrote it
+	if _, err := companies.Indexes().DropOne(ctx, "symbol_1"); err != nil && !isIndexNotFound(err) {
+		return fmt.Errorf("error dropping company indexes: %v", err)
+	}
+	_, err := companies.Indexes().CreateOne(ctx, driver.IndexModel{
+		Keys:    bson.D{{Key: "symbol", Value: 1}, {Key: "deletedAt", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("error creating company indexes: %v", err)
+	}
+
+	_, err = c.db.Collection(c.cfg.EarningsCollection).Indexes().CreateMany(ctx, []driver.IndexModel{
+		{
+			Keys:    bson.D{{Key: "symbol", Value: 1}, {Key: "fiscalYear", Value: 1}, {Key: "fiscalQuarter", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "reportDate", Value: 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("error creating earnings indexes: %v", err)
+	}
+
+	_, err = c.db.Collection(c.cfg.StatementsCollection).Indexes().CreateMany(ctx, []driver.IndexModel{
+		{
+			Keys:    bson.D{{Key: "cik", Value: 1}, {Key: "fiscalYear", Value: 1}, {Key: "fiscalPeriod", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "cik", Value: 1}, {Key: "periodEnd", Value: -1}, {Key: "fiscalPeriod", Value: 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("error creating statements indexes: %v", err)
+	}
+
+	_, err = c.db.Collection(c.cfg.RevisionsCollection).Indexes().CreateMany(ctx, []driver.IndexModel{
+		{Keys: bson.D{{Key: "symbol", Value: 1}, {Key: "fiscalYear", Value: 1}, {Key: "fiscalPeriod", Value: 1}, {Key: "recordedAt", Value: 1}}},
+		{Keys: bson.D{{Key: "cik", Value: 1}, {Key: "fiscalYear", Value: 1}, {Key: "fiscalPeriod", Value: 1}, {Key: "recordedAt", Value: 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("error creating revisions indexes: %v", err)
+	}
+
+	_, err = c.db.Collection(c.cfg.DividendsCollection).Indexes().CreateOne(ctx, driver.IndexModel{
+		Keys:    bson.D{{Key: "symbol", Value: 1}, {Key: "exDate", Value: 1}, {Key: "type", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("error creating dividends indexes: %v", err)
+	}
+
+	_, err = c.db.Collection(c.cfg.SplitsCollection).Indexes().CreateOne(ctx, driver.IndexModel{
+		Keys:    bson.D{{Key: "symbol", Value: 1}, {Key: "date", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("error creating splits indexes: %v", err)
+	}
+
+	_, err = c.db.Collection(c.cfg.APIKeysCollection).Indexes().CreateMany(ctx, []driver.IndexModel{
+		{
+			Keys:    bson.D{{Key: "hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "tenantId", Value: 1}, {Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("error creating api keys indexes: %v", err)
+	}
+	return nil
+}
+
+// isIndexNotFound reports whether err is the server error for dropping an
+// index that does not exist, or an index of a collection that does not
+func isIndexNotFound(err error) bool {
+	var cmdErr driver.CommandError
+	return errors.As(err, &cmdErr) && (cmdErr.Code == 26 || cmdErr.Code == 27)
+}
+
+// Disconnect closes the underlying connection pool
+func (c *Client) Disconnect(ctx context.Context) error {
+	return c.client.Disconnect(ctx)
+}
+
+// Companies returns the company repository backed by the configured collection
+func (c *Client) Companies() Repository {
+	return NewRepository(c.db.Collection(c.cfg.CompanyCollection), c.cfg.Timeout)
+}
+
+// Earnings returns the earnings repository backed by the configured collection
+func (c *Client) Earnings() EarningsRepository {
+	return NewEarningsRepository(c.db.Collection(c.cfg.EarningsCollection), c.db.Collection(c.cfg.RevisionsCollection), c.cfg.CompanyCollection, c.cfg.Timeout)
+}
+
+// Statements returns the financial statements repository backed by the
+// configured collection
+func (c *Client) Statements() StatementsRepository {
+	return NewStatementsRepository(c.db.Collection(c.cfg.StatementsCollection), c.db.Collection(c.cfg.RevisionsCollection), c.cfg.Timeout)
+}
+
+// Revisions returns the revision log of earnings and financials backed by
+// the configured collection
+func (c *Client) Revisions() RevisionRepository {
+	return NewRevisionRepository(c.db.Collection(c.cfg.RevisionsCollection), c.cfg.Timeout)
+}
+
+// CorporateActions returns the dividend and split repository backed by the
+// configured collections
+func (c *Client) CorporateActions() CorporateActionsRepository {
+	return NewCorporateActionsRepository(c.db.Collection(c.cfg.DividendsCollection), c.db.Collection(c.cfg.SplitsCollection), c.cfg.Timeout)
+}
+
+// APIKeys returns the API key repository backed by the configured collection
+func (c *Client) APIKeys() APIKeyRepository {
+	return NewAPIKeyRepository(c.db.Collection(c.cfg.APIKeysCollection), c.cfg.Timeout)
+}
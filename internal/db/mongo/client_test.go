@@ -0,0 +1,88 @@
+package mongo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    Config
+		wantErr bool
+	}{
+		{
+			name: "Defaults",
+			env:  map[string]string{"MONGODB_URI": "mongodb://localhost:27017"},
+			want: Config{
+				URI:                  "mongodb://localhost:27017",
+				Database:             DefaultDatabase,
+				CompanyCollection:    DefaultCompanyCollection,
+				EarningsCollection:   DefaultEarningsCollection,
+				StatementsCollection: DefaultStatementsCollection,
+				RevisionsCollection:  DefaultRevisionsCollection,
+				DividendsCollection:  DefaultDividendsCollection,
+				SplitsCollection:     DefaultSplitsCollection,
+				APIKeysCollection:    DefaultAPIKeysCollection,
+				Timeout:              DefaultTimeout,
+			},
+		},
+		{
+			name: "Overrides",
+			env: map[string]string{
+				"MONGODB_URI":                   "mongodb://db:27017",
+				"MONGODB_DATABASE":              "earnings",
+				"MONGODB_COMPANY_COLLECTION":    "issuers",
+				"MONGODB_EARNINGS_COLLECTION":   "results",
+				"MONGODB_STATEMENTS_COLLECTION": "fundamentals",
+				"MONGODB_REVISIONS_COLLECTION":  "audit",
+				"MONGODB_DIVIDENDS_COLLECTION":  "distributions",
+				"MONGODB_SPLITS_COLLECTION":     "share_splits",
+				"MONGODB_API_KEYS_COLLECTION":   "credentials",
+				"MONGODB_TIMEOUT":               "2s",
+			},
+			want: Config{
+				URI:                  "mongodb://db:27017",
+				Database:             "earnings",
+				CompanyCollection:    "issuers",
+				EarningsCollection:   "results",
+				StatementsCollection: "fundamentals",
+				RevisionsCollection:  "audit",
+				DividendsCollection:  "distributions",
+				SplitsCollection:     "share_splits",
+				APIKeysCollection:    "credentials",
+				Timeout:              2 * time.Second,
+			},
+		},
+		{
+			name:    "Invalid timeout",
+			env:     map[string]string{"MONGODB_TIMEOUT": "soon"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"MONGODB_URI", "MONGODB_DATABASE", "MONGODB_COMPANY_COLLECTION", "MONGODB_EARNINGS_COLLECTION", "MONGODB_STATEMENTS_COLLECTION", "MONGODB_REVISIONS_COLLECTION", "MONGODB_DIVIDENDS_COLLECTION", "MONGODB_SPLITS_COLLECTION", "MONGODB_API_KEYS_COLLECTION", "MONGODB_TIMEOUT"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			got, err := ConfigFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ConfigFromEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConnect_RequiresURI(t *testing.T) {
+	_, err := Connect(context.Background(), Config{})
+	assert.Error(t, err)
+}
@@ -4,40 +4,540 @@ package mongo
 import (
 	"context"
 	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
 	"github.com/api-moose/company-earnings/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// SearchMode controls how the query is matched against symbols and names
+type SearchMode string
+
+const (
+	SearchModeExact      SearchMode = "exact"
+	SearchModeStartsWith SearchMode = "starts_with"
+	SearchModeContains   SearchMode = "contains"
+)
+
+// SearchModes lists the supported search modes
+var SearchModes = []string{string(SearchModeExact), string(SearchModeStartsWith), string(SearchModeContains)}
+
+// SearchRequest describes a company search. Exchange and Active are optional
+// filters applied on top of the query match. When After is set the results
+// start right after that position.
+type SearchRequest struct {
+	Query    string
+	Mode     SearchMode
+	Exchange string
+	Active   *bool
+	Limit    int
+	After    *SearchCursor
+}
+
+// SearchCursor is the position of a company within the ranked search order
+type SearchCursor struct {
+	Rank   int    `json:"r"`
+	Symbol string `json:"s"`
+}
+
+// SearchResult holds one page of companies along with the total number of
+// matches and the position to continue from, if there are more
+type SearchResult struct {
+	Companies []models.Company
+	Total     int64
+	Next      *SearchCursor
+}
+
 type Repository interface {
-	Search(ctx context.Context, query string, limit int) ([]models.Company, error)
+	Search(ctx context.Context, req SearchRequest) (*SearchResult, error)
+	GetBySymbol(ctx context.Context, symbol string) (*models.Company, error)
+	GetByCIK(ctx context.Context, cik string) (*models.Company, error)
+	Lookup(ctx context.Context, symbols, ciks []string) ([]models.Company, error)
+	List(ctx context.Context, filter ListFilter) ([]models.Company, error)
+	Create(ctx context.Context, company models.Company) error
+	Update(ctx context.Context, symbol string, update models.CompanyUpdate) (*models.Company, error)
+	Delete(ctx context.Context, symbol string) error
+	Upsert(ctx context.Context, company models.Company) (UpsertResult, error)
+}
+
+// ListFilter narrows the active companies List returns to those listed on
+// one of Exchanges and in one of Sectors, compared without regard to case.
+// Empty lists accept any value.
+type ListFilter struct {
+	Exchanges []string
+	Sectors   []string
+}
+
+// UpsertResult reports what an Upsert did to the stored company
+type UpsertResult int
+
+const (
+	UpsertUnchanged UpsertResult = iota
+	UpsertInserted
+	UpsertUpdated
+)
+
+// collection is the subset of *driver.Collection the repositories rely on, so
+// tests can substitute an in-process stand-in for a running mongod
+type collection interface {
+	Aggregate(ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) (*driver.Cursor, error)
+	CountDocuments(ctx context.Context, filter interface{}, opts ...*options.CountOptions) (int64, error)
+	Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*driver.Cursor, error)
+	FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) *driver.SingleResult
+	FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, opts ...*options.FindOneAndUpdateOptions) *driver.SingleResult
+	InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*driver.InsertOneResult, error)
+	ReplaceOne(ctx context.Context, filter interface{}, replacement interface{}, opts ...*options.ReplaceOptions) (*driver.UpdateResult, error)
+	UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*driver.UpdateResult, error)
+}
+
+// notDeleted restricts a filter to companies that have not been soft-deleted
+var notDeleted = bson.E{Key: "deletedAt", Value: nil}
+
+type rankedCompany struct {
+	models.Company `bson:",inline"`
+	Rank           int `bson:"_rank"`
 }
 
-type companyRepository struct{}
+type companyRepository struct {
+	coll    collection
+	timeout time.Duration
+}
+
+func NewRepository(coll *driver.Collection, timeout time.Duration) Repository {
+	return newCompanyRepository(coll, timeout)
+}
 
-func NewRepository() Repository {
-	return &companyRepository{}
+func newCompanyRepository(coll collection, timeout time.Duration) *companyRepository {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &companyRepository{coll: coll, timeout: timeout}
 }
 
-func (r *companyRepository) Search(ctx context.Context, query string, limit int) ([]models.Company, error) {
-	if query == "" {
+func (r *companyRepository) Search(ctx context.Context, req SearchRequest) (*SearchResult, error) {
+	if req.Query == "" {
 		return nil, errors.New("query cannot be empty")
 	}
+	if req.Mode == "" {
+		req.Mode = SearchModeContains
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	total, err := r.coll.CountDocuments(ctx, searchFilter(req))
+	if err != nil {
+		return nil, dberrors.NewDBError(fmt.Sprintf("error counting companies: %v", err))
+	}
+
+	cursor, err := r.coll.Aggregate(ctx, searchPipeline(req))
+	if err != nil {
+		return nil, dberrors.NewDBError(fmt.Sprintf("error searching companies: %v", err))
+	}
+	defer cursor.Close(ctx)
+
+	var ranked []rankedCompany
+	if err := cursor.All(ctx, &ranked); err != nil {
+		return nil, dberrors.NewDBError(fmt.Sprintf("error decoding companies: %v", err))
+	}
 
-	// TODO: Implement actual database search logic
-	// For now, we'll return a mock result
-	if query == "Apple" {
-		return []models.Company{
-			{
-				Symbol:       "AAPL",
-				CIK:          "0000320193",
-				SecurityName: "Apple Inc.",
-				SecurityType: "Common Stock",
-				Region:       "US",
-				Exchange:     "NASDAQ",
-				Sector:       "Technology",
-			},
-		}, nil
+	// One extra document is fetched to find out whether another page exists
+	var next *SearchCursor
+	if req.Limit > 0 && len(ranked) > req.Limit {
+		ranked = ranked[:req.Limit]
+		last := ranked[len(ranked)-1]
+		next = &SearchCursor{Rank: last.Rank, Symbol: last.Symbol}
+	}
+
+	companies := make([]models.Company, 0, len(ranked))
+	for _, c := range ranked {
+		companies = append(companies, c.Company)
+	}
+
+	return &SearchResult{Companies: companies, Total: total, Next: next}, nil
+}
+
+// GetBySymbol returns the company listed under symbol, or dberrors.ErrNotFound
+func (r *companyRepository) GetBySymbol(ctx context.Context, symbol string) (*models.Company, error) {
+	return r.findOne(ctx, bson.D{{Key: "symbol", Value: strings.ToUpper(symbol)}, notDeleted})
+}
+
+// GetByCIK returns the company registered under the ten digit cik. Several
+// share classes can share one CIK, in which case active listings win and
+// ties are broken by symbol so the answer is deterministic.
+func (r *companyRepository) GetByCIK(ctx context.Context, cik string) (*models.Company, error) {
+	return r.findOne(ctx, bson.D{{Key: "cik", Value: cik}, notDeleted},
+		options.FindOne().SetSort(bson.D{{Key: "active", Value: -1}, {Key: "symbol", Value: 1}}))
+}
+
+// Lookup returns every company listed under one of the symbols or registered
+// under one of the ten digit ciks in a single round trip. Results are ordered
+// like GetByCIK so callers can pick the first listing per CIK.
+func (r *companyRepository) Lookup(ctx context.Context, symbols, ciks []string) ([]models.Company, error) {
+	if len(symbols) == 0 && len(ciks) == 0 {
+		return []models.Company{}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	upper := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		upper[i] = strings.ToUpper(symbol)
+	}
+
+	cursor, err := r.coll.Find(ctx, lookupFilter(upper, ciks),
+		options.Find().SetSort(bson.D{{Key: "active", Value: -1}, {Key: "symbol", Value: 1}}))
+	if err != nil {
+		return nil, dberrors.NewDBError(fmt.Sprintf("error looking up companies: %v", err))
+	}
+	defer cursor.Close(ctx)
+
+	companies := []models.Company{}
+	if err := cursor.All(ctx, &companies); err != nil {
+		return nil, dberrors.NewDBError(fmt.Sprintf("error decoding companies: %v", err))
+	}
+	return companies, nil
+}
+
+// List returns the active companies matching filter, ordered by symbol
+func (r *companyRepository) List(ctx context.Context, filter ListFilter) ([]models.Company, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	cursor, err := r.coll.Find(ctx, listFilter(filter), options.Find().SetSort(bson.D{{Key: "symbol", Value: 1}}))
+	if err != nil {
+		return nil, dberrors.NewDBError(fmt.Sprintf("error listing companies: %v", err))
+	}
+	defer cursor.Close(ctx)
+
+	companies := []models.Company{}
+	if err := cursor.All(ctx, &companies); err != nil {
+		return nil, dberrors.NewDBError(fmt.Sprintf("error decoding companies: %v", err))
+	}
+	return companies, nil
+}
+
+func listFilter(filter ListFilter) bson.D {
+	query := bson.D{{Key: "active", Value: true}, notDeleted}
+	if len(filter.Exchanges) > 0 {
+		upper := make([]string, len(filter.Exchanges))
+		for i, exchange := range filter.Exchanges {
+			upper[i] = strings.ToUpper(exchange)
+		}
+		query = append(query, bson.E{Key: "exchange", Value: bson.D{{Key: "$in", Value: upper}}})
+	}
+	if len(filter.Sectors) > 0 {
+		quoted := make([]string, len(filter.Sectors))
+		for i, sector := range filter.Sectors {
+			quoted[i] = regexp.QuoteMeta(sector)
+		}
+		pattern := "^(" + strings.Join(quoted, "|") + ")$"
+		query = append(query, bson.E{Key: "sector", Value: bson.D{{Key: "$regex", Value: pattern}, {Key: "$options", Value: "i"}}})
+	}
+	return query
+}
+
+func lookupFilter(symbols, ciks []string) bson.D {
+	var clauses bson.A
+	if len(symbols) > 0 {
+		clauses = append(clauses, bson.D{{Key: "symbol", Value: bson.D{{Key: "$in", Value: symbols}}}})
+	}
+	if len(ciks) > 0 {
+		clauses = append(clauses, bson.D{{Key: "cik", Value: bson.D{{Key: "$in", Value: ciks}}}})
+	}
+	return bson.D{{Key: "$or", Value: clauses}, notDeleted}
+}
+
+// Create inserts a new company. A soft-deleted company under the same symbol
+// is kept as it was, any other existing one yields dberrors.ErrConflict.
+func (r *companyRepository) Create(ctx context.Context, company models.Company) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	company.DeletedAt = nil
+	if _, err := r.coll.InsertOne(ctx, company); err != nil {
+		if driver.IsDuplicateKeyError(err) {
+			return dberrors.ErrConflict
+		}
+		return dberrors.NewDBError(fmt.Sprintf("error creating company: %v", err))
+	}
+	return nil
+}
+
+// maxUpdateAttempts bounds how often a write guarded against concurrent
+// changes is retried before giving up with dberrors.ErrWriteConflict
+const maxUpdateAttempts = 3
+
+// Update applies the fields set in update and returns the updated company.
+// The update is validated against the stored company, so a patch cannot set
+// a delisting date before the stored listing date, and is only written while
+// the fields that validation read are unchanged. A failed validation is
+// returned as a *models.FieldError.
+func (r *companyRepository) Update(ctx context.Context, symbol string, update models.CompanyUpdate) (*models.Company, error) {
+	set := updateFields(update)
+	if len(set) == 0 {
+		return r.GetBySymbol(ctx, symbol)
+	}
+
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		stored, err := r.GetBySymbol(ctx, symbol)
+		if err != nil {
+			return nil, err
+		}
+		merged := stored.Apply(update)
+		if err := merged.Validate(); err != nil {
+			return nil, err
+		}
+
+		company, err := r.updateIfUnchanged(ctx, stored, set)
+		if errors.Is(err, driver.ErrNoDocuments) {
+			// deleted or changed since it was read, which the next read
+			// tells apart
+			continue
+		}
+		if err != nil {
+			return nil, dberrors.NewDBError(fmt.Sprintf("error updating company: %v", err))
+		}
+		return company, nil
+	}
+	return nil, dberrors.ErrWriteConflict
+}
+
+// updateIfUnchanged sets the fields of the stored company unless the fields
+// its validation depends on have changed since it was read
+func (r *companyRepository) updateIfUnchanged(ctx context.Context, stored *models.Company, set bson.D) (*models.Company, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "symbol", Value: stored.Symbol},
+		notDeleted,
+		{Key: "active", Value: stored.Active},
+		{Key: "listingDate", Value: stored.ListingDate},
+		{Key: "delistingDate", Value: stored.DelistingDate},
+	}
+	var company models.Company
+	err := r.coll.FindOneAndUpdate(ctx, filter,
+		bson.D{{Key: "$set", Value: set}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&company)
+	if err != nil {
+		return nil, err
+	}
+	return &company, nil
+}
+
+// Delete soft-deletes a company so it disappears from reads while the record
+// is kept for auditing
+func (r *companyRepository) Delete(ctx context.Context, symbol string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	res, err := r.coll.UpdateOne(ctx,
+		bson.D{{Key: "symbol", Value: strings.ToUpper(symbol)}, notDeleted},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "deletedAt", Value: time.Now().UTC()}}}},
+	)
+	if err != nil {
+		return dberrors.NewDBError(fmt.Sprintf("error deleting company: %v", err))
+	}
+	if res.MatchedCount == 0 {
+		return dberrors.ErrNotFound
+	}
+	return nil
+}
+
+// Upsert creates a company or refreshes the listing fields of an existing one.
+// Curated fields such as the sector, active flag, listing dates and price are
+// only written when the company is inserted, so a re-import does not
+// reactivate a deactivated or delisted company. A symbol whose company was
+// soft-deleted, and not created again since, stays deleted and yields
+// dberrors.ErrConflict.
+func (r *companyRepository) Upsert(ctx context.Context, company models.Company) (UpsertResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	set := bson.D{
+		{Key: "cik", Value: company.CIK},
+		{Key: "securityName", Value: company.SecurityName},
+		{Key: "securityType", Value: company.SecurityType},
+		{Key: "region", Value: company.Region},
+		{Key: "exchange", Value: company.Exchange},
+	}
+	setOnInsert := bson.D{
+		{Key: "sector", Value: company.Sector},
+		{Key: "active", Value: company.Active},
+	}
+	if company.ListingDate != nil {
+		setOnInsert = append(setOnInsert, bson.E{Key: "listingDate", Value: company.ListingDate})
+	}
+	if company.DelistingDate != nil {
+		setOnInsert = append(setOnInsert, bson.E{Key: "delistingDate", Value: company.DelistingDate})
+	}
+	if company.Price != nil {
+		setOnInsert = append(setOnInsert, bson.E{Key: "price", Value: company.Price})
+	}
+
+	// a live company sorts before the deleted ones, as its deletedAt is
+	// missing
+	latest, err := r.findOne(ctx, bson.D{{Key: "symbol", Value: company.Symbol}},
+		options.FindOne().SetSort(bson.D{{Key: "deletedAt", Value: 1}}))
+	switch {
+	case errors.Is(err, dberrors.ErrNotFound):
+	case err != nil:
+		return UpsertUnchanged, err
+	case latest.DeletedAt != nil:
+		return UpsertUnchanged, dberrors.ErrConflict
+	}
+
+	res, err := r.coll.UpdateOne(ctx,
+		bson.D{{Key: "symbol", Value: company.Symbol}, notDeleted},
+		bson.D{{Key: "$set", Value: set}, {Key: "$setOnInsert", Value: setOnInsert}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		// another upsert or Create inserted the symbol since it was read
+		if driver.IsDuplicateKeyError(err) {
+			return UpsertUnchanged, dberrors.ErrConflict
+		}
+		return UpsertUnchanged, dberrors.NewDBError(fmt.Sprintf("error upserting company: %v", err))
+	}
+
+	switch {
+	case res.UpsertedCount > 0:
+		return UpsertInserted, nil
+	case res.ModifiedCount > 0:
+		return UpsertUpdated, nil
+	default:
+		return UpsertUnchanged, nil
+	}
+}
+
+func updateFields(u models.CompanyUpdate) bson.D {
+	var set bson.D
+	add := func(key string, value interface{}, ok bool) {
+		if ok {
+			set = append(set, bson.E{Key: key, Value: value})
+		}
+	}
+	add("cik", u.CIK, u.CIK != nil)
+	add("securityName", u.SecurityName, u.SecurityName != nil)
+	add("securityType", u.SecurityType, u.SecurityType != nil)
+	add("region", u.Region, u.Region != nil)
+	add("exchange", u.Exchange, u.Exchange != nil)
+	add("sector", u.Sector, u.Sector != nil)
+	add("active", u.Active, u.Active != nil)
+	add("listingDate", u.ListingDate, u.ListingDate != nil)
+	add("delistingDate", u.DelistingDate, u.DelistingDate != nil)
+	add("price", u.Price, u.Price != nil)
+	return set
+}
+
+func (r *companyRepository) findOne(ctx context.Context, filter bson.D, opts ...*options.FindOneOptions) (*models.Company, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var company models.Company
+	if err := r.coll.FindOne(ctx, filter, opts...).Decode(&company); err != nil {
+		if errors.Is(err, driver.ErrNoDocuments) {
+			return nil, dberrors.ErrNotFound
+		}
+		return nil, dberrors.NewDBError(fmt.Sprintf("error finding company: %v", err))
+	}
+	return &company, nil
+}
+
+// Search results are ranked so that an exact symbol hit always comes first,
+// followed by symbol prefixes, exact names, name prefixes and finally any
+// other substring match. Ties are broken by symbol, which is unique, so the
+// order is total and pages never overlap.
+const (
+	rankExactSymbol = iota
+	rankSymbolPrefix
+	rankExactName
+	rankNamePrefix
+	rankOther
+)
+
+const rankField = "_rank"
+
+// searchPipeline builds the aggregation that filters, ranks and pages a search
+func searchPipeline(req SearchRequest) bson.A {
+	pipeline := bson.A{
+		bson.D{{Key: "$match", Value: searchFilter(req)}},
+		bson.D{{Key: "$addFields", Value: bson.D{{Key: rankField, Value: rankExpression(req.Query)}}}},
+	}
+	if req.After != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: afterFilter(*req.After)}})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$sort", Value: bson.D{{Key: rankField, Value: 1}, {Key: "symbol", Value: 1}}}})
+	if req.Limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: req.Limit + 1}})
+	}
+	return pipeline
+}
+
+// afterFilter selects the companies that sort strictly after the cursor
+func afterFilter(after SearchCursor) bson.D {
+	return bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: rankField, Value: bson.D{{Key: "$gt", Value: after.Rank}}}},
+		bson.D{{Key: rankField, Value: after.Rank}, {Key: "symbol", Value: bson.D{{Key: "$gt", Value: after.Symbol}}}},
+	}}}
+}
+
+// searchFilter matches the query case-insensitively against the symbol or the
+// security name according to the search mode, narrowed by the optional
+// exchange and active filters
+func searchFilter(req SearchRequest) bson.D {
+	pattern := regexp.QuoteMeta(req.Query)
+	switch req.Mode {
+	case SearchModeExact:
+		pattern = "^" + pattern + "$"
+	case SearchModeStartsWith:
+		pattern = "^" + pattern
+	}
+
+	filter := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "symbol", Value: bson.D{{Key: "$regex", Value: pattern}, {Key: "$options", Value: "i"}}}},
+		bson.D{{Key: "securityName", Value: bson.D{{Key: "$regex", Value: pattern}, {Key: "$options", Value: "i"}}}},
+	}}}
+	if req.Exchange != "" {
+		filter = append(filter, bson.E{Key: "exchange", Value: req.Exchange})
+	}
+	if req.Active != nil {
+		filter = append(filter, bson.E{Key: "active", Value: *req.Active})
+	}
+	return append(filter, notDeleted)
+}
+
+func rankExpression(query string) bson.D {
+	q := strings.ToUpper(query)
+	symbol := bson.D{{Key: "$toUpper", Value: "$symbol"}}
+	name := bson.D{{Key: "$toUpper", Value: "$securityName"}}
+
+	equals := func(field bson.D) bson.D {
+		return bson.D{{Key: "$eq", Value: bson.A{field, q}}}
+	}
+	startsWith := func(field bson.D) bson.D {
+		return bson.D{{Key: "$eq", Value: bson.A{bson.D{{Key: "$indexOfCP", Value: bson.A{field, q}}}, 0}}}
+	}
+	branch := func(cond bson.D, rank int) bson.D {
+		return bson.D{{Key: "case", Value: cond}, {Key: "then", Value: rank}}
 	}
 
-	return []models.Company{}, nil
+	return bson.D{{Key: "$switch", Value: bson.D{
+		{Key: "branches", Value: bson.A{
+			branch(equals(symbol), rankExactSymbol),
+			branch(startsWith(symbol), rankSymbolPrefix),
+			branch(equals(name), rankExactName),
+			branch(startsWith(name), rankNamePrefix),
+		}},
+		{Key: "default", Value: rankOther},
+	}}}
 }
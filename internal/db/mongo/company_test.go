@@ -3,55 +3,707 @@ package mongo
 
 import (
 	"context"
+	"errors"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
 	"github.com/api-moose/company-earnings/internal/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// MockCollection is an in-process stand-in for a mongod collection. Results
+// are served through cursors built from in-memory documents.
+type MockCollection struct {
+	mock.Mock
+}
+
+func (m *MockCollection) Aggregate(ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) (*driver.Cursor, error) {
+	args := m.Called(ctx, pipeline, opts)
+	if err := args.Error(1); err != nil {
+		return nil, err
+	}
+	return driver.NewCursorFromDocuments(args.Get(0).([]interface{}), nil, nil)
+}
+
+func (m *MockCollection) CountDocuments(ctx context.Context, filter interface{}, opts ...*options.CountOptions) (int64, error) {
+	args := m.Called(ctx, filter, opts)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockCollection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*driver.Cursor, error) {
+	args := m.Called(ctx, filter, opts)
+	if err := args.Error(1); err != nil {
+		return nil, err
+	}
+	return driver.NewCursorFromDocuments(args.Get(0).([]interface{}), nil, nil)
+}
+
+func (m *MockCollection) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) *driver.SingleResult {
+	args := m.Called(ctx, filter, opts)
+	doc := args.Get(0)
+	if doc == nil {
+		doc = bson.D{}
+	}
+	return driver.NewSingleResultFromDocument(doc, args.Error(1), nil)
+}
+
+func (m *MockCollection) FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, opts ...*options.FindOneAndUpdateOptions) *driver.SingleResult {
+	args := m.Called(ctx, filter, update, opts)
+	doc := args.Get(0)
+	if doc == nil {
+		doc = bson.D{}
+	}
+	return driver.NewSingleResultFromDocument(doc, args.Error(1), nil)
+}
+
+func (m *MockCollection) InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*driver.InsertOneResult, error) {
+	args := m.Called(ctx, document, opts)
+	if err := args.Error(0); err != nil {
+		return nil, err
+	}
+	return &driver.InsertOneResult{}, nil
+}
+
+func (m *MockCollection) ReplaceOne(ctx context.Context, filter interface{}, replacement interface{}, opts ...*options.ReplaceOptions) (*driver.UpdateResult, error) {
+	args := m.Called(ctx, filter, replacement, opts)
+	if err := args.Error(1); err != nil {
+		return nil, err
+	}
+	return args.Get(0).(*driver.UpdateResult), nil
+}
+
+func (m *MockCollection) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*driver.UpdateResult, error) {
+	args := m.Called(ctx, filter, update, opts)
+	if err := args.Error(1); err != nil {
+		return nil, err
+	}
+	return args.Get(0).(*driver.UpdateResult), nil
+}
+
+var apple = models.Company{
+	Symbol:       "AAPL",
+	CIK:          "0000320193",
+	SecurityName: "Apple Inc.",
+	SecurityType: "Common Stock",
+	Region:       "US",
+	Exchange:     "NASDAQ",
+	Sector:       "Technology",
+	Active:       true,
+}
+
+var aple = models.Company{
+	Symbol:       "APLE",
+	CIK:          "0001418121",
+	SecurityName: "Apple Hospitality REIT Inc.",
+	SecurityType: "REIT",
+	Region:       "US",
+	Exchange:     "NYSE",
+	Sector:       "Real Estate",
+	Active:       true,
+}
+
 func TestCompanyRepository_Search(t *testing.T) {
 	tests := []struct {
-		name    string
-		query   string
-		limit   int
-		want    []models.Company
-		wantErr bool
+		name       string
+		req        SearchRequest
+		total      int64
+		docs       []interface{}
+		findErr    error
+		want       *SearchResult
+		wantErr    bool
+		wantDBErr  bool
+		expectFind bool
 	}{
 		{
-			name:  "Valid search",
-			query: "Apple",
-			limit: 1,
-			want: []models.Company{
-				{
-					Symbol:       "AAPL",
-					CIK:          "0000320193",
-					SecurityName: "Apple Inc.",
-					SecurityType: "Common Stock",
-					Region:       "US",
-					Exchange:     "NASDAQ",
-					Sector:       "Technology",
-				},
+			name:       "Valid search",
+			req:        SearchRequest{Query: "Apple", Mode: SearchModeContains, Limit: 10},
+			total:      1,
+			docs:       []interface{}{rankedCompany{Company: apple, Rank: rankExactName}},
+			want:       &SearchResult{Companies: []models.Company{apple}, Total: 1},
+			expectFind: true,
+		},
+		{
+			name:  "More pages",
+			req:   SearchRequest{Query: "Apple", Mode: SearchModeContains, Limit: 1},
+			total: 2,
+			docs: []interface{}{
+				rankedCompany{Company: apple, Rank: rankNamePrefix},
+				rankedCompany{Company: aple, Rank: rankNamePrefix},
+			},
+			want: &SearchResult{
+				Companies: []models.Company{apple},
+				Total:     2,
+				Next:      &SearchCursor{Rank: rankNamePrefix, Symbol: "AAPL"},
 			},
-			wantErr: false,
+			expectFind: true,
+		},
+		{
+			name:       "Last page",
+			req:        SearchRequest{Query: "Apple", Mode: SearchModeContains, Limit: 1, After: &SearchCursor{Rank: rankNamePrefix, Symbol: "AAPL"}},
+			total:      2,
+			docs:       []interface{}{rankedCompany{Company: aple, Rank: rankNamePrefix}},
+			want:       &SearchResult{Companies: []models.Company{aple}, Total: 2},
+			expectFind: true,
+		},
+		{
+			name:       "No matches",
+			req:        SearchRequest{Query: "Nothing", Mode: SearchModeExact, Limit: 10},
+			docs:       []interface{}{},
+			want:       &SearchResult{Companies: []models.Company{}},
+			expectFind: true,
 		},
 		{
 			name:    "Empty query",
-			query:   "",
-			limit:   10,
+			req:     SearchRequest{Query: "", Limit: 10},
 			want:    nil,
 			wantErr: true,
 		},
+		{
+			name:       "Database failure",
+			req:        SearchRequest{Query: "Apple", Mode: SearchModeStartsWith, Limit: 10},
+			findErr:    errors.New("connection refused"),
+			want:       nil,
+			wantErr:    true,
+			wantDBErr:  true,
+			expectFind: true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			r := NewRepository()
-			got, err := r.Search(context.Background(), tt.query, tt.limit)
+			coll := new(MockCollection)
+			if tt.expectFind {
+				coll.On("CountDocuments", mock.Anything, searchFilter(tt.req), mock.Anything).Return(tt.total, nil)
+				coll.On("Aggregate", mock.Anything, searchPipeline(tt.req), mock.Anything).Return(tt.docs, tt.findErr)
+			}
+
+			r := newCompanyRepository(coll, time.Second)
+			got, err := r.Search(context.Background(), tt.req)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CompanyRepository.Search() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			assert.Equal(t, tt.wantDBErr, dberrors.IsDBError(err))
 			assert.Equal(t, tt.want, got)
+			coll.AssertExpectations(t)
 		})
 	}
 }
+
+func TestCompanyRepository_SearchDefaultsToContains(t *testing.T) {
+	coll := new(MockCollection)
+	want := SearchRequest{Query: "app", Mode: SearchModeContains, Limit: 5}
+	coll.On("CountDocuments", mock.Anything, searchFilter(want), mock.Anything).Return(int64(0), nil)
+	coll.On("Aggregate", mock.Anything, searchPipeline(want), mock.Anything).Return([]interface{}{}, nil)
+
+	_, err := newCompanyRepository(coll, time.Second).Search(context.Background(), SearchRequest{Query: "app", Limit: 5})
+	assert.NoError(t, err)
+	coll.AssertExpectations(t)
+}
+
+func TestCompanyRepository_GetBySymbol(t *testing.T) {
+	tests := []struct {
+		name    string
+		symbol  string
+		doc     interface{}
+		findErr error
+		want    *models.Company
+		wantErr error
+	}{
+		{"Found", "aapl", apple, nil, &apple, nil},
+		{"Not found", "ZZZZ", nil, driver.ErrNoDocuments, nil, dberrors.ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coll := new(MockCollection)
+			coll.On("FindOne", mock.Anything, bson.D{{Key: "symbol", Value: strings.ToUpper(tt.symbol)}, notDeleted}, mock.Anything).Return(tt.doc, tt.findErr)
+
+			got, err := newCompanyRepository(coll, time.Second).GetBySymbol(context.Background(), tt.symbol)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+			coll.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCompanyRepository_GetByCIK(t *testing.T) {
+	coll := new(MockCollection)
+	coll.On("FindOne", mock.Anything, bson.D{{Key: "cik", Value: "0000320193"}, notDeleted}, mock.Anything).Return(apple, nil)
+	coll.On("FindOne", mock.Anything, bson.D{{Key: "cik", Value: "0000000001"}, notDeleted}, mock.Anything).Return(nil, errors.New("socket closed"))
+
+	r := newCompanyRepository(coll, time.Second)
+
+	got, err := r.GetByCIK(context.Background(), "0000320193")
+	assert.NoError(t, err)
+	assert.Equal(t, &apple, got)
+
+	_, err = r.GetByCIK(context.Background(), "0000000001")
+	assert.True(t, dberrors.IsDBError(err))
+
+	coll.AssertExpectations(t)
+}
+
+func TestCompanyRepository_Lookup(t *testing.T) {
+	coll := new(MockCollection)
+	filter := lookupFilter([]string{"AAPL", "ZZZZ"}, []string{"0001418121"})
+	coll.On("Find", mock.Anything, filter, mock.Anything).Return([]interface{}{apple, aple}, nil)
+
+	r := newCompanyRepository(coll, time.Second)
+	got, err := r.Lookup(context.Background(), []string{"aapl", "ZZZZ"}, []string{"0001418121"})
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Company{apple, aple}, got)
+
+	got, err = r.Lookup(context.Background(), nil, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+
+	coll.AssertExpectations(t)
+}
+
+func TestLookupFilter(t *testing.T) {
+	assert.Equal(t, bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "symbol", Value: bson.D{{Key: "$in", Value: []string{"AAPL"}}}}},
+	}}, notDeleted}, lookupFilter([]string{"AAPL"}, nil))
+
+	assert.Equal(t, bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "cik", Value: bson.D{{Key: "$in", Value: []string{"0000320193"}}}}},
+	}}, notDeleted}, lookupFilter(nil, []string{"0000320193"}))
+}
+
+func TestCompanyRepository_List(t *testing.T) {
+	coll := new(MockCollection)
+	filter := listFilter(ListFilter{Exchanges: []string{"nasdaq"}})
+	coll.On("Find", mock.Anything, filter, mock.Anything).Return([]interface{}{apple}, nil).Once()
+	coll.On("Find", mock.Anything, listFilter(ListFilter{}), mock.Anything).Return(nil, errors.New("socket closed")).Once()
+
+	r := newCompanyRepository(coll, time.Second)
+	got, err := r.List(context.Background(), ListFilter{Exchanges: []string{"nasdaq"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Company{apple}, got)
+
+	_, err = r.List(context.Background(), ListFilter{})
+	assert.True(t, dberrors.IsDBError(err))
+
+	coll.AssertExpectations(t)
+}
+
+func TestListFilter(t *testing.T) {
+	assert.Equal(t, bson.D{{Key: "active", Value: true}, notDeleted}, listFilter(ListFilter{}))
+
+	assert.Equal(t, bson.D{
+		{Key: "active", Value: true},
+		notDeleted,
+		{Key: "exchange", Value: bson.D{{Key: "$in", Value: []string{"NASDAQ", "NYSE"}}}},
+		{Key: "sector", Value: bson.D{{Key: "$regex", Value: `^(Technology|Real Estate|S\.A\.)$`}, {Key: "$options", Value: "i"}}},
+	}, listFilter(ListFilter{Exchanges: []string{"nasdaq", "NYSE"}, Sectors: []string{"Technology", "Real Estate", "S.A."}}))
+}
+
+func TestSearchFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		mode    SearchMode
+		pattern string
+	}{
+		{"Exact", "AAPL", SearchModeExact, `^AAPL$`},
+		{"Starts with", "App", SearchModeStartsWith, `^App`},
+		{"Contains", "ppl", SearchModeContains, `ppl`},
+		{"Escapes regex", "BRK.A", SearchModeExact, `^BRK\.A$`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clauses := searchFilter(SearchRequest{Query: tt.query, Mode: tt.mode})[0].Value.(bson.A)
+			assert.Len(t, clauses, 2)
+			for i, field := range []string{"symbol", "securityName"} {
+				clause := clauses[i].(bson.D)[0]
+				assert.Equal(t, field, clause.Key)
+				assert.Equal(t, bson.D{{Key: "$regex", Value: tt.pattern}, {Key: "$options", Value: "i"}}, clause.Value)
+			}
+		})
+	}
+}
+
+func TestSearchFilter_ExchangeAndActive(t *testing.T) {
+	active := false
+	filter := searchFilter(SearchRequest{Query: "a", Mode: SearchModeContains, Exchange: "NYSE", Active: &active})
+
+	assert.Len(t, filter, 4)
+	assert.Equal(t, bson.E{Key: "exchange", Value: "NYSE"}, filter[1])
+	assert.Equal(t, bson.E{Key: "active", Value: false}, filter[2])
+	assert.Equal(t, notDeleted, filter[3], "soft-deleted companies are never searched")
+
+	assert.Len(t, searchFilter(SearchRequest{Query: "a"}), 2)
+}
+
+func TestCompanyRepository_Create(t *testing.T) {
+	duplicate := driver.WriteException{WriteErrors: []driver.WriteError{{Code: 11000, Message: "duplicate key"}}}
+
+	tests := []struct {
+		name      string
+		insertErr error
+		wantErr   error
+	}{
+		{"Inserted", nil, nil},
+		{"Duplicate", duplicate, dberrors.ErrConflict},
+		{"Failure", errors.New("socket closed"), dberrors.NewDBError("error creating company: socket closed")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coll := new(MockCollection)
+			coll.On("InsertOne", mock.Anything, apple, mock.Anything).Return(tt.insertErr)
+
+			err := newCompanyRepository(coll, time.Second).Create(context.Background(), apple)
+			assert.Equal(t, tt.wantErr, err)
+			coll.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCompanyRepository_Create_OverSoftDeleted(t *testing.T) {
+	// the soft-deleted record is not touched; the unique index on symbol and
+	// deletion date lets the new one sit next to it
+	deletedAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	recreated := apple
+	recreated.DeletedAt = &deletedAt
+
+	coll := new(MockCollection)
+	coll.On("InsertOne", mock.Anything, apple, mock.Anything).Return(nil)
+
+	err := newCompanyRepository(coll, time.Second).Create(context.Background(), recreated)
+	require.NoError(t, err)
+	coll.AssertNotCalled(t, "ReplaceOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	coll.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	coll.AssertExpectations(t)
+}
+
+func TestCompanyRepository_Update(t *testing.T) {
+	name := "Apple"
+	active := false
+	filter := bson.D{{Key: "symbol", Value: "AAPL"}, notDeleted}
+	unchanged := bson.D{
+		{Key: "symbol", Value: "AAPL"}, notDeleted,
+		{Key: "active", Value: true},
+		{Key: "listingDate", Value: (*time.Time)(nil)},
+		{Key: "delistingDate", Value: (*time.Time)(nil)},
+	}
+	set := bson.D{{Key: "$set", Value: bson.D{{Key: "securityName", Value: &name}, {Key: "active", Value: &active}}}}
+
+	coll := new(MockCollection)
+	coll.On("FindOne", mock.Anything, filter, mock.Anything).Return(apple, nil).Times(3)
+	coll.On("FindOneAndUpdate", mock.Anything, unchanged, set, mock.Anything).Return(apple, nil).Once()
+	coll.On("FindOneAndUpdate", mock.Anything, unchanged, set, mock.Anything).Return(nil, driver.ErrNoDocuments).Once()
+	coll.On("FindOne", mock.Anything, filter, mock.Anything).Return(nil, driver.ErrNoDocuments).Once()
+
+	r := newCompanyRepository(coll, time.Second)
+	update := models.CompanyUpdate{SecurityName: &name, Active: &active}
+
+	got, err := r.Update(context.Background(), "aapl", update)
+	assert.NoError(t, err)
+	assert.Equal(t, &apple, got)
+
+	got, err = r.Update(context.Background(), "aapl", models.CompanyUpdate{})
+	assert.NoError(t, err, "an empty update reads the company back")
+	assert.Equal(t, &apple, got)
+
+	_, err = r.Update(context.Background(), "aapl", update)
+	assert.Equal(t, dberrors.ErrNotFound, err, "deleted after it was read")
+
+	coll.AssertExpectations(t)
+}
+
+func TestCompanyRepository_Update_ValidatesStoredCompany(t *testing.T) {
+	listed := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	stored := apple
+	stored.ListingDate = &listed
+	filter := bson.D{{Key: "symbol", Value: "AAPL"}, notDeleted}
+
+	coll := new(MockCollection)
+	coll.On("FindOne", mock.Anything, filter, mock.Anything).Return(stored, nil)
+	r := newCompanyRepository(coll, time.Second)
+
+	var fieldErr *models.FieldError
+	delisted := listed.AddDate(0, 0, -1)
+	_, err := r.Update(context.Background(), "aapl", models.CompanyUpdate{DelistingDate: &delisted})
+	require.ErrorAs(t, err, &fieldErr, "delisting before the stored listing date")
+	assert.Equal(t, "delistingDate", fieldErr.Field)
+
+	delisted = listed.AddDate(1, 0, 0)
+	_, err = r.Update(context.Background(), "aapl", models.CompanyUpdate{DelistingDate: &delisted})
+	require.ErrorAs(t, err, &fieldErr, "the stored company is still active")
+	assert.Equal(t, "active", fieldErr.Field)
+
+	coll.AssertNotCalled(t, "FindOneAndUpdate", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	// a concurrent write keeps changing the company
+	inactive := false
+	coll.On("FindOneAndUpdate", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, driver.ErrNoDocuments)
+	_, err = r.Update(context.Background(), "aapl", models.CompanyUpdate{Active: &inactive, DelistingDate: &delisted})
+	assert.Equal(t, dberrors.ErrWriteConflict, err)
+	coll.AssertNumberOfCalls(t, "FindOneAndUpdate", maxUpdateAttempts)
+}
+
+func TestCompanyRepository_Delete(t *testing.T) {
+	filter := bson.D{{Key: "symbol", Value: "AAPL"}, notDeleted}
+
+	coll := new(MockCollection)
+	coll.On("UpdateOne", mock.Anything, filter, mock.Anything, mock.Anything).Return(&driver.UpdateResult{MatchedCount: 1}, nil).Once()
+	coll.On("UpdateOne", mock.Anything, filter, mock.Anything, mock.Anything).Return(&driver.UpdateResult{}, nil).Once()
+
+	r := newCompanyRepository(coll, time.Second)
+	assert.NoError(t, r.Delete(context.Background(), "aapl"))
+	assert.Equal(t, dberrors.ErrNotFound, r.Delete(context.Background(), "aapl"))
+
+	set := coll.Calls[0].Arguments.Get(2).(bson.D)[0].Value.(bson.D)[0]
+	assert.Equal(t, "deletedAt", set.Key)
+	assert.IsType(t, time.Time{}, set.Value)
+
+	coll.AssertExpectations(t)
+}
+
+func TestCompanyRepository_Upsert(t *testing.T) {
+	duplicate := driver.WriteException{WriteErrors: []driver.WriteError{{Code: 11000, Message: "duplicate key"}}}
+	filter := bson.D{{Key: "symbol", Value: "AAPL"}, notDeleted}
+	bySymbol := bson.D{{Key: "symbol", Value: "AAPL"}}
+	deletedAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	deleted := apple
+	deleted.DeletedAt = &deletedAt
+
+	tests := []struct {
+		name    string
+		latest  interface{}
+		result  *driver.UpdateResult
+		err     error
+		want    UpsertResult
+		wantErr error
+	}{
+		{"Inserted", nil, &driver.UpdateResult{UpsertedCount: 1}, nil, UpsertInserted, nil},
+		{"Updated", apple, &driver.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil, UpsertUpdated, nil},
+		{"Unchanged", apple, &driver.UpdateResult{MatchedCount: 1}, nil, UpsertUnchanged, nil},
+		{"Inserted concurrently", nil, nil, duplicate, UpsertUnchanged, dberrors.ErrConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coll := new(MockCollection)
+			if tt.latest == nil {
+				coll.On("FindOne", mock.Anything, bySymbol, mock.Anything).Return(nil, driver.ErrNoDocuments)
+			} else {
+				coll.On("FindOne", mock.Anything, bySymbol, mock.Anything).Return(tt.latest, nil)
+			}
+			coll.On("UpdateOne", mock.Anything, filter, mock.Anything, mock.Anything).Return(tt.result, tt.err)
+
+			got, err := newCompanyRepository(coll, time.Second).Upsert(context.Background(), apple)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+
+			update := coll.Calls[1].Arguments.Get(2).(bson.D)
+			assert.Equal(t, "$set", update[0].Key)
+			assert.NotContains(t, update[0].Value, bson.E{Key: "active", Value: true}, "a re-import must not reactivate a company")
+			assert.Equal(t, bson.D{
+				{Key: "sector", Value: apple.Sector},
+				{Key: "active", Value: true},
+			}, update[1].Value, "the sector and active flag are curated and only set on insert")
+			coll.AssertExpectations(t)
+		})
+	}
+
+	t.Run("Soft-deleted", func(t *testing.T) {
+		coll := new(MockCollection)
+		coll.On("FindOne", mock.Anything, bySymbol, mock.Anything).Return(deleted, nil)
+
+		got, err := newCompanyRepository(coll, time.Second).Upsert(context.Background(), apple)
+		assert.Equal(t, dberrors.ErrConflict, err)
+		assert.Equal(t, UpsertUnchanged, got)
+
+		opts := coll.Calls[0].Arguments.Get(2).([]*options.FindOneOptions)
+		assert.Equal(t, bson.D{{Key: "deletedAt", Value: 1}}, opts[0].Sort, "a live company is found before deleted ones")
+		coll.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Lookup failure", func(t *testing.T) {
+		coll := new(MockCollection)
+		coll.On("FindOne", mock.Anything, bySymbol, mock.Anything).Return(nil, errors.New("socket closed"))
+
+		_, err := newCompanyRepository(coll, time.Second).Upsert(context.Background(), apple)
+		assert.True(t, dberrors.IsDBError(err))
+		coll.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestSearchPipeline_RanksBeforeLimit(t *testing.T) {
+	pipeline := searchPipeline(SearchRequest{Query: "aapl", Mode: SearchModeContains, Limit: 3})
+
+	var stages []string
+	for _, stage := range pipeline {
+		stages = append(stages, stage.(bson.D)[0].Key)
+	}
+	assert.Equal(t, []string{"$match", "$addFields", "$sort", "$limit"}, stages)
+
+	sort := pipeline[2].(bson.D)[0].Value.(bson.D)
+	assert.Equal(t, bson.D{{Key: rankField, Value: 1}, {Key: "symbol", Value: 1}}, sort)
+	assert.Equal(t, 4, pipeline[3].(bson.D)[0].Value, "one extra document detects the next page")
+
+	// The rank expression compares against the upper-cased query
+	branches := rankExpression("aapl")[0].Value.(bson.D)[0].Value.(bson.A)
+	exactSymbol := branches[0].(bson.D)
+	assert.Equal(t, rankExactSymbol, exactSymbol[1].Value)
+	assert.Equal(t, "AAPL", exactSymbol[0].Value.(bson.D)[0].Value.(bson.A)[1])
+}
+
+func TestSearchPipeline_After(t *testing.T) {
+	after := SearchCursor{Rank: rankSymbolPrefix, Symbol: "AAPL"}
+	pipeline := searchPipeline(SearchRequest{Query: "a", Mode: SearchModeContains, Limit: 3, After: &after})
+
+	assert.Equal(t, bson.D{{Key: "$match", Value: afterFilter(after)}}, pipeline[2], "cursor applies after ranking and before sorting")
+	assert.Equal(t, "$sort", pipeline[3].(bson.D)[0].Key)
+}
+
+// TestCompanyRepository_Integration runs against a real mongod when
+// MONGODB_TEST_URI is set, e.g. mongodb://localhost:27017
+func TestCompanyRepository_Integration(t *testing.T) {
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI not set")
+	}
+
+	ctx := context.Background()
+	client, err := Connect(ctx, Config{
+		URI:               uri,
+		Database:          "company_earnings_test",
+		CompanyCollection: "companies_" + time.Now().Format("20060102150405"),
+	})
+	require.NoError(t, err)
+	defer client.Disconnect(ctx)
+
+	coll := client.db.Collection(client.cfg.CompanyCollection)
+	defer coll.Drop(ctx)
+
+	delisted := time.Date(2024, 3, 29, 0, 0, 0, 0, time.UTC)
+	pegy := models.Company{Symbol: "PEGY", CIK: "0001738758", SecurityName: "Pineapple Energy Inc.", SecurityType: "Common Stock", Region: "US", Exchange: "NASDAQ", Sector: "Energy", DelistingDate: &delisted}
+	_, err = coll.InsertMany(ctx, []interface{}{pegy, aple, apple})
+	require.NoError(t, err)
+
+	active, inactive := true, false
+	require.NoError(t, client.EnsureIndexes(ctx))
+	repo := client.Companies()
+	tests := []struct {
+		name string
+		req  SearchRequest
+		want []models.Company
+	}{
+		{"Exact symbol", SearchRequest{Query: "aple", Mode: SearchModeExact}, []models.Company{aple}},
+		{"Starts with", SearchRequest{Query: "apple", Mode: SearchModeStartsWith}, []models.Company{apple, aple}},
+		{"Contains", SearchRequest{Query: "apple", Mode: SearchModeContains}, []models.Company{apple, aple, pegy}},
+		{"Symbol prefix ranks before substring", SearchRequest{Query: "APL", Mode: SearchModeContains}, []models.Company{aple, apple}},
+		{"Limit", SearchRequest{Query: "apple", Mode: SearchModeContains, Limit: 1}, []models.Company{apple}},
+		{"Exchange", SearchRequest{Query: "apple", Mode: SearchModeContains, Exchange: "NASDAQ"}, []models.Company{apple, pegy}},
+		{"Active only", SearchRequest{Query: "apple", Mode: SearchModeContains, Active: &active}, []models.Company{apple, aple}},
+		{"Delisted only", SearchRequest{Query: "apple", Mode: SearchModeContains, Active: &inactive}, []models.Company{pegy}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.Search(ctx, tt.req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.Companies)
+		})
+	}
+
+	t.Run("Lookups", func(t *testing.T) {
+		got, err := repo.GetBySymbol(ctx, "aple")
+		require.NoError(t, err)
+		assert.Equal(t, &aple, got)
+
+		got, err = repo.GetByCIK(ctx, "0000320193")
+		require.NoError(t, err)
+		assert.Equal(t, &apple, got)
+
+		_, err = repo.GetByCIK(ctx, "0000000001")
+		assert.ErrorIs(t, err, dberrors.ErrNotFound)
+
+		many, err := repo.Lookup(ctx, []string{"pegy", "ZZZZ"}, []string{"0000320193"})
+		require.NoError(t, err)
+		assert.Equal(t, []models.Company{apple, pegy}, many)
+
+		many, err = repo.List(ctx, ListFilter{Sectors: []string{"technology", "real estate", "energy"}})
+		require.NoError(t, err)
+		assert.Equal(t, []models.Company{apple, aple}, many, "delisted companies are left out")
+
+		many, err = repo.List(ctx, ListFilter{Exchanges: []string{"nasdaq"}})
+		require.NoError(t, err)
+		assert.Equal(t, []models.Company{apple}, many)
+	})
+
+	t.Run("Pages", func(t *testing.T) {
+		req := SearchRequest{Query: "apple", Mode: SearchModeContains, Limit: 2}
+		first, err := repo.Search(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), first.Total)
+		assert.Equal(t, []models.Company{apple, aple}, first.Companies)
+		require.NotNil(t, first.Next)
+
+		req.After = first.Next
+		second, err := repo.Search(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), second.Total)
+		assert.Equal(t, []models.Company{pegy}, second.Companies)
+		assert.Nil(t, second.Next)
+	})
+
+	t.Run("Soft delete", func(t *testing.T) {
+		assert.ErrorIs(t, repo.Create(ctx, pegy), dberrors.ErrConflict)
+
+		sector := "Utilities"
+		got, err := repo.Update(ctx, "pegy", models.CompanyUpdate{Sector: &sector})
+		require.NoError(t, err)
+		assert.Equal(t, "Utilities", got.Sector)
+
+		require.NoError(t, repo.Delete(ctx, "PEGY"))
+		assert.ErrorIs(t, repo.Delete(ctx, "PEGY"), dberrors.ErrNotFound)
+
+		_, err = repo.GetBySymbol(ctx, "PEGY")
+		assert.ErrorIs(t, err, dberrors.ErrNotFound)
+		res, err := repo.Search(ctx, SearchRequest{Query: "apple", Mode: SearchModeContains})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), res.Total)
+
+		require.NoError(t, repo.Create(ctx, pegy), "a deleted symbol can be created again")
+		got, err = repo.GetBySymbol(ctx, "PEGY")
+		require.NoError(t, err)
+		assert.Equal(t, &pegy, got)
+	})
+
+	t.Run("Upsert", func(t *testing.T) {
+		refreshed := aple
+		refreshed.SecurityName = "Appreciate Holdings Inc."
+		refreshed.Sector = "Real Estate"
+		got, err := repo.Upsert(ctx, refreshed)
+		require.NoError(t, err)
+		assert.Equal(t, UpsertUpdated, got)
+
+		stored, err := repo.GetBySymbol(ctx, "APLE")
+		require.NoError(t, err)
+		assert.Equal(t, "Appreciate Holdings Inc.", stored.SecurityName)
+		assert.Equal(t, aple.Sector, stored.Sector)
+
+		got, err = repo.Upsert(ctx, refreshed)
+		require.NoError(t, err)
+		assert.Equal(t, UpsertUnchanged, got)
+
+		msft := models.Company{Symbol: "MSFT", CIK: "0000789019", SecurityName: "Microsoft Corp", SecurityType: "Common Stock", Region: "US", Exchange: "NASDAQ", Active: true}
+		got, err = repo.Upsert(ctx, msft)
+		require.NoError(t, err)
+		assert.Equal(t, UpsertInserted, got)
+	})
+}
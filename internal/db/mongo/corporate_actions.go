@@ -0,0 +1,148 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CorporateActionsRepository stores the dividends and stock splits of
+// listings, keyed by symbol as each share class has its own
+type CorporateActionsRepository interface {
+	// ListDividends returns the dividends of a listing, latest ex-date first
+	ListDividends(ctx context.Context, symbol string) ([]models.Dividend, error)
+	// ListSplits returns the splits of a listing, latest first
+	ListSplits(ctx context.Context, symbol string) ([]models.Split, error)
+	// ListSplitsBySymbols returns the splits of each of the listings, latest
+	// first, in a single query. Listings without splits are left out.
+	ListSplitsBySymbols(ctx context.Context, symbols []string) (map[string][]models.Split, error)
+	// UpsertDividend stores a dividend, replacing the one of the same type
+	// with the same ex-date
+	UpsertDividend(ctx context.Context, dividend models.Dividend) (UpsertResult, error)
+	// UpsertSplit stores a split, replacing the one on the same date
+	UpsertSplit(ctx context.Context, split models.Split) (UpsertResult, error)
+}
+
+type corporateActionsRepository struct {
+	dividends collection
+	splits    collection
+	timeout   time.Duration
+}
+
+// NewCorporateActionsRepository returns a CorporateActionsRepository over the
+// dividends and splits collections
+func NewCorporateActionsRepository(dividends, splits *driver.Collection, timeout time.Duration) CorporateActionsRepository {
+	return newCorporateActionsRepository(dividends, splits, timeout)
+}
+
+func newCorporateActionsRepository(dividends, splits collection, timeout time.Duration) *corporateActionsRepository {
+	return &corporateActionsRepository{dividends: dividends, splits: splits, timeout: timeout}
+}
+
+func (r *corporateActionsRepository) ListDividends(ctx context.Context, symbol string) ([]models.Dividend, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := bson.D{{Key: "symbol", Value: strings.ToUpper(symbol)}}
+	opts := options.Find().SetSort(bson.D{{Key: "exDate", Value: -1}, {Key: "type", Value: 1}})
+	cursor, err := r.dividends.Find(ctx, query, opts)
+	if err != nil {
+		return nil, dberrors.NewDBError(fmt.Sprintf("error listing dividends: %v", err))
+	}
+	defer cursor.Close(ctx)
+
+	dividends := []models.Dividend{}
+	if err := cursor.All(ctx, &dividends); err != nil {
+		return nil, dberrors.NewDBError(fmt.Sprintf("error decoding dividends: %v", err))
+	}
+	return dividends, nil
+}
+
+func (r *corporateActionsRepository) ListSplits(ctx context.Context, symbol string) ([]models.Split, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := bson.D{{Key: "symbol", Value: strings.ToUpper(symbol)}}
+	cursor, err := r.splits.Find(ctx, query, options.Find().SetSort(bson.D{{Key: "date", Value: -1}}))
+	if err != nil {
+		return nil, dberrors.NewDBError(fmt.Sprintf("error listing splits: %v", err))
+	}
+	defer cursor.Close(ctx)
+
+	splits := []models.Split{}
+	if err := cursor.All(ctx, &splits); err != nil {
+		return nil, dberrors.NewDBError(fmt.Sprintf("error decoding splits: %v", err))
+	}
+	return splits, nil
+}
+
+func (r *corporateActionsRepository) ListSplitsBySymbols(ctx context.Context, symbols []string) (map[string][]models.Split, error) {
+	bySymbol := make(map[string][]models.Split, len(symbols))
+	if len(symbols) == 0 {
+		return bySymbol, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	upper := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		upper[i] = strings.ToUpper(symbol)
+	}
+	query := bson.D{{Key: "symbol", Value: bson.D{{Key: "$in", Value: upper}}}}
+	cursor, err := r.splits.Find(ctx, query, options.Find().SetSort(bson.D{{Key: "date", Value: -1}}))
+	if err != nil {
+		return nil, dberrors.NewDBError(fmt.Sprintf("error listing splits: %v", err))
+	}
+	defer cursor.Close(ctx)
+
+	var splits []models.Split
+	if err := cursor.All(ctx, &splits); err != nil {
+		return nil, dberrors.NewDBError(fmt.Sprintf("error decoding splits: %v", err))
+	}
+	for _, s := range splits {
+		bySymbol[s.Symbol] = append(bySymbol[s.Symbol], s)
+	}
+	return bySymbol, nil
+}
+
+func (r *corporateActionsRepository) UpsertDividend(ctx context.Context, dividend models.Dividend) (UpsertResult, error) {
+	filter := bson.D{
+		{Key: "symbol", Value: dividend.Symbol},
+		{Key: "exDate", Value: dividend.ExDate},
+		{Key: "type", Value: dividend.Type},
+	}
+	return r.replace(ctx, r.dividends, filter, dividend, "dividend")
+}
+
+func (r *corporateActionsRepository) UpsertSplit(ctx context.Context, split models.Split) (UpsertResult, error) {
+	filter := bson.D{{Key: "symbol", Value: split.Symbol}, {Key: "date", Value: split.Date}}
+	return r.replace(ctx, r.splits, filter, split, "split")
+}
+
+// replace upserts the document matching filter, naming it kind in errors
+func (r *corporateActionsRepository) replace(ctx context.Context, coll collection, filter bson.D, doc interface{}, kind string) (UpsertResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	res, err := coll.ReplaceOne(ctx, filter, doc, options.Replace().SetUpsert(true))
+	if err != nil {
+		return UpsertUnchanged, dberrors.NewDBError(fmt.Sprintf("error upserting %s: %v", kind, err))
+	}
+
+	switch {
+	case res.UpsertedCount > 0:
+		return UpsertInserted, nil
+	case res.ModifiedCount > 0:
+		return UpsertUpdated, nil
+	default:
+		return UpsertUnchanged, nil
+	}
+}
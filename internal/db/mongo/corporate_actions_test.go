@@ -0,0 +1,121 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	appleDividend = models.Dividend{
+		Symbol: "AAPL", ExDate: time.Date(2024, 8, 12, 0, 0, 0, 0, time.UTC),
+		Amount: 0.25, Currency: "USD", Type: models.DividendTypeRegular,
+	}
+	appleSplit = models.Split{Symbol: "AAPL", Date: time.Date(2020, 8, 31, 0, 0, 0, 0, time.UTC), Numerator: 4, Denominator: 1}
+)
+
+func TestCorporateActionsRepository_ListDividends(t *testing.T) {
+	latestFirst := func(opts []*options.FindOptions) bool {
+		return len(opts) == 1 && assert.ObjectsAreEqual(bson.D{{Key: "exDate", Value: -1}, {Key: "type", Value: 1}}, opts[0].Sort)
+	}
+	dividends := new(MockCollection)
+	dividends.On("Find", mock.Anything, bson.D{{Key: "symbol", Value: "AAPL"}}, mock.MatchedBy(latestFirst)).Return([]interface{}{appleDividend}, nil)
+	dividends.On("Find", mock.Anything, bson.D{{Key: "symbol", Value: "MSFT"}}, mock.Anything).Return(nil, errors.New("socket closed"))
+	r := newCorporateActionsRepository(dividends, nil, time.Second)
+
+	got, err := r.ListDividends(context.Background(), "aapl")
+	require.NoError(t, err)
+	assert.Equal(t, []models.Dividend{appleDividend}, got)
+
+	_, err = r.ListDividends(context.Background(), "MSFT")
+	assert.True(t, dberrors.IsDBError(err))
+	dividends.AssertExpectations(t)
+}
+
+func TestCorporateActionsRepository_ListSplits(t *testing.T) {
+	splits := new(MockCollection)
+	splits.On("Find", mock.Anything, bson.D{{Key: "symbol", Value: "AAPL"}}, mock.Anything).Return([]interface{}{appleSplit}, nil)
+	splits.On("Find", mock.Anything, bson.D{{Key: "symbol", Value: "MSFT"}}, mock.Anything).Return([]interface{}{}, nil)
+	r := newCorporateActionsRepository(nil, splits, time.Second)
+
+	got, err := r.ListSplits(context.Background(), "AAPL")
+	require.NoError(t, err)
+	assert.Equal(t, []models.Split{appleSplit}, got)
+
+	got, err = r.ListSplits(context.Background(), "msft")
+	require.NoError(t, err)
+	assert.Equal(t, []models.Split{}, got)
+	splits.AssertExpectations(t)
+}
+
+func TestCorporateActionsRepository_ListSplitsBySymbols(t *testing.T) {
+	earlierSplit := models.Split{Symbol: "AAPL", Date: time.Date(2014, 6, 9, 0, 0, 0, 0, time.UTC), Numerator: 7, Denominator: 1}
+	nvidiaSplit := models.Split{Symbol: "NVDA", Date: time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC), Numerator: 10, Denominator: 1}
+	in := func(symbols ...string) bson.D {
+		return bson.D{{Key: "symbol", Value: bson.D{{Key: "$in", Value: symbols}}}}
+	}
+	splits := new(MockCollection)
+	splits.On("Find", mock.Anything, in("AAPL", "NVDA", "MSFT"), mock.Anything).Return([]interface{}{nvidiaSplit, appleSplit, earlierSplit}, nil).Once()
+	splits.On("Find", mock.Anything, in("AAPL"), mock.Anything).Return(nil, errors.New("socket closed")).Once()
+	r := newCorporateActionsRepository(nil, splits, time.Second)
+
+	got, err := r.ListSplitsBySymbols(context.Background(), []string{"aapl", "NVDA", "MSFT"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]models.Split{
+		"AAPL": {appleSplit, earlierSplit},
+		"NVDA": {nvidiaSplit},
+	}, got)
+
+	got, err = r.ListSplitsBySymbols(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Empty(t, got, "no query without symbols")
+
+	_, err = r.ListSplitsBySymbols(context.Background(), []string{"AAPL"})
+	assert.True(t, dberrors.IsDBError(err))
+	splits.AssertExpectations(t)
+}
+
+func TestCorporateActionsRepository_Upsert(t *testing.T) {
+	dividendFilter := bson.D{
+		{Key: "symbol", Value: "AAPL"},
+		{Key: "exDate", Value: appleDividend.ExDate},
+		{Key: "type", Value: models.DividendTypeRegular},
+	}
+	splitFilter := bson.D{{Key: "symbol", Value: "AAPL"}, {Key: "date", Value: appleSplit.Date}}
+
+	dividends := new(MockCollection)
+	dividends.On("ReplaceOne", mock.Anything, dividendFilter, appleDividend, mock.Anything).Return(&driver.UpdateResult{UpsertedCount: 1}, nil).Once()
+	dividends.On("ReplaceOne", mock.Anything, dividendFilter, appleDividend, mock.Anything).Return(&driver.UpdateResult{MatchedCount: 1}, nil).Once()
+	splits := new(MockCollection)
+	splits.On("ReplaceOne", mock.Anything, splitFilter, appleSplit, mock.Anything).Return(&driver.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil).Once()
+	splits.On("ReplaceOne", mock.Anything, splitFilter, appleSplit, mock.Anything).Return(nil, errors.New("socket closed")).Once()
+	r := newCorporateActionsRepository(dividends, splits, time.Second)
+
+	result, err := r.UpsertDividend(context.Background(), appleDividend)
+	require.NoError(t, err)
+	assert.Equal(t, UpsertInserted, result)
+
+	result, err = r.UpsertDividend(context.Background(), appleDividend)
+	require.NoError(t, err)
+	assert.Equal(t, UpsertUnchanged, result)
+
+	result, err = r.UpsertSplit(context.Background(), appleSplit)
+	require.NoError(t, err)
+	assert.Equal(t, UpsertUpdated, result)
+
+	_, err = r.UpsertSplit(context.Background(), appleSplit)
+	assert.EqualError(t, err, "DB Error: error upserting split: socket closed")
+
+	dividends.AssertExpectations(t)
+	splits.AssertExpectations(t)
+}
@@ -0,0 +1,319 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EarningsRepository stores the quarterly earnings of companies
+type EarningsRepository interface {
+	// ListBySymbol returns the earnings of a company, oldest period first
+	ListBySymbol(ctx context.Context, symbol string, filter EarningsFilter) ([]models.Earnings, error)
+	// Upsert stores the earnings of one fiscal quarter, replacing any
+	// previously stored figures for the same period. An estimate, report
+	// date or report time left unset keeps the stored one. Changed figures
+	// are recorded as a new version next to the earlier ones, and the EPS
+	// surprise is recomputed from the estimate and actuals before writing.
+	// Changed figures and estimates are appended to the revision log as
+	// written by source. The quarter is only written if no other upsert wrote
+	// it since it was read, and read again and merged otherwise, up to
+	// dberrors.ErrWriteConflict when that keeps happening.
+	Upsert(ctx context.Context, earnings models.Earnings, source string) (UpsertResult, error)
+	// Calendar returns the earnings events reported or scheduled in a date
+	// range together with the reporting companies
+	Calendar(ctx context.Context, req CalendarRequest) ([]CalendarEvent, error)
+}
+
+// EarningsFilter selects the earnings ListBySymbol returns
+type EarningsFilter struct {
+	Surprise SurpriseFilter
+	// AsOf, when set, returns the earnings as they were known at the end of
+	// that day, leaving out the quarters not reported by then
+	AsOf *time.Time
+}
+
+// SurpriseFilter bounds the EPS surprise percent of earnings, leaving out
+// quarters without a surprise. Nil bounds are open.
+type SurpriseFilter struct {
+	PctGT *float64
+	PctLT *float64
+}
+
+// clause returns the filter on the stored surprise, or nothing when the
+// filter is open on both ends
+func (f SurpriseFilter) clause() bson.D {
+	var bounds bson.D
+	if f.PctGT != nil {
+		bounds = append(bounds, bson.E{Key: "$gt", Value: *f.PctGT})
+	}
+	if f.PctLT != nil {
+		bounds = append(bounds, bson.E{Key: "$lt", Value: *f.PctLT})
+	}
+	if len(bounds) == 0 {
+		return nil
+	}
+	return bson.D{{Key: "epsSurprisePct", Value: bounds}}
+}
+
+// match reports whether earnings pass the filter, for earnings resolved as of
+// a date whose surprise differs from the stored one
+func (f SurpriseFilter) match(e *models.Earnings) bool {
+	if f.PctGT == nil && f.PctLT == nil {
+		return true
+	}
+	if e.EPSSurprisePct == nil {
+		return false
+	}
+	return (f.PctGT == nil || *e.EPSSurprisePct > *f.PctGT) && (f.PctLT == nil || *e.EPSSurprisePct < *f.PctLT)
+}
+
+// CalendarRequest selects earnings events by report date. From and To are
+// whole days and both are included. Exchange, Sector, Surprise and AsOf are
+// optional; AsOf shows the figures as they were known at the end of that
+// day, with quarters reported later as scheduled.
+type CalendarRequest struct {
+	From     time.Time
+	To       time.Time
+	Exchange string
+	Sector   string
+	Surprise SurpriseFilter
+	AsOf     *time.Time
+}
+
+// CalendarEvent is an earnings event joined with the company reporting it
+type CalendarEvent struct {
+	models.Earnings `bson:",inline"`
+	Company         models.Company `bson:"company"`
+}
+
+type earningsRepository struct {
+	coll              collection
+	revisions         collection
+	companyCollection string
+	timeout           time.Duration
+	// now dates revisions and the restatements upserted without a
+	// publication time
+	now func() time.Time
+}
+
+// NewEarningsRepository returns an EarningsRepository over coll that appends
+// revisions to the revisions collection. Calendar events are joined with the
+// companies stored in companyCollection.
+func NewEarningsRepository(coll, revisions *driver.Collection, companyCollection string, timeout time.Duration) EarningsRepository {
+	return newEarningsRepository(coll, revisions, companyCollection, timeout)
+}
+
+func newEarningsRepository(coll, revisions collection, companyCollection string, timeout time.Duration) *earningsRepository {
+	return &earningsRepository{
+		coll:              coll,
+		revisions:         revisions,
+		companyCollection: companyCollection,
+		timeout:           timeout,
+		now:               time.Now,
+	}
+}
+
+// periodSort orders earnings chronologically by fiscal period
+var periodSort = bson.D{{Key: "fiscalYear", Value: 1}, {Key: "fiscalQuarter", Value: 1}}
+
+func (r *earningsRepository) ListBySymbol(ctx context.Context, symbol string, filter EarningsFilter) ([]models.Earnings, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := bson.D{{Key: "symbol", Value: strings.ToUpper(symbol)}}
+	if filter.AsOf == nil {
+		query = append(query, filter.Surprise.clause()...)
+	}
+	cursor, err := r.coll.Find(ctx, query, options.Find().SetSort(periodSort))
+	if err != nil {
+		return nil, dberrors.NewDBError(fmt.Sprintf("error listing earnings: %v", err))
+	}
+	defer cursor.Close(ctx)
+
+	earnings := []models.Earnings{}
+	if err := cursor.All(ctx, &earnings); err != nil {
+		return nil, dberrors.NewDBError(fmt.Sprintf("error decoding earnings: %v", err))
+	}
+	if filter.AsOf == nil {
+		return earnings, nil
+	}
+
+	known := earnings[:0]
+	for _, e := range earnings {
+		e = e.AsOf(*filter.AsOf)
+		if e.Reported() && filter.Surprise.match(&e) {
+			known = append(known, e)
+		}
+	}
+	return known, nil
+}
+
+func (r *earningsRepository) Upsert(ctx context.Context, earnings models.Earnings, source string) (UpsertResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		result, err := r.upsert(ctx, earnings, source)
+		if !errors.Is(err, dberrors.ErrWriteConflict) {
+			return result, err
+		}
+	}
+	return UpsertUnchanged, dberrors.ErrWriteConflict
+}
+
+// upsert merges earnings into the stored quarter as read once and writes the
+// result unless another upsert wrote the quarter in between
+func (r *earningsRepository) upsert(ctx context.Context, earnings models.Earnings, source string) (UpsertResult, error) {
+	filter := periodFilter(earnings.Symbol, earnings.FiscalYear, earnings.FiscalQuarter)
+	// MongoDB stores times to the millisecond
+	now := r.now().UTC().Truncate(time.Millisecond)
+	var stored models.Earnings
+	var previous *models.Earnings
+	err := r.coll.FindOne(ctx, filter).Decode(&stored)
+	switch {
+	case errors.Is(err, driver.ErrNoDocuments):
+	case err != nil:
+		return UpsertUnchanged, dberrors.NewDBError(fmt.Sprintf("error finding earnings: %v", err))
+	default:
+		previous = &stored
+	}
+	earnings.KeepStored(previous)
+	earnings.RecordVersion(previous, now)
+	earnings.ComputeSurprise()
+
+	written := 0
+	if previous != nil {
+		if previous.RevisionID != "" {
+			if err := confirmRevision(ctx, r.revisions, previous.RevisionID); err != nil {
+				return UpsertUnchanged, err
+			}
+		}
+		written = previous.Writes
+		earnings.Writes, earnings.RevisionID = written, previous.RevisionID
+		if unchanged(previous, &earnings, new(models.Earnings)) {
+			return UpsertUnchanged, nil
+		}
+	}
+	earnings.Writes, earnings.RevisionID = written+1, ""
+
+	// the revision is written pending before the quarter and confirmed after
+	// it, so the log neither misses a change nor records one never made. An
+	// upsert that lost a race leaves its revision pending and logs the changes
+	// it finally made on retry.
+	if changes := earnings.Changes(previous); len(changes) > 0 {
+		id, err := appendRevision(ctx, r.revisions, earnings.Revision(changes, source, now))
+		if err != nil {
+			return UpsertUnchanged, err
+		}
+		earnings.RevisionID = id
+	}
+	result, err := replaceRecord(ctx, r.coll, filter, previous != nil, written, earnings, "earnings")
+	if err != nil {
+		return result, err
+	}
+	if earnings.RevisionID != "" {
+		// left pending on failure until the next upsert of the quarter
+		if err := confirmRevision(ctx, r.revisions, earnings.RevisionID); err != nil {
+			return UpsertUnchanged, err
+		}
+	}
+	return result, nil
+}
+
+func (r *earningsRepository) Calendar(ctx context.Context, req CalendarRequest) ([]CalendarEvent, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	cursor, err := r.coll.Aggregate(ctx, calendarPipeline(req, r.companyCollection))
+	if err != nil {
+		return nil, dberrors.NewDBError(fmt.Sprintf("error listing earnings calendar: %v", err))
+	}
+	defer cursor.Close(ctx)
+
+	events := []CalendarEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, dberrors.NewDBError(fmt.Sprintf("error decoding earnings calendar: %v", err))
+	}
+	if req.AsOf == nil {
+		return events, nil
+	}
+
+	known := events[:0]
+	for _, e := range events {
+		e.Earnings = e.Earnings.AsOf(*req.AsOf)
+		if req.Surprise.match(&e.Earnings) {
+			known = append(known, e)
+		}
+	}
+	return known, nil
+}
+
+const timingField = "_timing"
+
+// calendarPipeline selects the events in the date range, joins each with its
+// company so companies that were deleted drop out, and orders the events by
+// day and then by time of day
+func calendarPipeline(req CalendarRequest, companyCollection string) bson.A {
+	companyFilter := bson.D{}
+	if req.Exchange != "" {
+		companyFilter = append(companyFilter, bson.E{Key: "company.exchange", Value: req.Exchange})
+	}
+	if req.Sector != "" {
+		pattern := "^" + regexp.QuoteMeta(req.Sector) + "$"
+		companyFilter = append(companyFilter, bson.E{Key: "company.sector", Value: bson.D{{Key: "$regex", Value: pattern}, {Key: "$options", Value: "i"}}})
+	}
+
+	match := bson.D{{Key: "reportDate", Value: bson.D{
+		{Key: "$gte", Value: req.From},
+		{Key: "$lt", Value: req.To.AddDate(0, 0, 1)},
+	}}}
+	if req.AsOf == nil {
+		// the surprise of earnings as of a date is only known once they are
+		// resolved
+		match = append(match, req.Surprise.clause()...)
+	}
+
+	pipeline := bson.A{
+		bson.D{{Key: "$match", Value: match}},
+		// the join is written with let and $expr rather than localField and
+		// foreignField next to a pipeline, which needs MongoDB 5.0
+		bson.D{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: companyCollection},
+			{Key: "let", Value: bson.D{{Key: "symbol", Value: "$symbol"}}},
+			{Key: "pipeline", Value: bson.A{bson.D{{Key: "$match", Value: bson.D{
+				{Key: "$expr", Value: bson.D{{Key: "$eq", Value: bson.A{"$symbol", "$$symbol"}}}},
+				notDeleted,
+			}}}}},
+			{Key: "as", Value: "company"},
+		}}},
+		bson.D{{Key: "$unwind", Value: "$company"}},
+	}
+	if len(companyFilter) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: companyFilter}})
+	}
+	return append(pipeline,
+		bson.D{{Key: "$addFields", Value: bson.D{{Key: timingField, Value: bson.D{
+			{Key: "$indexOfArray", Value: bson.A{models.ReportTimes, "$reportTime"}},
+		}}}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "reportDate", Value: 1}, {Key: timingField, Value: 1}, {Key: "symbol", Value: 1}}}},
+	)
+}
+
+// periodFilter matches the earnings of one fiscal quarter
+func periodFilter(symbol string, fiscalYear, fiscalQuarter int) bson.D {
+	return bson.D{
+		{Key: "symbol", Value: strings.ToUpper(symbol)},
+		{Key: "fiscalYear", Value: fiscalYear},
+		{Key: "fiscalQuarter", Value: fiscalQuarter},
+	}
+}
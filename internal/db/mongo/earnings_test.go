@@ -0,0 +1,495 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	driver "go.mongodb.org/mongo-driver/mongo"
+)
+
+func float64Ptr(f float64) *float64 {
+	return &f
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+var (
+	appleQ1 = models.Earnings{
+		Symbol:        "AAPL",
+		FiscalYear:    2024,
+		FiscalQuarter: 1,
+		PeriodEnd:     time.Date(2023, 12, 30, 0, 0, 0, 0, time.UTC),
+		ReportDate:    timePtr(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)),
+		ReportTime:    models.ReportTimeAfterClose,
+		EPSBasic:      float64Ptr(2.19),
+		EPSDiluted:    float64Ptr(2.18),
+		Revenue:       float64Ptr(119575000000),
+		NetIncome:     float64Ptr(33916000000),
+		Currency:      "USD",
+	}
+	appleQ2 = models.Earnings{
+		Symbol:        "AAPL",
+		FiscalYear:    2024,
+		FiscalQuarter: 2,
+		PeriodEnd:     time.Date(2024, 3, 30, 0, 0, 0, 0, time.UTC),
+		ReportDate:    timePtr(time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)),
+		ReportTime:    models.ReportTimeAfterClose,
+		EPSBasic:      float64Ptr(1.53),
+		EPSDiluted:    float64Ptr(1.53),
+		Revenue:       float64Ptr(90753000000),
+		NetIncome:     float64Ptr(23636000000),
+		Currency:      "USD",
+	}
+)
+
+func TestEarningsRepository_ListBySymbol(t *testing.T) {
+	coll := new(MockCollection)
+	beats := bson.D{{Key: "symbol", Value: "AAPL"}, {Key: "epsSurprisePct", Value: bson.D{{Key: "$gt", Value: 10.0}}}}
+	coll.On("Find", mock.Anything, bson.D{{Key: "symbol", Value: "AAPL"}}, mock.Anything).Return([]interface{}{appleQ1, appleQ2}, nil)
+	coll.On("Find", mock.Anything, beats, mock.Anything).Return([]interface{}{appleQ1}, nil)
+	coll.On("Find", mock.Anything, bson.D{{Key: "symbol", Value: "MSFT"}}, mock.Anything).Return(nil, errors.New("socket closed"))
+
+	r := newEarningsRepository(coll, nil, "companies", time.Second)
+
+	got, err := r.ListBySymbol(context.Background(), "aapl", EarningsFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []models.Earnings{appleQ1, appleQ2}, got)
+
+	got, err = r.ListBySymbol(context.Background(), "aapl", EarningsFilter{Surprise: SurpriseFilter{PctGT: float64Ptr(10)}})
+	require.NoError(t, err)
+	assert.Equal(t, []models.Earnings{appleQ1}, got)
+
+	_, err = r.ListBySymbol(context.Background(), "MSFT", EarningsFilter{})
+	assert.True(t, dberrors.IsDBError(err))
+
+	coll.AssertExpectations(t)
+}
+
+func TestEarningsRepository_ListBySymbol_AsOf(t *testing.T) {
+	restatedAt := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	restated := appleQ1
+	restated.EPSEstimate = &models.EPSEstimate{Mean: 2.10, High: 2.22, Low: 1.95, NumAnalysts: 28}
+	restated.RecordVersion(nil, time.Time{})
+	restated.NetIncome = float64Ptr(33900000000)
+	restated.EPSDiluted = float64Ptr(2.17)
+	restated.RecordVersion(&restated, restatedAt)
+
+	coll := new(MockCollection)
+	coll.On("Find", mock.Anything, bson.D{{Key: "symbol", Value: "AAPL"}}, mock.Anything).Return([]interface{}{restated, appleQ2}, nil)
+
+	r := newEarningsRepository(coll, nil, "companies", time.Second)
+
+	asOf := time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC)
+	got, err := r.ListBySymbol(context.Background(), "aapl", EarningsFilter{AsOf: &asOf})
+	require.NoError(t, err)
+	require.Len(t, got, 1, "the second quarter had not been reported")
+	assert.Equal(t, 1, got[0].Version)
+	assert.Equal(t, appleQ1.NetIncome, got[0].NetIncome, "before the restatement")
+	assert.InDelta(t, 3.8095, *got[0].EPSSurprisePct, 1e-4)
+
+	// the surprise filter applies to the figures known at the time
+	got, err = r.ListBySymbol(context.Background(), "aapl", EarningsFilter{AsOf: &restatedAt, Surprise: SurpriseFilter{PctGT: float64Ptr(3.5)}})
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	coll.AssertExpectations(t)
+}
+
+func TestSurpriseFilter(t *testing.T) {
+	assert.Nil(t, SurpriseFilter{}.clause())
+	assert.Equal(t, bson.D{{Key: "epsSurprisePct", Value: bson.D{{Key: "$gt", Value: 5.0}, {Key: "$lt", Value: 20.0}}}},
+		SurpriseFilter{PctGT: float64Ptr(5), PctLT: float64Ptr(20)}.clause())
+	assert.Equal(t, bson.D{{Key: "epsSurprisePct", Value: bson.D{{Key: "$lt", Value: -10.0}}}},
+		SurpriseFilter{PctLT: float64Ptr(-10)}.clause())
+}
+
+func TestEarningsRepository_Upsert_ComputesSurprise(t *testing.T) {
+	withEstimate := appleQ1
+	withEstimate.EPSEstimate = &models.EPSEstimate{Mean: 2.10, High: 2.22, Low: 1.95, NumAnalysts: 28}
+	withEstimate.EPSSurprise = float64Ptr(100)
+
+	coll := new(MockCollection)
+	coll.On("FindOne", mock.Anything, mock.Anything, mock.Anything).Return(nil, driver.ErrNoDocuments)
+	coll.On("ReplaceOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&driver.UpdateResult{UpsertedCount: 1}, nil)
+	revisions := revisionLog()
+
+	_, err := newEarningsRepository(coll, revisions, "companies", time.Second).Upsert(context.Background(), withEstimate, "test")
+	require.NoError(t, err)
+
+	stored := coll.Calls[1].Arguments.Get(2).(models.Earnings)
+	assert.InDelta(t, 0.08, *stored.EPSSurprise, 1e-9)
+	assert.InDelta(t, 3.8095, *stored.EPSSurprisePct, 1e-4)
+}
+
+func TestEarningsRepository_Upsert_EstimateAfterActuals(t *testing.T) {
+	estimate := models.Earnings{
+		Symbol: "AAPL", FiscalYear: 2024, FiscalQuarter: 1, PeriodEnd: appleQ1.PeriodEnd,
+		ReportTime: models.ReportTimeNotAnnounced, Currency: "USD",
+		EPSEstimate: &models.EPSEstimate{Mean: 2.10, High: 2.22, Low: 1.95, NumAnalysts: 28},
+	}
+
+	coll := new(MockCollection)
+	coll.On("FindOne", mock.Anything, mock.Anything, mock.Anything).Return(appleQ1, nil)
+	coll.On("ReplaceOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&driver.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+	revisions := revisionLog()
+
+	_, err := newEarningsRepository(coll, revisions, "companies", time.Second).Upsert(context.Background(), estimate, "test")
+	require.NoError(t, err)
+
+	stored := coll.Calls[1].Arguments.Get(2).(models.Earnings)
+	assert.Equal(t, appleQ1.EPSDiluted, stored.EPSDiluted, "an estimate keeps the reported figures")
+	assert.Equal(t, appleQ1.ReportDate, stored.ReportDate)
+	assert.Equal(t, models.ReportTimeAfterClose, stored.ReportTime)
+	assert.InDelta(t, 3.8095, *stored.EPSSurprisePct, 1e-4, "the surprise is computed once both exist")
+}
+
+func TestEarningsRepository_Upsert(t *testing.T) {
+	filter := bson.D{{Key: "symbol", Value: "AAPL"}, {Key: "fiscalYear", Value: 2024}, {Key: "fiscalQuarter", Value: 1}}
+	guarded := func(written interface{}) bson.D {
+		return append(append(bson.D{}, filter...), bson.E{Key: "writes", Value: written})
+	}
+	missing := bson.D{{Key: "$exists", Value: false}}
+
+	// stored is appleQ1 as the first upsert writes it
+	stored := appleQ1
+	stored.RecordVersion(nil, time.Time{})
+	stored.Writes = 1
+	restated := appleQ1
+	restated.NetIncome = float64Ptr(33900000000)
+
+	tests := []struct {
+		name     string
+		stored   interface{}
+		earnings models.Earnings
+		filter   bson.D
+		results  []*driver.UpdateResult
+		want     UpsertResult
+		writes   int
+	}{
+		{"Inserted", nil, appleQ1, guarded(missing), []*driver.UpdateResult{{UpsertedCount: 1}}, UpsertInserted, 1},
+		{"Updated", stored, restated, guarded(1), []*driver.UpdateResult{{MatchedCount: 1, ModifiedCount: 1}}, UpsertUpdated, 2},
+		{"Stored before writes were counted", appleQ1, restated, guarded(missing), []*driver.UpdateResult{{MatchedCount: 1, ModifiedCount: 1}}, UpsertUpdated, 1},
+		{"Written concurrently", stored, restated, guarded(1), []*driver.UpdateResult{{}, {MatchedCount: 1, ModifiedCount: 1}}, UpsertUpdated, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coll := new(MockCollection)
+			if tt.stored == nil {
+				coll.On("FindOne", mock.Anything, filter, mock.Anything).Return(nil, driver.ErrNoDocuments)
+			} else {
+				coll.On("FindOne", mock.Anything, filter, mock.Anything).Return(tt.stored, nil)
+			}
+			for _, result := range tt.results {
+				coll.On("ReplaceOne", mock.Anything, tt.filter, mock.Anything, mock.Anything).Return(result, nil).Once()
+			}
+			revisions := revisionLog()
+
+			got, err := newEarningsRepository(coll, revisions, "companies", time.Second).Upsert(context.Background(), tt.earnings, "test")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			coll.AssertExpectations(t)
+			coll.AssertNumberOfCalls(t, "FindOne", len(tt.results))
+			revisions.AssertNumberOfCalls(t, "InsertOne", len(tt.results))
+			revisions.AssertNumberOfCalls(t, "UpdateOne", 1)
+
+			written := coll.Calls[len(coll.Calls)-1].Arguments.Get(2).(models.Earnings)
+			assert.Equal(t, tt.writes, written.Writes)
+		})
+	}
+
+	t.Run("Unchanged", func(t *testing.T) {
+		coll := new(MockCollection)
+		coll.On("FindOne", mock.Anything, filter, mock.Anything).Return(stored, nil)
+		revisions := new(MockCollection)
+
+		got, err := newEarningsRepository(coll, revisions, "companies", time.Second).Upsert(context.Background(), appleQ1, "test")
+		assert.NoError(t, err)
+		assert.Equal(t, UpsertUnchanged, got)
+		coll.AssertNotCalled(t, "ReplaceOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		revisions.AssertNotCalled(t, "InsertOne", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Inserted concurrently", func(t *testing.T) {
+		duplicate := driver.WriteException{WriteErrors: driver.WriteErrors{{Code: 11000, Message: "duplicate key"}}}
+		coll := new(MockCollection)
+		coll.On("FindOne", mock.Anything, filter, mock.Anything).Return(nil, driver.ErrNoDocuments).Once()
+		coll.On("FindOne", mock.Anything, filter, mock.Anything).Return(stored, nil)
+		coll.On("ReplaceOne", mock.Anything, guarded(missing), mock.Anything, mock.Anything).Return(nil, duplicate)
+		revisions := revisionLog()
+
+		got, err := newEarningsRepository(coll, revisions, "companies", time.Second).Upsert(context.Background(), appleQ1, "test")
+		assert.NoError(t, err)
+		assert.Equal(t, UpsertUnchanged, got, "the concurrent insert wrote the same figures")
+		revisions.AssertNumberOfCalls(t, "InsertOne", 1)
+		revisions.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		coll := new(MockCollection)
+		coll.On("FindOne", mock.Anything, filter, mock.Anything).Return(stored, nil)
+		coll.On("ReplaceOne", mock.Anything, guarded(1), mock.Anything, mock.Anything).Return(&driver.UpdateResult{}, nil)
+		revisions := revisionLog()
+
+		_, err := newEarningsRepository(coll, revisions, "companies", time.Second).Upsert(context.Background(), restated, "test")
+		assert.ErrorIs(t, err, dberrors.ErrWriteConflict)
+		coll.AssertNumberOfCalls(t, "ReplaceOne", maxUpdateAttempts)
+		revisions.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Lookup failure", func(t *testing.T) {
+		coll := new(MockCollection)
+		coll.On("FindOne", mock.Anything, filter, mock.Anything).Return(nil, errors.New("socket closed"))
+
+		_, err := newEarningsRepository(coll, nil, "companies", time.Second).Upsert(context.Background(), appleQ1, "test")
+		assert.True(t, dberrors.IsDBError(err))
+		coll.AssertNotCalled(t, "ReplaceOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Write failure", func(t *testing.T) {
+		coll := new(MockCollection)
+		coll.On("FindOne", mock.Anything, filter, mock.Anything).Return(nil, driver.ErrNoDocuments)
+		coll.On("ReplaceOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("socket closed"))
+		revisions := revisionLog()
+
+		_, err := newEarningsRepository(coll, revisions, "companies", time.Second).Upsert(context.Background(), appleQ1, "test")
+		assert.True(t, dberrors.IsDBError(err))
+		revisions.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Revision failure", func(t *testing.T) {
+		coll := new(MockCollection)
+		coll.On("FindOne", mock.Anything, filter, mock.Anything).Return(nil, driver.ErrNoDocuments)
+		revisions := new(MockCollection)
+		revisions.On("InsertOne", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("socket closed"))
+
+		_, err := newEarningsRepository(coll, revisions, "companies", time.Second).Upsert(context.Background(), appleQ1, "test")
+		assert.True(t, dberrors.IsDBError(err))
+		coll.AssertNotCalled(t, "ReplaceOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Confirmation failure", func(t *testing.T) {
+		coll := new(MockCollection)
+		coll.On("FindOne", mock.Anything, filter, mock.Anything).Return(nil, driver.ErrNoDocuments)
+		coll.On("ReplaceOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&driver.UpdateResult{UpsertedCount: 1}, nil)
+		revisions := new(MockCollection)
+		revisions.On("InsertOne", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		revisions.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("socket closed"))
+
+		_, err := newEarningsRepository(coll, revisions, "companies", time.Second).Upsert(context.Background(), appleQ1, "test")
+		assert.True(t, dberrors.IsDBError(err))
+
+		revision := revisions.Calls[0].Arguments.Get(1).(models.Revision)
+		written := coll.Calls[1].Arguments.Get(2).(models.Earnings)
+		assert.Equal(t, revision.ID, written.RevisionID, "the quarter keeps the pending revision for the next upsert to confirm")
+	})
+
+	t.Run("Left pending", func(t *testing.T) {
+		pending := stored
+		pending.RevisionID = "6650f1c2a1b2c3d4e5f60718"
+		coll := new(MockCollection)
+		coll.On("FindOne", mock.Anything, filter, mock.Anything).Return(pending, nil)
+		revisions := revisionLog()
+
+		got, err := newEarningsRepository(coll, revisions, "companies", time.Second).Upsert(context.Background(), appleQ1, "test")
+		require.NoError(t, err)
+		assert.Equal(t, UpsertUnchanged, got)
+		revisions.AssertCalled(t, "UpdateOne", mock.Anything,
+			bson.D{{Key: "_id", Value: pending.RevisionID}, {Key: "pending", Value: true}},
+			bson.D{{Key: "$unset", Value: bson.D{{Key: "pending", Value: ""}}}}, mock.Anything)
+		revisions.AssertNotCalled(t, "InsertOne", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestEarningsRepository_Upsert_Versions(t *testing.T) {
+	filter := bson.D{{Key: "symbol", Value: "AAPL"}, {Key: "fiscalYear", Value: 2024}, {Key: "fiscalQuarter", Value: 1}}
+	original := models.EarningsVersion{
+		Version: 1, PublishedAt: *appleQ1.ReportDate,
+		EPSBasic: appleQ1.EPSBasic, EPSDiluted: appleQ1.EPSDiluted, Revenue: appleQ1.Revenue, NetIncome: appleQ1.NetIncome,
+	}
+	stored := appleQ1
+	stored.Version, stored.PublishedAt, stored.Versions = 1, appleQ1.ReportDate, []models.EarningsVersion{original}
+
+	restated := appleQ1
+	restated.NetIncome = float64Ptr(33900000000)
+	now := time.Date(2024, 3, 15, 9, 30, 0, 0, time.UTC)
+
+	coll := new(MockCollection)
+	coll.On("FindOne", mock.Anything, filter, mock.Anything).Return(stored, nil)
+	coll.On("ReplaceOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&driver.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+	revisions := revisionLog()
+
+	r := newEarningsRepository(coll, revisions, "companies", time.Second)
+	r.now = func() time.Time { return now }
+	_, err := r.Upsert(context.Background(), restated, "restatement-feed")
+	require.NoError(t, err)
+
+	revision := revisions.Calls[0].Arguments.Get(1).(models.Revision)
+	require.NotEmpty(t, revision.ID)
+	assert.Equal(t, models.Revision{
+		ID: revision.ID, Record: models.RecordEarnings, Symbol: "AAPL", FiscalYear: 2024, FiscalPeriod: "Q1",
+		Source: "restatement-feed", RecordedAt: now,
+		Changes: []models.FieldChange{{Field: "netIncome", Old: appleQ1.NetIncome, New: float64Ptr(33900000000)}},
+		Pending: true,
+	}, revision, "the revision is pending until the quarter is written")
+	revisions.AssertCalled(t, "UpdateOne", mock.Anything,
+		bson.D{{Key: "_id", Value: revision.ID}, {Key: "pending", Value: true}}, mock.Anything, mock.Anything)
+
+	written := coll.Calls[1].Arguments.Get(2).(models.Earnings)
+	assert.Equal(t, revision.ID, written.RevisionID)
+	assert.Equal(t, 2, written.Version)
+	assert.Equal(t, now, *written.PublishedAt, "restatements without a publication time are dated when recorded")
+	require.Len(t, written.Versions, 2)
+	assert.Equal(t, original, written.Versions[0])
+	assert.Equal(t, 33900000000.0, *written.Versions[1].NetIncome)
+}
+
+func TestEarningsRepository_Calendar(t *testing.T) {
+	req := CalendarRequest{From: time.Date(2024, 1, 29, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)}
+	event := CalendarEvent{Earnings: appleQ1, Company: apple}
+
+	coll := new(MockCollection)
+	coll.On("Aggregate", mock.Anything, calendarPipeline(req, "companies"), mock.Anything).Return([]interface{}{event}, nil)
+
+	got, err := newEarningsRepository(coll, nil, "companies", time.Second).Calendar(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, []CalendarEvent{event}, got)
+	coll.AssertExpectations(t)
+}
+
+func TestCalendarPipeline(t *testing.T) {
+	req := CalendarRequest{From: time.Date(2024, 1, 29, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)}
+
+	var stages []string
+	for _, stage := range calendarPipeline(req, "companies") {
+		stages = append(stages, stage.(bson.D)[0].Key)
+	}
+	assert.Equal(t, []string{"$match", "$lookup", "$unwind", "$addFields", "$sort"}, stages)
+
+	lookup := calendarPipeline(req, "companies")[1].(bson.D)[0].Value.(bson.D)
+	var lookupFields []string
+	for _, field := range lookup {
+		lookupFields = append(lookupFields, field.Key)
+	}
+	assert.Equal(t, []string{"from", "let", "pipeline", "as"}, lookupFields, "the join runs on servers before MongoDB 5.0")
+
+	reportDate := calendarPipeline(req, "companies")[0].(bson.D)[0].Value.(bson.D)[0].Value.(bson.D)
+	assert.Equal(t, req.From, reportDate[0].Value)
+	assert.Equal(t, time.Date(2024, 2, 3, 0, 0, 0, 0, time.UTC), reportDate[1].Value, "the to date is included")
+
+	req.Surprise = SurpriseFilter{PctGT: float64Ptr(10)}
+	match := calendarPipeline(req, "companies")[0].(bson.D)[0].Value.(bson.D)
+	assert.Equal(t, bson.E{Key: "epsSurprisePct", Value: bson.D{{Key: "$gt", Value: 10.0}}}, match[1], "surprise filters apply before the join")
+
+	req.Exchange = "NASDAQ"
+	req.Sector = "Consumer Electronics"
+	pipeline := calendarPipeline(req, "companies")
+	assert.Equal(t, bson.D{{Key: "$match", Value: bson.D{
+		{Key: "company.exchange", Value: "NASDAQ"},
+		{Key: "company.sector", Value: bson.D{{Key: "$regex", Value: `^Consumer Electronics$`}, {Key: "$options", Value: "i"}}},
+	}}}, pipeline[3], "company filters apply after the join")
+}
+
+// TestEarningsRepository_Integration runs against a real mongod when
+// MONGODB_TEST_URI is set
+func TestEarningsRepository_Integration(t *testing.T) {
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI not set")
+	}
+
+	ctx := context.Background()
+	client, err := Connect(ctx, Config{
+		URI:                 uri,
+		Database:            "company_earnings_test",
+		EarningsCollection:  "earnings_" + time.Now().Format("20060102150405"),
+		RevisionsCollection: "revisions_" + time.Now().Format("20060102150405"),
+	})
+	require.NoError(t, err)
+	defer client.Disconnect(ctx)
+	defer client.db.Collection(client.cfg.EarningsCollection).Drop(ctx)
+	defer client.db.Collection(client.cfg.RevisionsCollection).Drop(ctx)
+	require.NoError(t, client.EnsureIndexes(ctx))
+
+	repo := client.Earnings()
+	for _, e := range []models.Earnings{appleQ2, appleQ1} {
+		got, err := repo.Upsert(ctx, e, "test")
+		require.NoError(t, err)
+		assert.Equal(t, UpsertInserted, got)
+	}
+
+	restated := appleQ1
+	restated.NetIncome = float64Ptr(33900000000)
+	restated.PublishedAt = timePtr(time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC))
+	got, err := repo.Upsert(ctx, restated, "restatement-feed")
+	require.NoError(t, err)
+	assert.Equal(t, UpsertUpdated, got)
+
+	// feeds replaying their history do not restate the quarter again
+	replayed := appleQ1
+	replayed.PublishedAt = appleQ1.ReportDate
+	got, err = repo.Upsert(ctx, replayed, "test")
+	require.NoError(t, err)
+	assert.Equal(t, UpsertUnchanged, got)
+
+	// both the original report and the restatement are in the revision log
+	revisions, err := client.Revisions().ListEarnings(ctx, "aapl", 2024, 1)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, "test", revisions[0].Source)
+	assert.Equal(t, []models.FieldChange{{Field: "netIncome", Old: appleQ1.NetIncome, New: restated.NetIncome}}, revisions[1].Changes)
+
+	// the restatement is recorded as a version next to the original report
+	q1, q2 := appleQ1, appleQ2
+	q1.RecordVersion(nil, time.Time{})
+	q2.RecordVersion(nil, time.Time{})
+	restated.RecordVersion(&q1, time.Time{})
+	q1.Writes, q2.Writes, restated.Writes = 2, 1, 2
+
+	list, err := repo.ListBySymbol(ctx, "aapl", EarningsFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []models.Earnings{restated, q2}, list)
+
+	asOf := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	list, err = repo.ListBySymbol(ctx, "aapl", EarningsFilter{AsOf: &asOf})
+	require.NoError(t, err)
+	assert.Equal(t, []models.Earnings{q1}, list)
+
+	t.Run("Calendar", func(t *testing.T) {
+		companies := client.db.Collection(client.cfg.CompanyCollection)
+		_, err := companies.InsertMany(ctx, []interface{}{apple, aple})
+		require.NoError(t, err)
+		defer companies.Drop(ctx)
+
+		// APLE reports before the open on the same day AAPL reports after the close
+		apleQ4 := models.Earnings{
+			Symbol: "APLE", FiscalYear: 2023, FiscalQuarter: 4,
+			PeriodEnd:  time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC),
+			ReportDate: appleQ1.ReportDate, ReportTime: models.ReportTimeBeforeOpen, Currency: "USD",
+		}
+		_, err = repo.Upsert(ctx, apleQ4, "test")
+		require.NoError(t, err)
+		apleQ4.Writes = 1
+
+		week := CalendarRequest{From: time.Date(2024, 1, 29, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)}
+		events, err := repo.Calendar(ctx, week)
+		require.NoError(t, err)
+		assert.Equal(t, []CalendarEvent{{Earnings: apleQ4, Company: aple}, {Earnings: restated, Company: apple}}, events)
+
+		week.Exchange = "NASDAQ"
+		events, err = repo.Calendar(ctx, week)
+		require.NoError(t, err)
+		assert.Equal(t, []CalendarEvent{{Earnings: restated, Company: apple}}, events)
+	})
+}
@@ -0,0 +1,149 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RevisionRepository reads the revision log the earnings and statements
+// repositories append to. Revisions are never deleted, and only updated to
+// confirm them.
+type RevisionRepository interface {
+	// ListEarnings returns the revisions of the earnings of one fiscal
+	// quarter, oldest first
+	ListEarnings(ctx context.Context, symbol string, fiscalYear, fiscalQuarter int) ([]models.Revision, error)
+	// ListFinancials returns the revisions of the financials of one fiscal
+	// period, oldest first
+	ListFinancials(ctx context.Context, cik string, fiscalYear int, fiscalPeriod string) ([]models.Revision, error)
+}
+
+type revisionRepository struct {
+	coll    collection
+	timeout time.Duration
+}
+
+// NewRevisionRepository returns a RevisionRepository over coll
+func NewRevisionRepository(coll *driver.Collection, timeout time.Duration) RevisionRepository {
+	return newRevisionRepository(coll, timeout)
+}
+
+func newRevisionRepository(coll collection, timeout time.Duration) *revisionRepository {
+	return &revisionRepository{coll: coll, timeout: timeout}
+}
+
+func (r *revisionRepository) ListEarnings(ctx context.Context, symbol string, fiscalYear, fiscalQuarter int) ([]models.Revision, error) {
+	return r.list(ctx, bson.D{
+		{Key: "record", Value: models.RecordEarnings},
+		{Key: "symbol", Value: strings.ToUpper(symbol)},
+		{Key: "fiscalYear", Value: fiscalYear},
+		{Key: "fiscalPeriod", Value: fmt.Sprintf("Q%d", fiscalQuarter)},
+	})
+}
+
+func (r *revisionRepository) ListFinancials(ctx context.Context, cik string, fiscalYear int, fiscalPeriod string) ([]models.Revision, error) {
+	if normalized, err := models.NormalizeCIK(cik); err == nil {
+		cik = normalized
+	}
+	return r.list(ctx, bson.D{
+		{Key: "record", Value: models.RecordFinancials},
+		{Key: "cik", Value: cik},
+		{Key: "fiscalYear", Value: fiscalYear},
+		{Key: "fiscalPeriod", Value: fiscalPeriod},
+	})
+}
+
+func (r *revisionRepository) list(ctx context.Context, query bson.D) ([]models.Revision, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query = append(query, bson.E{Key: "pending", Value: bson.D{{Key: "$exists", Value: false}}})
+	// _id breaks ties between revisions recorded in the same millisecond
+	opts := options.Find().SetSort(bson.D{{Key: "recordedAt", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := r.coll.Find(ctx, query, opts)
+	if err != nil {
+		return nil, dberrors.NewDBError(fmt.Sprintf("error listing revisions: %v", err))
+	}
+	defer cursor.Close(ctx)
+
+	revisions := []models.Revision{}
+	if err := cursor.All(ctx, &revisions); err != nil {
+		return nil, dberrors.NewDBError(fmt.Sprintf("error decoding revisions: %v", err))
+	}
+	return revisions, nil
+}
+
+// appendRevision inserts a revision into the log in coll ahead of the write
+// of its record and returns its ID. It stays pending, and out of the log,
+// until confirmRevision, so a failed record write leaves no revision behind.
+func appendRevision(ctx context.Context, coll collection, revision models.Revision) (string, error) {
+	revision.ID = primitive.NewObjectID().Hex()
+	revision.Pending = true
+	if _, err := coll.InsertOne(ctx, revision); err != nil {
+		return "", dberrors.NewDBError(fmt.Sprintf("error recording revision: %v", err))
+	}
+	return revision.ID, nil
+}
+
+// confirmRevision adds the pending revision id to the log once its record is
+// written. Confirming a revision again changes nothing, so a record read back
+// with a revision left pending can confirm it.
+func confirmRevision(ctx context.Context, coll collection, id string) error {
+	_, err := coll.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}, {Key: "pending", Value: true}},
+		bson.D{{Key: "$unset", Value: bson.D{{Key: "pending", Value: ""}}}},
+	)
+	if err != nil {
+		return dberrors.NewDBError(fmt.Sprintf("error confirming revision: %v", err))
+	}
+	return nil
+}
+
+// replaceRecord writes doc, the next state of the revisioned record filter
+// matches, unless another upsert wrote the record since it was read. exists
+// tells whether the record was read at all and written is the write count it
+// was read with, 0 for records stored before writes were counted. A record
+// written since is reported as dberrors.ErrWriteConflict so the caller can
+// read it again and retry.
+func replaceRecord(ctx context.Context, coll collection, filter bson.D, exists bool, written int, doc interface{}, what string) (UpsertResult, error) {
+	guard := bson.E{Key: "writes", Value: written}
+	if written == 0 {
+		guard.Value = bson.D{{Key: "$exists", Value: false}}
+	}
+	guarded := append(append(bson.D{}, filter...), guard)
+
+	res, err := coll.ReplaceOne(ctx, guarded, doc, options.Replace().SetUpsert(!exists))
+	switch {
+	case driver.IsDuplicateKeyError(err):
+		// inserted since it was found missing
+		return UpsertUnchanged, dberrors.ErrWriteConflict
+	case err != nil:
+		return UpsertUnchanged, dberrors.NewDBError(fmt.Sprintf("error upserting %s: %v", what, err))
+	case res.UpsertedCount > 0:
+		return UpsertInserted, nil
+	case res.MatchedCount == 0:
+		return UpsertUnchanged, dberrors.ErrWriteConflict
+	default:
+		return UpsertUpdated, nil
+	}
+}
+
+// unchanged reports whether writing doc would leave stored, the record it
+// replaces as it was read, as it is. written receives doc as it would be read
+// back, so both are compared in their stored form.
+func unchanged(stored, doc, written interface{}) bool {
+	raw, err := bson.Marshal(doc)
+	if err != nil || bson.Unmarshal(raw, written) != nil {
+		return false
+	}
+	return reflect.DeepEqual(stored, written)
+}
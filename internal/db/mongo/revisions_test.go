@@ -0,0 +1,74 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// revisionLog returns a revision collection that records and confirms every
+// revision
+func revisionLog() *MockCollection {
+	revisions := new(MockCollection)
+	revisions.On("InsertOne", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	revisions.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&driver.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+	return revisions
+}
+
+func TestRevisionRepository_ListEarnings(t *testing.T) {
+	query := bson.D{
+		{Key: "record", Value: "earnings"},
+		{Key: "symbol", Value: "AAPL"},
+		{Key: "fiscalYear", Value: 2024},
+		{Key: "fiscalPeriod", Value: "Q1"},
+		{Key: "pending", Value: bson.D{{Key: "$exists", Value: false}}},
+	}
+	chronological := func(opts []*options.FindOptions) bool {
+		return len(opts) == 1 && assert.ObjectsAreEqual(bson.D{{Key: "recordedAt", Value: 1}, {Key: "_id", Value: 1}}, opts[0].Sort)
+	}
+	revision := models.Revision{
+		Record: models.RecordEarnings, Symbol: "AAPL", FiscalYear: 2024, FiscalPeriod: "Q1",
+		Source: "test", RecordedAt: time.Date(2024, 2, 1, 21, 30, 0, 0, time.UTC),
+		Changes: []models.FieldChange{{Field: "epsDiluted", New: float64Ptr(2.18)}},
+	}
+
+	coll := new(MockCollection)
+	coll.On("Find", mock.Anything, query, mock.MatchedBy(chronological)).Return([]interface{}{revision}, nil)
+
+	got, err := newRevisionRepository(coll, time.Second).ListEarnings(context.Background(), "aapl", 2024, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []models.Revision{revision}, got)
+	coll.AssertExpectations(t)
+}
+
+func TestRevisionRepository_ListFinancials(t *testing.T) {
+	query := bson.D{
+		{Key: "record", Value: "financials"},
+		{Key: "cik", Value: "0000320193"},
+		{Key: "fiscalYear", Value: 2023},
+		{Key: "fiscalPeriod", Value: "FY"},
+		{Key: "pending", Value: bson.D{{Key: "$exists", Value: false}}},
+	}
+
+	coll := new(MockCollection)
+	coll.On("Find", mock.Anything, query, mock.Anything).Return([]interface{}{}, nil)
+	coll.On("Find", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("socket closed"))
+	r := newRevisionRepository(coll, time.Second)
+
+	got, err := r.ListFinancials(context.Background(), "320193", 2023, "FY")
+	require.NoError(t, err)
+	assert.Equal(t, []models.Revision{}, got)
+
+	_, err = r.ListFinancials(context.Background(), "0000789019", 2023, "FY")
+	assert.True(t, dberrors.IsDBError(err))
+}
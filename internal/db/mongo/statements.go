@@ -0,0 +1,258 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// StatementsRepository stores the normalized financial statements of
+// companies, keyed by CIK so every share class of a registrant shares them
+type StatementsRepository interface {
+	// ListByCIK returns the financials of a registrant, most recent period
+	// first
+	ListByCIK(ctx context.Context, cik string, filter FinancialsFilter) ([]models.Financials, error)
+	// ListByCIKs returns the financials of several registrants in a single
+	// query, keyed by CIK and most recent period first. The limit of filter
+	// applies to each registrant.
+	ListByCIKs(ctx context.Context, ciks []string, filter FinancialsFilter) (map[string][]models.Financials, error)
+	// Upsert stores the financials of one fiscal period, replacing any
+	// previously stored line items for the same period. Changed values are
+	// appended to the revision log as written by source. Like the earnings
+	// Upsert, it only writes a period no other upsert wrote since it was
+	// read, retrying otherwise.
+	Upsert(ctx context.Context, financials models.Financials, source string) (UpsertResult, error)
+}
+
+// FinancialsFilter selects the periods ListByCIK returns
+type FinancialsFilter struct {
+	// FiscalPeriods restricts the fiscal periods, e.g. to the quarters.
+	// Empty returns every period.
+	FiscalPeriods []string
+	// Limit caps the number of periods, 0 for no limit
+	Limit int
+	// AsOf, when set, returns the financials as they were known at the end
+	// of that day, leaving out the periods and line items filed later
+	AsOf *time.Time
+	// Since, when set, leaves out the periods ending before it
+	Since *time.Time
+}
+
+type statementsRepository struct {
+	coll      collection
+	revisions collection
+	timeout   time.Duration
+	// now dates revisions
+	now func() time.Time
+}
+
+// NewStatementsRepository returns a StatementsRepository over coll that
+// appends revisions to the revisions collection
+func NewStatementsRepository(coll, revisions *driver.Collection, timeout time.Duration) StatementsRepository {
+	return newStatementsRepository(coll, revisions, timeout)
+}
+
+func newStatementsRepository(coll, revisions collection, timeout time.Duration) *statementsRepository {
+	return &statementsRepository{coll: coll, revisions: revisions, timeout: timeout, now: time.Now}
+}
+
+// normalizeCIK returns cik as stored, or unchanged when it is not a CIK
+func normalizeCIK(cik string) string {
+	if normalized, err := models.NormalizeCIK(cik); err == nil {
+		return normalized
+	}
+	return cik
+}
+
+// clauses returns the query conditions of the filter other than the limit
+func (filter FinancialsFilter) clauses() bson.D {
+	var query bson.D
+	if len(filter.FiscalPeriods) > 0 {
+		query = append(query, bson.E{Key: "fiscalPeriod", Value: bson.D{{Key: "$in", Value: filter.FiscalPeriods}}})
+	}
+	if filter.AsOf != nil {
+		query = append(query, bson.E{Key: "filed", Value: bson.D{{Key: "$lt", Value: filter.AsOf.AddDate(0, 0, 1)}}})
+	}
+	if filter.Since != nil {
+		query = append(query, bson.E{Key: "periodEnd", Value: bson.D{{Key: "$gte", Value: *filter.Since}}})
+	}
+	return query
+}
+
+// statementSort orders financials from the latest period end, with the
+// fiscal year before the fourth quarter ending on the same day
+var statementSort = bson.D{{Key: "periodEnd", Value: -1}, {Key: "fiscalPeriod", Value: 1}}
+
+func (r *statementsRepository) ListByCIK(ctx context.Context, cik string, filter FinancialsFilter) ([]models.Financials, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := append(bson.D{{Key: "cik", Value: normalizeCIK(cik)}}, filter.clauses()...)
+	opts := options.Find().SetSort(statementSort)
+	if filter.Limit > 0 {
+		opts.SetLimit(int64(filter.Limit))
+	}
+
+	cursor, err := r.coll.Find(ctx, query, opts)
+	if err != nil {
+		return nil, dberrors.NewDBError(fmt.Sprintf("error listing financials: %v", err))
+	}
+	defer cursor.Close(ctx)
+
+	financials := []models.Financials{}
+	if err := cursor.All(ctx, &financials); err != nil {
+		return nil, dberrors.NewDBError(fmt.Sprintf("error decoding financials: %v", err))
+	}
+	if filter.AsOf != nil {
+		known := financials[:0]
+		for _, f := range financials {
+			if f, ok := f.AsOf(*filter.AsOf); ok {
+				known = append(known, f)
+			}
+		}
+		financials = known
+	}
+	return financials, nil
+}
+
+func (r *statementsRepository) Upsert(ctx context.Context, financials models.Financials, source string) (UpsertResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		result, err := r.upsert(ctx, financials, source)
+		if !errors.Is(err, dberrors.ErrWriteConflict) {
+			return result, err
+		}
+	}
+	return UpsertUnchanged, dberrors.ErrWriteConflict
+}
+
+func (r *statementsRepository) ListByCIKs(ctx context.Context, ciks []string, filter FinancialsFilter) (map[string][]models.Financials, error) {
+	byCIK := make(map[string][]models.Financials, len(ciks))
+	if len(ciks) == 0 {
+		return byCIK, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	normalized := make([]string, len(ciks))
+	for i, cik := range ciks {
+		normalized[i] = normalizeCIK(cik)
+	}
+	// the periods of a screen can outgrow the memory of a group stage
+	cursor, err := r.coll.Aggregate(ctx, listByCIKsPipeline(normalized, filter), options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, dberrors.NewDBError(fmt.Sprintf("error listing financials: %v", err))
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var registrant struct {
+			CIK     string              `bson:"_id"`
+			Periods []models.Financials `bson:"periods"`
+		}
+		if err := cursor.Decode(&registrant); err != nil {
+			return nil, dberrors.NewDBError(fmt.Sprintf("error decoding financials: %v", err))
+		}
+		for _, f := range registrant.Periods {
+			if filter.AsOf != nil {
+				var ok bool
+				if f, ok = f.AsOf(*filter.AsOf); !ok {
+					continue
+				}
+			}
+			byCIK[registrant.CIK] = append(byCIK[registrant.CIK], f)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, dberrors.NewDBError(fmt.Sprintf("error listing financials: %v", err))
+	}
+	return byCIK, nil
+}
+
+// listByCIKsPipeline groups the periods of each registrant, most recent
+// first, keeping the first filter.Limit of them in the query so only those
+// are returned. The limit counts the periods read, as it does for ListByCIK.
+func listByCIKsPipeline(ciks []string, filter FinancialsFilter) bson.A {
+	match := append(bson.D{{Key: "cik", Value: bson.D{{Key: "$in", Value: ciks}}}}, filter.clauses()...)
+	pipeline := bson.A{
+		bson.D{{Key: "$match", Value: match}},
+		bson.D{{Key: "$sort", Value: append(bson.D{{Key: "cik", Value: 1}}, statementSort...)}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$cik"},
+			{Key: "periods", Value: bson.D{{Key: "$push", Value: "$$ROOT"}}},
+		}}},
+	}
+	if filter.Limit > 0 {
+		// $topN would keep the periods while grouping, but needs MongoDB 5.2
+		pipeline = append(pipeline, bson.D{{Key: "$project", Value: bson.D{
+			{Key: "periods", Value: bson.D{{Key: "$slice", Value: bson.A{"$periods", filter.Limit}}}},
+		}}})
+	}
+	return pipeline
+}
+
+// upsert replaces the stored period as read once unless another upsert wrote
+// it in between
+func (r *statementsRepository) upsert(ctx context.Context, financials models.Financials, source string) (UpsertResult, error) {
+	filter := bson.D{
+		{Key: "cik", Value: financials.CIK},
+		{Key: "fiscalYear", Value: financials.FiscalYear},
+		{Key: "fiscalPeriod", Value: financials.FiscalPeriod},
+	}
+	var stored models.Financials
+	var previous *models.Financials
+	err := r.coll.FindOne(ctx, filter).Decode(&stored)
+	switch {
+	case errors.Is(err, driver.ErrNoDocuments):
+	case err != nil:
+		return UpsertUnchanged, dberrors.NewDBError(fmt.Sprintf("error finding financials: %v", err))
+	default:
+		previous = &stored
+	}
+
+	written := 0
+	if previous != nil {
+		if previous.RevisionID != "" {
+			if err := confirmRevision(ctx, r.revisions, previous.RevisionID); err != nil {
+				return UpsertUnchanged, err
+			}
+		}
+		written = previous.Writes
+		financials.Writes, financials.RevisionID = written, previous.RevisionID
+		if unchanged(previous, &financials, new(models.Financials)) {
+			return UpsertUnchanged, nil
+		}
+	}
+	financials.Writes, financials.RevisionID = written+1, ""
+
+	// recorded around the write for the reasons given in the earnings upsert
+	if changes := financials.Changes(previous); len(changes) > 0 {
+		// MongoDB stores times to the millisecond
+		now := r.now().UTC().Truncate(time.Millisecond)
+		id, err := appendRevision(ctx, r.revisions, financials.Revision(changes, source, now))
+		if err != nil {
+			return UpsertUnchanged, err
+		}
+		financials.RevisionID = id
+	}
+	result, err := replaceRecord(ctx, r.coll, filter, previous != nil, written, financials, "financials")
+	if err != nil {
+		return result, err
+	}
+	if financials.RevisionID != "" {
+		if err := confirmRevision(ctx, r.revisions, financials.RevisionID); err != nil {
+			return UpsertUnchanged, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,339 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	driver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	appleQ4FY2023 = models.Financials{
+		CIK:          "0000320193",
+		FiscalYear:   2023,
+		FiscalPeriod: models.FiscalPeriodQ4,
+		PeriodStart:  timePtr(time.Date(2023, 7, 2, 0, 0, 0, 0, time.UTC)),
+		PeriodEnd:    time.Date(2023, 9, 30, 0, 0, 0, 0, time.UTC),
+		Currency:     "USD",
+		Items: map[string]models.LineItem{
+			models.LineItemRevenue: {
+				Value: 89498000000, Concept: "RevenueFromContractWithCustomerExcludingAssessedTax",
+				Form: "10-K", Accession: "0000320193-23-000106", Filed: time.Date(2023, 11, 3, 0, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+	appleFY2023 = models.Financials{
+		CIK:          "0000320193",
+		FiscalYear:   2023,
+		FiscalPeriod: models.FiscalPeriodFY,
+		PeriodStart:  timePtr(time.Date(2022, 9, 25, 0, 0, 0, 0, time.UTC)),
+		PeriodEnd:    time.Date(2023, 9, 30, 0, 0, 0, 0, time.UTC),
+		Currency:     "USD",
+		Items: map[string]models.LineItem{
+			models.LineItemRevenue: {
+				Value: 383285000000, Concept: "RevenueFromContractWithCustomerExcludingAssessedTax",
+				Form: "10-K", Accession: "0000320193-23-000106", Filed: time.Date(2023, 11, 3, 0, 0, 0, 0, time.UTC),
+			},
+			models.LineItemAssets: {
+				Value: 352583000000, Concept: "Assets",
+				Form: "10-K", Accession: "0000320193-23-000106", Filed: time.Date(2023, 11, 3, 0, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+)
+
+func TestStatementsRepository_ListByCIK(t *testing.T) {
+	annual := bson.D{{Key: "cik", Value: "0000320193"}, {Key: "fiscalPeriod", Value: bson.D{{Key: "$in", Value: []string{"FY"}}}}}
+	limited := func(opts []*options.FindOptions) bool {
+		return len(opts) == 1 && opts[0].Limit != nil && *opts[0].Limit == 4
+	}
+
+	coll := new(MockCollection)
+	coll.On("Find", mock.Anything, bson.D{{Key: "cik", Value: "0000320193"}}, mock.Anything).Return([]interface{}{appleFY2023, appleQ4FY2023}, nil)
+	coll.On("Find", mock.Anything, annual, mock.MatchedBy(limited)).Return([]interface{}{appleFY2023}, nil)
+	coll.On("Find", mock.Anything, bson.D{{Key: "cik", Value: "0000789019"}}, mock.Anything).Return(nil, errors.New("socket closed"))
+
+	r := newStatementsRepository(coll, nil, time.Second)
+
+	got, err := r.ListByCIK(context.Background(), "320193", FinancialsFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []models.Financials{appleFY2023, appleQ4FY2023}, got)
+
+	got, err = r.ListByCIK(context.Background(), "0000320193", FinancialsFilter{FiscalPeriods: []string{"FY"}, Limit: 4})
+	require.NoError(t, err)
+	assert.Equal(t, []models.Financials{appleFY2023}, got)
+
+	_, err = r.ListByCIK(context.Background(), "0000789019", FinancialsFilter{})
+	assert.True(t, dberrors.IsDBError(err))
+
+	coll.AssertExpectations(t)
+}
+
+func TestStatementsRepository_ListByCIKs(t *testing.T) {
+	msft := appleFY2023
+	msft.CIK = "0000789019"
+	since := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	filter := FinancialsFilter{Limit: 1, Since: &since}
+
+	coll := new(MockCollection)
+	coll.On("Aggregate", mock.Anything, listByCIKsPipeline([]string{"0000320193", "0000789019"}, filter), mock.Anything).Return([]interface{}{
+		bson.D{{Key: "_id", Value: "0000320193"}, {Key: "periods", Value: bson.A{appleFY2023}}},
+		bson.D{{Key: "_id", Value: "0000789019"}, {Key: "periods", Value: bson.A{msft}}},
+	}, nil)
+	coll.On("Aggregate", mock.Anything, listByCIKsPipeline([]string{"0000051143"}, FinancialsFilter{}), mock.Anything).Return(nil, errors.New("socket closed"))
+	r := newStatementsRepository(coll, nil, time.Second)
+
+	got, err := r.ListByCIKs(context.Background(), []string{"320193", "0000789019"}, filter)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]models.Financials{
+		"0000320193": {appleFY2023},
+		"0000789019": {msft},
+	}, got)
+
+	got, err = r.ListByCIKs(context.Background(), nil, FinancialsFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	_, err = r.ListByCIKs(context.Background(), []string{"51143"}, FinancialsFilter{})
+	assert.True(t, dberrors.IsDBError(err))
+	coll.AssertExpectations(t)
+}
+
+func TestListByCIKsPipeline_LimitsEachRegistrant(t *testing.T) {
+	since := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	pipeline := listByCIKsPipeline([]string{"0000320193"}, FinancialsFilter{Limit: 16, Since: &since})
+
+	var stages []string
+	for _, stage := range pipeline {
+		stages = append(stages, stage.(bson.D)[0].Key)
+	}
+	assert.Equal(t, []string{"$match", "$sort", "$group", "$project"}, stages)
+
+	assert.Equal(t, bson.D{
+		{Key: "cik", Value: bson.D{{Key: "$in", Value: []string{"0000320193"}}}},
+		{Key: "periodEnd", Value: bson.D{{Key: "$gte", Value: since}}},
+	}, pipeline[0].(bson.D)[0].Value)
+	assert.Equal(t, bson.D{{Key: "cik", Value: 1}, {Key: "periodEnd", Value: -1}, {Key: "fiscalPeriod", Value: 1}}, pipeline[1].(bson.D)[0].Value)
+	project := pipeline[3].(bson.D)[0].Value.(bson.D)
+	assert.Equal(t, bson.D{{Key: "$slice", Value: bson.A{"$periods", 16}}}, project[0].Value, "only the latest periods of each registrant are returned")
+
+	assert.Len(t, listByCIKsPipeline([]string{"0000320193"}, FinancialsFilter{}), 3, "no limit returns every period")
+}
+
+func TestStatementsRepository_ListByCIK_AsOf(t *testing.T) {
+	original := models.LineItemVersion{
+		Value: 352755000000, Concept: "Assets",
+		Form: "10-K", Accession: "0000320193-23-000106", Filed: time.Date(2023, 11, 3, 0, 0, 0, 0, time.UTC),
+	}
+	restatement := models.LineItemVersion{
+		Value: 352583000000, Concept: "Assets",
+		Form: "10-Q", Accession: "0000320193-24-000006", Filed: time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC),
+	}
+	restated := appleFY2023
+	restated.Filed = original.Filed
+	restated.Items = map[string]models.LineItem{models.LineItemAssets: models.NewLineItem([]models.LineItemVersion{original, restatement})}
+
+	asOf := time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)
+	query := bson.D{{Key: "cik", Value: "0000320193"}, {Key: "filed", Value: bson.D{{Key: "$lt", Value: asOf.AddDate(0, 0, 1)}}}}
+	coll := new(MockCollection)
+	coll.On("Find", mock.Anything, query, mock.Anything).Return([]interface{}{restated}, nil)
+
+	got, err := newStatementsRepository(coll, nil, time.Second).ListByCIK(context.Background(), "320193", FinancialsFilter{AsOf: &asOf})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, models.NewLineItem([]models.LineItemVersion{original}), got[0].Items[models.LineItemAssets], "the restatement was filed later")
+	coll.AssertExpectations(t)
+}
+
+func TestStatementsRepository_Upsert(t *testing.T) {
+	filter := bson.D{{Key: "cik", Value: "0000320193"}, {Key: "fiscalYear", Value: 2023}, {Key: "fiscalPeriod", Value: "FY"}}
+	guarded := func(written interface{}) bson.D {
+		return append(append(bson.D{}, filter...), bson.E{Key: "writes", Value: written})
+	}
+	inserted := appleFY2023
+	inserted.Writes = 1
+	stored := appleFY2023
+	stored.Items = map[string]models.LineItem{models.LineItemRevenue: appleFY2023.Items[models.LineItemRevenue]}
+	stored.Writes = 1
+	updated := appleFY2023
+	updated.Writes = 2
+
+	tests := []struct {
+		name   string
+		stored interface{}
+		filter bson.D
+		write  models.Financials
+		result *driver.UpdateResult
+		err    error
+		want   UpsertResult
+	}{
+		{"Inserted", nil, guarded(bson.D{{Key: "$exists", Value: false}}), inserted, &driver.UpdateResult{UpsertedCount: 1}, nil, UpsertInserted},
+		{"Updated", stored, guarded(1), updated, &driver.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil, UpsertUpdated},
+		{"Failure", nil, guarded(bson.D{{Key: "$exists", Value: false}}), inserted, &driver.UpdateResult{}, errors.New("socket closed"), UpsertUnchanged},
+	}
+
+	// the revision ID is generated, so writes are compared without it
+	writing := func(want models.Financials) interface{} {
+		return mock.MatchedBy(func(f models.Financials) bool {
+			f.RevisionID = ""
+			return assert.ObjectsAreEqual(want, f)
+		})
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coll := new(MockCollection)
+			if tt.stored == nil {
+				coll.On("FindOne", mock.Anything, filter, mock.Anything).Return(nil, driver.ErrNoDocuments)
+			} else {
+				coll.On("FindOne", mock.Anything, filter, mock.Anything).Return(tt.stored, nil)
+			}
+			coll.On("ReplaceOne", mock.Anything, tt.filter, writing(tt.write), mock.Anything).Return(tt.result, tt.err)
+			revisions := revisionLog()
+
+			got, err := newStatementsRepository(coll, revisions, time.Second).Upsert(context.Background(), appleFY2023, "test")
+			assert.Equal(t, tt.err != nil, dberrors.IsDBError(err))
+			assert.Equal(t, tt.want, got)
+			coll.AssertExpectations(t)
+		})
+	}
+
+	t.Run("Written concurrently", func(t *testing.T) {
+		coll := new(MockCollection)
+		coll.On("FindOne", mock.Anything, filter, mock.Anything).Return(stored, nil).Once()
+		coll.On("FindOne", mock.Anything, filter, mock.Anything).Return(inserted, nil)
+		coll.On("ReplaceOne", mock.Anything, guarded(1), writing(updated), mock.Anything).Return(&driver.UpdateResult{}, nil)
+		revisions := revisionLog()
+
+		got, err := newStatementsRepository(coll, revisions, time.Second).Upsert(context.Background(), appleFY2023, "test")
+		require.NoError(t, err)
+		assert.Equal(t, UpsertUnchanged, got, "the concurrent upsert wrote the same values")
+		coll.AssertNumberOfCalls(t, "ReplaceOne", 1)
+		revisions.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		coll := new(MockCollection)
+		coll.On("FindOne", mock.Anything, filter, mock.Anything).Return(stored, nil)
+		coll.On("ReplaceOne", mock.Anything, guarded(1), writing(updated), mock.Anything).Return(&driver.UpdateResult{}, nil)
+		revisions := revisionLog()
+
+		_, err := newStatementsRepository(coll, revisions, time.Second).Upsert(context.Background(), appleFY2023, "test")
+		assert.ErrorIs(t, err, dberrors.ErrWriteConflict)
+		coll.AssertNumberOfCalls(t, "ReplaceOne", maxUpdateAttempts)
+		revisions.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Revision failure", func(t *testing.T) {
+		coll := new(MockCollection)
+		coll.On("FindOne", mock.Anything, filter, mock.Anything).Return(stored, nil)
+		revisions := new(MockCollection)
+		revisions.On("InsertOne", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("socket closed"))
+
+		_, err := newStatementsRepository(coll, revisions, time.Second).Upsert(context.Background(), appleFY2023, "test")
+		assert.True(t, dberrors.IsDBError(err))
+		coll.AssertNotCalled(t, "ReplaceOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestStatementsRepository_Upsert_Revisions(t *testing.T) {
+	filter := bson.D{{Key: "cik", Value: "0000320193"}, {Key: "fiscalYear", Value: 2023}, {Key: "fiscalPeriod", Value: "FY"}}
+	stored := appleFY2023
+	stored.Items = map[string]models.LineItem{
+		models.LineItemRevenue: appleFY2023.Items[models.LineItemRevenue],
+		models.LineItemAssets:  {Value: 352755000000, Concept: "Assets", Form: "10-K", Accession: "0000320193-23-000106"},
+	}
+	now := time.Date(2024, 2, 3, 6, 0, 0, 0, time.UTC)
+
+	coll := new(MockCollection)
+	coll.On("FindOne", mock.Anything, filter, mock.Anything).Return(stored, nil)
+	coll.On("ReplaceOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&driver.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+	revisions := revisionLog()
+
+	r := newStatementsRepository(coll, revisions, time.Second)
+	r.now = func() time.Time { return now }
+	_, err := r.Upsert(context.Background(), appleFY2023, "sec-companyfacts")
+	require.NoError(t, err)
+	revision := revisions.Calls[0].Arguments.Get(1).(models.Revision)
+	require.NotEmpty(t, revision.ID)
+	assert.Equal(t, models.Revision{
+		ID: revision.ID, Record: models.RecordFinancials, CIK: "0000320193", FiscalYear: 2023, FiscalPeriod: "FY",
+		Source: "sec-companyfacts", RecordedAt: now,
+		Changes: []models.FieldChange{{
+			Field: models.LineItemAssets, Old: float64Ptr(352755000000), New: float64Ptr(352583000000),
+			Accession: "0000320193-23-000106",
+		}},
+		Pending: true,
+	}, revision)
+	revisions.AssertCalled(t, "UpdateOne", mock.Anything,
+		bson.D{{Key: "_id", Value: revision.ID}, {Key: "pending", Value: true}}, mock.Anything, mock.Anything)
+
+	t.Run("Unchanged", func(t *testing.T) {
+		coll := new(MockCollection)
+		coll.On("FindOne", mock.Anything, filter, mock.Anything).Return(appleFY2023, nil)
+		revisions := new(MockCollection)
+
+		_, err := newStatementsRepository(coll, revisions, time.Second).Upsert(context.Background(), appleFY2023, "sec-companyfacts")
+		require.NoError(t, err)
+		coll.AssertNotCalled(t, "ReplaceOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		revisions.AssertNotCalled(t, "InsertOne", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+// TestStatementsRepository_Integration runs against a real mongod when
+// MONGODB_TEST_URI is set
+func TestStatementsRepository_Integration(t *testing.T) {
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI not set")
+	}
+
+	ctx := context.Background()
+	client, err := Connect(ctx, Config{
+		URI:                  uri,
+		Database:             "company_earnings_test",
+		StatementsCollection: "statements_" + time.Now().Format("20060102150405"),
+		RevisionsCollection:  "revisions_" + time.Now().Format("20060102150405"),
+	})
+	require.NoError(t, err)
+	defer client.Disconnect(ctx)
+	defer client.db.Collection(client.cfg.StatementsCollection).Drop(ctx)
+	defer client.db.Collection(client.cfg.RevisionsCollection).Drop(ctx)
+	require.NoError(t, client.EnsureIndexes(ctx))
+
+	repo := client.Statements()
+	for _, f := range []models.Financials{appleFY2023, appleQ4FY2023} {
+		got, err := repo.Upsert(ctx, f, "test")
+		require.NoError(t, err)
+		assert.Equal(t, UpsertInserted, got)
+	}
+
+	got, err := repo.Upsert(ctx, appleFY2023, "test")
+	require.NoError(t, err)
+	assert.Equal(t, UpsertUnchanged, got)
+
+	revisions, err := client.Revisions().ListFinancials(ctx, "320193", 2023, "FY")
+	require.NoError(t, err)
+	require.Len(t, revisions, 1, "the unchanged upsert is not a revision")
+	assert.Len(t, revisions[0].Changes, 2)
+
+	fy, q4 := appleFY2023, appleQ4FY2023
+	fy.Writes, q4.Writes = 1, 1
+	list, err := repo.ListByCIK(ctx, "CIK320193", FinancialsFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []models.Financials{fy, q4}, list)
+
+	list, err = repo.ListByCIK(ctx, "0000320193", FinancialsFilter{FiscalPeriods: []string{"Q1", "Q2", "Q3", "Q4"}, Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []models.Financials{q4}, list)
+}
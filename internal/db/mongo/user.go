@@ -9,6 +9,9 @@ type User struct {
 	Email    string
 	Role     string
 	TenantID string
+	// Scopes limits what the user may do when authenticated by API key; nil
+	// grants every scope
+	Scopes []string
 }
 
 // NewUser creates a new User instance
@@ -22,6 +25,19 @@ func NewUser(id, username, email, role, tenantID string) *User {
 	}
 }
 
+// HasScope reports whether the user was granted scope
+func (u *User) HasScope(scope string) bool {
+	if u.Scopes == nil {
+		return true
+	}
+	for _, s := range u.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
 // Validate checks if the user data is valid
 func (u *User) Validate() error {
 	if u.ID == "" {
@@ -1,6 +1,19 @@
 package dberrors
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNotFound is returned by lookups that match no record
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a write would duplicate an existing record
+	ErrConflict = errors.New("already exists")
+	// ErrWriteConflict is returned when a record kept changing while a
+	// write guarded against concurrent changes was retried
+	ErrWriteConflict = errors.New("record changed concurrently")
+)
 
 type DBError struct {
 	Message string
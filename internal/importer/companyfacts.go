@@ -0,0 +1,729 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/models"
+)
+
+// factsCurrency is the only currency read from companyfacts documents. Other
+// currencies are reported by foreign filers under ifrs-full, which is not
+// mapped.
+const factsCurrency = "USD"
+
+// factsUnits maps the line item units to the unit keys of companyfacts facts
+var factsUnits = map[string]string{
+	models.UnitCurrency:         factsCurrency,
+	models.UnitCurrencyPerShare: factsCurrency + "/shares",
+	models.UnitShares:           "shares",
+}
+
+// Bounds in days of the durations accepted as a fiscal quarter (13 or 14
+// weeks) and a fiscal year (52 or 53 weeks). Year-to-date facts of two and
+// three quarters fall within multiples of the quarter bounds.
+const (
+	minQuarterDays = 84
+	maxQuarterDays = 98
+	minYearDays    = 357
+	maxYearDays    = 378
+)
+
+// companyFacts is the layout of an SEC companyfacts document as served by
+// https://data.sec.gov/api/xbrl/companyfacts/CIK##########.json
+type companyFacts struct {
+	CIK        int64  `json:"cik"`
+	EntityName string `json:"entityName"`
+	Facts      struct {
+		USGAAP map[string]struct {
+			Units map[string][]xbrlFact `json:"units"`
+		} `json:"us-gaap"`
+	} `json:"facts"`
+}
+
+// xbrlFact is one reported value. FY and FP identify the fiscal period of the
+// filing the value was reported in, which for comparative figures is not the
+// period the value covers.
+type xbrlFact struct {
+	Start string  `json:"start"`
+	End   string  `json:"end"`
+	Val   float64 `json:"val"`
+	Accn  string  `json:"accn"`
+	FY    int     `json:"fy"`
+	FP    string  `json:"fp"`
+	Form  string  `json:"form"`
+	Filed string  `json:"filed"`
+}
+
+// fact is a companyfacts value mapped to a line item
+type fact struct {
+	item     string
+	concept  string
+	priority int
+	start    *time.Time
+	end      time.Time
+	value    float64
+	form     string
+	accn     string
+	fy       int
+	fp       string
+	filed    time.Time
+}
+
+// duration returns the fiscal period kind a duration fact covers, or "" for
+// instants and durations that are neither a quarter nor a year
+func (f *fact) duration() string {
+	if f.start == nil {
+		return ""
+	}
+	days := int(f.end.Sub(*f.start).Hours()/24) + 1
+	switch {
+	case days >= minQuarterDays && days <= maxQuarterDays:
+		return "quarter"
+	case days >= minYearDays && days <= maxYearDays:
+		return models.FiscalPeriodFY
+	default:
+		return ""
+	}
+}
+
+// ytdQuarters returns how many quarters a year-to-date duration fact covers,
+// 2 for six months and 3 for nine, or 0 for any other fact
+func (f *fact) ytdQuarters() int {
+	if f.start == nil {
+		return 0
+	}
+	days := int(f.end.Sub(*f.start).Hours()/24) + 1
+	for n := 2; n <= 3; n++ {
+		if days >= n*minQuarterDays && days <= n*maxQuarterDays {
+			return n
+		}
+	}
+	return 0
+}
+
+// fiscalQuarter is the fiscal year and quarter ending on a date
+type fiscalQuarter struct {
+	year    int
+	quarter string
+	filed   time.Time
+}
+
+// ParseCompanyFacts parses an SEC companyfacts document into the financials
+// of each fiscal period, oldest first.
+//
+// Facts are reported again as comparatives in later filings, so each line
+// item takes the value of the latest filing that reports it, which picks up
+// restatements, and keeps every value filed for it as a version dated by the
+// filing that first reported it. Fiscal periods are identified by the end date of the period
+// each 10-Q and 10-K covers rather than by calendar arithmetic, which handles
+// fiscal years that do not follow the calendar and 53-week years. Values for
+// periods that no filing in the document covers are dropped.
+//
+// Quarters are discrete: income and cash flow items a filing only reports
+// year to date, as 10-Q cash flow statements and most 10-K filings do, are
+// derived for the quarter by deriveQuarters.
+func ParseCompanyFacts(r io.Reader) ([]models.Financials, error) {
+	var doc companyFacts
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid companyfacts file: %w", err)
+	}
+	if doc.CIK <= 0 {
+		return nil, fmt.Errorf("invalid companyfacts file: missing cik")
+	}
+	cik, err := models.NormalizeCIK(strconv.FormatInt(doc.CIK, 10))
+	if err != nil {
+		return nil, fmt.Errorf("invalid companyfacts file: %w", err)
+	}
+
+	facts, err := readFacts(doc)
+	if err != nil {
+		return nil, err
+	}
+	quarters := fiscalQuarters(facts)
+
+	periods := make(map[string]*models.Financials)
+	reported := make(map[string]map[string][]*fact) // the facts of each line item by period
+	yearToDate := make(map[string]map[string][]*fact)
+	add := func(fiscal fiscalQuarter, period string, f *fact) {
+		key := periodKey(fiscal.year, period)
+		p, ok := periods[key]
+		if !ok {
+			p = &models.Financials{
+				CIK:          cik,
+				FiscalYear:   fiscal.year,
+				FiscalPeriod: period,
+				PeriodEnd:    f.end,
+				Currency:     factsCurrency,
+				Items:        make(map[string]models.LineItem),
+			}
+			periods[key] = p
+			reported[key] = make(map[string][]*fact)
+		}
+		if p.PeriodStart == nil && f.start != nil {
+			p.PeriodStart = f.start
+		}
+		reported[key][f.item] = append(reported[key][f.item], f)
+	}
+
+	for _, f := range facts {
+		fiscal, ok := quarters[f.end]
+		if !ok {
+			continue
+		}
+		switch f.duration() {
+		case "quarter":
+			add(fiscal, fiscal.quarter, f)
+		case models.FiscalPeriodFY:
+			if fiscal.quarter == models.FiscalPeriodQ4 {
+				add(fiscal, models.FiscalPeriodFY, f)
+			}
+		default:
+			if f.start != nil {
+				if n := f.ytdQuarters(); n > 0 && fiscal.quarter == models.FiscalPeriods[n-1] {
+					key := periodKey(fiscal.year, fiscal.quarter)
+					if yearToDate[key] == nil {
+						yearToDate[key] = make(map[string][]*fact)
+					}
+					yearToDate[key][f.item] = append(yearToDate[key][f.item], f)
+				}
+				continue
+			}
+			add(fiscal, fiscal.quarter, f)
+			if fiscal.quarter == models.FiscalPeriodQ4 {
+				add(fiscal, models.FiscalPeriodFY, f)
+			}
+		}
+	}
+
+	for key, p := range periods {
+		for item, itemFacts := range reported[key] {
+			p.Items[item] = models.NewLineItem(versions(itemFacts))
+		}
+	}
+	ytd := make(map[string]*models.Financials, len(yearToDate))
+	for key, items := range yearToDate {
+		var end time.Time
+		f := &models.Financials{Items: make(map[string]models.LineItem, len(items))}
+		for item, itemFacts := range items {
+			f.Items[item] = models.NewLineItem(versions(itemFacts))
+			end = itemFacts[0].end
+		}
+		f.PeriodEnd = end
+		ytd[key] = f
+	}
+	deriveQuarters(periods, ytd, cik)
+
+	for _, p := range periods {
+		for _, item := range p.Items {
+			if filed := item.Versions[0].Filed; p.Filed.IsZero() || filed.Before(p.Filed) {
+				p.Filed = filed
+			}
+		}
+	}
+
+	financials := make([]models.Financials, 0, len(periods))
+	for _, p := range periods {
+		financials = append(financials, *p)
+	}
+	sort.Slice(financials, func(i, j int) bool {
+		a, b := financials[i], financials[j]
+		if !a.PeriodEnd.Equal(b.PeriodEnd) {
+			return a.PeriodEnd.Before(b.PeriodEnd)
+		}
+		return !a.Annual() && b.Annual()
+	})
+	return financials, nil
+}
+
+// periodKey identifies the fiscal period of a fiscal year
+func periodKey(fiscalYear int, period string) string {
+	return fmt.Sprintf("%d-%s", fiscalYear, period)
+}
+
+// derivedItems are the line items summed over a period that can be derived
+// by subtraction. Balance sheet items are values at the period end, share
+// counts are averages and per-share figures are divided by those averages,
+// none of which add up over quarters.
+var derivedItems = func() []string {
+	var items []string
+	for _, def := range models.LineItems {
+		if def.Statement != models.StatementBalance && def.Unit != models.UnitShares && def.Unit != models.UnitCurrencyPerShare {
+			items = append(items, def.Name)
+		}
+	}
+	return items
+}()
+
+// deriveQuarters fills in the second to fourth quarter values of the derived
+// items that were not reported for the quarter itself, as the figure through
+// the quarter less the figure through the quarter before it: the second
+// quarter is the first half less the first quarter and the fourth the fiscal
+// year less the first nine months. Figures through a quarter are taken from
+// ytd, keyed like periods, or summed from the discrete quarters when not
+// reported year to date. Quarters are added when a derived value is all
+// there is for them.
+func deriveQuarters(periods, ytd map[string]*models.Financials, cik string) {
+	years := make(map[int]bool)
+	for _, p := range periods {
+		years[p.FiscalYear] = true
+	}
+
+	for year := range years {
+		// through returns the terms adding up to the figure of item from
+		// the start of the year through quarter n
+		var through func(n int, item string) []term
+		through = func(n int, item string) []term {
+			if n == 4 {
+				if fy, ok := periods[periodKey(year, models.FiscalPeriodFY)]; ok {
+					if v, ok := fy.Items[item]; ok {
+						return []term{{item: v, sign: 1}}
+					}
+				}
+				return nil
+			}
+			if n > 1 {
+				if f, ok := ytd[periodKey(year, models.FiscalPeriods[n-1])]; ok {
+					if v, ok := f.Items[item]; ok {
+						return []term{{item: v, sign: 1}}
+					}
+				}
+			}
+			q, ok := periods[periodKey(year, models.FiscalPeriods[n-1])]
+			if !ok {
+				return nil
+			}
+			v, ok := q.Items[item]
+			if !ok {
+				return nil
+			}
+			if n == 1 {
+				return []term{{item: v, sign: 1}}
+			}
+			if before := through(n-1, item); before != nil {
+				return append(before, term{item: v, sign: 1})
+			}
+			return nil
+		}
+
+		for n := 2; n <= 4; n++ {
+			period := models.FiscalPeriods[n-1]
+			for _, item := range derivedItems {
+				q, ok := periods[periodKey(year, period)]
+				if ok {
+					if _, reported := q.Items[item]; reported {
+						continue
+					}
+				}
+				total := through(n, item)
+				if len(total) != 1 {
+					// only figures reported through the quarter are split
+					continue
+				}
+				before := through(n-1, item)
+				if before == nil {
+					continue
+				}
+				terms := total
+				for _, t := range before {
+					terms = append(terms, term{item: t.item, sign: -t.sign})
+				}
+				derived, ok := deriveLineItem(terms)
+				if !ok {
+					continue
+				}
+
+				if q == nil {
+					q = &models.Financials{
+						CIK:          cik,
+						FiscalYear:   year,
+						FiscalPeriod: period,
+						PeriodEnd:    periodEnd(periods, ytd, year, n),
+						Currency:     factsCurrency,
+						Items:        make(map[string]models.LineItem),
+					}
+					periods[periodKey(year, period)] = q
+				}
+				if q.PeriodStart == nil {
+					if prior, ok := periods[periodKey(year, models.FiscalPeriods[n-2])]; ok {
+						start := prior.PeriodEnd.AddDate(0, 0, 1)
+						q.PeriodStart = &start
+					}
+				}
+				q.Items[item] = derived
+			}
+		}
+	}
+}
+
+// periodEnd returns the end of quarter n of a fiscal year from the year or
+// the year-to-date figures through the quarter
+func periodEnd(periods, ytd map[string]*models.Financials, year, n int) time.Time {
+	if n == 4 {
+		return periods[periodKey(year, models.FiscalPeriodFY)].PeriodEnd
+	}
+	return ytd[periodKey(year, models.FiscalPeriods[n-1])].PeriodEnd
+}
+
+// term is a line item entering a derived value with a sign
+type term struct {
+	item models.LineItem
+	sign float64
+}
+
+// derivedPrecision rounds derived values to drop the binary floating point
+// noise of subtracting decimal figures
+const derivedPrecision = 1e6
+
+// deriveLineItem returns the line item summing terms, with a version for
+// every filing that changed one of them once all of them were filed. Each
+// version is credited to the filing of the latest term, which is the filing
+// the derived value became known with. It reports false when the terms were
+// never all filed.
+func deriveLineItem(terms []term) (models.LineItem, bool) {
+	var dates []time.Time
+	for _, t := range terms {
+		for _, v := range t.item.Versions {
+			dates = append(dates, v.Filed)
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	var derived []models.LineItemVersion
+	for i, date := range dates {
+		if i > 0 && date.Equal(dates[i-1]) {
+			continue
+		}
+		var value float64
+		var latest *models.LineItemVersion
+		known := true
+		for ti, t := range terms {
+			v := versionAt(t.item, date)
+			if v == nil {
+				known = false
+				break
+			}
+			value += t.sign * v.Value
+			if ti == 0 || v.Filed.After(latest.Filed) {
+				latest = v
+			}
+		}
+		if !known {
+			continue
+		}
+		value = math.Round(value*derivedPrecision) / derivedPrecision
+		if n := len(derived); n > 0 && derived[n-1].Value == value {
+			continue
+		}
+		derived = append(derived, models.LineItemVersion{
+			Value:     value,
+			Concept:   terms[0].item.Concept,
+			Form:      latest.Form,
+			Accession: latest.Accession,
+			Filed:     latest.Filed,
+			Derived:   true,
+		})
+	}
+	if len(derived) == 0 {
+		return models.LineItem{}, false
+	}
+	return models.NewLineItem(derived), true
+}
+
+// versionAt returns the latest version of item filed by date, or nil
+func versionAt(item models.LineItem, date time.Time) *models.LineItemVersion {
+	var at *models.LineItemVersion
+	for i := range item.Versions {
+		if item.Versions[i].Filed.After(date) {
+			break
+		}
+		at = &item.Versions[i]
+	}
+	return at
+}
+
+// versions returns the values filings reported for a line item of a period,
+// oldest first, each with the filing that first reported it. A filing
+// reporting the item under several concepts counts with the preferred one.
+func versions(facts []*fact) []models.LineItemVersion {
+	filings := make(map[string]*fact)
+	for _, f := range facts {
+		if current, ok := filings[f.accn]; !ok || f.priority < current.priority {
+			filings[f.accn] = f
+		}
+	}
+	ordered := make([]*fact, 0, len(filings))
+	for _, f := range filings {
+		ordered = append(ordered, f)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		if !ordered[i].filed.Equal(ordered[j].filed) {
+			return ordered[i].filed.Before(ordered[j].filed)
+		}
+		return ordered[i].accn < ordered[j].accn
+	})
+
+	var versions []models.LineItemVersion
+	for _, f := range ordered {
+		if n := len(versions); n > 0 && versions[n-1].Value == f.value {
+			continue
+		}
+		versions = append(versions, models.LineItemVersion{
+			Value:     f.value,
+			Concept:   f.concept,
+			Form:      f.form,
+			Accession: f.accn,
+			Filed:     f.filed,
+		})
+	}
+	return versions
+}
+
+// readFacts collects the 10-Q and 10-K facts of the mapped us-gaap concepts
+func readFacts(doc companyFacts) ([]*fact, error) {
+	var facts []*fact
+	for _, def := range models.LineItems {
+		for priority, concept := range def.Concepts {
+			for _, x := range doc.Facts.USGAAP[concept].Units[factsUnits[def.Unit]] {
+				if !strings.HasPrefix(x.Form, "10-Q") && !strings.HasPrefix(x.Form, "10-K") {
+					continue
+				}
+				f := &fact{
+					item:     def.Name,
+					concept:  concept,
+					priority: priority,
+					value:    x.Val,
+					form:     x.Form,
+					accn:     x.Accn,
+					fy:       x.FY,
+					fp:       x.FP,
+				}
+				var err error
+				if f.end, err = time.Parse(time.DateOnly, x.End); err != nil {
+					return nil, fmt.Errorf("%s: invalid end date: %w", concept, err)
+				}
+				if f.filed, err = time.Parse(time.DateOnly, x.Filed); err != nil {
+					return nil, fmt.Errorf("%s: invalid filed date: %w", concept, err)
+				}
+				if x.Start != "" {
+					start, err := time.Parse(time.DateOnly, x.Start)
+					if err != nil {
+						return nil, fmt.Errorf("%s: invalid start date: %w", concept, err)
+					}
+					f.start = &start
+				}
+				facts = append(facts, f)
+			}
+		}
+	}
+	return facts, nil
+}
+
+// fiscalQuarters maps the end date of the period each filing covers to its
+// fiscal year and quarter. A filing covers the latest period it reports a
+// quarter or year for; 10-K filings cover the fourth quarter. When filings
+// disagree, as amendments may, the latest filing wins.
+func fiscalQuarters(facts []*fact) map[time.Time]fiscalQuarter {
+	covered := make(map[string]*fact)
+	for _, f := range facts {
+		if f.duration() == "" {
+			continue
+		}
+		if current, ok := covered[f.accn]; !ok || f.end.After(current.end) {
+			covered[f.accn] = f
+		}
+	}
+
+	quarters := make(map[time.Time]fiscalQuarter)
+	for _, f := range covered {
+		quarter := f.fp
+		if quarter == models.FiscalPeriodFY {
+			quarter = models.FiscalPeriodQ4
+		}
+		if f.fy > 0 && models.IsValidFiscalPeriod(quarter) {
+			if current, ok := quarters[f.end]; !ok || f.filed.After(current.filed) {
+				quarters[f.end] = fiscalQuarter{year: f.fy, quarter: quarter, filed: f.filed}
+			}
+		}
+	}
+	return quarters
+}
+
+// LoadCompanyFacts parses a companyfacts document, or every CIK*.json document
+// in a directory such as the extracted companyfacts.zip bulk download
+func LoadCompanyFacts(path string) ([]models.Financials, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{path}
+	if info.IsDir() {
+		if paths, err = filepath.Glob(filepath.Join(path, "CIK*.json")); err != nil {
+			return nil, err
+		}
+	}
+
+	var financials []models.Financials
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := ParseCompanyFacts(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		financials = append(financials, parsed...)
+	}
+	return financials, nil
+}
+
+// StatementUpserter is the part of mongo.StatementsRepository used by the
+// importer
+type StatementUpserter interface {
+	Upsert(ctx context.Context, financials models.Financials, source string) (mongo.UpsertResult, error)
+}
+
+// SourceCompanyFacts is the source revisions written by the statement
+// importer are recorded under
+const SourceCompanyFacts = "sec-companyfacts"
+
+// StatementImporter upserts parsed financials
+type StatementImporter struct {
+	store StatementUpserter
+}
+
+// NewStatementImporter returns a StatementImporter writing to store
+func NewStatementImporter(store StatementUpserter) *StatementImporter {
+	return &StatementImporter{store: store}
+}
+
+// Import upserts the financials of each period. Invalid periods are skipped
+// and logged; a repository failure stops the import.
+func (im *StatementImporter) Import(ctx context.Context, financials []models.Financials) (Summary, error) {
+	var summary Summary
+	for _, f := range financials {
+		if err := f.Validate(); err != nil {
+			log.Printf("import: skipping %s %d %s: %v", f.CIK, f.FiscalYear, f.FiscalPeriod, err)
+			summary.Skipped++
+			continue
+		}
+
+		result, err := im.store.Upsert(ctx, f, SourceCompanyFacts)
+		if err != nil {
+			return summary, err
+		}
+		switch result {
+		case mongo.UpsertInserted:
+			summary.Inserted++
+		case mongo.UpsertUpdated:
+			summary.Updated++
+		default:
+			summary.Unchanged++
+		}
+	}
+	return summary, nil
+}
+
+// CompanyLookup is the part of mongo.Repository used to find the listings of
+// the companies financials are filed by
+type CompanyLookup interface {
+	Lookup(ctx context.Context, symbols, ciks []string) ([]models.Company, error)
+}
+
+// FactsEarnings returns the quarterly earnings in financials for every
+// listing of their CIK, as share classes of one registrant report the same
+// figures. Each quarter is returned once per filing that changed its EPS,
+// revenue or net income, oldest first, published on the filing date, so
+// upserting them in order records restatements as new versions. The report
+// date is the date the quarter was first filed. Financials of companies
+// without a listing are left out.
+func FactsEarnings(ctx context.Context, companies CompanyLookup, financials []models.Financials) ([]models.Earnings, error) {
+	seen := make(map[string]bool)
+	var ciks []string
+	for _, f := range financials {
+		if !seen[f.CIK] {
+			seen[f.CIK] = true
+			ciks = append(ciks, f.CIK)
+		}
+	}
+	listed, err := companies.Lookup(ctx, nil, ciks)
+	if err != nil {
+		return nil, err
+	}
+	symbols := make(map[string][]string)
+	for _, c := range listed {
+		symbols[c.CIK] = append(symbols[c.CIK], c.Symbol)
+	}
+
+	var earnings []models.Earnings
+	for _, f := range financials {
+		for _, symbol := range symbols[f.CIK] {
+			earnings = append(earnings, publications(f, symbol)...)
+		}
+	}
+	return earnings, nil
+}
+
+// earningsItems are the line items carried by earnings
+var earningsItems = []string{models.LineItemEPSBasic, models.LineItemEPSDiluted, models.LineItemRevenue, models.LineItemNetIncome}
+
+// publications returns the earnings of a fiscal quarter as published by each
+// filing that changed them, or nothing for fiscal years and quarters without
+// any of the earnings items
+func publications(f models.Financials, symbol string) []models.Earnings {
+	quarter := 0
+	for i, period := range models.FiscalPeriods[:4] {
+		if f.FiscalPeriod == period {
+			quarter = i + 1
+		}
+	}
+	if quarter == 0 {
+		return nil
+	}
+
+	var dates []time.Time
+	for _, name := range earningsItems {
+		for _, v := range f.Items[name].Versions {
+			dates = append(dates, v.Filed)
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	var earnings []models.Earnings
+	for i, date := range dates {
+		if i > 0 && date.Equal(dates[i-1]) {
+			continue
+		}
+		asOf, _ := f.AsOf(date)
+		reportDate, published := f.Filed, date
+		e := models.Earnings{
+			Symbol:        symbol,
+			FiscalYear:    f.FiscalYear,
+			FiscalQuarter: quarter,
+			PeriodEnd:     f.PeriodEnd,
+			ReportDate:    &reportDate,
+			ReportTime:    models.ReportTimeNotAnnounced,
+			EPSBasic:      asOf.Value(models.LineItemEPSBasic),
+			EPSDiluted:    asOf.Value(models.LineItemEPSDiluted),
+			Revenue:       asOf.Value(models.LineItemRevenue),
+			NetIncome:     asOf.Value(models.LineItemNetIncome),
+			Currency:      f.Currency,
+			PublishedAt:   &published,
+		}
+		earnings = append(earnings, e)
+	}
+	return earnings
+}
@@ -0,0 +1,297 @@
+package importer
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockStatementUpserter struct {
+	mock.Mock
+}
+
+func (m *MockStatementUpserter) Upsert(ctx context.Context, financials models.Financials, source string) (mongo.UpsertResult, error) {
+	args := m.Called(ctx, financials, source)
+	return args.Get(0).(mongo.UpsertResult), args.Error(1)
+}
+
+type MockCompanyLookup struct {
+	mock.Mock
+}
+
+func (m *MockCompanyLookup) Lookup(ctx context.Context, symbols, ciks []string) ([]models.Company, error) {
+	args := m.Called(ctx, symbols, ciks)
+	return args.Get(0).([]models.Company), args.Error(1)
+}
+
+func date(s string) time.Time {
+	d, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func datePtr(s string) *time.Time {
+	d := date(s)
+	return &d
+}
+
+// Filings in testdata/CIK0000320193.json
+var (
+	q1FY2023 = func(value float64, concept string) models.LineItemVersion {
+		return models.LineItemVersion{Value: value, Concept: concept, Form: "10-Q", Accession: "0000320193-23-000006", Filed: date("2023-02-03")}
+	}
+	tenKFY2023 = func(value float64, concept string) models.LineItemVersion {
+		return models.LineItemVersion{Value: value, Concept: concept, Form: "10-K", Accession: "0000320193-23-000106", Filed: date("2023-11-03")}
+	}
+	q1FY2024 = func(value float64, concept string) models.LineItemVersion {
+		return models.LineItemVersion{Value: value, Concept: concept, Form: "10-Q", Accession: "0000320193-24-000006", Filed: date("2024-02-02")}
+	}
+	q2FY2024 = func(value float64, concept string) models.LineItemVersion {
+		return models.LineItemVersion{Value: value, Concept: concept, Form: "10-Q", Accession: "0000320193-24-000069", Filed: date("2024-05-03")}
+	}
+)
+
+// derived marks a version as derived from year-to-date figures
+func derived(v models.LineItemVersion) models.LineItemVersion {
+	v.Derived = true
+	return v
+}
+
+// item returns a line item reported in versions
+func item(versions ...models.LineItemVersion) models.LineItem {
+	return models.NewLineItem(versions)
+}
+
+const revenueConcept = "RevenueFromContractWithCustomerExcludingAssessedTax"
+
+func TestLoadCompanyFacts(t *testing.T) {
+	financials, err := LoadCompanyFacts("testdata/CIK0000320193.json")
+	require.NoError(t, err)
+
+	apple := func(fiscalYear int, period, start, end, filed string, items map[string]models.LineItem) models.Financials {
+		return models.Financials{
+			CIK: "0000320193", FiscalYear: fiscalYear, FiscalPeriod: period,
+			PeriodStart: datePtr(start), PeriodEnd: date(end), Currency: "USD", Filed: date(filed), Items: items,
+		}
+	}
+	assert.Equal(t, []models.Financials{
+		// The 14-week first quarter of the 53-week fiscal 2023, restated in
+		// the comparatives of the fiscal 2024 first quarter filing
+		apple(2023, "Q1", "2022-09-25", "2022-12-31", "2023-02-03", map[string]models.LineItem{
+			models.LineItemRevenue:   item(q1FY2023(117154000000, revenueConcept)),
+			models.LineItemNetIncome: item(q1FY2023(29998000000, "NetIncomeLoss"), q1FY2024(29961000000, "NetIncomeLoss")),
+		}),
+		apple(2023, "Q4", "2023-07-02", "2023-09-30", "2023-11-03", map[string]models.LineItem{
+			models.LineItemRevenue: item(tenKFY2023(89498000000, revenueConcept)),
+			models.LineItemAssets:  item(tenKFY2023(352583000000, "Assets")),
+		}),
+		apple(2023, "FY", "2022-09-25", "2023-09-30", "2023-11-03", map[string]models.LineItem{
+			models.LineItemRevenue:    item(tenKFY2023(383285000000, revenueConcept)),
+			models.LineItemNetIncome:  item(tenKFY2023(96995000000, "NetIncomeLoss")),
+			models.LineItemEPSDiluted: item(tenKFY2023(6.13, "EarningsPerShareDiluted")),
+			models.LineItemAssets:     item(tenKFY2023(352583000000, "Assets")),
+		}),
+		apple(2024, "Q1", "2023-10-01", "2023-12-30", "2024-02-02", map[string]models.LineItem{
+			models.LineItemRevenue:           item(q1FY2024(119575000000, "Revenues")),
+			models.LineItemNetIncome:         item(q1FY2024(33916000000, "NetIncomeLoss")),
+			models.LineItemEPSDiluted:        item(q1FY2024(2.18, "EarningsPerShareDiluted")),
+			models.LineItemAssets:            item(q1FY2024(353514000000, "Assets")),
+			models.LineItemOperatingCashFlow: item(q1FY2024(39895000000, "NetCashProvidedByUsedInOperatingActivities")),
+		}),
+		// Operating cash flow is only reported for the first half
+		apple(2024, "Q2", "2023-12-31", "2024-03-30", "2024-05-03", map[string]models.LineItem{
+			models.LineItemRevenue:           item(q2FY2024(90753000000, revenueConcept)),
+			models.LineItemOperatingCashFlow: item(derived(q2FY2024(22690000000, "NetCashProvidedByUsedInOperatingActivities"))),
+		}),
+	}, financials)
+
+	t.Run("As of", func(t *testing.T) {
+		asOf, ok := financials[0].AsOf(date("2024-02-01"))
+		require.True(t, ok)
+		assert.Equal(t, 29998000000.0, *asOf.Value(models.LineItemNetIncome), "before the restatement was filed")
+
+		_, ok = financials[4].AsOf(date("2024-05-02"))
+		assert.False(t, ok, "not filed yet")
+	})
+}
+
+func TestParseCompanyFacts_DerivesQuarters(t *testing.T) {
+	// A fiscal year reported in a 10-Q per quarter with year-to-date cash
+	// flows and a 10-K without the fourth quarter, then the first quarter
+	// restated in the following year
+	const doc = `{"cik":1,"facts":{"us-gaap":{
+		"NetIncomeLoss":{"units":{"USD":[
+			{"start":"2023-01-01","end":"2023-03-31","val":10,"accn":"q1","fy":2023,"fp":"Q1","form":"10-Q","filed":"2023-05-01"},
+			{"start":"2023-04-01","end":"2023-06-30","val":20,"accn":"q2","fy":2023,"fp":"Q2","form":"10-Q","filed":"2023-08-01"},
+			{"start":"2023-07-01","end":"2023-09-30","val":30,"accn":"q3","fy":2023,"fp":"Q3","form":"10-Q","filed":"2023-11-01"},
+			{"start":"2023-01-01","end":"2023-12-31","val":100,"accn":"k","fy":2023,"fp":"FY","form":"10-K","filed":"2024-02-01"},
+			{"start":"2023-01-01","end":"2023-03-31","val":12,"accn":"q1-24","fy":2024,"fp":"Q1","form":"10-Q","filed":"2024-05-01"},
+			{"start":"2024-01-01","end":"2024-03-31","val":15,"accn":"q1-24","fy":2024,"fp":"Q1","form":"10-Q","filed":"2024-05-01"}
+		]}},
+		"EarningsPerShareDiluted":{"units":{"USD/shares":[
+			{"start":"2023-01-01","end":"2023-03-31","val":0.1,"accn":"q1","fy":2023,"fp":"Q1","form":"10-Q","filed":"2023-05-01"},
+			{"start":"2023-01-01","end":"2023-09-30","val":0.6,"accn":"q3","fy":2023,"fp":"Q3","form":"10-Q","filed":"2023-11-01"},
+			{"start":"2023-01-01","end":"2023-12-31","val":1.01,"accn":"k","fy":2023,"fp":"FY","form":"10-K","filed":"2024-02-01"}
+		]}},
+		"NetCashProvidedByUsedInOperatingActivities":{"units":{"USD":[
+			{"start":"2023-01-01","end":"2023-03-31","val":5,"accn":"q1","fy":2023,"fp":"Q1","form":"10-Q","filed":"2023-05-01"},
+			{"start":"2023-01-01","end":"2023-06-30","val":15,"accn":"q2","fy":2023,"fp":"Q2","form":"10-Q","filed":"2023-08-01"},
+			{"start":"2023-01-01","end":"2023-09-30","val":40,"accn":"q3","fy":2023,"fp":"Q3","form":"10-Q","filed":"2023-11-01"},
+			{"start":"2023-01-01","end":"2023-12-31","val":70,"accn":"k","fy":2023,"fp":"FY","form":"10-K","filed":"2024-02-01"}
+		]}},
+		"WeightedAverageNumberOfDilutedSharesOutstanding":{"units":{"shares":[
+			{"start":"2023-01-01","end":"2023-12-31","val":100,"accn":"k","fy":2023,"fp":"FY","form":"10-K","filed":"2024-02-01"}
+		]}}
+	}}}`
+	financials, err := ParseCompanyFacts(strings.NewReader(doc))
+	require.NoError(t, err)
+	byPeriod := make(map[string]models.Financials)
+	for _, f := range financials {
+		if f.FiscalYear == 2023 {
+			byPeriod[f.FiscalPeriod] = f
+		}
+	}
+
+	value := func(period, item string) float64 {
+		f := byPeriod[period]
+		require.NotNil(t, f.Value(item), "%s %s", period, item)
+		return *f.Value(item)
+	}
+	assert.Equal(t, 10.0, value("Q2", models.LineItemOperatingCashFlow), "the first half less the first quarter")
+	assert.Equal(t, 25.0, value("Q3", models.LineItemOperatingCashFlow), "nine months less the first half")
+	assert.Equal(t, 30.0, value("Q4", models.LineItemOperatingCashFlow), "the year less nine months")
+	assert.Equal(t, 38.0, value("Q4", models.LineItemNetIncome), "the year less the quarters, using the restated first quarter")
+	q3, q4 := byPeriod["Q3"], byPeriod["Q4"]
+	assert.Nil(t, q4.Value(models.LineItemSharesDiluted), "share counts do not add up")
+	assert.Nil(t, q4.Value(models.LineItemEPSDiluted), "EPS does not add up when the share count changes")
+	assert.Nil(t, q3.Value(models.LineItemEPSDiluted), "nor is it derived from nine months")
+	assert.False(t, byPeriod["Q3"].Items[models.LineItemNetIncome].Derived, "reported quarters are kept")
+
+	assert.Equal(t, datePtr("2023-10-01"), q4.PeriodStart)
+	assert.Equal(t, date("2023-12-31"), q4.PeriodEnd)
+	assert.Equal(t, date("2024-02-01"), q4.Filed)
+
+	netIncome := q4.Items[models.LineItemNetIncome]
+	assert.True(t, netIncome.Derived)
+	assert.Equal(t, []models.LineItemVersion{
+		{Value: 40, Concept: "NetIncomeLoss", Form: "10-K", Accession: "k", Filed: date("2024-02-01"), Derived: true},
+		{Value: 38, Concept: "NetIncomeLoss", Form: "10-Q", Accession: "q1-24", Filed: date("2024-05-01"), Derived: true},
+	}, netIncome.Versions, "restating an earlier quarter restates the derived one")
+
+	asOf, ok := q4.AsOf(date("2024-03-01"))
+	require.True(t, ok)
+	assert.Equal(t, 40.0, *asOf.Value(models.LineItemNetIncome))
+}
+
+func TestLoadCompanyFacts_Directory(t *testing.T) {
+	financials, err := LoadCompanyFacts("testdata")
+	require.NoError(t, err)
+	assert.Len(t, financials, 5, "only companyfacts documents are read from a directory")
+
+	_, err = LoadCompanyFacts("testdata/missing.json")
+	assert.Error(t, err)
+}
+
+func TestParseCompanyFacts_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		err  string
+	}{
+		{"Not JSON", `<html>`, "invalid companyfacts file: invalid character '<' looking for beginning of value"},
+		{"Missing CIK", `{"entityName":"Apple Inc.","facts":{}}`, "invalid companyfacts file: missing cik"},
+		{"Bad date", `{"cik":320193,"facts":{"us-gaap":{"Assets":{"units":{"USD":[{"end":"09/30/2023","val":1,"form":"10-K","filed":"2023-11-03"}]}}}}}`, "Assets: invalid end date"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseCompanyFacts(strings.NewReader(tt.doc))
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestStatementImporter_Import(t *testing.T) {
+	financials, err := LoadCompanyFacts("testdata/CIK0000320193.json")
+	require.NoError(t, err)
+	invalid := financials[0]
+	invalid.FiscalPeriod = "H1"
+	financials = append(financials, invalid)
+
+	store := new(MockStatementUpserter)
+	store.On("Upsert", mock.Anything, financials[0], SourceCompanyFacts).Return(mongo.UpsertUpdated, nil)
+	store.On("Upsert", mock.Anything, financials[1], SourceCompanyFacts).Return(mongo.UpsertUnchanged, nil)
+	store.On("Upsert", mock.Anything, mock.Anything, SourceCompanyFacts).Return(mongo.UpsertInserted, nil)
+
+	summary, err := NewStatementImporter(store).Import(context.Background(), financials)
+	require.NoError(t, err)
+	assert.Equal(t, Summary{Inserted: 3, Updated: 1, Unchanged: 1, Skipped: 1}, summary)
+	store.AssertNumberOfCalls(t, "Upsert", 5)
+
+	failing := new(MockStatementUpserter)
+	failing.On("Upsert", mock.Anything, mock.Anything, mock.Anything).Return(mongo.UpsertUnchanged, dberrors.NewDBError("timeout"))
+	_, err = NewStatementImporter(failing).Import(context.Background(), financials)
+	assert.True(t, dberrors.IsDBError(err))
+	failing.AssertNumberOfCalls(t, "Upsert", 1)
+}
+
+func TestFactsEarnings(t *testing.T) {
+	financials, err := LoadCompanyFacts("testdata/CIK0000320193.json")
+	require.NoError(t, err)
+
+	companies := new(MockCompanyLookup)
+	companies.On("Lookup", mock.Anything, []string(nil), []string{"0000320193"}).
+		Return([]models.Company{{Symbol: "AAPL", CIK: "0000320193"}}, nil)
+	earnings, err := FactsEarnings(context.Background(), companies, financials)
+	require.NoError(t, err)
+
+	num := func(f float64) *float64 { return &f }
+	quarter := func(fiscalYear, fiscalQuarter int, end, reported, published string, revenue, netIncome, eps *float64) models.Earnings {
+		return models.Earnings{
+			Symbol: "AAPL", FiscalYear: fiscalYear, FiscalQuarter: fiscalQuarter, PeriodEnd: date(end),
+			ReportDate: datePtr(reported), ReportTime: models.ReportTimeNotAnnounced, Currency: "USD",
+			Revenue: revenue, NetIncome: netIncome, EPSDiluted: eps, PublishedAt: datePtr(published),
+		}
+	}
+	assert.Equal(t, []models.Earnings{
+		quarter(2023, 1, "2022-12-31", "2023-02-03", "2023-02-03", num(117154000000), num(29998000000), nil),
+		quarter(2023, 1, "2022-12-31", "2023-02-03", "2024-02-02", num(117154000000), num(29961000000), nil),
+		quarter(2023, 4, "2023-09-30", "2023-11-03", "2023-11-03", num(89498000000), nil, nil),
+		quarter(2024, 1, "2023-12-30", "2024-02-02", "2024-02-02", num(119575000000), num(33916000000), num(2.18)),
+		quarter(2024, 2, "2024-03-30", "2024-05-03", "2024-05-03", num(90753000000), nil, nil),
+	}, earnings, "a publication per filing, without the fiscal year")
+
+	store := new(MockEarningsUpserter)
+	store.On("Upsert", mock.Anything, mock.Anything, SourceCompanyFacts).Return(mongo.UpsertInserted, nil)
+	summary, err := NewEarningsImporter(store, SourceCompanyFacts).Import(context.Background(), earnings)
+	require.NoError(t, err)
+	assert.Equal(t, Summary{Inserted: 5}, summary)
+	for _, e := range earnings {
+		store.AssertCalled(t, "Upsert", mock.Anything, e, SourceCompanyFacts)
+	}
+
+	t.Run("Unlisted", func(t *testing.T) {
+		companies := new(MockCompanyLookup)
+		companies.On("Lookup", mock.Anything, mock.Anything, mock.Anything).Return([]models.Company{}, nil)
+		earnings, err := FactsEarnings(context.Background(), companies, financials)
+		require.NoError(t, err)
+		assert.Empty(t, earnings)
+	})
+
+	t.Run("Lookup error", func(t *testing.T) {
+		companies := new(MockCompanyLookup)
+		companies.On("Lookup", mock.Anything, mock.Anything, mock.Anything).Return([]models.Company(nil), dberrors.NewDBError("timeout"))
+		_, err := FactsEarnings(context.Background(), companies, financials)
+		assert.True(t, dberrors.IsDBError(err))
+	})
+}
@@ -0,0 +1,261 @@
+package importer
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/models"
+)
+
+// ParseDividends parses a dividend CSV file with a header row naming the
+// columns symbol, ex_date and amount, and optionally record_date, pay_date,
+// currency and type. Dates are formatted as YYYY-MM-DD; the currency defaults
+// to USD and the type to regular.
+func ParseDividends(r io.Reader) ([]models.Dividend, error) {
+	var dividends []models.Dividend
+	err := readCSV(r, []string{"symbol", "ex_date", "amount"}, func(row csvRow) error {
+		d := models.Dividend{
+			Symbol:   strings.ToUpper(row.get("symbol")),
+			Currency: strings.ToUpper(row.get("currency")),
+			Type:     strings.ToLower(row.get("type")),
+		}
+		if d.Currency == "" {
+			d.Currency = models.DefaultCurrency
+		}
+		if d.Type == "" {
+			d.Type = models.DividendTypeRegular
+		}
+
+		var err error
+		if d.ExDate, err = row.date("ex_date"); err != nil {
+			return err
+		}
+		if d.RecordDate, err = row.optionalDate("record_date"); err != nil {
+			return err
+		}
+		if d.PayDate, err = row.optionalDate("pay_date"); err != nil {
+			return err
+		}
+		if d.Amount, err = strconv.ParseFloat(row.get("amount"), 64); err != nil {
+			return fmt.Errorf("invalid amount %q", row.get("amount"))
+		}
+		dividends = append(dividends, d)
+		return nil
+	})
+	return dividends, err
+}
+
+// ParseSplits parses a split CSV file with a header row naming the columns
+// symbol, date and ratio. Dates are formatted as YYYY-MM-DD and ratios as
+// 4:1, 4/1 or 4-for-1.
+func ParseSplits(r io.Reader) ([]models.Split, error) {
+	var splits []models.Split
+	err := readCSV(r, []string{"symbol", "date", "ratio"}, func(row csvRow) error {
+		s := models.Split{Symbol: strings.ToUpper(row.get("symbol"))}
+		var err error
+		if s.Date, err = row.date("date"); err != nil {
+			return err
+		}
+		if s.Numerator, s.Denominator, err = models.ParseSplitRatio(row.get("ratio")); err != nil {
+			return err
+		}
+		splits = append(splits, s)
+		return nil
+	})
+	return splits, err
+}
+
+// csvRow is a record of a CSV file by column name
+type csvRow struct {
+	columns map[string]int
+	fields  []string
+}
+
+// get returns the trimmed value of a column, empty if the file lacks it
+func (r csvRow) get(column string) string {
+	if i, ok := r.columns[column]; ok {
+		return strings.TrimSpace(r.fields[i])
+	}
+	return ""
+}
+
+func (r csvRow) date(column string) (time.Time, error) {
+	t, err := time.Parse(time.DateOnly, r.get(column))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q", column, r.get(column))
+	}
+	return t, nil
+}
+
+func (r csvRow) float(column string) (float64, error) {
+	f, err := strconv.ParseFloat(r.get(column), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", column, r.get(column))
+	}
+	return f, nil
+}
+
+func (r csvRow) int(column string) (int, error) {
+	i, err := strconv.Atoi(r.get(column))
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", column, r.get(column))
+	}
+	return i, nil
+}
+
+func (r csvRow) optionalDate(column string) (*time.Time, error) {
+	if r.get(column) == "" {
+		return nil, nil
+	}
+	t, err := r.date(column)
+	return &t, err
+}
+
+// readCSV reads a CSV file, checking the header has the required columns and
+// passing each record to row. Errors are reported with their line number.
+func readCSV(r io.Reader, required []string, row func(csvRow) error) error {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid csv file: missing header")
+	}
+	if err != nil {
+		return err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range required {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("invalid csv file: missing column %q", name)
+		}
+	}
+
+	for {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := row(csvRow{columns: columns, fields: fields}); err != nil {
+			line, _ := reader.FieldPos(0)
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+}
+
+// loadFile opens the file at path and passes it to parse, prefixing errors
+// with the path
+func loadFile(path string, parse func(io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := parse(f); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// LoadDividends reads and parses a dividend CSV file
+func LoadDividends(path string) ([]models.Dividend, error) {
+	var dividends []models.Dividend
+	err := loadFile(path, func(r io.Reader) (err error) {
+		dividends, err = ParseDividends(r)
+		return err
+	})
+	return dividends, err
+}
+
+// LoadSplits reads and parses a split CSV file
+func LoadSplits(path string) ([]models.Split, error) {
+	var splits []models.Split
+	err := loadFile(path, func(r io.Reader) (err error) {
+		splits, err = ParseSplits(r)
+		return err
+	})
+	return splits, err
+}
+
+// CorporateActionUpserter is the part of mongo.CorporateActionsRepository
+// used by the importer
+type CorporateActionUpserter interface {
+	UpsertDividend(ctx context.Context, dividend models.Dividend) (mongo.UpsertResult, error)
+	UpsertSplit(ctx context.Context, split models.Split) (mongo.UpsertResult, error)
+}
+
+// CorporateActionImporter upserts parsed dividends and splits
+type CorporateActionImporter struct {
+	store CorporateActionUpserter
+}
+
+// NewCorporateActionImporter returns a CorporateActionImporter writing to
+// store
+func NewCorporateActionImporter(store CorporateActionUpserter) *CorporateActionImporter {
+	return &CorporateActionImporter{store: store}
+}
+
+// ImportDividends upserts the dividends. Invalid dividends are skipped and
+// logged; a repository failure stops the import.
+func (im *CorporateActionImporter) ImportDividends(ctx context.Context, dividends []models.Dividend) (Summary, error) {
+	var summary Summary
+	for _, d := range dividends {
+		if err := d.Validate(); err != nil {
+			log.Printf("import: skipping %s dividend %s: %v", d.Symbol, d.ExDate.Format(time.DateOnly), err)
+			summary.Skipped++
+			continue
+		}
+		result, err := im.store.UpsertDividend(ctx, d)
+		if err != nil {
+			return summary, err
+		}
+		summary.add(result)
+	}
+	return summary, nil
+}
+
+// ImportSplits upserts the splits. Invalid splits are skipped and logged; a
+// repository failure stops the import.
+func (im *CorporateActionImporter) ImportSplits(ctx context.Context, splits []models.Split) (Summary, error) {
+	var summary Summary
+	for _, s := range splits {
+		if err := s.Validate(); err != nil {
+			log.Printf("import: skipping %s split %s: %v", s.Symbol, s.Date.Format(time.DateOnly), err)
+			summary.Skipped++
+			continue
+		}
+		result, err := im.store.UpsertSplit(ctx, s)
+		if err != nil {
+			return summary, err
+		}
+		summary.add(result)
+	}
+	return summary, nil
+}
+
+// add counts the result of an upsert
+func (s *Summary) add(result mongo.UpsertResult) {
+	switch result {
+	case mongo.UpsertInserted:
+		s.Inserted++
+	case mongo.UpsertUpdated:
+		s.Updated++
+	default:
+		s.Unchanged++
+	}
+}
@@ -0,0 +1,114 @@
+package importer
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockCorporateActionUpserter struct {
+	mock.Mock
+}
+
+func (m *MockCorporateActionUpserter) UpsertDividend(ctx context.Context, dividend models.Dividend) (mongo.UpsertResult, error) {
+	args := m.Called(ctx, dividend)
+	return args.Get(0).(mongo.UpsertResult), args.Error(1)
+}
+
+func (m *MockCorporateActionUpserter) UpsertSplit(ctx context.Context, split models.Split) (mongo.UpsertResult, error) {
+	args := m.Called(ctx, split)
+	return args.Get(0).(mongo.UpsertResult), args.Error(1)
+}
+
+func TestLoadDividends(t *testing.T) {
+	dividends, err := LoadDividends("testdata/dividends.csv")
+	require.NoError(t, err)
+	assert.Equal(t, []models.Dividend{
+		{Symbol: "AAPL", ExDate: date("2024-08-12"), RecordDate: datePtr("2024-08-12"), PayDate: datePtr("2024-08-15"), Amount: 0.25, Currency: "USD", Type: models.DividendTypeRegular},
+		{Symbol: "AAPL", ExDate: date("2024-05-10"), RecordDate: datePtr("2024-05-13"), PayDate: datePtr("2024-05-16"), Amount: 0.25, Currency: "USD", Type: models.DividendTypeRegular},
+		{Symbol: "COST", ExDate: date("2023-12-27"), RecordDate: datePtr("2023-12-28"), PayDate: datePtr("2024-01-12"), Amount: 15, Currency: "USD", Type: models.DividendTypeSpecial},
+	}, dividends)
+}
+
+func TestLoadSplits(t *testing.T) {
+	splits, err := LoadSplits("testdata/splits.csv")
+	require.NoError(t, err)
+	assert.Equal(t, []models.Split{
+		{Symbol: "AAPL", Date: date("2014-06-09"), Numerator: 7, Denominator: 1},
+		{Symbol: "AAPL", Date: date("2020-08-31"), Numerator: 4, Denominator: 1},
+		{Symbol: "GE", Date: date("2021-08-02"), Numerator: 1, Denominator: 8},
+	}, splits)
+
+	_, err = LoadSplits("testdata/missing.csv")
+	assert.Error(t, err)
+}
+
+func TestParseCorporateActions_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(string) error
+		doc   string
+		err   string
+	}{
+		{"Empty", parseDividends, ``, "invalid csv file: missing header"},
+		{"Missing column", parseDividends, "symbol,ex_date\nAAPL,2024-08-12\n", `invalid csv file: missing column "amount"`},
+		{"Bad date", parseDividends, "symbol,ex_date,amount\nAAPL,08/12/2024,0.25\n", `line 2: invalid ex_date "08/12/2024"`},
+		{"Bad amount", parseDividends, "symbol,ex_date,amount\nAAPL,2024-08-12,0.25\nAAPL,2024-05-10,$0.25\n", `line 3: invalid amount "$0.25"`},
+		{"Bad ratio", parseSplits, "symbol,date,ratio\nAAPL,2020-08-31,four\n", `line 2: invalid split ratio "four"`},
+		{"Short record", parseSplits, "symbol,date,ratio\nAAPL,2020-08-31\n", "record on line 2: wrong number of fields"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.EqualError(t, tt.parse(tt.doc), tt.err)
+		})
+	}
+}
+
+func parseDividends(doc string) error {
+	_, err := ParseDividends(strings.NewReader(doc))
+	return err
+}
+
+func parseSplits(doc string) error {
+	_, err := ParseSplits(strings.NewReader(doc))
+	return err
+}
+
+func TestCorporateActionImporter(t *testing.T) {
+	dividends, err := LoadDividends("testdata/dividends.csv")
+	require.NoError(t, err)
+	invalid := dividends[0]
+	invalid.Amount = -1
+	dividends = append(dividends, invalid)
+	splits, err := LoadSplits("testdata/splits.csv")
+	require.NoError(t, err)
+
+	store := new(MockCorporateActionUpserter)
+	store.On("UpsertDividend", mock.Anything, dividends[0]).Return(mongo.UpsertUnchanged, nil)
+	store.On("UpsertDividend", mock.Anything, mock.Anything).Return(mongo.UpsertInserted, nil)
+	store.On("UpsertSplit", mock.Anything, splits[2]).Return(mongo.UpsertUpdated, nil)
+	store.On("UpsertSplit", mock.Anything, mock.Anything).Return(mongo.UpsertInserted, nil)
+	im := NewCorporateActionImporter(store)
+
+	summary, err := im.ImportDividends(context.Background(), dividends)
+	require.NoError(t, err)
+	assert.Equal(t, Summary{Inserted: 2, Unchanged: 1, Skipped: 1}, summary)
+
+	summary, err = im.ImportSplits(context.Background(), splits)
+	require.NoError(t, err)
+	assert.Equal(t, Summary{Inserted: 2, Updated: 1}, summary)
+
+	failing := new(MockCorporateActionUpserter)
+	failing.On("UpsertSplit", mock.Anything, mock.Anything).Return(mongo.UpsertUnchanged, dberrors.NewDBError("timeout"))
+	_, err = NewCorporateActionImporter(failing).ImportSplits(context.Background(), splits)
+	assert.True(t, dberrors.IsDBError(err))
+	failing.AssertNumberOfCalls(t, "UpsertSplit", 1)
+}
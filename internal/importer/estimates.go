@@ -0,0 +1,116 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/models"
+)
+
+// ParseEstimates parses an EPS estimate CSV file with a header row naming the
+// columns symbol, fiscal_year, fiscal_quarter, period_end, mean, high, low and
+// num_analysts, and optionally report_date, report_time and currency. Dates
+// are formatted as YYYY-MM-DD and the quarter as 1 to 4 or Q1 to Q4; the
+// currency defaults to USD.
+func ParseEstimates(r io.Reader) ([]models.Earnings, error) {
+	var estimates []models.Earnings
+	required := []string{"symbol", "fiscal_year", "fiscal_quarter", "period_end", "mean", "high", "low", "num_analysts"}
+	err := readCSV(r, required, func(row csvRow) error {
+		e := models.Earnings{
+			Symbol:     row.get("symbol"),
+			ReportTime: row.get("report_time"),
+			Currency:   row.get("currency"),
+		}
+		e.Normalize()
+
+		var err error
+		if e.FiscalYear, err = row.int("fiscal_year"); err != nil {
+			return err
+		}
+		quarter := strings.TrimPrefix(strings.ToUpper(row.get("fiscal_quarter")), "Q")
+		if e.FiscalQuarter, err = strconv.Atoi(quarter); err != nil {
+			return fmt.Errorf("invalid fiscal_quarter %q", row.get("fiscal_quarter"))
+		}
+		if e.PeriodEnd, err = row.date("period_end"); err != nil {
+			return err
+		}
+		if e.ReportDate, err = row.optionalDate("report_date"); err != nil {
+			return err
+		}
+
+		var estimate models.EPSEstimate
+		if estimate.Mean, err = row.float("mean"); err != nil {
+			return err
+		}
+		if estimate.High, err = row.float("high"); err != nil {
+			return err
+		}
+		if estimate.Low, err = row.float("low"); err != nil {
+			return err
+		}
+		if estimate.NumAnalysts, err = row.int("num_analysts"); err != nil {
+			return err
+		}
+		e.EPSEstimate = &estimate
+		estimates = append(estimates, e)
+		return nil
+	})
+	return estimates, err
+}
+
+// LoadEstimates reads and parses an EPS estimate CSV file
+func LoadEstimates(path string) ([]models.Earnings, error) {
+	var estimates []models.Earnings
+	err := loadFile(path, func(r io.Reader) (err error) {
+		estimates, err = ParseEstimates(r)
+		return err
+	})
+	return estimates, err
+}
+
+// EarningsUpserter is the part of mongo.EarningsRepository used by the
+// importer
+type EarningsUpserter interface {
+	Upsert(ctx context.Context, earnings models.Earnings, source string) (mongo.UpsertResult, error)
+}
+
+// SourceEstimates is the source revisions written by the estimate import are
+// recorded under
+const SourceEstimates = "estimates-csv"
+
+// EarningsImporter upserts quarterly earnings, which recomputes the EPS
+// surprise of each quarter from its estimate and reported figures
+type EarningsImporter struct {
+	store  EarningsUpserter
+	source string
+}
+
+// NewEarningsImporter returns an EarningsImporter writing to store and
+// recording revisions under source
+func NewEarningsImporter(store EarningsUpserter, source string) *EarningsImporter {
+	return &EarningsImporter{store: store, source: source}
+}
+
+// Import upserts the earnings of each quarter. Invalid quarters are skipped
+// and logged; a repository failure stops the import.
+func (im *EarningsImporter) Import(ctx context.Context, earnings []models.Earnings) (Summary, error) {
+	var summary Summary
+	for _, e := range earnings {
+		if err := e.Validate(); err != nil {
+			log.Printf("import: skipping %s %d Q%d: %v", e.Symbol, e.FiscalYear, e.FiscalQuarter, err)
+			summary.Skipped++
+			continue
+		}
+		result, err := im.store.Upsert(ctx, e, im.source)
+		if err != nil {
+			return summary, err
+		}
+		summary.add(result)
+	}
+	return summary, nil
+}
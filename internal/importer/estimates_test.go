@@ -0,0 +1,92 @@
+package importer
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockEarningsUpserter struct {
+	mock.Mock
+}
+
+func (m *MockEarningsUpserter) Upsert(ctx context.Context, earnings models.Earnings, source string) (mongo.UpsertResult, error) {
+	args := m.Called(ctx, earnings, source)
+	return args.Get(0).(mongo.UpsertResult), args.Error(1)
+}
+
+func TestLoadEstimates(t *testing.T) {
+	estimates, err := LoadEstimates("testdata/estimates.csv")
+	require.NoError(t, err)
+	assert.Equal(t, []models.Earnings{
+		{
+			Symbol: "AAPL", FiscalYear: 2024, FiscalQuarter: 1, PeriodEnd: date("2023-12-30"),
+			ReportDate: datePtr("2024-02-01"), ReportTime: models.ReportTimeAfterClose, Currency: "USD",
+			EPSEstimate: &models.EPSEstimate{Mean: 2.10, High: 2.22, Low: 1.95, NumAnalysts: 28},
+		},
+		{
+			Symbol: "AAPL", FiscalYear: 2024, FiscalQuarter: 2, PeriodEnd: date("2024-03-30"),
+			ReportTime: models.ReportTimeNotAnnounced, Currency: "USD",
+			EPSEstimate: &models.EPSEstimate{Mean: 1.50, High: 1.58, Low: 1.43, NumAnalysts: 27},
+		},
+		{
+			Symbol: "MSFT", FiscalYear: 2024, FiscalQuarter: 3, PeriodEnd: date("2024-03-31"),
+			ReportDate: datePtr("2024-04-25"), ReportTime: models.ReportTimeAfterClose, Currency: "USD",
+			EPSEstimate: &models.EPSEstimate{Mean: 2.82, High: 2.95, Low: 2.70, NumAnalysts: 35},
+		},
+	}, estimates)
+
+	_, err = LoadEstimates("testdata/missing.csv")
+	assert.Error(t, err)
+}
+
+func TestParseEstimates_Invalid(t *testing.T) {
+	const header = "symbol,fiscal_year,fiscal_quarter,period_end,mean,high,low,num_analysts\n"
+	tests := []struct {
+		name string
+		doc  string
+		err  string
+	}{
+		{"Missing column", "symbol,fiscal_year,fiscal_quarter,period_end,mean\n", `invalid csv file: missing column "high"`},
+		{"Bad quarter", header + "AAPL,2024,first,2023-12-30,2.10,2.22,1.95,28\n", `line 2: invalid fiscal_quarter "first"`},
+		{"Bad mean", header + "AAPL,2024,1,2023-12-30,n/a,2.22,1.95,28\n", `line 2: invalid mean "n/a"`},
+		{"Missing analysts", header + "AAPL,2024,1,2023-12-30,2.10,2.22,1.95,\n", `line 2: invalid num_analysts ""`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseEstimates(strings.NewReader(tt.doc))
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestEarningsImporter_Import(t *testing.T) {
+	estimates, err := LoadEstimates("testdata/estimates.csv")
+	require.NoError(t, err)
+	inverted := estimates[0]
+	inverted.EPSEstimate = &models.EPSEstimate{Mean: 2.10, High: 1.95, Low: 2.22, NumAnalysts: 28}
+	estimates = append(estimates, inverted)
+
+	store := new(MockEarningsUpserter)
+	store.On("Upsert", mock.Anything, estimates[0], SourceEstimates).Return(mongo.UpsertUpdated, nil)
+	store.On("Upsert", mock.Anything, mock.Anything, SourceEstimates).Return(mongo.UpsertInserted, nil)
+
+	summary, err := NewEarningsImporter(store, SourceEstimates).Import(context.Background(), estimates)
+	require.NoError(t, err)
+	assert.Equal(t, Summary{Inserted: 2, Updated: 1, Skipped: 1}, summary)
+	store.AssertNumberOfCalls(t, "Upsert", 3)
+
+	failing := new(MockEarningsUpserter)
+	failing.On("Upsert", mock.Anything, mock.Anything, mock.Anything).Return(mongo.UpsertUnchanged, dberrors.NewDBError("timeout"))
+	_, err = NewEarningsImporter(failing, SourceEstimates).Import(context.Background(), estimates)
+	assert.True(t, dberrors.IsDBError(err))
+	failing.AssertNumberOfCalls(t, "Upsert", 1)
+}
@@ -0,0 +1,212 @@
+// Package importer loads company reference data from the SEC and Nasdaq Trader
+// listing files into the company repository.
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+)
+
+// defaultRegion is used for every imported company, as the listing files only
+// cover US exchanges and carry no country of incorporation
+const defaultRegion = "US"
+
+// Source identifies the kind of file a listing was read from
+type Source int
+
+const (
+	// SourceSEC listings come from company_tickers_exchange.json and carry
+	// the CIK and registrant name
+	SourceSEC Source = iota
+	// SourceNasdaq listings come from the Nasdaq Trader symbol directory and
+	// carry the exchange and security type
+	SourceNasdaq
+)
+
+// Listing is one row of a listing file, before rows for the same symbol are
+// merged into a company
+type Listing struct {
+	Source       Source
+	Symbol       string
+	CIK          string
+	Name         string
+	Exchange     string
+	SecurityType string
+	// Skip explains why the symbol cannot be imported, e.g. a test issue
+	Skip string
+}
+
+// Files names the listing files to import. Empty paths are ignored.
+type Files struct {
+	SECTickers   string
+	NasdaqListed string
+	OtherListed  string
+}
+
+// Upserter is the part of mongo.Repository used by the importer
+type Upserter interface {
+	Upsert(ctx context.Context, company models.Company) (mongo.UpsertResult, error)
+}
+
+// Summary counts what happened to each imported symbol
+type Summary struct {
+	Inserted  int
+	Updated   int
+	Unchanged int
+	Skipped   int
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("inserted %d, updated %d, unchanged %d, skipped %d", s.Inserted, s.Updated, s.Unchanged, s.Skipped)
+}
+
+// Importer merges listings into companies and upserts them
+type Importer struct {
+	store Upserter
+}
+
+// New returns an Importer writing to store
+func New(store Upserter) *Importer {
+	return &Importer{store: store}
+}
+
+// Load reads and parses the listing files
+func Load(files Files) ([]Listing, error) {
+	parsers := []struct {
+		path  string
+		parse func(io.Reader) ([]Listing, error)
+	}{
+		{files.SECTickers, ParseSECTickers},
+		{files.NasdaqListed, ParseNasdaqListed},
+		{files.OtherListed, ParseOtherListed},
+	}
+
+	var listings []Listing
+	for _, p := range parsers {
+		if p.path == "" {
+			continue
+		}
+		f, err := os.Open(p.path)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := p.parse(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p.path, err)
+		}
+		listings = append(listings, parsed...)
+	}
+	return listings, nil
+}
+
+// Import merges the listings by symbol and upserts the resulting companies.
+// Symbols that do not make a valid company are skipped and logged; a
+// repository failure stops the import.
+func (im *Importer) Import(ctx context.Context, listings []Listing) (Summary, error) {
+	var summary Summary
+	for _, company := range mergeListings(listings) {
+		if company.err != nil {
+			log.Printf("import: skipping %s: %v", company.Symbol, company.err)
+			summary.Skipped++
+			continue
+		}
+
+		result, err := im.store.Upsert(ctx, company.Company)
+		if errors.Is(err, dberrors.ErrConflict) {
+			log.Printf("import: skipping %s: company was deleted", company.Symbol)
+			summary.Skipped++
+			continue
+		}
+		if err != nil {
+			return summary, err
+		}
+
+		switch result {
+		case mongo.UpsertInserted:
+			summary.Inserted++
+		case mongo.UpsertUpdated:
+			summary.Updated++
+		default:
+			summary.Unchanged++
+		}
+	}
+	return summary, nil
+}
+
+// mergedCompany is the company built from all listings of a symbol, or the
+// reason it could not be built
+type mergedCompany struct {
+	models.Company
+	err error
+}
+
+// mergeListings combines the listings of each symbol into one company, in
+// symbol order. The SEC file supplies the CIK and name; the Nasdaq Trader files
+// take precedence for the exchange and security type.
+func mergeListings(listings []Listing) []mergedCompany {
+	bySymbol := make(map[string][]Listing)
+	for _, l := range listings {
+		bySymbol[l.Symbol] = append(bySymbol[l.Symbol], l)
+	}
+
+	symbols := make([]string, 0, len(bySymbol))
+	for symbol := range bySymbol {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	merged := make([]mergedCompany, 0, len(symbols))
+	for _, symbol := range symbols {
+		merged = append(merged, merge(symbol, bySymbol[symbol]))
+	}
+	return merged
+}
+
+func merge(symbol string, listings []Listing) mergedCompany {
+	company := models.Company{
+		Symbol:       symbol,
+		SecurityType: models.SecurityTypeCommonStock,
+		Region:       defaultRegion,
+		Active:       true,
+	}
+
+	var secExchange, nasdaqExchange string
+	for _, l := range listings {
+		if l.Skip != "" {
+			return mergedCompany{Company: company, err: errors.New(l.Skip)}
+		}
+		switch l.Source {
+		case SourceSEC:
+			company.CIK = l.CIK
+			company.SecurityName = l.Name
+			secExchange = l.Exchange
+		case SourceNasdaq:
+			if company.SecurityName == "" {
+				company.SecurityName = l.Name
+			}
+			company.SecurityType = l.SecurityType
+			nasdaqExchange = l.Exchange
+		}
+	}
+
+	if company.CIK == "" {
+		return mergedCompany{Company: company, err: errors.New("not in the SEC ticker file")}
+	}
+	company.Exchange = nasdaqExchange
+	if company.Exchange == "" {
+		company.Exchange = secExchange
+	}
+
+	company.Normalize()
+	return mergedCompany{Company: company, err: company.Validate()}
+}
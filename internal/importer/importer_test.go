@@ -0,0 +1,230 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockUpserter struct {
+	mock.Mock
+}
+
+func (m *MockUpserter) Upsert(ctx context.Context, company models.Company) (mongo.UpsertResult, error) {
+	args := m.Called(ctx, company)
+	return args.Get(0).(mongo.UpsertResult), args.Error(1)
+}
+
+var testFiles = Files{
+	SECTickers:   "testdata/company_tickers_exchange.json",
+	NasdaqListed: "testdata/nasdaqlisted.txt",
+	OtherListed:  "testdata/otherlisted.txt",
+}
+
+func TestParseSECTickers(t *testing.T) {
+	listings, err := ParseSECTickers(strings.NewReader(`{"fields":["cik","name","ticker","exchange"],"data":[
+		[320193,"Apple Inc.","AAPL","Nasdaq"],
+		[1067983,"BERKSHIRE HATHAWAY INC","BRK-B","NYSE"],
+		[49938,"IMPERIAL OIL LTD","IMO","NYSE American"],
+		[1000045,"OLD MARKET CAPITAL Corp","OMCC",null]
+	]}`))
+	require.NoError(t, err)
+	assert.Equal(t, []Listing{
+		{Source: SourceSEC, Symbol: "AAPL", CIK: "320193", Name: "Apple Inc.", Exchange: "NASDAQ"},
+		{Source: SourceSEC, Symbol: "BRK.B", CIK: "1067983", Name: "BERKSHIRE HATHAWAY INC", Exchange: "NYSE"},
+		{Source: SourceSEC, Symbol: "IMO", CIK: "49938", Name: "IMPERIAL OIL LTD", Exchange: "AMEX"},
+		{Source: SourceSEC, Symbol: "OMCC", CIK: "1000045", Name: "OLD MARKET CAPITAL Corp"},
+	}, listings)
+
+	_, err = ParseSECTickers(strings.NewReader(`{"fields":["cik","name","ticker"],"data":[]}`))
+	assert.EqualError(t, err, `invalid SEC tickers file: missing field "exchange"`)
+
+	_, err = ParseSECTickers(strings.NewReader(`{"fields":["cik","name","ticker","exchange"],"data":[["320193","Apple Inc.","AAPL","Nasdaq"]]}`))
+	assert.ErrorContains(t, err, "row 1:")
+}
+
+func TestParseNasdaqListed(t *testing.T) {
+	listings, err := ParseNasdaqListed(strings.NewReader("Symbol|Security Name|Market Category|Test Issue|Financial Status|Round Lot Size|ETF|NextShares\r\n" +
+		"AAPL|Apple Inc. - Common Stock|Q|N|N|100|N|N\r\n" +
+		"QQQ|Invesco QQQ Trust, Series 1|G|N|N|100|Y|N\r\n" +
+		"ZAZZT|Tick Pilot Test Stock Class A Common Stock|G|Y|N|100|N|N\r\n" +
+		"SOUNW|SoundHound AI, Inc. - Warrant|G|N|N|100|N|N\r\n" +
+		"File Creation Time: 1017202621:31|||||||\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []Listing{
+		{Source: SourceNasdaq, Symbol: "AAPL", Name: "Apple Inc. - Common Stock", Exchange: "NASDAQ", SecurityType: "Common Stock"},
+		{Source: SourceNasdaq, Symbol: "QQQ", Name: "Invesco QQQ Trust, Series 1", Exchange: "NASDAQ", SecurityType: "ETF"},
+		{Source: SourceNasdaq, Symbol: "ZAZZT", Name: "Tick Pilot Test Stock Class A Common Stock", Exchange: "NASDAQ", SecurityType: "Common Stock", Skip: "test issue"},
+		{Source: SourceNasdaq, Symbol: "SOUNW", Name: "SoundHound AI, Inc. - Warrant", Exchange: "NASDAQ", Skip: `unsupported security "SoundHound AI, Inc. - Warrant"`},
+	}, listings)
+
+	_, err = ParseNasdaqListed(strings.NewReader("Symbol|Security Name\nAAPL|Apple Inc.\n"))
+	assert.EqualError(t, err, `invalid symbol directory file: missing column "Test Issue"`)
+
+	_, err = ParseNasdaqListed(strings.NewReader("Symbol|Security Name|Test Issue|ETF\nAAPL|Apple Inc.|N\n"))
+	assert.EqualError(t, err, "line 2: expected 4 fields, got 3")
+}
+
+func TestParseOtherListed(t *testing.T) {
+	listings, err := ParseOtherListed(strings.NewReader("ACT Symbol|Security Name|Exchange|CQS Symbol|ETF|Round Lot Size|Test Issue|NASDAQ Symbol\n" +
+		"BRK.B|Berkshire Hathaway Inc. New Common Stock|N|BRK.B|N|100|N|BRK.B\n" +
+		"IMO|Imperial Oil Limited Common Stock|A|IMO|N|100|N|IMO\n" +
+		"SPY|SPDR S&P 500 ETF Trust|P|SPY|Y|100|N|SPY\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []Listing{
+		{Source: SourceNasdaq, Symbol: "BRK.B", Name: "Berkshire Hathaway Inc. New Common Stock", Exchange: "NYSE", SecurityType: "Common Stock"},
+		{Source: SourceNasdaq, Symbol: "IMO", Name: "Imperial Oil Limited Common Stock", Exchange: "AMEX", SecurityType: "Common Stock"},
+		{Source: SourceNasdaq, Symbol: "SPY", Name: "SPDR S&P 500 ETF Trust", Exchange: "P", SecurityType: "ETF"},
+	}, listings)
+}
+
+func TestSecurityType(t *testing.T) {
+	tests := []struct {
+		name string
+		etf  bool
+		want string
+	}{
+		{"Apple Inc. - Common Stock", false, models.SecurityTypeCommonStock},
+		{"Invesco QQQ Trust, Series 1", true, models.SecurityTypeETF},
+		{"Baidu, Inc. - American Depositary Shares, each representing eight Class A Ordinary Shares", false, models.SecurityTypeADR},
+		{"Apple Hospitality REIT, Inc. Common Shares", false, models.SecurityTypeREIT},
+		{"Bright Horizons Family Solutions Inc. Common Stock", false, models.SecurityTypeCommonStock},
+		{"SoundHound AI, Inc. - Warrant", false, ""},
+		{"Arbor Realty Trust 6.375% Series D Cumulative Redeemable Preferred Stock", false, ""},
+		{"Arbor Realty Trust Depositary Shares, each representing 1/40th of a Preferred Share", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, securityType(tt.name, tt.etf))
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	listings, err := Load(testFiles)
+	require.NoError(t, err)
+	assert.Len(t, listings, 22)
+
+	_, err = Load(Files{SECTickers: "testdata/missing.json"})
+	assert.Error(t, err)
+}
+
+func TestMergeListings(t *testing.T) {
+	listings, err := Load(testFiles)
+	require.NoError(t, err)
+
+	merged := make(map[string]mergedCompany)
+	for _, m := range mergeListings(listings) {
+		merged[m.Symbol] = m
+	}
+
+	assert.Equal(t, models.Company{
+		Symbol: "BRK.B", CIK: "0001067983", SecurityName: "BERKSHIRE HATHAWAY INC", SecurityType: "Common Stock",
+		Region: "US", Exchange: "NYSE", Active: true,
+	}, merged["BRK.B"].Company, "SEC share classes are matched in Nasdaq symbology")
+	assert.Equal(t, "REIT", merged["APLE"].SecurityType)
+	assert.Equal(t, "ADR", merged["BIDU"].SecurityType)
+	assert.Equal(t, "AMEX", merged["IMO"].Exchange)
+
+	skipped := make(map[string]string)
+	for symbol, m := range merged {
+		if m.err != nil {
+			skipped[symbol] = m.err.Error()
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"ABR-D": `unsupported security "Arbor Realty Trust 6.375% Series D Cumulative Redeemable Preferred Stock"`,
+		"QQQ":   "not in the SEC ticker file",
+		"SOUNW": `unsupported security "SoundHound AI, Inc. - Warrant"`,
+		"SPY":   "not in the SEC ticker file",
+		"TCEHY": "exchange must be one of NYSE, NASDAQ, AMEX",
+		"ZAZZT": "test issue",
+	}, skipped)
+}
+
+func TestImport(t *testing.T) {
+	listings, err := Load(testFiles)
+	require.NoError(t, err)
+
+	store := new(MockUpserter)
+	store.On("Upsert", mock.Anything, mock.MatchedBy(func(c models.Company) bool { return c.Symbol == "AAPL" })).Return(mongo.UpsertUnchanged, nil)
+	store.On("Upsert", mock.Anything, mock.MatchedBy(func(c models.Company) bool { return c.Symbol == "MSFT" })).Return(mongo.UpsertUpdated, nil)
+	store.On("Upsert", mock.Anything, mock.MatchedBy(func(c models.Company) bool { return c.Symbol == "IMO" })).Return(mongo.UpsertUnchanged, dberrors.ErrConflict)
+	store.On("Upsert", mock.Anything, mock.Anything).Return(mongo.UpsertInserted, nil)
+
+	summary, err := New(store).Import(context.Background(), listings)
+	require.NoError(t, err)
+	assert.Equal(t, Summary{Inserted: 5, Updated: 1, Unchanged: 1, Skipped: 7}, summary)
+	assert.Equal(t, "inserted 5, updated 1, unchanged 1, skipped 7", summary.String())
+	store.AssertNumberOfCalls(t, "Upsert", 8)
+}
+
+func TestImport_RepositoryFailure(t *testing.T) {
+	store := new(MockUpserter)
+	store.On("Upsert", mock.Anything, mock.Anything).Return(mongo.UpsertUnchanged, dberrors.NewDBError("timeout"))
+
+	listings := []Listing{
+		{Source: SourceSEC, Symbol: "AAPL", CIK: "320193", Name: "Apple Inc.", Exchange: "NASDAQ"},
+		{Source: SourceSEC, Symbol: "MSFT", CIK: "789019", Name: "MICROSOFT CORP", Exchange: "NASDAQ"},
+	}
+	_, err := New(store).Import(context.Background(), listings)
+	assert.True(t, dberrors.IsDBError(err))
+	assert.False(t, errors.Is(err, dberrors.ErrConflict))
+	store.AssertNumberOfCalls(t, "Upsert", 1)
+}
+
+// listingStore keeps companies the way the repository's Upsert does: listing
+// fields are refreshed and curated fields are only written on insert.
+type listingStore map[string]models.Company
+
+func (s listingStore) Upsert(ctx context.Context, company models.Company) (mongo.UpsertResult, error) {
+	stored, ok := s[company.Symbol]
+	if !ok {
+		s[company.Symbol] = company
+		return mongo.UpsertInserted, nil
+	}
+	updated := stored
+	updated.CIK = company.CIK
+	updated.SecurityName = company.SecurityName
+	updated.SecurityType = company.SecurityType
+	updated.Region = company.Region
+	updated.Exchange = company.Exchange
+	if assert.ObjectsAreEqual(stored, updated) {
+		return mongo.UpsertUnchanged, nil
+	}
+	s[company.Symbol] = updated
+	return mongo.UpsertUpdated, nil
+}
+
+func TestImport_KeepsDeactivatedCompany(t *testing.T) {
+	listings := []Listing{
+		{Source: SourceSEC, Symbol: "AAPL", CIK: "320193", Name: "Apple Inc.", Exchange: "NASDAQ"},
+	}
+	store := listingStore{}
+	_, err := New(store).Import(context.Background(), listings)
+	require.NoError(t, err)
+
+	delisted := time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC)
+	company := store["AAPL"]
+	company.Active = false
+	company.DelistingDate = &delisted
+	store["AAPL"] = company
+
+	summary, err := New(store).Import(context.Background(), listings)
+	require.NoError(t, err)
+	assert.Equal(t, Summary{Unchanged: 1}, summary)
+	reimported := store["AAPL"]
+	assert.False(t, reimported.Active)
+	assert.Equal(t, &delisted, reimported.DelistingDate)
+	assert.NoError(t, reimported.Validate())
+}
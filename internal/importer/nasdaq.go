@@ -0,0 +1,141 @@
+package importer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/api-moose/company-earnings/internal/models"
+)
+
+// nasdaqTrailer starts the last line of the symbol directory files
+const nasdaqTrailer = "File Creation Time"
+
+// otherExchanges maps the exchange codes of otherlisted.txt to
+// models.Exchanges. Codes without a mapping are kept as-is and fail
+// validation on import.
+var otherExchanges = map[string]string{
+	"A": models.ExchangeAMEX,
+	"N": models.ExchangeNYSE,
+}
+
+// unsupportedWords mark instruments that are not one of models.SecurityTypes
+var unsupportedWords = map[string]bool{
+	"warrant": true, "warrants": true,
+	"right": true, "rights": true,
+	"unit": true, "units": true,
+	"preferred":  true,
+	"notes":      true,
+	"debentures": true,
+}
+
+// ParseNasdaqListed parses nasdaqlisted.txt from the Nasdaq Trader symbol
+// directory. Every security in it is listed on Nasdaq.
+func ParseNasdaqListed(r io.Reader) ([]Listing, error) {
+	return parseSymbolDirectory(r, []string{"Symbol", "Security Name", "Test Issue", "ETF"}, func(row map[string]string) Listing {
+		return newNasdaqListing(row["Symbol"], row["Security Name"], models.ExchangeNASDAQ, row["ETF"], row["Test Issue"])
+	})
+}
+
+// ParseOtherListed parses otherlisted.txt from the Nasdaq Trader symbol
+// directory, which covers securities listed on the other US exchanges
+func ParseOtherListed(r io.Reader) ([]Listing, error) {
+	return parseSymbolDirectory(r, []string{"NASDAQ Symbol", "Security Name", "Exchange", "Test Issue", "ETF"}, func(row map[string]string) Listing {
+		exchange := row["Exchange"]
+		if mapped, ok := otherExchanges[exchange]; ok {
+			exchange = mapped
+		}
+		return newNasdaqListing(row["NASDAQ Symbol"], row["Security Name"], exchange, row["ETF"], row["Test Issue"])
+	})
+}
+
+// parseSymbolDirectory reads a pipe-delimited symbol directory file, checking
+// the header has the required columns and stopping at the trailer line
+func parseSymbolDirectory(r io.Reader, required []string, listing func(map[string]string) Listing) ([]Listing, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("invalid symbol directory file: missing header")
+	}
+
+	header := strings.Split(strings.TrimSpace(scanner.Text()), "|")
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range required {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("invalid symbol directory file: missing column %q", name)
+		}
+	}
+
+	var listings []Listing
+	for line := 2; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, nasdaqTrailer) {
+			break
+		}
+
+		fields := strings.Split(text, "|")
+		if len(fields) != len(header) {
+			return nil, fmt.Errorf("line %d: expected %d fields, got %d", line, len(header), len(fields))
+		}
+		row := make(map[string]string, len(required))
+		for _, name := range required {
+			row[name] = strings.TrimSpace(fields[columns[name]])
+		}
+		listings = append(listings, listing(row))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return listings, nil
+}
+
+func newNasdaqListing(symbol, name, exchange, etf, testIssue string) Listing {
+	listing := Listing{
+		Source:       SourceNasdaq,
+		Symbol:       strings.ToUpper(symbol),
+		Name:         name,
+		Exchange:     exchange,
+		SecurityType: securityType(name, etf == "Y"),
+	}
+	switch {
+	case testIssue == "Y":
+		listing.Skip = "test issue"
+	case listing.SecurityType == "":
+		listing.Skip = fmt.Sprintf("unsupported security %q", name)
+	}
+	return listing
+}
+
+// securityType classifies a security from its ETF flag and name, returning an
+// empty string for warrants, rights, units, preferreds and debt
+func securityType(name string, etf bool) string {
+	if etf {
+		return models.SecurityTypeETF
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	securityType := models.SecurityTypeCommonStock
+	for i, word := range words {
+		switch {
+		case unsupportedWords[word]:
+			return ""
+		case word == "depositary" && i > 0 && words[i-1] == "american":
+			securityType = models.SecurityTypeADR
+		case word == "reit" && securityType == models.SecurityTypeCommonStock:
+			securityType = models.SecurityTypeREIT
+		}
+	}
+	return securityType
+}
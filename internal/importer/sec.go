@@ -0,0 +1,86 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/api-moose/company-earnings/internal/models"
+)
+
+// secTickers is the layout of company_tickers_exchange.json: a list of field
+// names and one positional row per ticker
+type secTickers struct {
+	Fields []string            `json:"fields"`
+	Data   [][]json.RawMessage `json:"data"`
+}
+
+// secExchanges maps the exchange names used by the SEC to models.Exchanges
+var secExchanges = map[string]string{
+	"NASDAQ":        models.ExchangeNASDAQ,
+	"NYSE":          models.ExchangeNYSE,
+	"NYSE AMERICAN": models.ExchangeAMEX,
+	"NYSE MKT":      models.ExchangeAMEX,
+	"AMEX":          models.ExchangeAMEX,
+}
+
+// ParseSECTickers parses the SEC company_tickers_exchange.json file
+func ParseSECTickers(r io.Reader) ([]Listing, error) {
+	var file secTickers
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid SEC tickers file: %w", err)
+	}
+
+	columns := make(map[string]int, len(file.Fields))
+	for i, field := range file.Fields {
+		columns[field] = i
+	}
+	for _, field := range []string{"cik", "name", "ticker", "exchange"} {
+		if _, ok := columns[field]; !ok {
+			return nil, fmt.Errorf("invalid SEC tickers file: missing field %q", field)
+		}
+	}
+
+	listings := make([]Listing, 0, len(file.Data))
+	for i, row := range file.Data {
+		if len(row) != len(file.Fields) {
+			return nil, fmt.Errorf("row %d: expected %d fields, got %d", i+1, len(file.Fields), len(row))
+		}
+
+		var cik int64
+		var name, ticker string
+		var exchange *string
+		if err := errors.Join(
+			json.Unmarshal(row[columns["cik"]], &cik),
+			json.Unmarshal(row[columns["name"]], &name),
+			json.Unmarshal(row[columns["ticker"]], &ticker),
+			json.Unmarshal(row[columns["exchange"]], &exchange),
+		); err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+1, err)
+		}
+
+		listing := Listing{
+			Source: SourceSEC,
+			Symbol: secSymbol(ticker),
+			CIK:    strconv.FormatInt(cik, 10),
+			Name:   strings.TrimSpace(name),
+		}
+		if exchange != nil {
+			listing.Exchange = strings.ToUpper(strings.TrimSpace(*exchange))
+			if mapped, ok := secExchanges[listing.Exchange]; ok {
+				listing.Exchange = mapped
+			}
+		}
+		listings = append(listings, listing)
+	}
+	return listings, nil
+}
+
+// secSymbol converts an SEC ticker to Nasdaq Integrated symbology, which
+// separates share classes with a dot rather than a dash (BRK-B is BRK.B)
+func secSymbol(ticker string) string {
+	return strings.ReplaceAll(strings.ToUpper(strings.TrimSpace(ticker)), "-", ".")
+}
@@ -0,0 +1,309 @@
+// Package metrics derives trailing-twelve-month figures and growth rates from
+// the quarterly and annual financials of a company.
+//
+// Quarters are ordered and compared by fiscal year and fiscal quarter rather
+// than by calendar date, so fiscal years that end in any month line up, and a
+// 53-week year is still four quarters: its trailing twelve months cover 53
+// weeks and its long quarter is compared with the same quarter a year
+// earlier.
+package metrics
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/models"
+)
+
+// Items are the line items metrics are computed for
+var Items = []string{models.LineItemRevenue, models.LineItemNetIncome, models.LineItemEPSDiluted}
+
+// Metric is a quarterly figure with its trailing-twelve-month sum and growth
+// rates. Growth is a fraction of the magnitude of the earlier value, so 0.1 is
+// 10% growth and a smaller loss is positive growth. Values that cannot be
+// computed from the stored periods are nil.
+type Metric struct {
+	Value *float64 `json:"value"`
+	TTM   *float64 `json:"ttm"`
+	// QoQ compares the quarter with the preceding fiscal quarter
+	QoQ *float64 `json:"qoq"`
+	// YoY compares the quarter with the same fiscal quarter a year earlier
+	YoY *float64 `json:"yoy"`
+	// TTMYoY compares the trailing twelve months with those a year earlier
+	TTMYoY *float64 `json:"ttmYoY"`
+}
+
+// Quarter holds the metrics of one fiscal quarter
+type Quarter struct {
+	FiscalYear    int        `json:"fiscalYear"`
+	FiscalQuarter int        `json:"fiscalQuarter"`
+	PeriodStart   *time.Time `json:"periodStart"`
+	PeriodEnd     time.Time  `json:"periodEnd"`
+	// Days is the length of the quarter, 98 for the long quarter of a 53-week
+	// year, or 0 when the start is unknown
+	Days int `json:"days"`
+	// Derived is set for quarters with figures computed from year-to-date or
+	// fiscal year figures rather than filed for the quarter, as most companies
+	// only report the fourth quarter as part of the year
+	Derived bool              `json:"derived"`
+	Metrics map[string]Metric `json:"metrics"`
+}
+
+// fiscalQuarters maps the quarterly fiscal periods to quarter numbers
+var fiscalQuarters = map[string]int{
+	models.FiscalPeriodQ1: 1,
+	models.FiscalPeriodQ2: 2,
+	models.FiscalPeriodQ3: 3,
+	models.FiscalPeriodQ4: 4,
+}
+
+// balanceItems are the line items that are values at the period end rather
+// than sums over the period
+var balanceItems = func() map[string]bool {
+	items := make(map[string]bool)
+	for _, def := range models.StatementLineItems(models.StatementBalance) {
+		items[def.Name] = true
+	}
+	return items
+}()
+
+// shareItems are the share counts, averages over the period that do not add
+// up to the year
+var shareItems = func() map[string]bool {
+	items := make(map[string]bool)
+	for _, def := range models.LineItems {
+		if def.Unit == models.UnitShares {
+			items[def.Name] = true
+		}
+	}
+	return items
+}()
+
+// perShareItems are the per-share figures, which do not add up to the year
+// either when the share count changes
+var perShareItems = func() map[string]bool {
+	items := make(map[string]bool)
+	for _, def := range models.LineItems {
+		if def.Unit == models.UnitCurrencyPerShare {
+			items[def.Name] = true
+		}
+	}
+	return items
+}()
+
+// quarter is a fiscal quarter with the values of the metric items. A fourth
+// quarter also holds the fiscal year values of the per-share items, from
+// which their trailing twelve months are computed.
+type quarter struct {
+	Quarter
+	values map[string]*float64
+	annual map[string]*float64
+}
+
+// index numbers fiscal quarters consecutively
+func (q *quarter) index() int {
+	return q.FiscalYear*4 + q.FiscalQuarter - 1
+}
+
+// series is the fiscal quarters of a company by consecutive quarter number,
+// with the values of a set of line items
+type series map[int]*quarter
+
+// newSeries collects the quarters of financials, deriving the fourth quarter
+// values of items that are only reported for the fiscal year when they were
+// not derived at import. Per-share values derived by earlier imports are
+// ignored.
+func newSeries(financials []models.Financials, items []string) series {
+	s := make(series)
+	annual := make(map[int]models.Financials)
+	for _, f := range financials {
+		if f.Annual() {
+			annual[f.FiscalYear] = f
+			continue
+		}
+		n, ok := fiscalQuarters[f.FiscalPeriod]
+		if !ok {
+			continue
+		}
+		q := &quarter{
+			Quarter: Quarter{FiscalYear: f.FiscalYear, FiscalQuarter: n, PeriodStart: f.PeriodStart, PeriodEnd: f.PeriodEnd},
+			values:  make(map[string]*float64, len(items)),
+		}
+		for _, item := range items {
+			if perShareItems[item] && f.Items[item].Derived {
+				continue
+			}
+			q.values[item] = f.Value(item)
+			if q.values[item] != nil && f.Items[item].Derived {
+				q.Derived = true
+			}
+		}
+		s[q.index()] = q
+	}
+
+	for year, fy := range annual {
+		s.deriveFourthQuarter(year, fy, items)
+	}
+	return s
+}
+
+// deriveFourthQuarter fills in the fourth quarter values of a fiscal year
+// that are missing, as the year less its first three quarters. Balance sheet
+// items are taken from the year, which ends on the same day, and share counts
+// and per-share values are left missing, keeping the per-share values of the
+// year. The fourth quarter is added when it was not stored at all.
+func (s series) deriveFourthQuarter(year int, fy models.Financials, items []string) {
+	idx := year*4 + 3
+	q3, ok := s[idx-1]
+	if !ok {
+		return
+	}
+	q4, ok := s[idx]
+	if !ok {
+		start := q3.PeriodEnd.AddDate(0, 0, 1)
+		q4 = &quarter{
+			Quarter: Quarter{FiscalYear: year, FiscalQuarter: 4, PeriodStart: &start, PeriodEnd: fy.PeriodEnd},
+			values:  make(map[string]*float64, len(items)),
+		}
+	}
+
+	hasAnnual := false
+	for _, item := range items {
+		if perShareItems[item] {
+			if v := fy.Value(item); v != nil {
+				if q4.annual == nil {
+					q4.annual = make(map[string]*float64)
+				}
+				q4.annual[item] = v
+				hasAnnual = true
+			}
+			continue
+		}
+		if q4.values[item] != nil || shareItems[item] {
+			continue
+		}
+		v := fy.Value(item)
+		if balanceItems[item] {
+			q4.values[item] = v
+			continue
+		}
+		for i := 1; i <= 3 && v != nil; i++ {
+			prior := s.value(idx-i, item)
+			if prior == nil {
+				v = nil
+				break
+			}
+			*v -= *prior
+		}
+		if v != nil {
+			q4.values[item] = v
+			q4.Derived = true
+		}
+	}
+	if q4.Derived || ok || hasAnnual {
+		if q4.PeriodStart == nil {
+			start := q3.PeriodEnd.AddDate(0, 0, 1)
+			q4.PeriodStart = &start
+		}
+		s[idx] = q4
+	}
+}
+
+// latestFirst returns the quarters from the most recent
+func (s series) latestFirst() []*quarter {
+	quarters := make([]*quarter, 0, len(s))
+	for _, q := range s {
+		quarters = append(quarters, q)
+	}
+	sort.Slice(quarters, func(i, j int) bool { return quarters[i].index() > quarters[j].index() })
+	return quarters
+}
+
+// value returns the value of item in the quarter numbered idx
+func (s series) value(idx int, item string) *float64 {
+	q, ok := s[idx]
+	if !ok {
+		return nil
+	}
+	return q.values[item]
+}
+
+// trailing sums item over the four quarters ending with the quarter numbered
+// idx, or returns nil when any of them is missing. Per-share items without a
+// fourth quarter value are the fiscal year in the window plus the quarters
+// after it less the same quarters a year earlier.
+func (s series) trailing(idx int, item string) *float64 {
+	var sum float64
+	for i := idx - 3; i <= idx; i++ {
+		v := s.value(i, item)
+		if v == nil {
+			if perShareItems[item] {
+				return s.sinceFiscalYear(idx, item)
+			}
+			return nil
+		}
+		sum += *v
+	}
+	return &sum
+}
+
+// sinceFiscalYear returns the per-share item over the twelve months ending
+// with the quarter numbered idx from the last fiscal year ending in them, or
+// nil when any of the figures is missing
+func (s series) sinceFiscalYear(idx int, item string) *float64 {
+	q4 := idx - (idx+1)%4
+	q, ok := s[q4]
+	if !ok || q.annual[item] == nil {
+		return nil
+	}
+	sum := *q.annual[item]
+	for i := q4 + 1; i <= idx; i++ {
+		v, prior := s.value(i, item), s.value(i-4, item)
+		if v == nil || prior == nil {
+			return nil
+		}
+		sum += *v - *prior
+	}
+	return &sum
+}
+
+// Compute returns the metrics of every fiscal quarter of financials, most
+// recent first. Financials must belong to one company and may mix quarterly
+// and annual periods in any order.
+func Compute(financials []models.Financials) []Quarter {
+	s := newSeries(financials, Items)
+	quarters := s.latestFirst()
+
+	result := make([]Quarter, len(quarters))
+	for i, q := range quarters {
+		idx := q.index()
+		q.Metrics = make(map[string]Metric, len(Items))
+		for _, item := range Items {
+			value := q.values[item]
+			ttm := s.trailing(idx, item)
+			q.Metrics[item] = Metric{
+				Value:  value,
+				TTM:    ttm,
+				QoQ:    growth(value, s.value(idx-1, item)),
+				YoY:    growth(value, s.value(idx-4, item)),
+				TTMYoY: growth(ttm, s.trailing(idx-4, item)),
+			}
+		}
+		if q.PeriodStart != nil {
+			q.Days = int(q.PeriodEnd.Sub(*q.PeriodStart).Hours()/24) + 1
+		}
+		result[i] = q.Quarter
+	}
+	return result
+}
+
+// growth returns the change from base to value as a fraction of the
+// magnitude of base, or nil when either is missing or base is zero
+func growth(value, base *float64) *float64 {
+	if value == nil || base == nil || *base == 0 {
+		return nil
+	}
+	g := (*value - *base) / math.Abs(*base)
+	return &g
+}
@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// period builds financials with revenue and net income, in millions
+func period(fiscalYear int, fiscalPeriod string, start, end time.Time, revenue, netIncome float64) models.Financials {
+	return models.Financials{
+		CIK: "0000320193", FiscalYear: fiscalYear, FiscalPeriod: fiscalPeriod,
+		PeriodStart: &start, PeriodEnd: end, Currency: "USD",
+		Items: map[string]models.LineItem{
+			models.LineItemRevenue:   {Value: revenue},
+			models.LineItemNetIncome: {Value: netIncome},
+		},
+	}
+}
+
+// appleFinancials follow Apple's fiscal years, which end on the last Saturday
+// of September. Fiscal 2023 has 53 weeks and a 14-week first quarter, and its
+// fourth quarter is only reported as part of the year.
+var appleFinancials = []models.Financials{
+	period(2022, "Q1", date(2021, 9, 26), date(2021, 12, 25), 123945, 34630),
+	period(2022, "Q2", date(2021, 12, 26), date(2022, 3, 26), 97278, 25010),
+	period(2022, "Q3", date(2022, 3, 27), date(2022, 6, 25), 82959, 19442),
+	period(2022, "Q4", date(2022, 6, 26), date(2022, 9, 24), 90146, 20721),
+	period(2022, "FY", date(2021, 9, 26), date(2022, 9, 24), 394328, 99803),
+	period(2023, "Q1", date(2022, 9, 25), date(2022, 12, 31), 117154, 29998),
+	period(2023, "Q2", date(2023, 1, 1), date(2023, 4, 1), 94836, 24160),
+	period(2023, "Q3", date(2023, 4, 2), date(2023, 7, 1), 81797, 19881),
+	period(2023, "FY", date(2022, 9, 25), date(2023, 9, 30), 383285, 96995),
+	period(2024, "Q1", date(2023, 10, 1), date(2023, 12, 30), 119575, 33916),
+}
+
+func TestCompute(t *testing.T) {
+	quarters := Compute(appleFinancials)
+	require.Len(t, quarters, 9)
+
+	var order []string
+	for _, q := range quarters {
+		order = append(order, q.PeriodEnd.Format(time.DateOnly))
+	}
+	assert.Equal(t, []string{
+		"2023-12-30", "2023-09-30", "2023-07-01", "2023-04-01", "2022-12-31",
+		"2022-09-24", "2022-06-25", "2022-03-26", "2021-12-25",
+	}, order, "most recent first")
+
+	q1FY2024 := quarters[0]
+	assert.Equal(t, 91, q1FY2024.Days)
+	revenue := q1FY2024.Metrics[models.LineItemRevenue]
+	assert.Equal(t, 119575.0, *revenue.Value)
+	assert.Equal(t, 385706.0, *revenue.TTM, "Q2 to Q4 of fiscal 2023 and Q1 of fiscal 2024")
+	assert.InDelta(t, (119575.0-89498.0)/89498.0, *revenue.QoQ, 1e-9)
+	assert.InDelta(t, (119575.0-117154.0)/117154.0, *revenue.YoY, 1e-9, "compared with the 14-week quarter")
+	assert.InDelta(t, (385706.0-387537.0)/387537.0, *revenue.TTMYoY, 1e-9)
+	assert.Nil(t, q1FY2024.Metrics[models.LineItemEPSDiluted].Value)
+
+	q4FY2023 := quarters[1]
+	assert.True(t, q4FY2023.Derived)
+	assert.Equal(t, date(2023, 7, 2), *q4FY2023.PeriodStart)
+	assert.Equal(t, 91, q4FY2023.Days)
+	assert.Equal(t, 89498.0, *q4FY2023.Metrics[models.LineItemRevenue].Value)
+	assert.Equal(t, 22956.0, *q4FY2023.Metrics[models.LineItemNetIncome].Value)
+	assert.Equal(t, 383285.0, *q4FY2023.Metrics[models.LineItemRevenue].TTM, "the TTM of a fourth quarter is the fiscal year")
+
+	q1FY2023 := quarters[4]
+	assert.Equal(t, 98, q1FY2023.Days, "53-week years have a 14-week quarter")
+	assert.False(t, quarters[5].Derived, "reported fourth quarters are kept")
+
+	first := quarters[8]
+	assert.Nil(t, first.Metrics[models.LineItemRevenue].TTM)
+	assert.Nil(t, first.Metrics[models.LineItemRevenue].QoQ)
+	assert.Nil(t, first.Metrics[models.LineItemRevenue].YoY)
+}
+
+func TestCompute_Gaps(t *testing.T) {
+	quarters := Compute([]models.Financials{
+		period(2023, "Q1", date(2022, 9, 25), date(2022, 12, 31), 117154, 29998),
+		period(2023, "Q3", date(2023, 4, 2), date(2023, 7, 1), 81797, 19881),
+		period(2023, "FY", date(2022, 9, 25), date(2023, 9, 30), 383285, 96995),
+	})
+
+	require.Len(t, quarters, 2, "the fourth quarter cannot be derived without the second")
+	assert.Nil(t, quarters[0].Metrics[models.LineItemRevenue].QoQ, "quarters are not compared across a gap")
+}
+
+func TestCompute_FourthQuarterBalanceSheet(t *testing.T) {
+	// companyfacts store the balance sheet at the fiscal year end as a fourth
+	// quarter without any flows
+	q4 := models.Financials{
+		CIK: "0000320193", FiscalYear: 2023, FiscalPeriod: "Q4", PeriodEnd: date(2023, 9, 30), Currency: "USD",
+		Items: map[string]models.LineItem{models.LineItemAssets: {Value: 352583}},
+	}
+	fy := period(2023, "FY", date(2022, 9, 25), date(2023, 9, 30), 383285, 96995)
+	fy.Items[models.LineItemAssets] = models.LineItem{Value: 352583}
+	financials := append([]models.Financials{q4, fy}, appleFinancials[5:8]...)
+
+	quarters := Compute(financials)
+	require.Len(t, quarters, 4)
+	assert.True(t, quarters[0].Derived)
+	assert.Equal(t, date(2023, 7, 2), *quarters[0].PeriodStart)
+	assert.Equal(t, 89498.0, *quarters[0].Metrics[models.LineItemRevenue].Value)
+
+	s := newSeries(financials, []string{models.LineItemRevenue, models.LineItemAssets})
+	assert.Equal(t, 352583.0, *s.value(2023*4+3, models.LineItemAssets), "balance sheet items are not differenced")
+}
+
+func TestCompute_DerivedAtImport(t *testing.T) {
+	// the importer stores fourth quarters derived from the fiscal year
+	q4 := period(2023, "Q4", date(2023, 7, 2), date(2023, 9, 30), 89498, 23317)
+	for name, item := range q4.Items {
+		item.Derived = true
+		q4.Items[name] = item
+	}
+	financials := append([]models.Financials{q4}, appleFinancials[5:9]...)
+
+	quarters := Compute(financials)
+	require.Len(t, quarters, 4)
+	assert.True(t, quarters[0].Derived)
+	assert.Equal(t, 89498.0, *quarters[0].Metrics[models.LineItemRevenue].Value)
+	assert.False(t, quarters[1].Derived)
+}
+
+func TestCompute_PerShareItems(t *testing.T) {
+	// shares were issued in the fourth quarter, so the year's EPS is not the
+	// sum of its quarters
+	withEPS := func(f models.Financials, eps float64, derived bool) models.Financials {
+		f.Items[models.LineItemEPSDiluted] = models.LineItem{Value: eps, Derived: derived}
+		return f
+	}
+	financials := []models.Financials{
+		withEPS(period(2023, "Q1", date(2022, 9, 25), date(2022, 12, 31), 117154, 29998), 1.88, false),
+		withEPS(period(2023, "Q2", date(2023, 1, 1), date(2023, 4, 1), 94836, 24160), 1.52, false),
+		withEPS(period(2023, "Q3", date(2023, 4, 2), date(2023, 7, 1), 81797, 19881), 1.26, false),
+		// a fourth quarter EPS derived by an earlier import as the year less
+		// the quarters
+		withEPS(period(2023, "Q4", date(2023, 7, 2), date(2023, 9, 30), 89498, 22956), 1.47, true),
+		withEPS(period(2023, "FY", date(2022, 9, 25), date(2023, 9, 30), 383285, 96995), 6.13, false),
+		withEPS(period(2024, "Q1", date(2023, 10, 1), date(2023, 12, 30), 119575, 33916), 2.18, false),
+	}
+
+	quarters := Compute(financials)
+	require.Len(t, quarters, 5)
+	eps := func(i int) Metric { return quarters[i].Metrics[models.LineItemEPSDiluted] }
+
+	assert.Nil(t, eps(1).Value, "the fourth quarter EPS is not the year less the quarters")
+	assert.Equal(t, 6.13, *eps(1).TTM, "the TTM of a fourth quarter is the fiscal year")
+	assert.InDelta(t, 6.13+2.18-1.88, *eps(0).TTM, 1e-9, "the year plus the first quarter less the first quarter before")
+	assert.InDelta(t, (2.18-1.88)/1.88, *eps(0).YoY, 1e-9)
+	assert.Nil(t, eps(0).QoQ)
+	assert.Nil(t, eps(2).TTM, "no fiscal year ends in the twelve months")
+}
+
+func TestGrowth(t *testing.T) {
+	v := func(f float64) *float64 { return &f }
+
+	assert.InDelta(t, 0.1, *growth(v(110), v(100)), 1e-9)
+	assert.InDelta(t, 0.5, *growth(v(-1), v(-2)), 1e-9, "a smaller loss is growth")
+	assert.InDelta(t, -2.0, *growth(v(-1), v(1)), 1e-9)
+	assert.Nil(t, growth(v(1), v(0)))
+	assert.Nil(t, growth(nil, v(1)))
+	assert.Nil(t, growth(v(1), nil))
+}
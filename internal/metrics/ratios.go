@@ -0,0 +1,176 @@
+package metrics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/models"
+)
+
+// ratioItems are the line items ratios are computed from
+var ratioItems = []string{
+	models.LineItemRevenue,
+	models.LineItemCostOfRevenue,
+	models.LineItemGrossProfit,
+	models.LineItemOperatingIncome,
+	models.LineItemNetIncome,
+	models.LineItemEPSDiluted,
+	models.LineItemSharesDiluted,
+	models.LineItemDepreciationAmortization,
+	models.LineItemCash,
+	models.LineItemCurrentAssets,
+	models.LineItemAssets,
+	models.LineItemCurrentLiabilities,
+	models.LineItemLongTermDebt,
+	models.LineItemStockholdersEquity,
+}
+
+// Ratios are the financial ratios of a period. Margins and returns are
+// fractions, so 0.25 is 25%. A ratio is nil when an input was not reported
+// or its denominator is zero, never zero in place of a missing value.
+type Ratios struct {
+	GrossMargin     *float64 `json:"grossMargin"`
+	OperatingMargin *float64 `json:"operatingMargin"`
+	NetMargin       *float64 `json:"netMargin"`
+	// ROE and ROA relate the net income of the trailing twelve months to
+	// equity and assets at the period end
+	ROE          *float64 `json:"roe"`
+	ROA          *float64 `json:"roa"`
+	CurrentRatio *float64 `json:"currentRatio"`
+	// DebtToEquity is long-term debt over stockholders' equity
+	DebtToEquity *float64 `json:"debtToEquity"`
+	// PE and EVToEBITDA value the company at a share price. EBITDA is
+	// operating income plus depreciation and amortization, and enterprise
+	// value is the market capitalization at the diluted share count plus
+	// long-term debt less cash.
+	PE         *float64 `json:"pe"`
+	EVToEBITDA *float64 `json:"evToEbitda"`
+}
+
+// PeriodRatios holds the ratios of one fiscal period
+type PeriodRatios struct {
+	FiscalYear   int       `json:"fiscalYear"`
+	FiscalPeriod string    `json:"fiscalPeriod"`
+	PeriodEnd    time.Time `json:"periodEnd"`
+	Ratios
+}
+
+// ComputeRatios returns the ratios of every fiscal quarter of financials, or
+// of every fiscal year when annual is set, most recent first. Quarterly
+// returns and valuations use trailing-twelve-month income, so they compare
+// with the annual ones.
+//
+// Only a current share price is known, so price values the most recent
+// period alone and the valuation ratios of earlier periods are nil. A nil
+// price leaves every valuation ratio nil.
+func ComputeRatios(financials []models.Financials, annual bool, price *float64) []PeriodRatios {
+	var result []PeriodRatios
+	if annual {
+		years := make([]models.Financials, 0, len(financials))
+		for _, f := range financials {
+			if f.Annual() {
+				years = append(years, f)
+			}
+		}
+		sort.Slice(years, func(i, j int) bool { return years[i].FiscalYear > years[j].FiscalYear })
+		for _, f := range years {
+			result = append(result, PeriodRatios{
+				FiscalYear:   f.FiscalYear,
+				FiscalPeriod: f.FiscalPeriod,
+				PeriodEnd:    f.PeriodEnd,
+				Ratios:       ratios(f.Value, f.Value, latest(price, len(result))),
+			})
+		}
+		return result
+	}
+
+	s := newSeries(financials, ratioItems)
+	for _, q := range s.latestFirst() {
+		idx := q.index()
+		value := func(item string) *float64 { return q.values[item] }
+		ttm := func(item string) *float64 { return s.trailing(idx, item) }
+		result = append(result, PeriodRatios{
+			FiscalYear:   q.FiscalYear,
+			FiscalPeriod: quarterPeriods[q.FiscalQuarter-1],
+			PeriodEnd:    q.PeriodEnd,
+			Ratios:       ratios(value, ttm, latest(price, len(result))),
+		})
+	}
+	return result
+}
+
+// quarterPeriods are the fiscal periods by quarter number less one
+var quarterPeriods = []string{models.FiscalPeriodQ1, models.FiscalPeriodQ2, models.FiscalPeriodQ3, models.FiscalPeriodQ4}
+
+// latest returns price for the period at index i of the result when it is the
+// most recent one
+func latest(price *float64, i int) *float64 {
+	if i > 0 {
+		return nil
+	}
+	return price
+}
+
+// ratios computes the ratios of a period from its values, its income over the
+// trailing twelve months and a share price, which may be nil
+func ratios(value, ttm func(item string) *float64, price *float64) Ratios {
+	revenue := value(models.LineItemRevenue)
+	grossProfit := value(models.LineItemGrossProfit)
+	if grossProfit == nil {
+		grossProfit = difference(revenue, value(models.LineItemCostOfRevenue))
+	}
+	netIncome := ttm(models.LineItemNetIncome)
+	equity := value(models.LineItemStockholdersEquity)
+	debt := value(models.LineItemLongTermDebt)
+
+	r := Ratios{
+		GrossMargin:     ratio(grossProfit, revenue),
+		OperatingMargin: ratio(value(models.LineItemOperatingIncome), revenue),
+		NetMargin:       ratio(value(models.LineItemNetIncome), revenue),
+		ROE:             ratio(netIncome, equity),
+		ROA:             ratio(netIncome, value(models.LineItemAssets)),
+		CurrentRatio:    ratio(value(models.LineItemCurrentAssets), value(models.LineItemCurrentLiabilities)),
+		DebtToEquity:    ratio(debt, equity),
+	}
+	if price == nil {
+		return r
+	}
+
+	r.PE = ratio(price, ttm(models.LineItemEPSDiluted))
+	ebitda := sum(ttm(models.LineItemOperatingIncome), ttm(models.LineItemDepreciationAmortization))
+	var ev *float64
+	if shares, cash := value(models.LineItemSharesDiluted), value(models.LineItemCash); shares != nil && debt != nil && cash != nil {
+		v := *price**shares + *debt - *cash
+		ev = &v
+	}
+	r.EVToEBITDA = ratio(ev, ebitda)
+	return r
+}
+
+// ratio returns numerator over denominator, or nil when either is missing or
+// the denominator is zero
+func ratio(numerator, denominator *float64) *float64 {
+	if numerator == nil || denominator == nil || *denominator == 0 {
+		return nil
+	}
+	r := *numerator / *denominator
+	return &r
+}
+
+// sum returns a plus b, or nil when either is missing
+func sum(a, b *float64) *float64 {
+	if a == nil || b == nil {
+		return nil
+	}
+	v := *a + *b
+	return &v
+}
+
+// difference returns a less b, or nil when either is missing
+func difference(a, b *float64) *float64 {
+	if a == nil || b == nil {
+		return nil
+	}
+	d := *a - *b
+	return &d
+}
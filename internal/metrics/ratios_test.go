@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// periodEnds are the last days of calendar fiscal periods
+var periodEnds = map[string]func(year int) time.Time{
+	"Q1": func(year int) time.Time { return date(year, 3, 31) },
+	"Q2": func(year int) time.Time { return date(year, 6, 30) },
+	"Q3": func(year int) time.Time { return date(year, 9, 30) },
+	"Q4": func(year int) time.Time { return date(year, 12, 31) },
+	"FY": func(year int) time.Time { return date(year, 12, 31) },
+}
+
+// financials builds the financials of a calendar fiscal period from line item
+// values
+func financials(fiscalYear int, fiscalPeriod string, items map[string]float64) models.Financials {
+	f := models.Financials{
+		CIK: "0000320193", FiscalYear: fiscalYear, FiscalPeriod: fiscalPeriod,
+		PeriodEnd: periodEnds[fiscalPeriod](fiscalYear), Currency: "USD",
+		Items: make(map[string]models.LineItem, len(items)),
+	}
+	for name, value := range items {
+		f.Items[name] = models.LineItem{Value: value}
+	}
+	return f
+}
+
+func quarterItems() map[string]float64 {
+	return map[string]float64{
+		models.LineItemRevenue:                  100,
+		models.LineItemCostOfRevenue:            60,
+		models.LineItemOperatingIncome:          30,
+		models.LineItemNetIncome:                20,
+		models.LineItemEPSDiluted:               1,
+		models.LineItemDepreciationAmortization: 5,
+	}
+}
+
+func TestComputeRatios_Quarterly(t *testing.T) {
+	q4 := quarterItems()
+	for name, value := range map[string]float64{
+		models.LineItemAssets:             1000,
+		models.LineItemStockholdersEquity: 400,
+		models.LineItemCurrentAssets:      300,
+		models.LineItemCurrentLiabilities: 200,
+		models.LineItemLongTermDebt:       200,
+		models.LineItemCash:               100,
+		models.LineItemSharesDiluted:      80,
+	} {
+		q4[name] = value
+	}
+	price := 50.0
+
+	periods := ComputeRatios([]models.Financials{
+		financials(2023, "Q1", quarterItems()),
+		financials(2023, "Q2", quarterItems()),
+		financials(2023, "Q3", quarterItems()),
+		financials(2023, "Q4", q4),
+	}, false, &price)
+	require.Len(t, periods, 4)
+
+	latest := periods[0]
+	assert.Equal(t, 2023, latest.FiscalYear)
+	assert.Equal(t, "Q4", latest.FiscalPeriod)
+	assert.InDelta(t, 0.4, *latest.GrossMargin, 1e-9, "revenue less cost of revenue")
+	assert.InDelta(t, 0.3, *latest.OperatingMargin, 1e-9)
+	assert.InDelta(t, 0.2, *latest.NetMargin, 1e-9)
+	assert.InDelta(t, 0.2, *latest.ROE, 1e-9, "trailing net income over equity")
+	assert.InDelta(t, 0.08, *latest.ROA, 1e-9)
+	assert.InDelta(t, 1.5, *latest.CurrentRatio, 1e-9)
+	assert.InDelta(t, 0.5, *latest.DebtToEquity, 1e-9)
+	assert.InDelta(t, 12.5, *latest.PE, 1e-9)
+	assert.InDelta(t, 4100.0/140, *latest.EVToEBITDA, 1e-9)
+
+	q3 := periods[1]
+	assert.Equal(t, "Q3", q3.FiscalPeriod)
+	assert.InDelta(t, 0.2, *q3.NetMargin, 1e-9)
+	assert.Nil(t, q3.ROE, "no balance sheet")
+	assert.Nil(t, q3.CurrentRatio)
+	assert.Nil(t, q3.PE, "the price only values the latest period")
+}
+
+func TestComputeRatios_Annual(t *testing.T) {
+	price := 50.0
+	periods := ComputeRatios([]models.Financials{
+		financials(2022, "FY", map[string]float64{models.LineItemRevenue: 300, models.LineItemEPSDiluted: 3}),
+		financials(2023, "Q1", quarterItems()),
+		financials(2023, "FY", map[string]float64{
+			models.LineItemRevenue:            400,
+			models.LineItemGrossProfit:        170,
+			models.LineItemNetIncome:          80,
+			models.LineItemEPSDiluted:         4,
+			models.LineItemStockholdersEquity: 0,
+		}),
+	}, true, &price)
+	require.Len(t, periods, 2)
+
+	fy2023 := periods[0]
+	assert.Equal(t, 2023, fy2023.FiscalYear)
+	assert.InDelta(t, 0.425, *fy2023.GrossMargin, 1e-9, "reported gross profit")
+	assert.InDelta(t, 0.2, *fy2023.NetMargin, 1e-9)
+	assert.Nil(t, fy2023.ROE, "zero equity")
+	assert.Nil(t, fy2023.OperatingMargin, "operating income not reported")
+	assert.InDelta(t, 12.5, *fy2023.PE, 1e-9)
+	assert.Nil(t, fy2023.EVToEBITDA, "share count not reported")
+
+	assert.Equal(t, 2022, periods[1].FiscalYear)
+	assert.Nil(t, periods[1].PE)
+}
+
+func TestComputeRatios_NoPrice(t *testing.T) {
+	periods := ComputeRatios([]models.Financials{financials(2023, "FY", quarterItems())}, true, nil)
+	require.Len(t, periods, 1)
+	assert.NotNil(t, periods[0].NetMargin)
+	assert.Nil(t, periods[0].PE)
+	assert.Nil(t, periods[0].EVToEBITDA)
+}
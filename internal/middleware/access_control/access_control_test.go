@@ -119,3 +119,75 @@ func TestRouteExists(t *testing.T) {
 		})
 	}
 }
+
+func TestRequireRole(t *testing.T) {
+	tests := []struct {
+		name           string
+		user           *mongo.User
+		tenantID       string
+		expectedStatus int
+	}{
+		{"Admin", mongo.NewUser("1", "admin", "admin@example.com", "admin", "tenant1"), "tenant1", http.StatusOK},
+		{"User", mongo.NewUser("2", "user", "user@example.com", "user", "tenant1"), "tenant1", http.StatusForbidden},
+		{"No role", mongo.NewUser("3", "norole", "norole@example.com", "", "tenant1"), "tenant1", http.StatusUnauthorized},
+		{"No user", nil, "tenant1", http.StatusUnauthorized},
+		{"Cross-tenant access attempt", mongo.NewUser("1", "admin", "admin@example.com", "admin", "tenant1"), "tenant2", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			r.With(RequireRole("admin")).Post("/api/v1/companies", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+
+			req := httptest.NewRequest("POST", "/api/v1/companies", nil)
+			ctx := context.WithValue(req.Context(), tenancy.TenantContextKey, tt.tenantID)
+			if tt.user != nil {
+				ctx = context.WithValue(ctx, auth.UserContextKey, tt.user)
+			}
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req.WithContext(ctx))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	readOnly := mongo.NewUser("svc-reporting", "", "", "user", "tenant1")
+	readOnly.Scopes = []string{mongo.APIKeyScopeRead}
+	writeOnly := mongo.NewUser("svc-importer", "", "", "admin", "tenant1")
+	writeOnly.Scopes = []string{mongo.APIKeyScopeWrite}
+	signedIn := mongo.NewUser("1", "user", "user@example.com", "user", "tenant1")
+
+	tests := []struct {
+		name           string
+		user           *mongo.User
+		scope          string
+		expectedStatus int
+	}{
+		{"Read scope", readOnly, mongo.APIKeyScopeRead, http.StatusOK},
+		{"Missing write scope", readOnly, mongo.APIKeyScopeWrite, http.StatusForbidden},
+		{"Write scope", writeOnly, mongo.APIKeyScopeWrite, http.StatusOK},
+		{"Missing read scope", writeOnly, mongo.APIKeyScopeRead, http.StatusForbidden},
+		{"Bearer token", signedIn, mongo.APIKeyScopeWrite, http.StatusOK},
+		{"No user", nil, mongo.APIKeyScopeRead, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			r.With(RequireScope(tt.scope)).Post("/api/v1/screener", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+
+			req := httptest.NewRequest("POST", "/api/v1/screener", nil)
+			if tt.user != nil {
+				req = req.WithContext(context.WithValue(req.Context(), auth.UserContextKey, tt.user))
+			}
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+		})
+	}
+}
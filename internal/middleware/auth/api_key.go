@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	authHandler "github.com/api-moose/company-earnings/internal/api/v1/auth"
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+)
+
+// APIKeyHeader is the header server-to-server clients send their key in
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyMiddleware authenticates requests carrying an X-API-Key header,
+// storing the key's principal and user like AuthMiddleware. The key's scopes
+// are checked per route by access_control.RequireScope. Requests without the
+// header pass through untouched to the bearer token middleware.
+type APIKeyMiddleware struct {
+	authenticator authHandler.Authenticator
+}
+
+func NewAPIKeyMiddleware(authenticator authHandler.Authenticator) *APIKeyMiddleware {
+	return &APIKeyMiddleware{authenticator: authenticator}
+}
+
+func (km *APIKeyMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(APIKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		principal, err := km.authenticator.Authenticate(r.Context(), key)
+		if errors.Is(err, authHandler.ErrInvalidAPIKey) {
+			log.Println("APIKeyMiddleware: Invalid API key")
+			http.Error(w, "Invalid API key", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			log.Printf("APIKeyMiddleware: Error authenticating API key: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		user := principal.User
+		log.Printf("APIKeyMiddleware: User authenticated: ID=%s, Role=%s, TenantID=%s", user.ID, user.Role, user.TenantID)
+		next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), principal)))
+	})
+}
+
+// authenticated returns the user an earlier middleware stored in the
+// context, without logging when there is none
+func authenticated(r *http.Request) (*mongo.User, bool) {
+	user, ok := r.Context().Value(UserContextKey).(*mongo.User)
+	return user, ok
+}
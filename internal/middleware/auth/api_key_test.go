@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	authHandler "github.com/api-moose/company-earnings/internal/api/v1/auth"
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/errors/dberrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	mockAuthenticator := new(MockAuthenticator)
+	serviceUser := &mongo.User{ID: "svc-importer", Role: "admin", TenantID: "tenant1"}
+	mockAuthenticator.On("Authenticate", mock.Anything, "valid_key").Return(&authHandler.Principal{User: serviceUser, Provider: authHandler.ProviderAPIKey}, nil)
+	readOnlyUser := &mongo.User{ID: "svc-reporting", Role: "user", TenantID: "tenant1", Scopes: []string{mongo.APIKeyScopeRead}}
+	mockAuthenticator.On("Authenticate", mock.Anything, "read_key").Return(&authHandler.Principal{User: readOnlyUser, Provider: authHandler.ProviderAPIKey}, nil)
+	mockAuthenticator.On("Authenticate", mock.Anything, "unknown_key").Return(nil, authHandler.ErrInvalidAPIKey)
+	mockAuthenticator.On("Authenticate", mock.Anything, "any_key").Return(nil, dberrors.NewDBError("timeout"))
+
+	km := NewAPIKeyMiddleware(mockAuthenticator)
+
+	tests := []struct {
+		name           string
+		method         string
+		key            string
+		expectedStatus int
+		expectedUser   *mongo.User
+	}{
+		{"Valid key", "POST", "valid_key", http.StatusOK, serviceUser},
+		{"Read scope", "GET", "read_key", http.StatusOK, readOnlyUser},
+		{"Scopes checked per route", "DELETE", "read_key", http.StatusOK, readOnlyUser},
+		{"Unknown key", "GET", "unknown_key", http.StatusUnauthorized, nil},
+		{"Lookup failure", "GET", "any_key", http.StatusInternalServerError, nil},
+		{"No key", "GET", "", http.StatusOK, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", nil)
+			if tt.key != "" {
+				req.Header.Set(APIKeyHeader, tt.key)
+			}
+			w := httptest.NewRecorder()
+
+			km.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				user, _ := authenticated(r)
+				assert.Equal(t, tt.expectedUser, user)
+				w.WriteHeader(http.StatusOK)
+			})).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+
+	mockAuthenticator.AssertExpectations(t)
+}
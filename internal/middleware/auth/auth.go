@@ -6,7 +6,6 @@ import (
 	"net/http"
 	"strings"
 
-	firebaseAuth "firebase.google.com/go/v4/auth"
 	authHandler "github.com/api-moose/company-earnings/internal/api/v1/auth"
 	"github.com/api-moose/company-earnings/internal/db/mongo"
 )
@@ -15,17 +14,22 @@ type ContextKey string
 
 const UserContextKey ContextKey = "user"
 
-type FirebaseAuthClient interface {
-	VerifyIDToken(ctx context.Context, idToken string) (*firebaseAuth.Token, error)
-}
+// PrincipalContextKey holds the *authHandler.Principal the user under
+// UserContextKey was authenticated as, so later middleware can use it
+// without verifying the credential again
+const PrincipalContextKey ContextKey = "principal"
 
+// AuthMiddleware authenticates bearer tokens with whichever identity
+// providers authenticator stands for, storing the principal under
+// PrincipalContextKey and its user under UserContextKey. With a nil
+// authenticator every bearer token is rejected, so only requests an earlier
+// middleware authenticated get through.
 type AuthMiddleware struct {
-	client      FirebaseAuthClient
-	authHandler authHandler.AuthenticatorHandler
+	authenticator authHandler.Authenticator
 }
 
-func NewAuthMiddleware(client FirebaseAuthClient, authHandler authHandler.AuthenticatorHandler) *AuthMiddleware {
-	return &AuthMiddleware{client: client, authHandler: authHandler}
+func NewAuthMiddleware(authenticator authHandler.Authenticator) *AuthMiddleware {
+	return &AuthMiddleware{authenticator: authenticator}
 }
 
 func (am *AuthMiddleware) Middleware(next http.Handler) http.Handler {
@@ -33,6 +37,12 @@ func (am *AuthMiddleware) Middleware(next http.Handler) http.Handler {
 		log.Println("Entering AuthMiddleware")
 		defer log.Println("Exiting AuthMiddleware")
 
+		// Requests authenticated by API key carry no bearer token
+		if _, ok := authenticated(r); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		authHeader := r.Header.Get("Authorization")
 		log.Printf("AuthMiddleware: Authorization header: %s", authHeader)
 
@@ -52,26 +62,45 @@ func (am *AuthMiddleware) Middleware(next http.Handler) http.Handler {
 		token := parts[1]
 		log.Printf("AuthMiddleware: Extracted token: %s", token)
 
-		// Use the authHandler to authenticate the user
-		user, err := am.authHandler.AuthenticateUser(r.Context(), token)
+		if am.authenticator == nil {
+			log.Println("AuthMiddleware: No identity provider is configured")
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			return
+		}
+
+		// Use the authenticator to authenticate the user
+		principal, err := am.authenticator.Authenticate(r.Context(), token)
 		if err != nil {
 			log.Printf("AuthMiddleware: Error authenticating user: %v", err)
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
 			return
 		}
 
-		if user == nil {
+		if principal == nil || principal.User == nil {
 			log.Println("AuthMiddleware: User is nil after authentication")
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
 
-		log.Printf("AuthMiddleware: User authenticated: ID=%s, Email=%s, Role=%s, TenantID=%s", user.ID, user.Email, user.Role, user.TenantID)
-		ctx := context.WithValue(r.Context(), UserContextKey, user)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		user := principal.User
+		log.Printf("AuthMiddleware: User authenticated by %s: ID=%s, Email=%s, Role=%s, TenantID=%s", principal.Provider, user.ID, user.Email, user.Role, user.TenantID)
+		next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), principal)))
 	})
 }
 
+// withPrincipal stores principal and its user in ctx
+func withPrincipal(ctx context.Context, principal *authHandler.Principal) context.Context {
+	ctx = context.WithValue(ctx, PrincipalContextKey, principal)
+	return context.WithValue(ctx, UserContextKey, principal.User)
+}
+
+// GetPrincipalFromContext returns the principal AuthMiddleware or
+// APIKeyMiddleware authenticated the request as
+func GetPrincipalFromContext(r *http.Request) (*authHandler.Principal, bool) {
+	principal, ok := r.Context().Value(PrincipalContextKey).(*authHandler.Principal)
+	return principal, ok
+}
+
 func GetUserFromContext(r *http.Request) (*mongo.User, bool) {
 	user, ok := r.Context().Value(UserContextKey).(*mongo.User)
 	if !ok {
@@ -6,45 +6,33 @@ import (
 	"net/http/httptest"
 	"testing"
 
-	firebaseAuth "firebase.google.com/go/v4/auth"
+	authHandler "github.com/api-moose/company-earnings/internal/api/v1/auth"
 	"github.com/api-moose/company-earnings/internal/db/mongo"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
-type MockFirebaseAuthClient struct {
+type MockAuthenticator struct {
 	mock.Mock
 }
 
-func (m *MockFirebaseAuthClient) VerifyIDToken(ctx context.Context, idToken string) (*firebaseAuth.Token, error) {
-	args := m.Called(ctx, idToken)
+func (m *MockAuthenticator) Authenticate(ctx context.Context, credential string) (*authHandler.Principal, error) {
+	args := m.Called(ctx, credential)
 	if args.Get(0) != nil {
-		return args.Get(0).(*firebaseAuth.Token), args.Error(1)
-	}
-	return nil, args.Error(1)
-}
-
-type MockAuthHandler struct {
-	mock.Mock
-}
-
-func (m *MockAuthHandler) AuthenticateUser(ctx context.Context, token string) (*mongo.User, error) {
-	args := m.Called(ctx, token)
-	if args.Get(0) != nil {
-		return args.Get(0).(*mongo.User), args.Error(1)
+		return args.Get(0).(*authHandler.Principal), args.Error(1)
 	}
 	return nil, args.Error(1)
 }
 
 func TestAuthMiddleware(t *testing.T) {
-	mockAuthHandler := new(MockAuthHandler)
+	mockAuthenticator := new(MockAuthenticator)
 
 	validUser := &mongo.User{ID: "valid_user", Email: "test@example.com", Role: "user", TenantID: "tenant1"}
 
-	mockAuthHandler.On("AuthenticateUser", mock.Anything, "valid_token").Return(validUser, nil)
-	mockAuthHandler.On("AuthenticateUser", mock.Anything, "invalid_token").Return(nil, assert.AnError)
+	mockAuthenticator.On("Authenticate", mock.Anything, "valid_token").Return(&authHandler.Principal{User: validUser, Provider: authHandler.ProviderFirebase}, nil)
+	mockAuthenticator.On("Authenticate", mock.Anything, "invalid_token").Return(nil, assert.AnError)
 
-	am := NewAuthMiddleware(nil, mockAuthHandler)
+	am := NewAuthMiddleware(mockAuthenticator)
 
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user, ok := GetUserFromContext(r)
@@ -54,6 +42,9 @@ func TestAuthMiddleware(t *testing.T) {
 		if user.Email != "test@example.com" {
 			t.Errorf("Expected email 'test@example.com', got '%s'", user.Email)
 		}
+		principal, ok := GetPrincipalFromContext(r)
+		assert.True(t, ok)
+		assert.Equal(t, user, principal.User)
 		w.WriteHeader(http.StatusOK)
 	})
 
@@ -83,5 +74,49 @@ func TestAuthMiddleware(t *testing.T) {
 		})
 	}
 
-	mockAuthHandler.AssertExpectations(t)
+	mockAuthenticator.AssertExpectations(t)
+}
+
+func TestAuthMiddleware_AuthenticatedByAPIKey(t *testing.T) {
+	mockAuthenticator := new(MockAuthenticator)
+	am := NewAuthMiddleware(mockAuthenticator)
+
+	user := &mongo.User{ID: "svc-importer", Role: "admin", TenantID: "tenant1"}
+	req := httptest.NewRequest("GET", "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), UserContextKey, user))
+	w := httptest.NewRecorder()
+
+	am.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := GetUserFromContext(r)
+		assert.True(t, ok)
+		assert.Equal(t, user, got)
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockAuthenticator.AssertNotCalled(t, "Authenticate", mock.Anything, mock.Anything)
+}
+
+func TestAuthMiddleware_NoIdentityProvider(t *testing.T) {
+	am := NewAuthMiddleware(nil)
+	handler := am.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer any_token")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "bearer tokens are rejected")
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	user := &mongo.User{ID: "svc-importer", Role: "admin", TenantID: "tenant1"}
+	req = httptest.NewRequest("GET", "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), UserContextKey, user))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "requests authenticated by API key get through")
 }
@@ -4,8 +4,8 @@ import (
 	"context"
 	"log"
 	"net/http"
-	"strings"
 
+	authHandler "github.com/api-moose/company-earnings/internal/api/v1/auth"
 	"github.com/api-moose/company-earnings/internal/middleware/auth"
 )
 
@@ -13,50 +13,38 @@ type contextKey string
 
 const TenantContextKey contextKey = "tenantID"
 
-type TenantMiddleware struct {
-	client auth.FirebaseAuthClient
-}
+// TenantMiddleware scopes requests to the tenant of the principal an
+// authentication middleware stored in the context, so it must run after
+// them. The X-Tenant-ID header must name that tenant; API keys belong to a
+// single tenant, so it is optional for them.
+type TenantMiddleware struct{}
 
-func NewTenantMiddleware(client auth.FirebaseAuthClient) *TenantMiddleware {
-	return &TenantMiddleware{client: client}
+func NewTenantMiddleware() *TenantMiddleware {
+	return &TenantMiddleware{}
 }
 
-// ... rest of the file remains the same
-
 func (tm *TenantMiddleware) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		log.Println("Entering TenantMiddleware")
 
-		tenantID := r.Header.Get("X-Tenant-ID")
-		if tenantID == "" {
-			log.Println("TenantMiddleware: Tenant ID is required")
-			http.Error(w, "Tenant ID is required", http.StatusBadRequest)
-			return
-		}
-
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			log.Println("TenantMiddleware: Missing authorization header")
-			http.Error(w, "Missing authorization header", http.StatusUnauthorized)
+		principal, ok := auth.GetPrincipalFromContext(r)
+		if !ok || principal.User == nil {
+			log.Println("TenantMiddleware: Request is not authenticated")
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
 			return
 		}
 
-		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-			log.Println("TenantMiddleware: Invalid authorization header format")
-			http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
-			return
-		}
-
-		token := parts[1]
-		decodedToken, err := tm.client.VerifyIDToken(r.Context(), token)
-		if err != nil {
-			log.Printf("TenantMiddleware: Error verifying ID token: %v", err)
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
+		tenantID := r.Header.Get("X-Tenant-ID")
+		if tenantID == "" {
+			if principal.Provider != authHandler.ProviderAPIKey {
+				log.Println("TenantMiddleware: Tenant ID is required")
+				http.Error(w, "Tenant ID is required", http.StatusBadRequest)
+				return
+			}
+			tenantID = principal.User.TenantID
 		}
 
-		if decodedToken.Claims["tenantID"] != tenantID {
+		if principal.User.TenantID != tenantID {
 			log.Println("TenantMiddleware: Tenant ID mismatch")
 			http.Error(w, "Tenant ID mismatch", http.StatusUnauthorized)
 			return
@@ -1,12 +1,273 @@
 // internal/models/company.go
 package models
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Supported exchanges a security can be listed on
+const (
+	ExchangeNYSE   = "NYSE"
+	ExchangeNASDAQ = "NASDAQ"
+	ExchangeAMEX   = "AMEX"
+)
+
+var Exchanges = []string{ExchangeNYSE, ExchangeNASDAQ, ExchangeAMEX}
+
+// IsValidExchange reports whether exchange is one of the supported exchanges
+func IsValidExchange(exchange string) bool {
+	for _, e := range Exchanges {
+		if exchange == e {
+			return true
+		}
+	}
+	return false
+}
+
+// Supported security types
+const (
+	SecurityTypeCommonStock = "Common Stock"
+	SecurityTypeETF         = "ETF"
+	SecurityTypeADR         = "ADR"
+	SecurityTypeREIT        = "REIT"
+)
+
+var SecurityTypes = []string{SecurityTypeCommonStock, SecurityTypeETF, SecurityTypeADR, SecurityTypeREIT}
+
+// IsValidSecurityType reports whether securityType is one of the supported types
+func IsValidSecurityType(securityType string) bool {
+	for _, t := range SecurityTypes {
+		if securityType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// FieldError reports which field of a model failed validation
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s %s", e.Field, e.Message)
+}
+
+// cikLength is the width of the zero-padded CIKs used by the SEC
+const cikLength = 10
+
+var ErrInvalidCIK = errors.New("cik must be a number of at most 10 digits")
+
+// NormalizeCIK zero-pads a CIK to ten digits so "320193", "CIK320193" and
+// "0000320193" all refer to the same company
+func NormalizeCIK(cik string) (string, error) {
+	cik = strings.TrimSpace(cik)
+	if len(cik) >= 3 && strings.EqualFold(cik[:3], "CIK") {
+		cik = cik[3:]
+	}
+	if cik == "" || len(cik) > cikLength {
+		return "", ErrInvalidCIK
+	}
+	for _, c := range cik {
+		if c < '0' || c > '9' {
+			return "", ErrInvalidCIK
+		}
+	}
+	return strings.Repeat("0", cikLength-len(cik)) + cik, nil
+}
+
 type Company struct {
-	Symbol       string `json:"symbol"`
-	CIK          string `json:"cik"`
-	SecurityName string `json:"securityName"`
-	SecurityType string `json:"securityType"`
-	Region       string `json:"region"`
-	Exchange     string `json:"exchange"`
-	Sector       string `json:"sector"`
+	Symbol        string     `json:"symbol" bson:"symbol"`
+	CIK           string     `json:"cik" bson:"cik"`
+	SecurityName  string     `json:"securityName" bson:"securityName"`
+	SecurityType  string     `json:"securityType" bson:"securityType"`
+	Region        string     `json:"region" bson:"region"`
+	Exchange      string     `json:"exchange" bson:"exchange"`
+	Sector        string     `json:"sector" bson:"sector"`
+	Active        bool       `json:"active" bson:"active"`
+	ListingDate   *time.Time `json:"listingDate,omitempty" bson:"listingDate,omitempty"`
+	DelistingDate *time.Time `json:"delistingDate,omitempty" bson:"delistingDate,omitempty"`
+	// Price is the last known share price in the currency of the financials,
+	// used for valuation ratios when a request does not supply one
+	Price *float64 `json:"price,omitempty" bson:"price,omitempty"`
+	// DeletedAt marks a soft-deleted company, which is hidden from every read
+	DeletedAt *time.Time `json:"-" bson:"deletedAt,omitempty"`
+}
+
+// Normalize upper-cases the codes and zero-pads the CIK so equivalent input
+// is stored the same way. Invalid values are left for Validate to report.
+func (c *Company) Normalize() {
+	c.Symbol = strings.ToUpper(strings.TrimSpace(c.Symbol))
+	c.Exchange = strings.ToUpper(strings.TrimSpace(c.Exchange))
+	c.Region = strings.ToUpper(strings.TrimSpace(c.Region))
+	if cik, err := NormalizeCIK(c.CIK); err == nil {
+		c.CIK = cik
+	}
+}
+
+// Validate checks the required fields and the enumerated values of a company
+func (c *Company) Validate() error {
+	if c.Symbol == "" {
+		return &FieldError{Field: "symbol", Message: "is required"}
+	}
+	if _, err := NormalizeCIK(c.CIK); err != nil {
+		return &FieldError{Field: "cik", Message: "must be a number of at most 10 digits"}
+	}
+	if c.SecurityName == "" {
+		return &FieldError{Field: "securityName", Message: "is required"}
+	}
+	if c.SecurityType == "" {
+		return &FieldError{Field: "securityType", Message: "is required"}
+	}
+	if c.Exchange == "" {
+		return &FieldError{Field: "exchange", Message: "is required"}
+	}
+	if c.Region == "" {
+		return &FieldError{Field: "region", Message: "is required"}
+	}
+	if err := validateEnums(c.SecurityType, c.Exchange, c.Region); err != nil {
+		return err
+	}
+	if err := validatePrice(c.Price); err != nil {
+		return err
+	}
+	if err := validateDates(c.ListingDate, c.DelistingDate); err != nil {
+		return err
+	}
+	if c.Active && c.DelistingDate != nil && c.DelistingDate.Before(time.Now()) {
+		return &FieldError{Field: "active", Message: "cannot be true after delistingDate"}
+	}
+	return nil
+}
+
+// CompanyUpdate holds the fields of a partial update. Nil fields are left
+// untouched; the symbol identifies the company and cannot be changed.
+type CompanyUpdate struct {
+	CIK           *string    `json:"cik"`
+	SecurityName  *string    `json:"securityName"`
+	SecurityType  *string    `json:"securityType"`
+	Region        *string    `json:"region"`
+	Exchange      *string    `json:"exchange"`
+	Sector        *string    `json:"sector"`
+	Active        *bool      `json:"active"`
+	ListingDate   *time.Time `json:"listingDate"`
+	DelistingDate *time.Time `json:"delistingDate"`
+	Price         *float64   `json:"price"`
+}
+
+// Apply returns the company with the fields set in u replacing its own
+func (c Company) Apply(u CompanyUpdate) Company {
+	set := func(field *string, value *string) {
+		if value != nil {
+			*field = *value
+		}
+	}
+	set(&c.CIK, u.CIK)
+	set(&c.SecurityName, u.SecurityName)
+	set(&c.SecurityType, u.SecurityType)
+	set(&c.Region, u.Region)
+	set(&c.Exchange, u.Exchange)
+	set(&c.Sector, u.Sector)
+	if u.Active != nil {
+		c.Active = *u.Active
+	}
+	if u.ListingDate != nil {
+		c.ListingDate = u.ListingDate
+	}
+	if u.DelistingDate != nil {
+		c.DelistingDate = u.DelistingDate
+	}
+	if u.Price != nil {
+		c.Price = u.Price
+	}
+	return c
+}
+
+// Normalize applies the same normalization as Company.Normalize to the fields
+// that are set
+func (u *CompanyUpdate) Normalize() {
+	upper := func(s *string) {
+		if s != nil {
+			*s = strings.ToUpper(strings.TrimSpace(*s))
+		}
+	}
+	upper(u.Exchange)
+	upper(u.Region)
+	if u.CIK != nil {
+		if cik, err := NormalizeCIK(*u.CIK); err == nil {
+			u.CIK = &cik
+		}
+	}
+}
+
+// Validate checks the fields that are set. Fields that depend on each other,
+// such as the listing dates, are only checked against the stored company once
+// the update is applied to it.
+func (u *CompanyUpdate) Validate() error {
+	if u.CIK != nil {
+		if _, err := NormalizeCIK(*u.CIK); err != nil {
+			return &FieldError{Field: "cik", Message: "must be a number of at most 10 digits"}
+		}
+	}
+	if u.SecurityName != nil && *u.SecurityName == "" {
+		return &FieldError{Field: "securityName", Message: "cannot be empty"}
+	}
+	var securityType, exchange, region string
+	if u.SecurityType != nil {
+		securityType = *u.SecurityType
+		if securityType == "" {
+			return &FieldError{Field: "securityType", Message: "cannot be empty"}
+		}
+	}
+	if u.Exchange != nil {
+		exchange = *u.Exchange
+		if exchange == "" {
+			return &FieldError{Field: "exchange", Message: "cannot be empty"}
+		}
+	}
+	if u.Region != nil {
+		region = *u.Region
+		if region == "" {
+			return &FieldError{Field: "region", Message: "cannot be empty"}
+		}
+	}
+	if err := validateEnums(securityType, exchange, region); err != nil {
+		return err
+	}
+	if err := validatePrice(u.Price); err != nil {
+		return err
+	}
+	return validateDates(u.ListingDate, u.DelistingDate)
+}
+
+// validateEnums checks the enumerated values that are not empty
+func validateEnums(securityType, exchange, region string) error {
+	if securityType != "" && !IsValidSecurityType(securityType) {
+		return &FieldError{Field: "securityType", Message: "must be one of " + strings.Join(SecurityTypes, ", ")}
+	}
+	if exchange != "" && !IsValidExchange(exchange) {
+		return &FieldError{Field: "exchange", Message: "must be one of " + strings.Join(Exchanges, ", ")}
+	}
+	if region != "" && !IsValidRegion(region) {
+		return &FieldError{Field: "region", Message: "must be an ISO 3166-1 alpha-2 country code"}
+	}
+	return nil
+}
+
+func validatePrice(price *float64) error {
+	if price != nil && !(*price > 0) {
+		return &FieldError{Field: "price", Message: "must be greater than 0"}
+	}
+	return nil
+}
+
+func validateDates(listingDate, delistingDate *time.Time) error {
+	if listingDate != nil && delistingDate != nil && delistingDate.Before(*listingDate) {
+		return &FieldError{Field: "delistingDate", Message: "cannot be before listingDate"}
+	}
+	return nil
 }
@@ -0,0 +1,149 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeCIK(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"320193", "0000320193", false},
+		{"0000320193", "0000320193", false},
+		{"CIK320193", "0000320193", false},
+		{"cik0000320193", "0000320193", false},
+		{" 789019 ", "0000789019", false},
+		{"", "", true},
+		{"CIK", "", true},
+		{"32O193", "", true},
+		{"12345678901", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizeCIK(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NormalizeCIK(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+		assert.Equal(t, tt.want, got)
+	}
+}
+
+func TestIsValidExchange(t *testing.T) {
+	assert.True(t, IsValidExchange("NYSE"))
+	assert.False(t, IsValidExchange("nyse"))
+	assert.False(t, IsValidExchange("LSE"))
+}
+
+func validCompany() Company {
+	return Company{
+		Symbol:       "AAPL",
+		CIK:          "0000320193",
+		SecurityName: "Apple Inc.",
+		SecurityType: SecurityTypeCommonStock,
+		Region:       "US",
+		Exchange:     ExchangeNASDAQ,
+	}
+}
+
+func TestCompany_Normalize(t *testing.T) {
+	c := Company{Symbol: " aapl ", CIK: "320193", Exchange: "nasdaq", Region: "us"}
+	c.Normalize()
+
+	assert.Equal(t, "AAPL", c.Symbol)
+	assert.Equal(t, "0000320193", c.CIK)
+	assert.Equal(t, "NASDAQ", c.Exchange)
+	assert.Equal(t, "US", c.Region)
+}
+
+func TestCompany_Validate(t *testing.T) {
+	listed := time.Date(1980, 12, 12, 0, 0, 0, 0, time.UTC)
+	before := listed.AddDate(-1, 0, 0)
+	scheduled := time.Now().AddDate(0, 1, 0)
+
+	tests := []struct {
+		name   string
+		modify func(c *Company)
+		field  string
+	}{
+		{"Valid", func(c *Company) {}, ""},
+		{"Missing symbol", func(c *Company) { c.Symbol = "" }, "symbol"},
+		{"Invalid CIK", func(c *Company) { c.CIK = "apple" }, "cik"},
+		{"Missing name", func(c *Company) { c.SecurityName = "" }, "securityName"},
+		{"Missing security type", func(c *Company) { c.SecurityType = "" }, "securityType"},
+		{"Unknown security type", func(c *Company) { c.SecurityType = "Warrant" }, "securityType"},
+		{"Unknown exchange", func(c *Company) { c.Exchange = "LSE" }, "exchange"},
+		{"Unknown region", func(c *Company) { c.Region = "XX" }, "region"},
+		{"Alpha-3 region", func(c *Company) { c.Region = "USA" }, "region"},
+		{"Delisted before listed", func(c *Company) { c.ListingDate, c.DelistingDate = &listed, &before }, "delistingDate"},
+		{"Active after delisting", func(c *Company) { c.Active, c.DelistingDate = true, &listed }, "active"},
+		{"Active until a scheduled delisting", func(c *Company) { c.Active, c.DelistingDate = true, &scheduled }, ""},
+		{"Zero price", func(c *Company) { price := 0.0; c.Price = &price }, "price"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validCompany()
+			tt.modify(&c)
+
+			err := c.Validate()
+			if tt.field == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var fieldErr *FieldError
+			if assert.ErrorAs(t, err, &fieldErr) {
+				assert.Equal(t, tt.field, fieldErr.Field)
+			}
+		})
+	}
+}
+
+func TestCompanyUpdate_Validate(t *testing.T) {
+	str := func(s string) *string { return &s }
+	num := func(f float64) *float64 { return &f }
+
+	tests := []struct {
+		name   string
+		update CompanyUpdate
+		field  string
+	}{
+		{"Empty update", CompanyUpdate{}, ""},
+		{"Valid fields", CompanyUpdate{SecurityType: str("ETF"), Exchange: str("NYSE"), Region: str("GB")}, ""},
+		{"Invalid CIK", CompanyUpdate{CIK: str("x")}, "cik"},
+		{"Empty name", CompanyUpdate{SecurityName: str("")}, "securityName"},
+		{"Unknown security type", CompanyUpdate{SecurityType: str("Bond")}, "securityType"},
+		{"Empty exchange", CompanyUpdate{Exchange: str("")}, "exchange"},
+		{"Unknown region", CompanyUpdate{Region: str("ZZ")}, "region"},
+		{"Valid price", CompanyUpdate{Price: num(189.95)}, ""},
+		{"Negative price", CompanyUpdate{Price: num(-1)}, "price"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.update.Validate()
+			if tt.field == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var fieldErr *FieldError
+			if assert.ErrorAs(t, err, &fieldErr) {
+				assert.Equal(t, tt.field, fieldErr.Field)
+			}
+		})
+	}
+}
+
+func TestCompanyUpdate_Normalize(t *testing.T) {
+	exchange, region, cik := "nyse", "gb", "1652044"
+	u := CompanyUpdate{Exchange: &exchange, Region: &region, CIK: &cik}
+	u.Normalize()
+
+	assert.Equal(t, "NYSE", *u.Exchange)
+	assert.Equal(t, "GB", *u.Region)
+	assert.Equal(t, "0001652044", *u.CIK)
+}
@@ -0,0 +1,216 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of dividend
+const (
+	DividendTypeRegular         = "regular"
+	DividendTypeSpecial         = "special"
+	DividendTypeReturnOfCapital = "return-of-capital"
+)
+
+// DividendTypes lists the kinds of dividend
+var DividendTypes = []string{DividendTypeRegular, DividendTypeSpecial, DividendTypeReturnOfCapital}
+
+// IsValidDividendType reports whether dividendType is one of DividendTypes
+func IsValidDividendType(dividendType string) bool {
+	for _, t := range DividendTypes {
+		if t == dividendType {
+			return true
+		}
+	}
+	return false
+}
+
+// Dividend is a cash distribution declared per share of a listing. Amount is
+// as declared, not adjusted for later splits.
+type Dividend struct {
+	Symbol     string     `json:"symbol" bson:"symbol"`
+	ExDate     time.Time  `json:"exDate" bson:"exDate"`
+	RecordDate *time.Time `json:"recordDate" bson:"recordDate"`
+	PayDate    *time.Time `json:"payDate" bson:"payDate"`
+	Amount     float64    `json:"amount" bson:"amount"`
+	Currency   string     `json:"currency" bson:"currency"`
+	Type       string     `json:"type" bson:"type"`
+}
+
+// Validate checks the dividend is dated, positive and of a known type
+func (d *Dividend) Validate() error {
+	if d.Symbol == "" {
+		return &FieldError{Field: "symbol", Message: "is required"}
+	}
+	if d.ExDate.IsZero() {
+		return &FieldError{Field: "exDate", Message: "is required"}
+	}
+	if d.RecordDate != nil && d.RecordDate.Before(d.ExDate) {
+		return &FieldError{Field: "recordDate", Message: "cannot be before exDate"}
+	}
+	if d.PayDate != nil && d.PayDate.Before(d.ExDate) {
+		return &FieldError{Field: "payDate", Message: "cannot be before exDate"}
+	}
+	if d.Amount <= 0 {
+		return &FieldError{Field: "amount", Message: "must be positive"}
+	}
+	if !isCurrencyCode(d.Currency) {
+		return &FieldError{Field: "currency", Message: "must be an ISO 4217 currency code"}
+	}
+	if !IsValidDividendType(d.Type) {
+		return &FieldError{Field: "type", Message: "must be one of " + strings.Join(DividendTypes, ", ")}
+	}
+	return nil
+}
+
+// Split is a stock split of a listing effective on Date, giving Numerator
+// shares for every Denominator held: a 4-for-1 split is 4:1 and a 1-for-10
+// reverse split 1:10
+type Split struct {
+	Symbol      string    `json:"symbol" bson:"symbol"`
+	Date        time.Time `json:"date" bson:"date"`
+	Numerator   float64   `json:"numerator" bson:"numerator"`
+	Denominator float64   `json:"denominator" bson:"denominator"`
+}
+
+// Ratio is the number of shares after the split for each share before it
+func (s *Split) Ratio() float64 {
+	return s.Numerator / s.Denominator
+}
+
+// MarshalJSON adds the ratio to the fields of the split
+func (s Split) MarshalJSON() ([]byte, error) {
+	type split Split
+	return json.Marshal(struct {
+		split
+		Ratio float64 `json:"ratio"`
+	}{split(s), s.Ratio()})
+}
+
+// Validate checks the split is dated and both sides of its ratio are positive
+func (s *Split) Validate() error {
+	if s.Symbol == "" {
+		return &FieldError{Field: "symbol", Message: "is required"}
+	}
+	if s.Date.IsZero() {
+		return &FieldError{Field: "date", Message: "is required"}
+	}
+	if s.Numerator <= 0 || s.Denominator <= 0 {
+		return &FieldError{Field: "ratio", Message: "must have a positive numerator and denominator"}
+	}
+	if s.Numerator == s.Denominator {
+		return &FieldError{Field: "ratio", Message: "must change the number of shares"}
+	}
+	return nil
+}
+
+// ParseSplitRatio parses a ratio written as 4:1, 4/1 or 4-for-1
+func ParseSplitRatio(s string) (numerator, denominator float64, err error) {
+	ratio := strings.ToLower(strings.TrimSpace(s))
+	var parts []string
+	for _, sep := range []string{":", "/", "-for-"} {
+		if parts = strings.Split(ratio, sep); len(parts) == 2 {
+			break
+		}
+	}
+	if len(parts) == 2 {
+		numerator, err = strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		if err == nil {
+			denominator, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		}
+		if err == nil && numerator > 0 && denominator > 0 {
+			return numerator, denominator, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("invalid split ratio %q", s)
+}
+
+// SplitsBy returns the splits effective by the end of asOf, all of them when
+// asOf is nil
+func SplitsBy(splits []Split, asOf *time.Time) []Split {
+	if asOf == nil {
+		return splits
+	}
+	end := asOf.AddDate(0, 0, 1)
+	effective := []Split{}
+	for _, s := range splits {
+		if s.Date.Before(end) {
+			effective = append(effective, s)
+		}
+	}
+	return effective
+}
+
+// SplitFactor returns how many shares each share held on date has become
+// through the splits after it
+func SplitFactor(splits []Split, date time.Time) float64 {
+	factor := 1.0
+	for i := range splits {
+		if splits[i].Date.After(date) {
+			factor *= splits[i].Ratio()
+		}
+	}
+	return factor
+}
+
+// AdjustForSplits restates the per-share figures in shares after splits,
+// dividing each by the splits after it was published or estimated.
+func (e *Earnings) AdjustForSplits(splits []Split) {
+	estimated := e.PeriodEnd
+	if e.ReportDate != nil {
+		estimated = *e.ReportDate
+	}
+	published := estimated
+	if e.PublishedAt != nil {
+		published = *e.PublishedAt
+	}
+
+	adjust := func(v *float64, factor float64) *float64 {
+		if v == nil || factor == 1 {
+			return v
+		}
+		adjusted := *v / factor
+		return &adjusted
+	}
+	factor, estimateFactor := SplitFactor(splits, published), SplitFactor(splits, estimated)
+	e.EPSBasic = adjust(e.EPSBasic, factor)
+	e.EPSDiluted = adjust(e.EPSDiluted, factor)
+	if e.EPSEstimate != nil && estimateFactor != 1 {
+		estimate := *e.EPSEstimate
+		estimate.Mean /= estimateFactor
+		estimate.High /= estimateFactor
+		estimate.Low /= estimateFactor
+		e.EPSEstimate = &estimate
+	}
+	if factor == estimateFactor {
+		e.EPSSurprise = adjust(e.EPSSurprise, factor)
+	} else {
+		e.ComputeSurprise()
+	}
+}
+
+// AdjustForSplits restates the per-share values and share counts in shares
+// after splits, adjusting each item for the splits after it was filed.
+func (f *Financials) AdjustForSplits(splits []Split) {
+	if len(splits) == 0 {
+		return
+	}
+	// copies of the financials share the items, so they are replaced
+	items := make(map[string]LineItem, len(f.Items))
+	for name, item := range f.Items {
+		factor := SplitFactor(splits, item.Filed)
+		if factor != 1 {
+			switch lineItemUnits[name] {
+			case UnitCurrencyPerShare:
+				item.Value /= factor
+			case UnitShares:
+				item.Value *= factor
+			}
+		}
+		items[name] = item
+	}
+	f.Items = items
+}
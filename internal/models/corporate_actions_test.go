@@ -0,0 +1,198 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDividend_Validate(t *testing.T) {
+	exDate := time.Date(2024, 8, 12, 0, 0, 0, 0, time.UTC)
+	before := exDate.AddDate(0, 0, -1)
+	valid := Dividend{Symbol: "AAPL", ExDate: exDate, Amount: 0.25, Currency: "USD", Type: DividendTypeRegular}
+
+	tests := []struct {
+		name   string
+		modify func(d *Dividend)
+		field  string
+	}{
+		{"Valid", func(d *Dividend) {}, ""},
+		{"Missing symbol", func(d *Dividend) { d.Symbol = "" }, "symbol"},
+		{"Missing ex-date", func(d *Dividend) { d.ExDate = time.Time{} }, "exDate"},
+		{"Record date before ex-date", func(d *Dividend) { d.RecordDate = &before }, "recordDate"},
+		{"Pay date before ex-date", func(d *Dividend) { d.PayDate = &before }, "payDate"},
+		{"Zero amount", func(d *Dividend) { d.Amount = 0 }, "amount"},
+		{"Invalid currency", func(d *Dividend) { d.Currency = "usd" }, "currency"},
+		{"Unknown type", func(d *Dividend) { d.Type = "stock" }, "type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := valid
+			tt.modify(&d)
+			err := d.Validate()
+			if tt.field == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var fieldErr *FieldError
+			require.ErrorAs(t, err, &fieldErr)
+			assert.Equal(t, tt.field, fieldErr.Field)
+		})
+	}
+}
+
+func TestSplit_Validate(t *testing.T) {
+	date := time.Date(2020, 8, 31, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, (&Split{Symbol: "AAPL", Date: date, Numerator: 4, Denominator: 1}).Validate())
+	assert.EqualError(t, (&Split{Symbol: "AAPL", Numerator: 4, Denominator: 1}).Validate(), "date is required")
+	assert.EqualError(t, (&Split{Symbol: "AAPL", Date: date, Numerator: 4}).Validate(), "ratio must have a positive numerator and denominator")
+	assert.EqualError(t, (&Split{Symbol: "AAPL", Date: date, Numerator: 2, Denominator: 2}).Validate(), "ratio must change the number of shares")
+}
+
+func TestSplit_MarshalJSON(t *testing.T) {
+	split := Split{Symbol: "GE", Date: time.Date(2021, 8, 2, 0, 0, 0, 0, time.UTC), Numerator: 1, Denominator: 8}
+	data, err := json.Marshal(split)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"symbol":"GE","date":"2021-08-02T00:00:00Z","numerator":1,"denominator":8,"ratio":0.125}`, string(data))
+}
+
+func TestParseSplitRatio(t *testing.T) {
+	tests := []struct {
+		input    string
+		num, den float64
+		wantErr  bool
+	}{
+		{"4:1", 4, 1, false},
+		{"3/2", 3, 2, false},
+		{"1-for-10", 1, 10, false},
+		{" 20 FOR 1 ", 0, 0, true},
+		{"4", 0, 0, true},
+		{"0:1", 0, 0, true},
+		{"4:1:2", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			num, den, err := ParseSplitRatio(tt.input)
+			if tt.wantErr {
+				assert.EqualError(t, err, "invalid split ratio \""+tt.input+"\"")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.num, num)
+			assert.Equal(t, tt.den, den)
+		})
+	}
+}
+
+func TestEarnings_AdjustForSplits(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	splits := []Split{
+		{Symbol: "AAPL", Date: date(2014, 6, 9), Numerator: 7, Denominator: 1},
+		{Symbol: "AAPL", Date: date(2020, 8, 31), Numerator: 4, Denominator: 1},
+	}
+	eps := func(v float64) *float64 { return &v }
+
+	reported := date(2014, 4, 23)
+	e := Earnings{
+		Symbol: "AAPL", FiscalYear: 2014, FiscalQuarter: 2, PeriodEnd: date(2014, 3, 29), ReportDate: &reported,
+		EPSBasic: eps(11.69), EPSDiluted: eps(11.62), EPSEstimate: &EPSEstimate{Mean: 10.18, High: 11.2, Low: 9.8, NumAnalysts: 30},
+	}
+	e.ComputeSurprise()
+	pct := *e.EPSSurprisePct
+
+	e.AdjustForSplits(splits)
+	assert.InDelta(t, 11.69/28, *e.EPSBasic, 1e-9)
+	assert.InDelta(t, 11.62/28, *e.EPSDiluted, 1e-9)
+	assert.InDelta(t, 10.18/28, e.EPSEstimate.Mean, 1e-9)
+	assert.InDelta(t, (11.62-10.18)/28, *e.EPSSurprise, 1e-9)
+	assert.InDelta(t, pct, *e.EPSSurprisePct, 1e-9)
+
+	// reported after the 2020 split, in shares of after it
+	reported = date(2020, 10, 29)
+	restated := Earnings{Symbol: "AAPL", PeriodEnd: date(2020, 9, 26), ReportDate: &reported, EPSDiluted: eps(0.73)}
+	restated.AdjustForSplits(splits)
+	assert.Equal(t, 0.73, *restated.EPSDiluted)
+	assert.Nil(t, restated.EPSBasic)
+
+	scheduled := Earnings{Symbol: "AAPL", PeriodEnd: date(2020, 6, 27)}
+	scheduled.AdjustForSplits(splits)
+	assert.Nil(t, scheduled.EPSDiluted)
+
+	t.Run("Restated after a split", func(t *testing.T) {
+		// reported before the 2020 split and restated in shares after it
+		reported, restatedAt := date(2020, 7, 30), date(2020, 10, 30)
+		q3 := Earnings{
+			Symbol: "AAPL", FiscalYear: 2020, FiscalQuarter: 3, PeriodEnd: date(2020, 6, 27), ReportDate: &reported,
+			EPSDiluted: eps(2.58), EPSEstimate: &EPSEstimate{Mean: 2.04, High: 2.3, Low: 1.8, NumAnalysts: 30},
+		}
+		q3.RecordVersion(nil, reported)
+		restated := q3
+		restated.EPSDiluted = eps(0.645)
+		restated.PublishedAt = &restatedAt
+		restated.RecordVersion(&q3, restatedAt)
+
+		current := restated
+		current.AdjustForSplits(splits)
+		assert.Equal(t, 0.645, *current.EPSDiluted, "published after the split")
+		assert.InDelta(t, 0.51, current.EPSEstimate.Mean, 1e-9, "estimated before the split")
+		assert.InDelta(t, 0.135, *current.EPSSurprise, 1e-9)
+
+		original := restated.AsOf(date(2020, 8, 1))
+		original.AdjustForSplits(splits)
+		assert.InDelta(t, 0.645, *original.EPSDiluted, 1e-9, "the original report is in shares before the split")
+		assert.InDelta(t, 0.51, original.EPSEstimate.Mean, 1e-9)
+	})
+}
+
+func TestSplitsBy(t *testing.T) {
+	splits := []Split{
+		{Symbol: "AAPL", Date: time.Date(2020, 8, 31, 0, 0, 0, 0, time.UTC), Numerator: 4, Denominator: 1},
+		{Symbol: "AAPL", Date: time.Date(2014, 6, 9, 0, 0, 0, 0, time.UTC), Numerator: 7, Denominator: 1},
+	}
+	assert.Equal(t, splits, SplitsBy(splits, nil))
+
+	asOf := time.Date(2020, 8, 31, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, splits, SplitsBy(splits, &asOf), "effective on the day")
+	asOf = asOf.AddDate(0, 0, -1)
+	assert.Equal(t, splits[1:], SplitsBy(splits, &asOf))
+	asOf = time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Empty(t, SplitsBy(splits, &asOf))
+}
+
+func TestFinancials_AdjustForSplits(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	splits := []Split{{Symbol: "AAPL", Date: date(2020, 8, 31), Numerator: 4, Denominator: 1}}
+
+	filed := date(2020, 7, 31)
+	f := Financials{
+		CIK: "0000320193", FiscalYear: 2020, FiscalPeriod: FiscalPeriodQ3, PeriodEnd: date(2020, 6, 27),
+		Items: map[string]LineItem{
+			LineItemRevenue:       {Value: 59685e6, Filed: filed, Version: 1},
+			LineItemEPSBasic:      {Value: 2.61, Filed: filed, Version: 1},
+			LineItemSharesDiluted: {Value: 4354e6, Filed: filed, Version: 1},
+			// restated in the 10-K after the split
+			LineItemEPSDiluted: {Value: 0.645, Filed: date(2020, 10, 30), Version: 2},
+		},
+	}
+	filedAsReported := f
+
+	f.AdjustForSplits(splits)
+	assert.Equal(t, 59685e6, *f.Value(LineItemRevenue))
+	assert.InDelta(t, 2.61/4, *f.Value(LineItemEPSBasic), 1e-9)
+	assert.Equal(t, 4354e6*4, *f.Value(LineItemSharesDiluted))
+	assert.Equal(t, 0.645, *f.Value(LineItemEPSDiluted), "filed after the split")
+	assert.Equal(t, 2.61, *filedAsReported.Value(LineItemEPSBasic), "copies keep the values as filed")
+
+	unsplit := filedAsReported
+	unsplit.AdjustForSplits(nil)
+	assert.Equal(t, 2.61, *unsplit.Value(LineItemEPSBasic))
+}
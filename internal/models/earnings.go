@@ -0,0 +1,307 @@
+package models
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// DefaultCurrency is assumed for earnings reported without a currency
+const DefaultCurrency = "USD"
+
+// When in the trading day earnings are released
+const (
+	ReportTimeBeforeOpen   = "before-open"
+	ReportTimeDuringMarket = "during-market"
+	ReportTimeAfterClose   = "after-close"
+	ReportTimeNotAnnounced = "not-announced"
+)
+
+// ReportTimes lists the report times in the order they occur during a day
+var ReportTimes = []string{ReportTimeBeforeOpen, ReportTimeDuringMarket, ReportTimeAfterClose, ReportTimeNotAnnounced}
+
+// IsValidReportTime reports whether reportTime is one of ReportTimes
+func IsValidReportTime(reportTime string) bool {
+	for _, t := range ReportTimes {
+		if t == reportTime {
+			return true
+		}
+	}
+	return false
+}
+
+// Earnings holds the results a company reported for one fiscal quarter.
+// Figures the company did not report are nil rather than zero, and a quarter
+// that is scheduled but not yet reported has no figures at all.
+type Earnings struct {
+	Symbol        string     `json:"symbol" bson:"symbol"`
+	FiscalYear    int        `json:"fiscalYear" bson:"fiscalYear"`
+	FiscalQuarter int        `json:"fiscalQuarter" bson:"fiscalQuarter"`
+	PeriodEnd     time.Time  `json:"periodEnd" bson:"periodEnd"`
+	ReportDate    *time.Time `json:"reportDate" bson:"reportDate"`
+	ReportTime    string     `json:"reportTime" bson:"reportTime"`
+	EPSBasic      *float64   `json:"epsBasic" bson:"epsBasic"`
+	EPSDiluted    *float64   `json:"epsDiluted" bson:"epsDiluted"`
+	Revenue       *float64   `json:"revenue" bson:"revenue"`
+	NetIncome     *float64   `json:"netIncome" bson:"netIncome"`
+	Currency      string     `json:"currency" bson:"currency"`
+	// EPSEstimate is the analyst consensus for the quarter, if any
+	EPSEstimate *EPSEstimate `json:"epsEstimate" bson:"epsEstimate"`
+	// EPSSurprise and EPSSurprisePct compare the diluted EPS with the mean
+	// estimate. They are derived by ComputeSurprise and nil until both exist.
+	EPSSurprise    *float64 `json:"epsSurprise" bson:"epsSurprise"`
+	EPSSurprisePct *float64 `json:"epsSurprisePct" bson:"epsSurprisePct"`
+	// Version counts the publications of the figures, 1 for the original
+	// report and one more for each restatement, and PublishedAt is when the
+	// current figures were published. Both are unset until the quarter is
+	// reported.
+	Version     int        `json:"version" bson:"version"`
+	PublishedAt *time.Time `json:"publishedAt" bson:"publishedAt"`
+	// Versions holds every publication of the figures, oldest first, so the
+	// earnings can be seen as they were known at an earlier date
+	Versions []EarningsVersion `json:"-" bson:"versions,omitempty"`
+	// Writes counts the writes of the stored quarter, so an upsert can tell
+	// whether another one wrote it since it was read
+	Writes int `json:"-" bson:"writes,omitempty"`
+	// RevisionID is the revision the stored quarter was last written with,
+	// which stays pending if the write could not confirm it
+	RevisionID string `json:"-" bson:"revisionId,omitempty"`
+}
+
+// EarningsVersion is the figures of a quarter as published at one time
+type EarningsVersion struct {
+	Version     int       `json:"version" bson:"version"`
+	PublishedAt time.Time `json:"publishedAt" bson:"publishedAt"`
+	EPSBasic    *float64  `json:"epsBasic" bson:"epsBasic"`
+	EPSDiluted  *float64  `json:"epsDiluted" bson:"epsDiluted"`
+	Revenue     *float64  `json:"revenue" bson:"revenue"`
+	NetIncome   *float64  `json:"netIncome" bson:"netIncome"`
+}
+
+// sameFigures reports whether two versions hold the same figures
+func (v EarningsVersion) sameFigures(other EarningsVersion) bool {
+	return equalFloat(v.EPSBasic, other.EPSBasic) && equalFloat(v.EPSDiluted, other.EPSDiluted) &&
+		equalFloat(v.Revenue, other.Revenue) && equalFloat(v.NetIncome, other.NetIncome)
+}
+
+func equalFloat(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// EPSEstimate is the consensus of analyst EPS estimates for a quarter
+type EPSEstimate struct {
+	Mean        float64 `json:"mean" bson:"mean"`
+	High        float64 `json:"high" bson:"high"`
+	Low         float64 `json:"low" bson:"low"`
+	NumAnalysts int     `json:"numAnalysts" bson:"numAnalysts"`
+}
+
+// ComputeSurprise derives the EPS surprise from the diluted EPS and the mean
+// estimate. The percentage is relative to the magnitude of the estimate, so a
+// smaller loss than expected is a positive surprise, and is left nil when the
+// estimate is zero.
+func (e *Earnings) ComputeSurprise() {
+	e.EPSSurprise, e.EPSSurprisePct = nil, nil
+	if e.EPSEstimate == nil || e.EPSDiluted == nil {
+		return
+	}
+	surprise := *e.EPSDiluted - e.EPSEstimate.Mean
+	e.EPSSurprise = &surprise
+	if e.EPSEstimate.Mean != 0 {
+		pct := surprise / math.Abs(e.EPSEstimate.Mean) * 100
+		e.EPSSurprisePct = &pct
+	}
+}
+
+// figures returns the reported figures as an unnumbered version
+func (e *Earnings) figures() EarningsVersion {
+	return EarningsVersion{EPSBasic: e.EPSBasic, EPSDiluted: e.EPSDiluted, Revenue: e.Revenue, NetIncome: e.NetIncome}
+}
+
+// setLatest replaces the figures with those of the last of versions, or
+// clears them when there are none
+func (e *Earnings) setLatest(versions []EarningsVersion) {
+	var v EarningsVersion
+	if len(versions) > 0 {
+		v = versions[len(versions)-1]
+	}
+	e.Versions = versions
+	e.EPSBasic, e.EPSDiluted, e.Revenue, e.NetIncome = v.EPSBasic, v.EPSDiluted, v.Revenue, v.NetIncome
+	e.Version, e.PublishedAt = v.Version, nil
+	if v.Version > 0 {
+		published := v.PublishedAt
+		e.PublishedAt = &published
+	}
+}
+
+// versions returns the publications of the figures. Earnings stored before
+// versions were recorded count as published once, on the report date.
+func (e *Earnings) versions() []EarningsVersion {
+	if len(e.Versions) > 0 || !e.Reported() || e.ReportDate == nil {
+		return e.Versions
+	}
+	v := e.figures()
+	v.Version, v.PublishedAt = 1, *e.ReportDate
+	return []EarningsVersion{v}
+}
+
+// RecordVersion carries over the versions of the stored earnings of the same
+// quarter, which may be nil, and records the figures of e as a new version
+// when they differ from the latest one. The version is published at
+// PublishedAt when it is set and after the latest version, or else on the
+// report date for the original report and now for a restatement. Earnings
+// without figures, such as a rescheduled report date, keep the stored figures,
+// and so do figures published at the time of a version already recorded with
+// them, as a feed replaying its history on every import sends.
+func (e *Earnings) RecordVersion(stored *Earnings, now time.Time) {
+	var versions []EarningsVersion
+	if stored != nil {
+		versions = append(versions, stored.versions()...)
+	}
+
+	n := len(versions)
+	switch {
+	case !e.Reported():
+	case n > 0 && e.figures().sameFigures(versions[n-1]):
+	case e.republishes(versions):
+	default:
+		v := e.figures()
+		v.Version, v.PublishedAt = n+1, now
+		switch {
+		case e.PublishedAt != nil && (n == 0 || e.PublishedAt.After(versions[n-1].PublishedAt)):
+			v.PublishedAt = *e.PublishedAt
+		case n == 0 && e.ReportDate != nil:
+			v.PublishedAt = *e.ReportDate
+		}
+		versions = append(versions, v)
+	}
+	e.setLatest(versions)
+}
+
+// republishes reports whether the figures of e were already recorded as one
+// of versions, published at e.PublishedAt
+func (e *Earnings) republishes(versions []EarningsVersion) bool {
+	if e.PublishedAt == nil {
+		return false
+	}
+	for _, v := range versions {
+		if v.PublishedAt.Equal(*e.PublishedAt) && e.figures().sameFigures(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// KeepStored fills in the estimate and report schedule e leaves unset from
+// the stored earnings of the same quarter, which may be nil, so feeds that
+// carry only estimates or only reported figures can update a quarter without
+// erasing what the other wrote
+func (e *Earnings) KeepStored(stored *Earnings) {
+	if stored == nil {
+		return
+	}
+	if e.EPSEstimate == nil {
+		e.EPSEstimate = stored.EPSEstimate
+	}
+	if e.ReportDate == nil {
+		e.ReportDate = stored.ReportDate
+	}
+	if e.ReportTime == ReportTimeNotAnnounced || e.ReportTime == "" {
+		e.ReportTime = stored.ReportTime
+	}
+}
+
+// AsOf returns the earnings as they were known at the end of day date, with
+// the figures of the latest version published by then and the surprise
+// recomputed from them. Quarters not reported by then have no figures.
+func (e Earnings) AsOf(date time.Time) Earnings {
+	end := date.AddDate(0, 0, 1)
+	var published []EarningsVersion
+	for _, v := range e.versions() {
+		if v.PublishedAt.Before(end) {
+			published = append(published, v)
+		}
+	}
+	e.setLatest(published)
+	e.ComputeSurprise()
+	return e
+}
+
+// Normalize upper-cases the symbol and currency and fills in the default
+// currency and report time
+func (e *Earnings) Normalize() {
+	e.Symbol = strings.ToUpper(strings.TrimSpace(e.Symbol))
+	e.Currency = strings.ToUpper(strings.TrimSpace(e.Currency))
+	if e.Currency == "" {
+		e.Currency = DefaultCurrency
+	}
+	e.ReportTime = strings.ToLower(strings.TrimSpace(e.ReportTime))
+	if e.ReportTime == "" {
+		e.ReportTime = ReportTimeNotAnnounced
+	}
+}
+
+// Reported reports whether any results have been published for the quarter
+func (e *Earnings) Reported() bool {
+	return e.EPSBasic != nil || e.EPSDiluted != nil || e.Revenue != nil || e.NetIncome != nil
+}
+
+// Validate checks the fields identifying the period, the currency code and
+// the estimate
+func (e *Earnings) Validate() error {
+	if e.Symbol == "" {
+		return &FieldError{Field: "symbol", Message: "is required"}
+	}
+	if e.FiscalYear < 1900 || e.FiscalYear > 2999 {
+		return &FieldError{Field: "fiscalYear", Message: "must be a four digit year"}
+	}
+	if e.FiscalQuarter < 1 || e.FiscalQuarter > 4 {
+		return &FieldError{Field: "fiscalQuarter", Message: "must be between 1 and 4"}
+	}
+	if e.PeriodEnd.IsZero() {
+		return &FieldError{Field: "periodEnd", Message: "is required"}
+	}
+	if e.ReportDate != nil && e.ReportDate.Before(e.PeriodEnd) {
+		return &FieldError{Field: "reportDate", Message: "cannot be before periodEnd"}
+	}
+	if e.PublishedAt != nil && e.PublishedAt.Before(e.PeriodEnd) {
+		return &FieldError{Field: "publishedAt", Message: "cannot be before periodEnd"}
+	}
+	if !IsValidReportTime(e.ReportTime) {
+		return &FieldError{Field: "reportTime", Message: "must be one of " + strings.Join(ReportTimes, ", ")}
+	}
+	if !isCurrencyCode(e.Currency) {
+		return &FieldError{Field: "currency", Message: "must be an ISO 4217 currency code"}
+	}
+	if e.EPSEstimate != nil {
+		return e.EPSEstimate.Validate()
+	}
+	return nil
+}
+
+// Validate checks the estimate range is ordered and backed by an analyst
+func (est *EPSEstimate) Validate() error {
+	if est.NumAnalysts < 1 {
+		return &FieldError{Field: "epsEstimate.numAnalysts", Message: "must be at least 1"}
+	}
+	if est.Low > est.Mean || est.Mean > est.High {
+		return &FieldError{Field: "epsEstimate", Message: "must satisfy low <= mean <= high"}
+	}
+	return nil
+}
+
+// isCurrencyCode reports whether code has the shape of an ISO 4217 code
+func isCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,216 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEarnings_Normalize(t *testing.T) {
+	e := Earnings{Symbol: " aapl ", Currency: ""}
+	e.Normalize()
+	assert.Equal(t, "AAPL", e.Symbol)
+	assert.Equal(t, DefaultCurrency, e.Currency)
+	assert.Equal(t, ReportTimeNotAnnounced, e.ReportTime)
+
+	e = Earnings{Symbol: "SAP", Currency: "eur", ReportTime: "Before-Open"}
+	e.Normalize()
+	assert.Equal(t, "EUR", e.Currency)
+	assert.Equal(t, ReportTimeBeforeOpen, e.ReportTime)
+}
+
+func TestEarnings_Reported(t *testing.T) {
+	eps := 1.53
+	assert.False(t, (&Earnings{Symbol: "AAPL"}).Reported())
+	assert.True(t, (&Earnings{Symbol: "AAPL", EPSDiluted: &eps}).Reported())
+}
+
+func TestEarnings_Validate(t *testing.T) {
+	periodEnd := time.Date(2023, 12, 30, 0, 0, 0, 0, time.UTC)
+	early := periodEnd.AddDate(0, 0, -1)
+	valid := Earnings{Symbol: "AAPL", FiscalYear: 2024, FiscalQuarter: 1, PeriodEnd: periodEnd, ReportTime: ReportTimeAfterClose, Currency: "USD"}
+
+	tests := []struct {
+		name   string
+		modify func(*Earnings)
+		field  string
+	}{
+		{"Valid", func(e *Earnings) {}, ""},
+		{"Missing symbol", func(e *Earnings) { e.Symbol = "" }, "symbol"},
+		{"Two digit year", func(e *Earnings) { e.FiscalYear = 24 }, "fiscalYear"},
+		{"Quarter zero", func(e *Earnings) { e.FiscalQuarter = 0 }, "fiscalQuarter"},
+		{"Quarter five", func(e *Earnings) { e.FiscalQuarter = 5 }, "fiscalQuarter"},
+		{"Missing period end", func(e *Earnings) { e.PeriodEnd = time.Time{} }, "periodEnd"},
+		{"Reported before period end", func(e *Earnings) { e.ReportDate = &early }, "reportDate"},
+		{"Invalid report time", func(e *Earnings) { e.ReportTime = "lunch" }, "reportTime"},
+		{"Invalid currency", func(e *Earnings) { e.Currency = "US$" }, "currency"},
+		{"Estimate", func(e *Earnings) { e.EPSEstimate = &EPSEstimate{Mean: 2.1, High: 2.3, Low: 1.9, NumAnalysts: 28} }, ""},
+		{"Estimate without analysts", func(e *Earnings) { e.EPSEstimate = &EPSEstimate{Mean: 2.1, High: 2.1, Low: 2.1} }, "epsEstimate.numAnalysts"},
+		{"Estimate out of range", func(e *Earnings) { e.EPSEstimate = &EPSEstimate{Mean: 2.4, High: 2.3, Low: 1.9, NumAnalysts: 28} }, "epsEstimate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := valid
+			tt.modify(&e)
+			err := e.Validate()
+			if tt.field == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.IsType(t, &FieldError{}, err) {
+				assert.Equal(t, tt.field, err.(*FieldError).Field)
+			}
+		})
+	}
+}
+
+func TestEarnings_ComputeSurprise(t *testing.T) {
+	tests := []struct {
+		name        string
+		actual      *float64
+		estimate    *EPSEstimate
+		surprise    *float64
+		surprisePct *float64
+	}{
+		{"Beat", float64Ptr(2.18), &EPSEstimate{Mean: 2.10, NumAnalysts: 1}, float64Ptr(0.08), float64Ptr(3.8095)},
+		{"Miss", float64Ptr(1.50), &EPSEstimate{Mean: 2.00, NumAnalysts: 1}, float64Ptr(-0.5), float64Ptr(-25)},
+		{"Smaller loss than expected", float64Ptr(-0.10), &EPSEstimate{Mean: -0.20, NumAnalysts: 1}, float64Ptr(0.1), float64Ptr(50)},
+		{"Zero estimate", float64Ptr(0.05), &EPSEstimate{Mean: 0, NumAnalysts: 1}, float64Ptr(0.05), nil},
+		{"Not reported", nil, &EPSEstimate{Mean: 2.00, NumAnalysts: 1}, nil, nil},
+		{"No estimate", float64Ptr(2.18), nil, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := Earnings{EPSDiluted: tt.actual, EPSEstimate: tt.estimate, EPSSurprise: float64Ptr(9), EPSSurprisePct: float64Ptr(9)}
+			e.ComputeSurprise()
+			assertFloatPtr(t, tt.surprise, e.EPSSurprise)
+			assertFloatPtr(t, tt.surprisePct, e.EPSSurprisePct)
+		})
+	}
+}
+
+func TestEarnings_RecordVersion(t *testing.T) {
+	periodEnd := time.Date(2023, 12, 30, 0, 0, 0, 0, time.UTC)
+	reported := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+	quarter := func(eps *float64) Earnings {
+		return Earnings{Symbol: "AAPL", FiscalYear: 2024, FiscalQuarter: 1, PeriodEnd: periodEnd, ReportDate: &reported, EPSDiluted: eps}
+	}
+
+	original := quarter(float64Ptr(2.18))
+	original.RecordVersion(nil, now)
+	assert.Equal(t, 1, original.Version)
+	assert.Equal(t, reported, *original.PublishedAt, "the original report is published on the report date")
+	assert.Len(t, original.Versions, 1)
+
+	unchanged := quarter(float64Ptr(2.18))
+	unchanged.RecordVersion(&original, now)
+	assert.Equal(t, 1, unchanged.Version)
+	assert.Len(t, unchanged.Versions, 1)
+
+	restated := quarter(float64Ptr(2.10))
+	restated.RecordVersion(&original, now)
+	assert.Equal(t, 2, restated.Version)
+	assert.Equal(t, now, *restated.PublishedAt, "a restatement is published now")
+	assert.Equal(t, 2.10, *restated.EPSDiluted)
+
+	filed := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	dated := quarter(float64Ptr(2.10))
+	dated.PublishedAt = &filed
+	dated.RecordVersion(&original, now)
+	assert.Equal(t, filed, *dated.PublishedAt)
+
+	stale := quarter(float64Ptr(2.10))
+	stale.PublishedAt = &periodEnd
+	stale.RecordVersion(&original, now)
+	assert.Equal(t, now, *stale.PublishedAt, "a publication before the latest version is ignored")
+
+	replayed := quarter(float64Ptr(2.18))
+	replayed.PublishedAt = &reported
+	replayed.RecordVersion(&dated, now)
+	assert.Equal(t, 2, replayed.Version, "a recorded publication is not recorded again")
+	assert.Equal(t, 2.10, *replayed.EPSDiluted)
+
+	rescheduled := quarter(nil)
+	rescheduled.RecordVersion(&restated, now)
+	assert.Equal(t, 2, rescheduled.Version)
+	assert.Equal(t, 2.10, *rescheduled.EPSDiluted, "the stored figures are kept")
+
+	legacy := quarter(float64Ptr(2.18))
+	legacyRestated := quarter(float64Ptr(2.10))
+	legacyRestated.RecordVersion(&legacy, now)
+	assert.Equal(t, 2, legacyRestated.Version, "earnings without versions count as published once")
+	assert.Equal(t, reported, legacyRestated.Versions[0].PublishedAt)
+}
+
+func TestEarnings_KeepStored(t *testing.T) {
+	reported := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	estimate := &EPSEstimate{Mean: 2.10, High: 2.22, Low: 1.95, NumAnalysts: 28}
+	stored := Earnings{Symbol: "AAPL", ReportDate: &reported, ReportTime: ReportTimeAfterClose, EPSEstimate: estimate}
+
+	actuals := Earnings{Symbol: "AAPL", ReportTime: ReportTimeNotAnnounced, EPSDiluted: float64Ptr(2.18)}
+	actuals.KeepStored(&stored)
+	assert.Equal(t, estimate, actuals.EPSEstimate, "reported figures keep the estimate")
+	assert.Equal(t, &reported, actuals.ReportDate)
+	assert.Equal(t, ReportTimeAfterClose, actuals.ReportTime)
+
+	revised := &EPSEstimate{Mean: 2.12, High: 2.22, Low: 1.95, NumAnalysts: 29}
+	rescheduled := time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)
+	update := Earnings{Symbol: "AAPL", ReportDate: &rescheduled, ReportTime: ReportTimeBeforeOpen, EPSEstimate: revised}
+	update.KeepStored(&stored)
+	assert.Equal(t, revised, update.EPSEstimate)
+	assert.Equal(t, &rescheduled, update.ReportDate)
+	assert.Equal(t, ReportTimeBeforeOpen, update.ReportTime)
+
+	first := Earnings{Symbol: "AAPL"}
+	first.KeepStored(nil)
+	assert.Nil(t, first.EPSEstimate)
+}
+
+func TestEarnings_AsOf(t *testing.T) {
+	periodEnd := time.Date(2023, 12, 30, 0, 0, 0, 0, time.UTC)
+	reported := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	restatedAt := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+	e := Earnings{
+		Symbol: "AAPL", FiscalYear: 2024, FiscalQuarter: 1, PeriodEnd: periodEnd, ReportDate: &reported,
+		EPSDiluted: float64Ptr(2.18), EPSEstimate: &EPSEstimate{Mean: 2.00, NumAnalysts: 1},
+	}
+	e.RecordVersion(nil, reported)
+	restated := e
+	restated.EPSDiluted = float64Ptr(2.10)
+	restated.RecordVersion(&e, restatedAt)
+
+	before := restated.AsOf(time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC))
+	assert.False(t, before.Reported())
+	assert.Equal(t, 0, before.Version)
+	assert.Nil(t, before.PublishedAt)
+	assert.Nil(t, before.EPSSurprise)
+
+	original := restated.AsOf(reported)
+	assert.Equal(t, 1, original.Version)
+	assert.Equal(t, 2.18, *original.EPSDiluted)
+	assertFloatPtr(t, float64Ptr(0.18), original.EPSSurprise)
+
+	latest := restated.AsOf(restatedAt)
+	assert.Equal(t, 2, latest.Version)
+	assertFloatPtr(t, float64Ptr(0.10), latest.EPSSurprise)
+	assert.Equal(t, 2.10, *restated.EPSDiluted, "the receiver is unchanged")
+}
+
+func float64Ptr(f float64) *float64 {
+	return &f
+}
+
+func assertFloatPtr(t *testing.T, want, got *float64) {
+	t.Helper()
+	if want == nil {
+		assert.Nil(t, got)
+		return
+	}
+	if assert.NotNil(t, got) {
+		assert.InDelta(t, *want, *got, 0.0001)
+	}
+}
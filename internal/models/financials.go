@@ -0,0 +1,264 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Fiscal periods of financial statements. Quarters cover three months and
+// FY the whole fiscal year.
+const (
+	FiscalPeriodQ1 = "Q1"
+	FiscalPeriodQ2 = "Q2"
+	FiscalPeriodQ3 = "Q3"
+	FiscalPeriodQ4 = "Q4"
+	FiscalPeriodFY = "FY"
+)
+
+// FiscalPeriods lists the fiscal periods in the order they end
+var FiscalPeriods = []string{FiscalPeriodQ1, FiscalPeriodQ2, FiscalPeriodQ3, FiscalPeriodQ4, FiscalPeriodFY}
+
+// IsValidFiscalPeriod reports whether period is one of FiscalPeriods
+func IsValidFiscalPeriod(period string) bool {
+	for _, p := range FiscalPeriods {
+		if p == period {
+			return true
+		}
+	}
+	return false
+}
+
+// Line items of the normalized financial statements. Each is reported in the
+// unit of its LineItemDefinition.
+const (
+	LineItemRevenue                  = "revenue"
+	LineItemCostOfRevenue            = "costOfRevenue"
+	LineItemGrossProfit              = "grossProfit"
+	LineItemOperatingExpenses        = "operatingExpenses"
+	LineItemOperatingIncome          = "operatingIncome"
+	LineItemIncomeTaxExpense         = "incomeTaxExpense"
+	LineItemNetIncome                = "netIncome"
+	LineItemEPSBasic                 = "epsBasic"
+	LineItemEPSDiluted               = "epsDiluted"
+	LineItemSharesDiluted            = "sharesDiluted"
+	LineItemCash                     = "cash"
+	LineItemCurrentAssets            = "currentAssets"
+	LineItemAssets                   = "assets"
+	LineItemCurrentLiabilities       = "currentLiabilities"
+	LineItemLiabilities              = "liabilities"
+	LineItemLongTermDebt             = "longTermDebt"
+	LineItemStockholdersEquity       = "stockholdersEquity"
+	LineItemOperatingCashFlow        = "operatingCashFlow"
+	LineItemInvestingCashFlow        = "investingCashFlow"
+	LineItemFinancingCashFlow        = "financingCashFlow"
+	LineItemCapitalExpenditure       = "capitalExpenditure"
+	LineItemDepreciationAmortization = "depreciationAmortization"
+	LineItemDividendsPaid            = "dividendsPaid"
+)
+
+// Statements a line item can belong to
+const (
+	StatementIncome   = "income"
+	StatementBalance  = "balance"
+	StatementCashFlow = "cashflow"
+)
+
+// Statements lists the statements in the order they are usually presented
+var Statements = []string{StatementIncome, StatementBalance, StatementCashFlow}
+
+// Units of line item values
+const (
+	UnitCurrency         = "currency"
+	UnitCurrencyPerShare = "currency/share"
+	UnitShares           = "shares"
+)
+
+// LineItemDefinition describes a line item and the us-gaap concepts it is
+// read from, in order of preference
+type LineItemDefinition struct {
+	Name      string
+	Statement string
+	Unit      string
+	Concepts  []string
+}
+
+// LineItems defines the normalized line items in statement order
+var LineItems = []LineItemDefinition{
+	{LineItemRevenue, StatementIncome, UnitCurrency, []string{"Revenues", "RevenueFromContractWithCustomerExcludingAssessedTax", "SalesRevenueNet"}},
+	{LineItemCostOfRevenue, StatementIncome, UnitCurrency, []string{"CostOfRevenue", "CostOfGoodsAndServicesSold"}},
+	{LineItemGrossProfit, StatementIncome, UnitCurrency, []string{"GrossProfit"}},
+	{LineItemOperatingExpenses, StatementIncome, UnitCurrency, []string{"OperatingExpenses"}},
+	{LineItemOperatingIncome, StatementIncome, UnitCurrency, []string{"OperatingIncomeLoss"}},
+	{LineItemIncomeTaxExpense, StatementIncome, UnitCurrency, []string{"IncomeTaxExpenseBenefit"}},
+	{LineItemNetIncome, StatementIncome, UnitCurrency, []string{"NetIncomeLoss"}},
+	{LineItemEPSBasic, StatementIncome, UnitCurrencyPerShare, []string{"EarningsPerShareBasic"}},
+	{LineItemEPSDiluted, StatementIncome, UnitCurrencyPerShare, []string{"EarningsPerShareDiluted"}},
+	{LineItemSharesDiluted, StatementIncome, UnitShares, []string{"WeightedAverageNumberOfDilutedSharesOutstanding"}},
+	{LineItemCash, StatementBalance, UnitCurrency, []string{"CashAndCashEquivalentsAtCarryingValue"}},
+	{LineItemCurrentAssets, StatementBalance, UnitCurrency, []string{"AssetsCurrent"}},
+	{LineItemAssets, StatementBalance, UnitCurrency, []string{"Assets"}},
+	{LineItemCurrentLiabilities, StatementBalance, UnitCurrency, []string{"LiabilitiesCurrent"}},
+	{LineItemLiabilities, StatementBalance, UnitCurrency, []string{"Liabilities"}},
+	{LineItemLongTermDebt, StatementBalance, UnitCurrency, []string{"LongTermDebtNoncurrent", "LongTermDebt"}},
+	{LineItemStockholdersEquity, StatementBalance, UnitCurrency, []string{"StockholdersEquity"}},
+	{LineItemOperatingCashFlow, StatementCashFlow, UnitCurrency, []string{"NetCashProvidedByUsedInOperatingActivities"}},
+	{LineItemInvestingCashFlow, StatementCashFlow, UnitCurrency, []string{"NetCashProvidedByUsedInInvestingActivities"}},
+	{LineItemFinancingCashFlow, StatementCashFlow, UnitCurrency, []string{"NetCashProvidedByUsedInFinancingActivities"}},
+	{LineItemCapitalExpenditure, StatementCashFlow, UnitCurrency, []string{"PaymentsToAcquirePropertyPlantAndEquipment"}},
+	{LineItemDepreciationAmortization, StatementCashFlow, UnitCurrency, []string{"DepreciationDepletionAndAmortization", "DepreciationAndAmortization"}},
+	{LineItemDividendsPaid, StatementCashFlow, UnitCurrency, []string{"PaymentsOfDividends", "PaymentsOfDividendsCommonStock"}},
+}
+
+// lineItemUnits are the units of the line items by name
+var lineItemUnits = func() map[string]string {
+	units := make(map[string]string, len(LineItems))
+	for _, def := range LineItems {
+		units[def.Name] = def.Unit
+	}
+	return units
+}()
+
+// StatementLineItems returns the line items of a statement in statement order
+func StatementLineItems(statement string) []LineItemDefinition {
+	var items []LineItemDefinition
+	for _, def := range LineItems {
+		if def.Statement == statement {
+			items = append(items, def)
+		}
+	}
+	return items
+}
+
+// Financials holds the normalized statement line items of a company for one
+// fiscal period, keyed by the company's CIK. Balance sheet items are the
+// values at PeriodEnd; the other items cover PeriodStart to PeriodEnd.
+type Financials struct {
+	CIK          string     `json:"cik" bson:"cik"`
+	FiscalYear   int        `json:"fiscalYear" bson:"fiscalYear"`
+	FiscalPeriod string     `json:"fiscalPeriod" bson:"fiscalPeriod"`
+	PeriodStart  *time.Time `json:"periodStart" bson:"periodStart"`
+	PeriodEnd    time.Time  `json:"periodEnd" bson:"periodEnd"`
+	Currency     string     `json:"currency" bson:"currency"`
+	// Filed is when the first line item of the period was filed
+	Filed time.Time           `json:"filed" bson:"filed"`
+	Items map[string]LineItem `json:"items" bson:"items"`
+	// Writes counts the writes of the stored period, so an upsert can tell
+	// whether another one wrote it since it was read
+	Writes int `json:"-" bson:"writes,omitempty"`
+	// RevisionID is the revision the stored period was last written with,
+	// which stays pending if the write could not confirm it
+	RevisionID string `json:"-" bson:"revisionId,omitempty"`
+}
+
+// LineItem is a reported value together with the filing that first reported
+// it. Version counts the values filings reported for the item, 1 unless it
+// was restated, and Versions holds each of them, oldest first, so the item
+// can be seen as it was known at an earlier date.
+type LineItem struct {
+	Value float64 `json:"value" bson:"value"`
+	// Concept is the us-gaap concept the value was reported under
+	Concept   string    `json:"concept" bson:"concept"`
+	Form      string    `json:"form" bson:"form"`
+	Accession string    `json:"accession" bson:"accession"`
+	Filed     time.Time `json:"filed" bson:"filed"`
+	Version   int       `json:"version" bson:"version"`
+	// Derived is set for quarterly values computed from figures reported
+	// year to date or for the fiscal year, less the quarters before
+	Derived  bool              `json:"derived,omitempty" bson:"derived,omitempty"`
+	Versions []LineItemVersion `json:"-" bson:"versions,omitempty"`
+}
+
+// LineItemVersion is a value of a line item and the filing that first
+// reported it
+type LineItemVersion struct {
+	Value     float64   `json:"value" bson:"value"`
+	Concept   string    `json:"concept" bson:"concept"`
+	Form      string    `json:"form" bson:"form"`
+	Accession string    `json:"accession" bson:"accession"`
+	Filed     time.Time `json:"filed" bson:"filed"`
+	Derived   bool      `json:"derived,omitempty" bson:"derived,omitempty"`
+}
+
+// NewLineItem returns the line item holding the last of versions, which must
+// not be empty
+func NewLineItem(versions []LineItemVersion) LineItem {
+	v := versions[len(versions)-1]
+	return LineItem{
+		Value:     v.Value,
+		Concept:   v.Concept,
+		Form:      v.Form,
+		Accession: v.Accession,
+		Filed:     v.Filed,
+		Derived:   v.Derived,
+		Version:   len(versions),
+		Versions:  versions,
+	}
+}
+
+// versions returns the values reported for the item. Items stored before
+// versions were recorded have only their current value.
+func (item *LineItem) versions() []LineItemVersion {
+	if len(item.Versions) > 0 {
+		return item.Versions
+	}
+	return []LineItemVersion{{Value: item.Value, Concept: item.Concept, Form: item.Form, Accession: item.Accession, Filed: item.Filed, Derived: item.Derived}}
+}
+
+// Annual reports whether the financials cover a whole fiscal year
+func (f *Financials) Annual() bool {
+	return f.FiscalPeriod == FiscalPeriodFY
+}
+
+// AsOf returns the financials as they were known at the end of day date: each
+// line item holds the latest value filed by then, and items first filed later
+// are left out. It reports false when no item had been filed.
+func (f Financials) AsOf(date time.Time) (Financials, bool) {
+	end := date.AddDate(0, 0, 1)
+	items := make(map[string]LineItem, len(f.Items))
+	for name, item := range f.Items {
+		var filed []LineItemVersion
+		for _, v := range item.versions() {
+			if v.Filed.Before(end) {
+				filed = append(filed, v)
+			}
+		}
+		if len(filed) > 0 {
+			items[name] = NewLineItem(filed)
+		}
+	}
+	f.Items = items
+	return f, len(items) > 0
+}
+
+// Value returns the value of a line item, or nil when it was not reported
+func (f *Financials) Value(name string) *float64 {
+	item, ok := f.Items[name]
+	if !ok {
+		return nil
+	}
+	v := item.Value
+	return &v
+}
+
+// Validate checks the fields identifying the period and the currency code
+func (f *Financials) Validate() error {
+	if _, err := NormalizeCIK(f.CIK); err != nil {
+		return &FieldError{Field: "cik", Message: "must be a number of at most 10 digits"}
+	}
+	if f.FiscalYear < 1900 || f.FiscalYear > 2999 {
+		return &FieldError{Field: "fiscalYear", Message: "must be a four digit year"}
+	}
+	if !IsValidFiscalPeriod(f.FiscalPeriod) {
+		return &FieldError{Field: "fiscalPeriod", Message: "must be one of " + strings.Join(FiscalPeriods, ", ")}
+	}
+	if f.PeriodEnd.IsZero() {
+		return &FieldError{Field: "periodEnd", Message: "is required"}
+	}
+	if f.PeriodStart != nil && !f.PeriodStart.Before(f.PeriodEnd) {
+		return &FieldError{Field: "periodStart", Message: "must be before periodEnd"}
+	}
+	if !isCurrencyCode(f.Currency) {
+		return &FieldError{Field: "currency", Message: "must be an ISO 4217 currency code"}
+	}
+	return nil
+}
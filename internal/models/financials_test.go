@@ -0,0 +1,107 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLineItems(t *testing.T) {
+	names := make(map[string]bool)
+	concepts := make(map[string]string)
+	for _, def := range LineItems {
+		assert.False(t, names[def.Name], "duplicate line item %s", def.Name)
+		names[def.Name] = true
+		assert.Contains(t, Statements, def.Statement, def.Name)
+		assert.NotEmpty(t, def.Concepts, def.Name)
+		for _, c := range def.Concepts {
+			assert.Empty(t, concepts[c], "concept %s is mapped to both %s and %s", c, concepts[c], def.Name)
+			concepts[c] = def.Name
+		}
+	}
+}
+
+func TestStatementLineItems(t *testing.T) {
+	var names []string
+	for _, def := range StatementLineItems(StatementBalance) {
+		names = append(names, def.Name)
+	}
+	assert.Equal(t, []string{
+		LineItemCash, LineItemCurrentAssets, LineItemAssets, LineItemCurrentLiabilities,
+		LineItemLiabilities, LineItemLongTermDebt, LineItemStockholdersEquity,
+	}, names)
+	assert.Empty(t, StatementLineItems("equity"))
+}
+
+func TestFinancials_Value(t *testing.T) {
+	f := Financials{Items: map[string]LineItem{LineItemRevenue: {Value: 119575000000}}}
+	assert.Equal(t, 119575000000.0, *f.Value(LineItemRevenue))
+	assert.Nil(t, f.Value(LineItemNetIncome))
+}
+
+func TestFinancials_AsOf(t *testing.T) {
+	q1 := time.Date(2023, 2, 3, 0, 0, 0, 0, time.UTC)
+	tenK := time.Date(2023, 11, 3, 0, 0, 0, 0, time.UTC)
+	f := Financials{Items: map[string]LineItem{
+		LineItemNetIncome: NewLineItem([]LineItemVersion{
+			{Value: 29998000000, Form: "10-Q", Filed: q1},
+			{Value: 29961000000, Form: "10-K", Filed: tenK},
+		}),
+		LineItemDepreciationAmortization: NewLineItem([]LineItemVersion{{Value: 2916000000, Form: "10-K", Filed: tenK}}),
+		LineItemRevenue:                  {Value: 117154000000, Form: "10-Q", Filed: q1},
+	}}
+
+	_, ok := f.AsOf(q1.AddDate(0, 0, -1))
+	assert.False(t, ok)
+
+	original, ok := f.AsOf(q1)
+	require.True(t, ok)
+	assert.Equal(t, 29998000000.0, *original.Value(LineItemNetIncome))
+	assert.Equal(t, 1, original.Items[LineItemNetIncome].Version)
+	assert.Equal(t, 117154000000.0, *original.Value(LineItemRevenue), "items without versions count as filed once")
+	assert.Nil(t, original.Value(LineItemDepreciationAmortization))
+
+	restated, ok := f.AsOf(tenK)
+	require.True(t, ok)
+	assert.Equal(t, 29961000000.0, *restated.Value(LineItemNetIncome))
+	assert.Equal(t, 2, restated.Items[LineItemNetIncome].Version)
+	assert.NotNil(t, restated.Value(LineItemDepreciationAmortization))
+}
+
+func TestFinancials_Validate(t *testing.T) {
+	periodEnd := time.Date(2023, 12, 30, 0, 0, 0, 0, time.UTC)
+	periodStart := time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC)
+	valid := Financials{CIK: "0000320193", FiscalYear: 2024, FiscalPeriod: FiscalPeriodQ1, PeriodStart: &periodStart, PeriodEnd: periodEnd, Currency: "USD"}
+
+	tests := []struct {
+		name   string
+		modify func(*Financials)
+		field  string
+	}{
+		{"Valid", func(f *Financials) {}, ""},
+		{"Balance sheet only", func(f *Financials) { f.PeriodStart = nil }, ""},
+		{"Invalid CIK", func(f *Financials) { f.CIK = "AAPL" }, "cik"},
+		{"Invalid fiscal year", func(f *Financials) { f.FiscalYear = 24 }, "fiscalYear"},
+		{"Invalid fiscal period", func(f *Financials) { f.FiscalPeriod = "H1" }, "fiscalPeriod"},
+		{"Missing period end", func(f *Financials) { f.PeriodEnd = time.Time{} }, "periodEnd"},
+		{"Start after end", func(f *Financials) { f.PeriodStart = &periodEnd }, "periodStart"},
+		{"Invalid currency", func(f *Financials) { f.Currency = "usd" }, "currency"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := valid
+			tt.modify(&f)
+			err := f.Validate()
+			if tt.field == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.IsType(t, &FieldError{}, err) {
+				assert.Equal(t, tt.field, err.(*FieldError).Field)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package models
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Records revisions are kept for
+const (
+	RecordEarnings   = "earnings"
+	RecordFinancials = "financials"
+)
+
+// Revision records one change to the stored earnings of a quarter or the
+// financials of a fiscal period. Revisions are only ever appended, so the
+// revisions of a record explain every value it has held.
+type Revision struct {
+	ID     string `json:"-" bson:"_id,omitempty"`
+	Record string `json:"record" bson:"record"`
+	// Symbol keys earnings revisions and CIK financials revisions
+	Symbol       string `json:"symbol,omitempty" bson:"symbol,omitempty"`
+	CIK          string `json:"cik,omitempty" bson:"cik,omitempty"`
+	FiscalYear   int    `json:"fiscalYear" bson:"fiscalYear"`
+	FiscalPeriod string `json:"fiscalPeriod" bson:"fiscalPeriod"`
+	// Source names what wrote the change, such as an importer
+	Source     string        `json:"source" bson:"source"`
+	RecordedAt time.Time     `json:"recordedAt" bson:"recordedAt"`
+	Changes    []FieldChange `json:"changes" bson:"changes"`
+	// Pending is set until the record is written with the change, so a
+	// revision whose record write failed never shows in the log
+	Pending bool `json:"-" bson:"pending,omitempty"`
+}
+
+// FieldChange is the old and new value of a field. Old is nil when the field
+// was first set and New when it was removed.
+type FieldChange struct {
+	Field string   `json:"field" bson:"field"`
+	Old   *float64 `json:"old" bson:"old"`
+	New   *float64 `json:"new" bson:"new"`
+	// Accession is the filing that reported a new financials value
+	Accession string `json:"accession,omitempty" bson:"accession,omitempty"`
+}
+
+// appendChange appends the change of field to changes when its values differ
+func appendChange(changes []FieldChange, field string, before, after *float64) []FieldChange {
+	if equalFloat(before, after) {
+		return changes
+	}
+	return append(changes, FieldChange{Field: field, Old: before, New: after})
+}
+
+// Changes returns the figures and estimates of e that differ from the stored
+// earnings of the quarter, which are nil when the quarter is new
+func (e *Earnings) Changes(stored *Earnings) []FieldChange {
+	if stored == nil {
+		stored = &Earnings{}
+	}
+	var changes []FieldChange
+	changes = appendChange(changes, "epsBasic", stored.EPSBasic, e.EPSBasic)
+	changes = appendChange(changes, "epsDiluted", stored.EPSDiluted, e.EPSDiluted)
+	changes = appendChange(changes, "revenue", stored.Revenue, e.Revenue)
+	changes = appendChange(changes, "netIncome", stored.NetIncome, e.NetIncome)
+
+	before, after := stored.EPSEstimate.values(), e.EPSEstimate.values()
+	for i, field := range estimateFields {
+		changes = appendChange(changes, field, before[i], after[i])
+	}
+	return changes
+}
+
+// estimateFields name the values of an EPSEstimate in a FieldChange
+var estimateFields = []string{"epsEstimate.mean", "epsEstimate.high", "epsEstimate.low", "epsEstimate.numAnalysts"}
+
+// values returns the values of the estimate in estimateFields order, all nil
+// when there is no estimate
+func (est *EPSEstimate) values() []*float64 {
+	if est == nil {
+		return make([]*float64, len(estimateFields))
+	}
+	numAnalysts := float64(est.NumAnalysts)
+	return []*float64{&est.Mean, &est.High, &est.Low, &numAnalysts}
+}
+
+// Revision returns the revision of e recording changes
+func (e *Earnings) Revision(changes []FieldChange, source string, recordedAt time.Time) Revision {
+	return Revision{
+		Record:       RecordEarnings,
+		Symbol:       e.Symbol,
+		FiscalYear:   e.FiscalYear,
+		FiscalPeriod: quarterPeriod(e.FiscalQuarter),
+		Source:       source,
+		RecordedAt:   recordedAt,
+		Changes:      changes,
+	}
+}
+
+// Changes returns the line items of f whose values differ from the stored
+// financials of the period, which are nil when the period is new. Changes
+// are in statement order.
+func (f *Financials) Changes(stored *Financials) []FieldChange {
+	if stored == nil {
+		stored = &Financials{}
+	}
+	var changes []FieldChange
+	for _, def := range LineItems {
+		n := len(changes)
+		changes = appendChange(changes, def.Name, stored.Value(def.Name), f.Value(def.Name))
+		if len(changes) > n {
+			changes[n].Accession = f.Items[def.Name].Accession
+		}
+	}
+	return changes
+}
+
+// Revision returns the revision of f recording changes
+func (f *Financials) Revision(changes []FieldChange, source string, recordedAt time.Time) Revision {
+	return Revision{
+		Record:       RecordFinancials,
+		CIK:          f.CIK,
+		FiscalYear:   f.FiscalYear,
+		FiscalPeriod: f.FiscalPeriod,
+		Source:       source,
+		RecordedAt:   recordedAt,
+		Changes:      changes,
+	}
+}
+
+// quarterPeriod returns the fiscal period of a fiscal quarter
+func quarterPeriod(fiscalQuarter int) string {
+	return "Q" + strconv.Itoa(fiscalQuarter)
+}
+
+// ErrInvalidPeriod is returned by ParsePeriod for malformed periods
+var ErrInvalidPeriod = errors.New("period must be a fiscal year and period such as 2024Q1 or 2023FY")
+
+// ParsePeriod parses a fiscal period written as the fiscal year followed by
+// the period, such as 2024Q1 or 2023FY
+func ParsePeriod(s string) (fiscalYear int, fiscalPeriod string, err error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) != 6 {
+		return 0, "", ErrInvalidPeriod
+	}
+	fiscalYear, err = strconv.Atoi(s[:4])
+	if err != nil || fiscalYear < 1900 || !IsValidFiscalPeriod(s[4:]) {
+		return 0, "", ErrInvalidPeriod
+	}
+	return fiscalYear, s[4:], nil
+}
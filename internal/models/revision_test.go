@@ -0,0 +1,79 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEarnings_Changes(t *testing.T) {
+	stored := Earnings{
+		Symbol: "AAPL", FiscalYear: 2024, FiscalQuarter: 1,
+		EPSDiluted: float64Ptr(2.18), Revenue: float64Ptr(119575000000),
+		EPSEstimate: &EPSEstimate{Mean: 2.10, High: 2.22, Low: 1.95, NumAnalysts: 28},
+	}
+
+	assert.Empty(t, stored.Changes(&stored))
+
+	revised := stored
+	revised.EPSDiluted = float64Ptr(2.10)
+	revised.EPSEstimate = &EPSEstimate{Mean: 2.12, High: 2.22, Low: 1.95, NumAnalysts: 29}
+	assert.Equal(t, []FieldChange{
+		{Field: "epsDiluted", Old: float64Ptr(2.18), New: float64Ptr(2.10)},
+		{Field: "epsEstimate.mean", Old: float64Ptr(2.10), New: float64Ptr(2.12)},
+		{Field: "epsEstimate.numAnalysts", Old: float64Ptr(28), New: float64Ptr(29)},
+	}, revised.Changes(&stored))
+
+	withdrawn := stored
+	withdrawn.EPSEstimate = nil
+	assert.Len(t, withdrawn.Changes(&stored), 4, "every estimate value is removed")
+
+	created := stored.Changes(nil)
+	require.Len(t, created, 6)
+	assert.Nil(t, created[0].Old)
+
+	revision := revised.Revision(revised.Changes(&stored), "test", time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, RecordEarnings, revision.Record)
+	assert.Equal(t, "Q1", revision.FiscalPeriod)
+}
+
+func TestFinancials_Changes(t *testing.T) {
+	stored := Financials{CIK: "0000320193", FiscalYear: 2023, FiscalPeriod: FiscalPeriodFY, Items: map[string]LineItem{
+		LineItemNetIncome: {Value: 96995000000, Accession: "0000320193-23-000106"},
+		LineItemAssets:    {Value: 352755000000, Accession: "0000320193-23-000106"},
+	}}
+	restated := Financials{CIK: "0000320193", FiscalYear: 2023, FiscalPeriod: FiscalPeriodFY, Items: map[string]LineItem{
+		LineItemRevenue:   {Value: 383285000000, Accession: "0000320193-24-000006"},
+		LineItemNetIncome: {Value: 96995000000, Accession: "0000320193-23-000106"},
+		LineItemAssets:    {Value: 352583000000, Accession: "0000320193-24-000006"},
+	}}
+
+	assert.Equal(t, []FieldChange{
+		{Field: LineItemRevenue, New: float64Ptr(383285000000), Accession: "0000320193-24-000006"},
+		{Field: LineItemAssets, Old: float64Ptr(352755000000), New: float64Ptr(352583000000), Accession: "0000320193-24-000006"},
+	}, restated.Changes(&stored), "changes are in statement order")
+	assert.Equal(t, []FieldChange{{Field: LineItemRevenue, Old: float64Ptr(383285000000)}}, stored.Changes(&Financials{Items: map[string]LineItem{
+		LineItemRevenue:   {Value: 383285000000},
+		LineItemNetIncome: {Value: 96995000000},
+		LineItemAssets:    {Value: 352755000000},
+	}}), "removed items have no filing")
+}
+
+func TestParsePeriod(t *testing.T) {
+	year, period, err := ParsePeriod("2024q1")
+	require.NoError(t, err)
+	assert.Equal(t, 2024, year)
+	assert.Equal(t, FiscalPeriodQ1, period)
+
+	year, period, err = ParsePeriod("2023FY")
+	require.NoError(t, err)
+	assert.Equal(t, 2023, year)
+	assert.Equal(t, FiscalPeriodFY, period)
+
+	for _, s := range []string{"", "2024", "2024Q5", "24Q1", "2024-Q1", "abcdQ1", "+024Q1"} {
+		_, _, err := ParsePeriod(s)
+		assert.Equal(t, ErrInvalidPeriod, err, s)
+	}
+}
@@ -0,0 +1,155 @@
+package screener
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Comparison operators
+const (
+	OpEqual        = "="
+	OpNotEqual     = "!="
+	OpLess         = "<"
+	OpLessEqual    = "<="
+	OpGreater      = ">"
+	OpGreaterEqual = ">="
+)
+
+// Expr is a node of the syntax tree of a filter expression. Match reports
+// whether a row passes the filter.
+//
+// Comparisons with a number the row does not have are false whatever the
+// operator, so pe < 20 and pe >= 20 both leave out companies without a P/E,
+// while NOT pe < 20 keeps them.
+type Expr interface {
+	Match(row *Row) bool
+	String() string
+	walk(visit func(Expr))
+}
+
+// Value is a literal of a comparison, Str for string fields and Num for
+// number fields
+type Value struct {
+	Str string
+	Num float64
+}
+
+// And matches rows both sides match
+type And struct {
+	Left, Right Expr
+}
+
+// Or matches rows either side matches
+type Or struct {
+	Left, Right Expr
+}
+
+// Not matches rows Expr does not match
+type Not struct {
+	Expr Expr
+}
+
+// Comparison compares a field with a value. Strings compare without regard
+// to case.
+type Comparison struct {
+	Field *Field
+	Op    string
+	Value Value
+}
+
+// In matches rows whose field equals one of Values
+type In struct {
+	Field  *Field
+	Values []Value
+}
+
+func (e *And) Match(row *Row) bool { return e.Left.Match(row) && e.Right.Match(row) }
+func (e *Or) Match(row *Row) bool  { return e.Left.Match(row) || e.Right.Match(row) }
+func (e *Not) Match(row *Row) bool { return !e.Expr.Match(row) }
+
+func (e *Comparison) Match(row *Row) bool {
+	if e.Field.Kind == KindString {
+		equal := strings.EqualFold(row.str(e.Field), e.Value.Str)
+		return equal == (e.Op == OpEqual)
+	}
+
+	v := row.num(e.Field)
+	if v == nil {
+		return false
+	}
+	switch e.Op {
+	case OpEqual:
+		return *v == e.Value.Num
+	case OpNotEqual:
+		return *v != e.Value.Num
+	case OpLess:
+		return *v < e.Value.Num
+	case OpLessEqual:
+		return *v <= e.Value.Num
+	case OpGreater:
+		return *v > e.Value.Num
+	default:
+		return *v >= e.Value.Num
+	}
+}
+
+func (e *In) Match(row *Row) bool {
+	for _, v := range e.Values {
+		if (&Comparison{Field: e.Field, Op: OpEqual, Value: v}).Match(row) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *And) String() string { return "(" + e.Left.String() + " AND " + e.Right.String() + ")" }
+func (e *Or) String() string  { return "(" + e.Left.String() + " OR " + e.Right.String() + ")" }
+func (e *Not) String() string { return "NOT " + e.Expr.String() }
+
+func (e *Comparison) String() string {
+	return e.Field.Name + " " + e.Op + " " + e.Field.format(e.Value)
+}
+
+func (e *In) String() string {
+	values := make([]string, len(e.Values))
+	for i, v := range e.Values {
+		values[i] = e.Field.format(v)
+	}
+	return e.Field.Name + " IN (" + strings.Join(values, ", ") + ")"
+}
+
+// format writes a value of the field as a literal
+func (f *Field) format(v Value) string {
+	if f.Kind == KindString {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v.Str) + `"`
+	}
+	return strconv.FormatFloat(v.Num, 'g', -1, 64)
+}
+
+func (e *And) walk(visit func(Expr))        { visit(e); e.Left.walk(visit); e.Right.walk(visit) }
+func (e *Or) walk(visit func(Expr))         { visit(e); e.Left.walk(visit); e.Right.walk(visit) }
+func (e *Not) walk(visit func(Expr))        { visit(e); e.Expr.walk(visit) }
+func (e *Comparison) walk(visit func(Expr)) { visit(e) }
+func (e *In) walk(visit func(Expr))         { visit(e) }
+
+// needsFundamentals reports whether expr compares any fundamental field
+func needsFundamentals(expr Expr) bool {
+	needed := false
+	expr.walk(func(e Expr) {
+		switch e := e.(type) {
+		case *Comparison:
+			needed = needed || e.Field.Fundamental
+		case *In:
+			needed = needed || e.Field.Fundamental
+		}
+	})
+	return needed
+}
+
+// conjuncts returns the expressions joined by the top-level ANDs of expr
+func conjuncts(expr Expr) []Expr {
+	if and, ok := expr.(*And); ok {
+		return append(conjuncts(and.Left), conjuncts(and.Right)...)
+	}
+	return []Expr{expr}
+}
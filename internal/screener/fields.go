@@ -0,0 +1,167 @@
+package screener
+
+import (
+	"github.com/api-moose/company-earnings/internal/metrics"
+	"github.com/api-moose/company-earnings/internal/models"
+)
+
+// Kind is the type of the values of a field
+type Kind int
+
+const (
+	KindString Kind = iota
+	KindNumber
+)
+
+func (k Kind) String() string {
+	if k == KindString {
+		return "string"
+	}
+	return "number"
+}
+
+// Field is a value of a company that can be filtered and sorted on. Company
+// fields are read from the company itself; fundamental fields are computed
+// from its financials and are nil when they cannot be.
+type Field struct {
+	Name        string
+	Kind        Kind
+	Fundamental bool
+	str         func(c *models.Company) string
+	num         func(c *models.Company) *float64
+}
+
+// Fundamental fields, all of the latest fiscal quarter. Growth rates and
+// margins are fractions, so 0.1 is 10%.
+const (
+	FieldRevenueTTM         = "revenue_ttm"
+	FieldNetIncomeTTM       = "net_income_ttm"
+	FieldEPSTTM             = "eps_ttm"
+	FieldRevenueGrowthQoQ   = "revenue_growth_qoq"
+	FieldRevenueGrowthYoY   = "revenue_growth_yoy"
+	FieldNetIncomeGrowthYoY = "net_income_growth_yoy"
+	FieldEPSGrowthYoY       = "eps_growth_yoy"
+	FieldGrossMargin        = "gross_margin"
+	FieldOperatingMargin    = "operating_margin"
+	FieldNetMargin          = "net_margin"
+	FieldROE                = "roe"
+	FieldROA                = "roa"
+	FieldCurrentRatio       = "current_ratio"
+	FieldDebtToEquity       = "debt_to_equity"
+	FieldPE                 = "pe"
+	FieldEVToEBITDA         = "ev_to_ebitda"
+)
+
+// fields are the fields of a screen by name
+var fields = func() map[string]*Field {
+	byName := make(map[string]*Field)
+	for _, f := range []*Field{
+		{Name: "symbol", Kind: KindString, str: func(c *models.Company) string { return c.Symbol }},
+		{Name: "name", Kind: KindString, str: func(c *models.Company) string { return c.SecurityName }},
+		{Name: "security_type", Kind: KindString, str: func(c *models.Company) string { return c.SecurityType }},
+		{Name: "exchange", Kind: KindString, str: func(c *models.Company) string { return c.Exchange }},
+		{Name: "region", Kind: KindString, str: func(c *models.Company) string { return c.Region }},
+		{Name: "sector", Kind: KindString, str: func(c *models.Company) string { return c.Sector }},
+		{Name: "price", Kind: KindNumber, num: func(c *models.Company) *float64 { return c.Price }},
+	} {
+		byName[f.Name] = f
+	}
+	for _, name := range []string{
+		FieldRevenueTTM, FieldNetIncomeTTM, FieldEPSTTM,
+		FieldRevenueGrowthQoQ, FieldRevenueGrowthYoY, FieldNetIncomeGrowthYoY, FieldEPSGrowthYoY,
+		FieldGrossMargin, FieldOperatingMargin, FieldNetMargin, FieldROE, FieldROA,
+		FieldCurrentRatio, FieldDebtToEquity, FieldPE, FieldEVToEBITDA,
+	} {
+		byName[name] = &Field{Name: name, Kind: KindNumber, Fundamental: true}
+	}
+	return byName
+}()
+
+// LookupField returns the field called name
+func LookupField(name string) (*Field, bool) {
+	f, ok := fields[name]
+	return f, ok
+}
+
+// Row is a company with the fundamental fields of a screen
+type Row struct {
+	Company      models.Company
+	Fundamentals map[string]*float64
+}
+
+// str returns the value of a string field of the row
+func (r *Row) str(f *Field) string {
+	return f.str(&r.Company)
+}
+
+// num returns the value of a number field of the row, nil when it is unknown
+func (r *Row) num(f *Field) *float64 {
+	if f.Fundamental {
+		return r.Fundamentals[f.Name]
+	}
+	return f.num(&r.Company)
+}
+
+// Value returns the value of field for the row, a string or a *float64 that
+// is nil when the value is unknown
+func (r *Row) Value(f *Field) interface{} {
+	if f.Kind == KindString {
+		return r.str(f)
+	}
+	return r.num(f)
+}
+
+// Fields returns the fields expr uses followed by extra, each once, in the
+// order they first appear
+func Fields(expr Expr, extra ...*Field) []*Field {
+	var used []*Field
+	seen := make(map[*Field]bool)
+	add := func(f *Field) {
+		if !seen[f] {
+			seen[f] = true
+			used = append(used, f)
+		}
+	}
+	expr.walk(func(e Expr) {
+		switch e := e.(type) {
+		case *Comparison:
+			add(e.Field)
+		case *In:
+			add(e.Field)
+		}
+	})
+	for _, f := range extra {
+		add(f)
+	}
+	return used
+}
+
+// Fundamentals computes the fundamental fields of the latest fiscal quarter
+// of financials, valuing the company at price, which may be nil
+func Fundamentals(financials []models.Financials, price *float64) map[string]*float64 {
+	values := make(map[string]*float64)
+	if quarters := metrics.Compute(financials); len(quarters) > 0 {
+		m := quarters[0].Metrics
+		revenue, netIncome, eps := m[models.LineItemRevenue], m[models.LineItemNetIncome], m[models.LineItemEPSDiluted]
+		values[FieldRevenueTTM] = revenue.TTM
+		values[FieldNetIncomeTTM] = netIncome.TTM
+		values[FieldEPSTTM] = eps.TTM
+		values[FieldRevenueGrowthQoQ] = revenue.QoQ
+		values[FieldRevenueGrowthYoY] = revenue.YoY
+		values[FieldNetIncomeGrowthYoY] = netIncome.YoY
+		values[FieldEPSGrowthYoY] = eps.YoY
+	}
+	if periods := metrics.ComputeRatios(financials, false, price); len(periods) > 0 {
+		r := periods[0].Ratios
+		values[FieldGrossMargin] = r.GrossMargin
+		values[FieldOperatingMargin] = r.OperatingMargin
+		values[FieldNetMargin] = r.NetMargin
+		values[FieldROE] = r.ROE
+		values[FieldROA] = r.ROA
+		values[FieldCurrentRatio] = r.CurrentRatio
+		values[FieldDebtToEquity] = r.DebtToEquity
+		values[FieldPE] = r.PE
+		values[FieldEVToEBITDA] = r.EVToEBITDA
+	}
+	return values
+}
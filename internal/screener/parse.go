@@ -0,0 +1,324 @@
+package screener
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Error reports an invalid filter expression and the byte offset it was
+// found at
+type Error struct {
+	Pos     int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Message, e.Pos+1)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// describe names the token in error messages
+func (t token) describe() string {
+	if t.kind == tokenEOF {
+		return "end of filter"
+	}
+	return strconv.Quote(t.text)
+}
+
+// operators are the comparison operators, longest first so that <= is not
+// read as <
+var operators = []string{"<=", ">=", "!=", "<>", "==", "<", ">", "="}
+
+// lex splits a filter expression into tokens
+func lex(input string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(input); {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokenLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokenRParen, ")", i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{tokenComma, ",", i})
+			i++
+		case c == '"':
+			s, n, err := lexString(input[i:])
+			if err != nil {
+				return nil, &Error{Pos: i, Message: err.Error()}
+			}
+			tokens = append(tokens, token{tokenString, s, i})
+			i += n
+		case isLetter(input[i]):
+			j := i
+			for j < len(input) && (isLetter(input[j]) || isDigit(input[j])) {
+				j++
+			}
+			tokens = append(tokens, token{tokenIdent, input[i:j], i})
+			i = j
+		case c == '-' || c == '.' || isDigit(input[i]):
+			j := i + 1
+			for j < len(input) && strings.ContainsRune("0123456789.eE", rune(input[j])) {
+				// an exponent may be signed
+				if (input[j] == 'e' || input[j] == 'E') && j+1 < len(input) && (input[j+1] == '-' || input[j+1] == '+') {
+					j++
+				}
+				j++
+			}
+			if _, err := strconv.ParseFloat(input[i:j], 64); err != nil {
+				return nil, &Error{Pos: i, Message: fmt.Sprintf("invalid number %q", input[i:j])}
+			}
+			tokens = append(tokens, token{tokenNumber, input[i:j], i})
+			i = j
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(input[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				r, _ := utf8.DecodeRuneInString(input[i:])
+				return nil, &Error{Pos: i, Message: fmt.Sprintf("unexpected character %q", r)}
+			}
+			tokens = append(tokens, token{tokenOperator, op, i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{tokenEOF, "", len(input)}), nil
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// lexString reads a double-quoted string at the start of input, in which \"
+// and \\ escape a quote and a backslash, returning the string and the length
+// of its quoted form
+func lexString(input string) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(input); i++ {
+		switch input[i] {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\\':
+			if i+1 < len(input) && (input[i+1] == '"' || input[i+1] == '\\') {
+				i++
+			}
+		}
+		b.WriteByte(input[i])
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+// Parse parses a filter expression into its syntax tree. Comparisons are
+// joined with AND, OR and NOT, in order of increasing precedence OR, AND,
+// NOT, and grouped with parentheses:
+//
+//	sector = "Technology" AND (pe < 20 OR revenue_growth_yoy > 0.1)
+//	exchange IN ("NASDAQ", "NYSE") AND NOT debt_to_equity > 2
+//
+// Keywords are case-insensitive. String fields support =, != and IN, and
+// number fields every comparison and IN. Every field must be known and every
+// value of the type of its field.
+func Parse(input string) (Expr, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, &Error{Pos: t.pos, Message: fmt.Sprintf("unexpected %s", t.describe())}
+	}
+	return expr, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// keyword consumes the next token when it is the keyword kw
+func (p *parser) keyword(kw string) bool {
+	if t := p.peek(); t.kind == tokenIdent && strings.EqualFold(t.text, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// expect consumes the next token, which must be of kind
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		return t, &Error{Pos: t.pos, Message: fmt.Sprintf("expected %s, found %s", what, t.describe())}
+	}
+	return t, nil
+}
+
+func (p *parser) or() (Expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &Or{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (Expr, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = &And{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) not() (Expr, error) {
+	if p.keyword("NOT") {
+		expr, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Expr: expr}, nil
+	}
+	if p.peek().kind == tokenLParen {
+		p.next()
+		expr, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRParen, `")"`); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (Expr, error) {
+	name, err := p.expect(tokenIdent, "a field")
+	if err != nil {
+		return nil, err
+	}
+	field, ok := LookupField(strings.ToLower(name.text))
+	if !ok {
+		return nil, &Error{Pos: name.pos, Message: fmt.Sprintf("unknown field %q", name.text)}
+	}
+
+	if p.keyword("IN") {
+		if _, err := p.expect(tokenLParen, `"("`); err != nil {
+			return nil, err
+		}
+		in := &In{Field: field}
+		for {
+			v, err := p.value(field)
+			if err != nil {
+				return nil, err
+			}
+			in.Values = append(in.Values, v)
+			if p.peek().kind != tokenComma {
+				break
+			}
+			p.next()
+		}
+		if _, err := p.expect(tokenRParen, `")"`); err != nil {
+			return nil, err
+		}
+		return in, nil
+	}
+
+	op, err := p.expect(tokenOperator, "a comparison operator")
+	if err != nil {
+		return nil, err
+	}
+	cmp := &Comparison{Field: field, Op: normalizeOperator(op.text)}
+	if field.Kind == KindString && cmp.Op != OpEqual && cmp.Op != OpNotEqual {
+		return nil, &Error{Pos: op.pos, Message: fmt.Sprintf("%s is a string field and only supports =, != and IN", field.Name)}
+	}
+	if cmp.Value, err = p.value(field); err != nil {
+		return nil, err
+	}
+	return cmp, nil
+}
+
+// value reads a literal of the type of field
+func (p *parser) value(field *Field) (Value, error) {
+	t := p.next()
+	switch {
+	case field.Kind == KindString && t.kind == tokenString:
+		return Value{Str: t.text}, nil
+	case field.Kind == KindNumber && t.kind == tokenNumber:
+		n, _ := strconv.ParseFloat(t.text, 64)
+		return Value{Num: n}, nil
+	}
+	return Value{}, &Error{Pos: t.pos, Message: fmt.Sprintf("%s is a %s field, found %s", field.Name, field.Kind, t.describe())}
+}
+
+// normalizeOperator maps the alternative spellings of operators to one
+func normalizeOperator(op string) string {
+	switch op {
+	case "==":
+		return OpEqual
+	case "<>":
+		return OpNotEqual
+	}
+	return op
+}
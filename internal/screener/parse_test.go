@@ -0,0 +1,99 @@
+package screener
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"Comparison", `pe < 20`, `pe < 20`},
+		{"AND binds tighter than OR", `pe < 20 OR roe > 0.1 AND sector = "Energy"`, `(pe < 20 OR (roe > 0.1 AND sector = "Energy"))`},
+		{"Parentheses", `(pe < 20 OR roe > 0.1) AND sector = "Energy"`, `((pe < 20 OR roe > 0.1) AND sector = "Energy")`},
+		{"NOT binds tightest", `NOT pe < 20 AND roe > 0`, `(NOT pe < 20 AND roe > 0)`},
+		{"Keywords and fields ignore case", `Sector = "Technology" and PE <= 2e1`, `(sector = "Technology" AND pe <= 20)`},
+		{"Alternative operators", `pe == -1.5 OR sector <> "Energy"`, `(pe = -1.5 OR sector != "Energy")`},
+		{"IN", `exchange IN ("NASDAQ", "NYSE")`, `exchange IN ("NASDAQ", "NYSE")`},
+		{"Escapes", `name = "Say \"hi\" \\ bye"`, `name = "Say \"hi\" \\ bye"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := Parse(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, expr.String())
+
+			again, err := Parse(expr.String())
+			require.NoError(t, err, "String can be parsed back")
+			assert.Equal(t, expr, again)
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"Empty", ``, `expected a field, found end of filter at position 1`},
+		{"Unknown field", `pe < 20 AND moat > 1`, `unknown field "moat" at position 13`},
+		{"Missing operator", `pe 20`, `expected a comparison operator, found "20" at position 4`},
+		{"String ordering", `sector < "M"`, `sector is a string field and only supports =, != and IN at position 8`},
+		{"String for number", `pe < "20"`, `pe is a number field, found "20" at position 6`},
+		{"Number for string", `sector = 1`, `sector is a string field, found "1" at position 10`},
+		{"Unbalanced parenthesis", `(pe < 20`, `expected ")", found end of filter at position 9`},
+		{"Trailing tokens", `pe < 20 roe > 1`, `unexpected "roe" at position 9`},
+		{"Unterminated string", `sector = "Energy`, `unterminated string at position 10`},
+		{"Invalid number", `pe < 1.2.3`, `invalid number "1.2.3" at position 6`},
+		{"Unexpected character", `pe < 20 & roe > 1`, `unexpected character '&' at position 9`},
+		{"Empty IN", `exchange IN ()`, `exchange is a string field, found ")" at position 14`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			var parseErr *Error
+			require.ErrorAs(t, err, &parseErr)
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
+
+func TestExpr_Match(t *testing.T) {
+	pe, growth := 15.0, 0.2
+	row := &Row{
+		Company:      apple,
+		Fundamentals: map[string]*float64{FieldPE: &pe, FieldRevenueGrowthYoY: &growth},
+	}
+
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{`sector = "technology" AND pe < 20 AND revenue_growth_yoy > 0.1`, true},
+		{`sector != "Technology"`, false},
+		{`exchange IN ("NYSE", "nasdaq")`, true},
+		{`pe IN (10, 15)`, true},
+		{`pe >= 20 OR revenue_growth_yoy = 0.2`, true},
+		{`NOT pe < 20`, false},
+		{`roe > 0 OR roe <= 0`, false},
+		{`NOT roe > 0`, true},
+		{`price > 1000`, false},
+		{`price < 1000`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			expr, err := Parse(tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, expr.Match(row))
+		})
+	}
+}
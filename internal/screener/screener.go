@@ -0,0 +1,243 @@
+package screener
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/models"
+)
+
+// lookbackYears is how many fiscal years of financials are loaded for each
+// company: the latest, partly reported year and the three before it, enough
+// for the trailing twelve months a year back
+const lookbackYears = 4
+
+// Companies is the part of mongo.Repository the screener uses
+type Companies interface {
+	List(ctx context.Context, filter mongo.ListFilter) ([]models.Company, error)
+}
+
+// Statements is the part of mongo.StatementsRepository the screener uses
+type Statements interface {
+	ListByCIKs(ctx context.Context, ciks []string, filter mongo.FinancialsFilter) (map[string][]models.Financials, error)
+}
+
+// Splits is the part of mongo.CorporateActionsRepository the screener uses
+type Splits interface {
+	ListSplitsBySymbols(ctx context.Context, symbols []string) (map[string][]models.Split, error)
+}
+
+// Screener runs screens over the active companies
+type Screener struct {
+	companies  Companies
+	statements Statements
+	splits     Splits
+	// now dates the lookback of the financials loaded
+	now func() time.Time
+}
+
+func New(companies Companies, statements Statements, splits Splits) *Screener {
+	return &Screener{companies: companies, statements: statements, splits: splits, now: time.Now}
+}
+
+// Query is a screen: the companies matching Filter, ordered by Sort, with the
+// values of Fields. A nil Filter matches every company and a nil Sort orders
+// by symbol. Fundamentals are in shares after the splits of each listing
+// unless AsFiled is set.
+type Query struct {
+	Filter     Expr
+	Sort       *Field
+	Descending bool
+	Fields     []*Field
+	AsFiled    bool
+}
+
+// Run returns the rows of the active companies matching the query in order.
+// Companies without a value of the sort field come last whatever the order.
+func (s *Screener) Run(ctx context.Context, q Query) ([]Row, error) {
+	var filter mongo.ListFilter
+	if q.Filter != nil {
+		filter = listFilter(q.Filter)
+	}
+	companies, err := s.companies.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]Row, len(companies))
+	for i, c := range companies {
+		rows[i] = Row{Company: c}
+	}
+	if q.needsFundamentals() {
+		if err := s.loadFundamentals(ctx, rows, !q.AsFiled); err != nil {
+			return nil, err
+		}
+	}
+
+	matched := rows[:0]
+	for i := range rows {
+		if q.Filter == nil || q.Filter.Match(&rows[i]) {
+			matched = append(matched, rows[i])
+		}
+	}
+	if q.Sort != nil {
+		sortRows(matched, q.Sort, q.Descending)
+	}
+	return matched, nil
+}
+
+// needsFundamentals reports whether the query filters, sorts or returns any
+// fundamental field
+func (q Query) needsFundamentals() bool {
+	if q.Filter != nil && needsFundamentals(q.Filter) {
+		return true
+	}
+	if q.Sort != nil && q.Sort.Fundamental {
+		return true
+	}
+	for _, f := range q.Fields {
+		if f.Fundamental {
+			return true
+		}
+	}
+	return false
+}
+
+// listFilter narrows the companies listed to the exchanges and sectors the
+// top-level ANDs of expr require. The whole filter is matched afterwards, so
+// this only saves loading companies that cannot match.
+func listFilter(expr Expr) mongo.ListFilter {
+	var filter mongo.ListFilter
+	for _, e := range conjuncts(expr) {
+		var field string
+		var values []string
+		switch e := e.(type) {
+		case *Comparison:
+			if e.Op != OpEqual {
+				continue
+			}
+			field, values = e.Field.Name, []string{e.Value.Str}
+		case *In:
+			field = e.Field.Name
+			for _, v := range e.Values {
+				values = append(values, v.Str)
+			}
+		default:
+			continue
+		}
+		// a second condition on the same field is left to Match, as the
+		// filter would take either
+		switch {
+		case field == "exchange" && filter.Exchanges == nil:
+			filter.Exchanges = values
+		case field == "sector" && filter.Sectors == nil:
+			filter.Sectors = values
+		}
+	}
+	return filter
+}
+
+// loadFundamentals computes the fundamentals of every row from the
+// financials of all registrants, loaded in a single query and shared by the
+// share classes of each. Periods ending more than a year before the lookback
+// are not loaded, so registrants that stopped filing have no fundamentals.
+// When splitAdjusted, per-share values are adjusted for the splits of each
+// listing, also loaded in a single query, so they compare across splits and
+// with the price.
+func (s *Screener) loadFundamentals(ctx context.Context, rows []Row, splitAdjusted bool) error {
+	seen := make(map[string]bool)
+	var ciks, symbols []string
+	for _, row := range rows {
+		cik := row.Company.CIK
+		if cik == "" {
+			continue
+		}
+		symbols = append(symbols, row.Company.Symbol)
+		if !seen[cik] {
+			seen[cik] = true
+			ciks = append(ciks, cik)
+		}
+	}
+
+	since := s.now().AddDate(-lookbackYears-1, 0, 0)
+	byCIK, err := s.statements.ListByCIKs(ctx, ciks, mongo.FinancialsFilter{
+		Limit: lookbackYears * len(models.FiscalPeriods),
+		Since: &since,
+	})
+	if err != nil {
+		return err
+	}
+	var bySymbol map[string][]models.Split
+	if splitAdjusted {
+		if bySymbol, err = s.splits.ListSplitsBySymbols(ctx, symbols); err != nil {
+			return err
+		}
+	}
+	for i := range rows {
+		rows[i].Fundamentals = map[string]*float64{}
+		financials := byCIK[rows[i].Company.CIK]
+		if len(financials) == 0 {
+			continue
+		}
+		if splits := bySymbol[rows[i].Company.Symbol]; len(splits) > 0 {
+			// the share classes of a registrant split separately
+			adjusted := make([]models.Financials, len(financials))
+			for j := range financials {
+				adjusted[j] = financials[j]
+				adjusted[j].AdjustForSplits(splits)
+			}
+			financials = adjusted
+		}
+		rows[i].Fundamentals = Fundamentals(financials, rows[i].Company.Price)
+	}
+	return nil
+}
+
+// sortRows orders rows by field, leaving rows without a value last and
+// breaking ties by symbol
+func sortRows(rows []Row, field *Field, descending bool) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := &rows[i], &rows[j]
+		if c := compare(a, b, field); c != 0 {
+			if c == missingFirst || c == missingSecond {
+				return c == missingSecond
+			}
+			if descending {
+				return c > 0
+			}
+			return c < 0
+		}
+		return a.Company.Symbol < b.Company.Symbol
+	})
+}
+
+// results of compare when only one row has a value of the field
+const (
+	missingFirst  = -2
+	missingSecond = 2
+)
+
+// compare orders two rows by field, returning -1, 0 or 1, or missingFirst or
+// missingSecond when only one of them has a value
+func compare(a, b *Row, field *Field) int {
+	if field.Kind == KindString {
+		return strings.Compare(strings.ToLower(a.str(field)), strings.ToLower(b.str(field)))
+	}
+	x, y := a.num(field), b.num(field)
+	switch {
+	case x == nil && y == nil:
+		return 0
+	case x == nil:
+		return missingFirst
+	case y == nil:
+		return missingSecond
+	case *x < *y:
+		return -1
+	case *x > *y:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,240 @@
+package screener
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/api-moose/company-earnings/internal/db/mongo"
+	"github.com/api-moose/company-earnings/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockCompanies struct {
+	mock.Mock
+}
+
+func (m *MockCompanies) List(ctx context.Context, filter mongo.ListFilter) ([]models.Company, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.Company), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+type MockStatements struct {
+	mock.Mock
+}
+
+func (m *MockStatements) ListByCIKs(ctx context.Context, ciks []string, filter mongo.FinancialsFilter) (map[string][]models.Financials, error) {
+	args := m.Called(ctx, ciks, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).(map[string][]models.Financials), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+type MockSplits struct {
+	mock.Mock
+}
+
+func (m *MockSplits) ListSplitsBySymbols(ctx context.Context, symbols []string) (map[string][]models.Split, error) {
+	args := m.Called(ctx, symbols)
+	if args.Get(0) != nil {
+		return args.Get(0).(map[string][]models.Split), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func price(p float64) *float64 { return &p }
+
+var (
+	apple = models.Company{Symbol: "AAPL", CIK: "0000320193", SecurityName: "Apple Inc.", Exchange: "NASDAQ", Sector: "Technology", Active: true, Price: price(190)}
+	msft  = models.Company{Symbol: "MSFT", CIK: "0000789019", SecurityName: "Microsoft Corp", Exchange: "NASDAQ", Sector: "Technology", Active: true, Price: price(400)}
+	goog  = models.Company{Symbol: "GOOG", CIK: "0001652044", SecurityName: "Alphabet Inc.", Exchange: "NASDAQ", Sector: "Technology", Active: true}
+	googl = models.Company{Symbol: "GOOGL", CIK: "0001652044", SecurityName: "Alphabet Inc.", Exchange: "NASDAQ", Sector: "Technology", Active: true}
+)
+
+// quarters returns first quarters of two years with the given revenue
+func quarters(cik string, before, after float64) []models.Financials {
+	q := func(year int, revenue float64) models.Financials {
+		start := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+		return models.Financials{
+			CIK: cik, FiscalYear: year, FiscalPeriod: "Q1",
+			PeriodStart: &start, PeriodEnd: start.AddDate(0, 3, -1), Currency: "USD",
+			Items: map[string]models.LineItem{models.LineItemRevenue: {Value: revenue}},
+		}
+	}
+	return []models.Financials{q(2024, after), q(2023, before)}
+}
+
+var (
+	now              = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	since            = time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	statementsFilter = mongo.FinancialsFilter{Limit: lookbackYears * len(models.FiscalPeriods), Since: &since}
+)
+
+// newScreener returns a Screener loading financials as of now
+func newScreener(companies Companies, statements Statements, splits Splits) *Screener {
+	s := New(companies, statements, splits)
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func TestScreener_Run(t *testing.T) {
+	companies := new(MockCompanies)
+	companies.On("List", mock.Anything, mongo.ListFilter{Sectors: []string{"Technology"}}).Return([]models.Company{apple, goog, googl, msft}, nil)
+	statements := new(MockStatements)
+	statements.On("ListByCIKs", mock.Anything, []string{apple.CIK, goog.CIK, msft.CIK}, statementsFilter).Return(map[string][]models.Financials{
+		apple.CIK: quarters(apple.CIK, 100, 105),
+		msft.CIK:  quarters(msft.CIK, 100, 120),
+		goog.CIK:  quarters(goog.CIK, 100, 150),
+	}, nil).Once()
+	splits := new(MockSplits)
+	splits.On("ListSplitsBySymbols", mock.Anything, []string{"AAPL", "GOOG", "GOOGL", "MSFT"}).Return(map[string][]models.Split{}, nil).Once()
+
+	filter, err := Parse(`sector = "Technology" AND revenue_growth_yoy > 0.1`)
+	require.NoError(t, err)
+	growth, _ := LookupField(FieldRevenueGrowthYoY)
+
+	rows, err := newScreener(companies, statements, splits).Run(context.Background(), Query{Filter: filter, Sort: growth, Descending: true})
+	require.NoError(t, err)
+	var symbols []string
+	for _, row := range rows {
+		symbols = append(symbols, row.Company.Symbol)
+	}
+	assert.Equal(t, []string{"GOOG", "GOOGL", "MSFT"}, symbols, "share classes are loaded once and tie by symbol")
+	assert.InDelta(t, 0.5, *rows[0].Fundamentals[FieldRevenueGrowthYoY], 1e-9)
+
+	companies.AssertExpectations(t)
+	statements.AssertExpectations(t)
+	splits.AssertExpectations(t)
+}
+
+func TestScreener_Run_SplitAdjusted(t *testing.T) {
+	// Alphabet's first quarter of 2023 was filed before a split of GOOGL only
+	q := func(year int, eps float64) models.Financials {
+		start := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+		filed := start.AddDate(0, 4, 0)
+		return models.Financials{
+			CIK: goog.CIK, FiscalYear: year, FiscalPeriod: "Q1",
+			PeriodStart: &start, PeriodEnd: start.AddDate(0, 3, -1), Currency: "USD", Filed: filed,
+			Items: map[string]models.LineItem{models.LineItemEPSDiluted: {Value: eps, Filed: filed}},
+		}
+	}
+	companies := new(MockCompanies)
+	companies.On("List", mock.Anything, mongo.ListFilter{}).Return([]models.Company{goog, googl}, nil)
+	statements := new(MockStatements)
+	statements.On("ListByCIKs", mock.Anything, []string{goog.CIK}, statementsFilter).Return(map[string][]models.Financials{
+		goog.CIK: {q(2024, 2.2), q(2023, 4)},
+	}, nil)
+	splits := new(MockSplits)
+	splits.On("ListSplitsBySymbols", mock.Anything, []string{"GOOG", "GOOGL"}).Return(map[string][]models.Split{
+		"GOOGL": {{Symbol: "GOOGL", Date: time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC), Numerator: 2, Denominator: 1}},
+	}, nil)
+
+	growth, _ := LookupField(FieldEPSGrowthYoY)
+	rows, err := newScreener(companies, statements, splits).Run(context.Background(), Query{Sort: growth})
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "GOOG", rows[0].Company.Symbol)
+	assert.InDelta(t, -0.45, *rows[0].Fundamentals[FieldEPSGrowthYoY], 1e-9, "the shared financials are left as filed")
+	assert.Equal(t, "GOOGL", rows[1].Company.Symbol)
+	assert.InDelta(t, 0.1, *rows[1].Fundamentals[FieldEPSGrowthYoY], 1e-9)
+
+	statements.AssertExpectations(t)
+	splits.AssertExpectations(t)
+}
+
+func TestScreener_Run_AsFiled(t *testing.T) {
+	q := func(year int, eps float64) models.Financials {
+		start := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+		filed := start.AddDate(0, 4, 0)
+		return models.Financials{
+			CIK: goog.CIK, FiscalYear: year, FiscalPeriod: "Q1",
+			PeriodStart: &start, PeriodEnd: start.AddDate(0, 3, -1), Currency: "USD", Filed: filed,
+			Items: map[string]models.LineItem{models.LineItemEPSDiluted: {Value: eps, Filed: filed}},
+		}
+	}
+	companies := new(MockCompanies)
+	companies.On("List", mock.Anything, mongo.ListFilter{}).Return([]models.Company{googl}, nil)
+	statements := new(MockStatements)
+	statements.On("ListByCIKs", mock.Anything, []string{goog.CIK}, statementsFilter).Return(map[string][]models.Financials{
+		goog.CIK: {q(2024, 2.2), q(2023, 4)},
+	}, nil)
+	splits := new(MockSplits)
+
+	growth, _ := LookupField(FieldEPSGrowthYoY)
+	rows, err := newScreener(companies, statements, splits).Run(context.Background(), Query{Sort: growth, AsFiled: true})
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.InDelta(t, -0.45, *rows[0].Fundamentals[FieldEPSGrowthYoY], 1e-9)
+
+	splits.AssertNotCalled(t, "ListSplitsBySymbols", mock.Anything, mock.Anything)
+}
+
+func TestScreener_Run_CompanyFieldsOnly(t *testing.T) {
+	companies := new(MockCompanies)
+	companies.On("List", mock.Anything, mongo.ListFilter{Exchanges: []string{"NASDAQ", "NYSE"}}).Return([]models.Company{msft, goog, apple}, nil)
+	statements := new(MockStatements)
+
+	filter, err := Parse(`exchange IN ("NASDAQ", "NYSE") AND NOT symbol = "GOOG"`)
+	require.NoError(t, err)
+	byPrice, _ := LookupField("price")
+
+	splits := new(MockSplits)
+	rows, err := newScreener(companies, statements, splits).Run(context.Background(), Query{Filter: filter, Sort: byPrice})
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "AAPL", rows[0].Company.Symbol)
+	assert.Equal(t, "MSFT", rows[1].Company.Symbol)
+
+	statements.AssertNotCalled(t, "ListByCIKs", mock.Anything, mock.Anything, mock.Anything)
+	splits.AssertNotCalled(t, "ListSplitsBySymbols", mock.Anything, mock.Anything)
+}
+
+func TestScreener_Run_Errors(t *testing.T) {
+	companies := new(MockCompanies)
+	companies.On("List", mock.Anything, mongo.ListFilter{}).Return([]models.Company{apple}, nil)
+	statements := new(MockStatements)
+	statements.On("ListByCIKs", mock.Anything, []string{apple.CIK}, statementsFilter).Return(nil, errors.New("socket closed")).Once()
+	statements.On("ListByCIKs", mock.Anything, []string{apple.CIK}, statementsFilter).Return(map[string][]models.Financials{}, nil).Once()
+	splits := new(MockSplits)
+	splits.On("ListSplitsBySymbols", mock.Anything, []string{"AAPL"}).Return(nil, errors.New("cursor killed"))
+
+	filter, err := Parse(`pe < 20`)
+	require.NoError(t, err)
+	_, err = newScreener(companies, statements, splits).Run(context.Background(), Query{Filter: filter})
+	assert.EqualError(t, err, "socket closed")
+	_, err = newScreener(companies, statements, splits).Run(context.Background(), Query{Filter: filter})
+	assert.EqualError(t, err, "cursor killed")
+
+	failing := new(MockCompanies)
+	failing.On("List", mock.Anything, mongo.ListFilter{}).Return(nil, errors.New("socket closed"))
+	_, err = newScreener(failing, statements, splits).Run(context.Background(), Query{})
+	assert.EqualError(t, err, "socket closed")
+}
+
+func TestListFilter(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   mongo.ListFilter
+	}{
+		{`pe < 20`, mongo.ListFilter{}},
+		{`sector = "Energy" AND exchange IN ("NYSE", "NASDAQ")`, mongo.ListFilter{Exchanges: []string{"NYSE", "NASDAQ"}, Sectors: []string{"Energy"}}},
+		{`sector = "Energy" OR exchange = "NYSE"`, mongo.ListFilter{}},
+		{`sector != "Energy" AND NOT exchange = "NYSE"`, mongo.ListFilter{}},
+		{`sector = "Energy" AND sector = "Utilities"`, mongo.ListFilter{Sectors: []string{"Energy"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			expr, err := Parse(tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, listFilter(expr))
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package pagination
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type position struct {
+	Rank   int    `json:"r"`
+	Symbol string `json:"s"`
+}
+
+func TestCursorCodec_RoundTrip(t *testing.T) {
+	codec := NewCursorCodec("secret")
+
+	token, err := codec.Encode(position{Rank: 1, Symbol: "AAPL"})
+	assert.NoError(t, err)
+
+	var got position
+	assert.NoError(t, codec.Decode(token, &got))
+	assert.Equal(t, position{Rank: 1, Symbol: "AAPL"}, got)
+}
+
+func TestCursorCodec_RejectsTampering(t *testing.T) {
+	codec := NewCursorCodec("secret")
+	token, err := codec.Encode(position{Rank: 1, Symbol: "AAPL"})
+	assert.NoError(t, err)
+
+	forged, err := NewCursorCodec("other").Encode(position{Rank: 1, Symbol: "MSFT"})
+	assert.NoError(t, err)
+	payload, sig, _ := strings.Cut(token, ".")
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"Empty", ""},
+		{"Missing signature", payload},
+		{"Signed with another key", forged},
+		{"Swapped payload", forgedPayload + "." + sig},
+		{"Not base64", "!!!." + sig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got position
+			assert.ErrorIs(t, codec.Decode(tt.token, &got), ErrInvalidCursor)
+		})
+	}
+}
+
+func TestNewRandomCursorCodec(t *testing.T) {
+	a, err := NewRandomCursorCodec()
+	assert.NoError(t, err)
+	b, err := NewRandomCursorCodec()
+	assert.NoError(t, err)
+
+	token, err := a.Encode(position{Symbol: "AAPL"})
+	assert.NoError(t, err)
+
+	var got position
+	assert.ErrorIs(t, b.Decode(token, &got), ErrInvalidCursor)
+}
@@ -9,6 +9,8 @@ import (
 type ErrorMessage struct {
 	Status  int    `json:"status"`
 	Message string `json:"error"`
+	// Parameter names the request parameter that caused the error, if any
+	Parameter string `json:"parameter,omitempty"`
 }
 
 // Implement the error interface for ErrorMessage
@@ -78,3 +78,23 @@ func TestErrorResponseWithRegularError(t *testing.T) {
 		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, response.Status)
 	}
 }
+
+func TestErrorResponseWithParameter(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := &ErrorMessage{Status: http.StatusBadRequest, Message: "limit must be an integer between 1 and 100", Parameter: "limit"}
+
+	ErrorResponse(w, err)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	var response map[string]interface{}
+	if jsonErr := json.Unmarshal(w.Body.Bytes(), &response); jsonErr != nil {
+		t.Fatalf("Error unmarshaling response: %v", jsonErr)
+	}
+
+	if response["parameter"] != "limit" {
+		t.Errorf("Expected parameter 'limit', got '%v'", response["parameter"])
+	}
+}